@@ -6,6 +6,8 @@ import (
 	"context"
 	"flag"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/go-kit/log/level"
 	"github.com/prometheus/client_golang/prometheus"
@@ -20,15 +22,19 @@ import (
 
 type Config struct {
 	ServerMetricsPort   int
+	PrintConfig         bool
 	LogLevel            logging.Level
 	Client              continuoustest.ClientConfig
+	Manager             continuoustest.ManagerConfig
 	WriteReadSeriesTest continuoustest.WriteReadSeriesTestConfig
 }
 
 func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	f.IntVar(&cfg.ServerMetricsPort, "server.metrics-port", 9900, "The port where metrics are exposed.")
+	f.BoolVar(&cfg.PrintConfig, "tests.print-config", false, "True to print the effective client config as YAML to stdout at startup, with secrets redacted.")
 	cfg.LogLevel.RegisterFlags(f)
 	cfg.Client.RegisterFlags(f)
+	cfg.Manager.RegisterFlags(f)
 	cfg.WriteReadSeriesTest.RegisterFlags(f)
 }
 
@@ -43,28 +49,55 @@ func main() {
 	})
 	logger := util_log.Logger
 
-	// Run the instrumentation server.
+	if cfg.PrintConfig {
+		if err := continuoustest.PrintClientConfig(os.Stdout, cfg.Client); err != nil {
+			level.Error(logger).Log("msg", "Failed to print the client config", "err", err.Error())
+			os.Exit(1)
+		}
+	}
+
 	registry := prometheus.NewRegistry()
 	registry.MustRegister(collectors.NewGoCollector())
 
+	m := continuoustest.NewManager(cfg.Manager)
+
+	// Run the instrumentation server.
 	i := instrumentation.NewMetricsServer(cfg.ServerMetricsPort, registry)
+	i.Handle("/live", m.LivenessHandler())
 	if err := i.Start(); err != nil {
 		level.Error(logger).Log("msg", "Unable to start instrumentation server", "err", err.Error())
 		os.Exit(1)
 	}
 
 	// Init the client used to write/read to/from Mimir.
-	client, err := continuoustest.NewClient(cfg.Client, logger)
+	client, err := continuoustest.NewClient(cfg.Client, logger, registry)
 	if err != nil {
 		level.Error(logger).Log("msg", "Failed to initialize client", "err", err.Error())
 		os.Exit(1)
 	}
 
-	// Run continuous testing.
-	m := continuoustest.NewManager()
+	// Push metrics to Pushgateway, if configured, with the same tenant and headers used by the client.
+	m.EnablePushgateway(registry, client.RoundTripper(), logger)
+
+	// Track the result of each test cycle.
+	m.AddResultSinks(continuoustest.NewMetricsResultSink(registry))
+
+	// Run continuous testing until the process is terminated.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	m.AddTest(continuoustest.NewWriteReadSeriesTest(cfg.WriteReadSeriesTest, client, logger, registry))
-	if err := m.Run(context.Background()); err != nil {
+	err = m.Run(ctx)
+	stop()
+	if err != nil {
 		level.Error(logger).Log("msg", "Failed to run continuous test", "err", err.Error())
 		os.Exit(1)
 	}
+
+	// Report the requests latency over the whole run, and fail if it exceeds the configured threshold.
+	for operation, p := range client.LatencyPercentiles() {
+		level.Info(logger).Log("msg", "Requests latency", "operation", operation, "requests", p.Count, "p50", p.P50, "p90", p.P90, "p99", p.P99)
+	}
+	if err := client.CheckLatency(); err != nil {
+		level.Error(logger).Log("msg", "Continuous test failed", "err", err.Error())
+		os.Exit(1)
+	}
 }
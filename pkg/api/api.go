@@ -50,6 +50,7 @@ type ConfigHandler func(actualCfg interface{}, defaultCfg interface{}) http.Hand
 
 type Config struct {
 	SkipLabelNameValidationHeader bool `yaml:"skip_label_name_validation_header_enabled" category:"advanced"`
+	ConfigDiffIgnoreOrder         bool `yaml:"config_diff_ignore_order" category:"advanced"`
 
 	AlertmanagerHTTPPrefix string `yaml:"alertmanager_http_prefix" category:"advanced"`
 	PrometheusHTTPPrefix   string `yaml:"prometheus_http_prefix" category:"advanced"`
@@ -72,6 +73,7 @@ type Config struct {
 // RegisterFlags adds the flags required to config this to the given FlagSet.
 func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	f.BoolVar(&cfg.SkipLabelNameValidationHeader, "api.skip-label-name-validation-header-enabled", false, "Allows to skip label name validation via X-Mimir-SkipLabelNameValidation header on the http write path. Use with caution as it breaks PromQL. Allowing this for external clients allows any client to send invalid label names. After enabling it, requests with a specific HTTP header set to true will not have label names validated.")
+	f.BoolVar(&cfg.ConfigDiffIgnoreOrder, "api.config-diff-ignore-order", false, "True to not report lists containing the same items of the default ones in a different order as changed, in the output of the /config?mode=diff endpoint.")
 	cfg.RegisterFlagsWithPrefix("", f)
 }
 
@@ -225,6 +227,15 @@ func (a *API) RegisterAPI(httpPathPrefix string, actualCfg interface{}, defaultC
 	a.RegisterRoute("/api/v1/status/buildinfo", buildInfoHandler, false, true, "GET")
 }
 
+// RegisterConfigVersion registers the endpoint exposing the Mimir version and the config schema version.
+func (a *API) RegisterConfigVersion(configVersionHandler http.Handler) {
+	a.indexPage.AddLinks(configWeight, "Config version", []IndexPageLink{
+		{Desc: "Mimir version and config schema version", Path: "/config/version"},
+	})
+
+	a.RegisterRoute("/config/version", configVersionHandler, false, true, "GET")
+}
+
 // RegisterRuntimeConfig registers the endpoints associates with the runtime configuration
 func (a *API) RegisterRuntimeConfig(runtimeConfigHandler http.HandlerFunc) {
 	a.indexPage.AddLinks(runtimeConfigWeight, "Current runtime config", []IndexPageLink{
@@ -235,6 +246,16 @@ func (a *API) RegisterRuntimeConfig(runtimeConfigHandler http.HandlerFunc) {
 	a.RegisterRoute("/runtime_config", runtimeConfigHandler, false, true, "GET")
 }
 
+// RegisterTenantLimits registers the endpoint exposing the effective limits of a tenant. Like the runtime
+// config endpoint, it exposes the limits of any tenant, so it should not be publicly reachable.
+func (a *API) RegisterTenantLimits(tenantLimitsHandler http.HandlerFunc) {
+	a.indexPage.AddLinks(runtimeConfigWeight, "Tenant limits", []IndexPageLink{
+		{Desc: "Effective limits of a tenant (set the tenant query parameter)", Path: "/api/v1/tenant_limits?tenant="},
+	})
+
+	a.RegisterRoute("/api/v1/tenant_limits", tenantLimitsHandler, false, true, "GET")
+}
+
 // RegisterDistributor registers the endpoints associated with the distributor.
 func (a *API) RegisterDistributor(d *distributor.Distributor, pushConfig distributor.Config) {
 	distributorpb.RegisterDistributorServer(a.server.GRPC, d)
@@ -121,10 +121,16 @@ func (cfg *Config) configHandler(actualCfg interface{}, defaultCfg interface{})
 	if cfg.CustomConfigHandler != nil {
 		return cfg.CustomConfigHandler(actualCfg, defaultCfg)
 	}
-	return DefaultConfigHandler(actualCfg, defaultCfg)
+	return configHandlerWithDiffOptions(actualCfg, defaultCfg, util.DiffConfigOptions{IgnoreSliceOrder: cfg.ConfigDiffIgnoreOrder})
 }
 
+// DefaultConfigHandler returns the handler of the /config endpoint. In diff mode, slices containing the same
+// items of the default ones in a different order are reported as changed.
 func DefaultConfigHandler(actualCfg interface{}, defaultCfg interface{}) http.HandlerFunc {
+	return configHandlerWithDiffOptions(actualCfg, defaultCfg, util.DiffConfigOptions{})
+}
+
+func configHandlerWithDiffOptions(actualCfg interface{}, defaultCfg interface{}, diffOpts util.DiffConfigOptions) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var output interface{}
 		switch r.URL.Query().Get("mode") {
@@ -141,7 +147,7 @@ func DefaultConfigHandler(actualCfg interface{}, defaultCfg interface{}) http.Ha
 				return
 			}
 
-			diff, err := util.DiffConfig(defaultCfgObj, actualCfgObj)
+			diff, err := util.DiffConfigWithOptions(defaultCfgObj, actualCfgObj, diffOpts)
 			if err != nil {
 				http.Error(w, err.Error(), http.StatusInternalServerError)
 				return
@@ -67,10 +67,12 @@ func TestIndexPageContent(t *testing.T) {
 }
 
 type diffConfigMock struct {
-	MyInt          int          `yaml:"my_int"`
-	MyFloat        float64      `yaml:"my_float"`
-	MySlice        []string     `yaml:"my_slice"`
-	IgnoredField   func() error `yaml:"-"`
+	MyInt          int                 `yaml:"my_int"`
+	MyFloat        float64             `yaml:"my_float"`
+	MySlice        []string            `yaml:"my_slice"`
+	MyMap          map[string][]string `yaml:"my_map"`
+	MyTarget       string              `yaml:"my_target"`
+	IgnoredField   func() error        `yaml:"-"`
 	MyNestedStruct struct {
 		MyString      string   `yaml:"my_string"`
 		MyBool        bool     `yaml:"my_bool"`
@@ -83,6 +85,8 @@ func newDefaultDiffConfigMock() *diffConfigMock {
 		MyInt:        666,
 		MyFloat:      6.66,
 		MySlice:      []string{"value1", "value2"},
+		MyMap:        map[string][]string{"key1": {"value1"}, "key2": {"value2", "value3"}},
+		MyTarget:     "all,ruler",
 		IgnoredField: func() error { return nil },
 	}
 	c.MyNestedStruct.MyString = "string1"
@@ -114,6 +118,40 @@ func TestConfigDiffHandler(t *testing.T) {
 				"    - value2\n" +
 				"    - value3\n",
 		},
+		{
+			name: "slice reordered",
+			actualConfig: func() interface{} {
+				c := newDefaultDiffConfigMock()
+				c.MySlice = []string{"value2", "value1"}
+				return c
+			},
+			expectedStatusCode: 200,
+			expectedBody: "my_slice:\n" +
+				"    - value2\n" +
+				"    - value1\n",
+		},
+		{
+			name: "map value changed",
+			actualConfig: func() interface{} {
+				c := newDefaultDiffConfigMock()
+				c.MyMap = map[string][]string{"key1": {"value1"}, "key2": {"value2"}}
+				return c
+			},
+			expectedStatusCode: 200,
+			expectedBody: "my_map:\n" +
+				"    key2:\n" +
+				"        - value2\n",
+		},
+		{
+			name: "comma-separated string reordered",
+			actualConfig: func() interface{} {
+				c := newDefaultDiffConfigMock()
+				c.MyTarget = "ruler,all"
+				return c
+			},
+			expectedStatusCode: 200,
+			expectedBody:       "my_target: ruler,all\n",
+		},
 		{
 			name: "string in nested struct changed",
 			actualConfig: func() interface{} {
@@ -173,6 +211,42 @@ func TestConfigDiffHandler(t *testing.T) {
 
 }
 
+func TestConfigDiffHandler_ShouldNotReportReorderedSlicesIfOrderIsIgnored(t *testing.T) {
+	for name, tc := range map[string]struct {
+		actualSlice  []string
+		expectedBody string
+	}{
+		"slice reordered": {
+			actualSlice:  []string{"value2", "value1"},
+			expectedBody: "{}\n",
+		},
+		"slice reordered and changed": {
+			actualSlice: []string{"value3", "value1"},
+			expectedBody: "my_slice:\n" +
+				"    - value3\n" +
+				"    - value1\n",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			actualCfg := newDefaultDiffConfigMock()
+			actualCfg.MySlice = tc.actualSlice
+
+			req := httptest.NewRequest("GET", "http://test.com/config?mode=diff", nil)
+			w := httptest.NewRecorder()
+
+			cfg := &Config{ConfigDiffIgnoreOrder: true}
+			cfg.configHandler(actualCfg, newDefaultDiffConfigMock())(w, req)
+
+			resp := w.Result()
+			assert.Equal(t, 200, resp.StatusCode)
+
+			body, err := ioutil.ReadAll(resp.Body)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedBody, string(body))
+		})
+	}
+}
+
 func TestConfigOverrideHandler(t *testing.T) {
 	cfg := &Config{
 		CustomConfigHandler: func(_ interface{}, _ interface{}) http.HandlerFunc {
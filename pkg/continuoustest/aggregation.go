@@ -0,0 +1,87 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+)
+
+// aggregation is a PromQL aggregation operator whose result can be verified by verifyAggregation.
+type aggregation string
+
+const (
+	aggregationSum   aggregation = "sum"
+	aggregationAvg   aggregation = "avg"
+	aggregationCount aggregation = "count"
+)
+
+// query returns the PromQL query aggregating all the series matching the input selector.
+func (a aggregation) query(selector string) string {
+	return fmt.Sprintf("%s(%s)", a, selector)
+}
+
+// expectedAggregationValue returns the value expected when aggregating series with the input values.
+func expectedAggregationValue(a aggregation, values []float64) (float64, error) {
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+
+	switch a {
+	case aggregationSum:
+		return sum, nil
+	case aggregationCount:
+		return float64(len(values)), nil
+	case aggregationAvg:
+		if len(values) == 0 {
+			return 0, errors.New("the average of no series is undefined")
+		}
+		return sum / float64(len(values)), nil
+	default:
+		return 0, fmt.Errorf("unsupported aggregation %q", a)
+	}
+}
+
+// verifyAggregation assumes the input value is the result of a query aggregating series with the input
+// values, and checks whether the actual value matches the expected one. The result can be a scalar, a
+// vector with a single sample or a matrix with a single series, in which case each sample is checked.
+func verifyAggregation(result model.Value, a aggregation, values []float64) error {
+	expected, err := expectedAggregationValue(a, values)
+	if err != nil {
+		return err
+	}
+
+	var actual []model.SamplePair
+
+	switch v := result.(type) {
+	case *model.Scalar:
+		actual = []model.SamplePair{{Timestamp: v.Timestamp, Value: v.Value}}
+	case model.Vector:
+		if len(v) != 1 {
+			return fmt.Errorf("expected 1 sample in the result but got %d", len(v))
+		}
+		actual = []model.SamplePair{{Timestamp: v[0].Timestamp, Value: v[0].Value}}
+	case model.Matrix:
+		if len(v) != 1 {
+			return fmt.Errorf("expected 1 series in the result but got %d", len(v))
+		}
+		actual = v[0].Values
+	default:
+		return fmt.Errorf("unsupported result type %T", result)
+	}
+
+	if len(actual) == 0 {
+		return ErrNoData
+	}
+
+	for _, sample := range actual {
+		if !compareSampleValues(float64(sample.Value), expected) {
+			return fmt.Errorf("%s at timestamp %d has value %f while was expecting %f", a, sample.Timestamp, sample.Value, expected)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,155 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"testing"
+
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAggregation_Query(t *testing.T) {
+	assert.Equal(t, `sum(test{job="a"})`, aggregationSum.query(`test{job="a"}`))
+	assert.Equal(t, "avg(test)", aggregationAvg.query("test"))
+	assert.Equal(t, "count(test)", aggregationCount.query("test"))
+}
+
+func TestExpectedAggregationValue(t *testing.T) {
+	values := []float64{1, 2.5, -0.5, 5}
+
+	tests := map[string]struct {
+		aggregation   aggregation
+		values        []float64
+		expectedValue float64
+		expectedErr   bool
+	}{
+		"sum": {
+			aggregation:   aggregationSum,
+			values:        values,
+			expectedValue: 8,
+		},
+		"avg": {
+			aggregation:   aggregationAvg,
+			values:        values,
+			expectedValue: 2,
+		},
+		"count": {
+			aggregation:   aggregationCount,
+			values:        values,
+			expectedValue: 4,
+		},
+		"sum of no series": {
+			aggregation:   aggregationSum,
+			values:        nil,
+			expectedValue: 0,
+		},
+		"avg of no series": {
+			aggregation: aggregationAvg,
+			values:      nil,
+			expectedErr: true,
+		},
+		"unsupported aggregation": {
+			aggregation: aggregation("stddev"),
+			values:      values,
+			expectedErr: true,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			actual, err := expectedAggregationValue(testData.aggregation, testData.values)
+			if testData.expectedErr {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.InDelta(t, testData.expectedValue, actual, 1e-9)
+		})
+	}
+}
+
+func TestVerifyAggregation(t *testing.T) {
+	// Known values of the series written to Mimir.
+	values := []float64{1, 2.5, -0.5, 5}
+
+	tests := map[string]struct {
+		result      model.Value
+		aggregation aggregation
+		expectedErr string
+	}{
+		"sum of vector result": {
+			result:      model.Vector{{Timestamp: 1000, Value: 8}},
+			aggregation: aggregationSum,
+		},
+		"avg of vector result": {
+			result:      model.Vector{{Timestamp: 1000, Value: 2}},
+			aggregation: aggregationAvg,
+		},
+		"count of vector result": {
+			result:      model.Vector{{Timestamp: 1000, Value: 4}},
+			aggregation: aggregationCount,
+		},
+		"sum of scalar result": {
+			result:      &model.Scalar{Timestamp: 1000, Value: 8},
+			aggregation: aggregationSum,
+		},
+		"avg of matrix result": {
+			result: model.Matrix{{Values: []model.SamplePair{
+				{Timestamp: 1000, Value: 2},
+				{Timestamp: 2000, Value: 2},
+			}}},
+			aggregation: aggregationAvg,
+		},
+		"sum within tolerance": {
+			result:      model.Vector{{Timestamp: 1000, Value: 8.0000001}},
+			aggregation: aggregationSum,
+		},
+		"sum of vector result mismatch": {
+			result:      model.Vector{{Timestamp: 1000, Value: 7}},
+			aggregation: aggregationSum,
+			expectedErr: "sum at timestamp 1000 has value 7.000000 while was expecting 8.000000",
+		},
+		"count of matrix result mismatch in a sample": {
+			result: model.Matrix{{Values: []model.SamplePair{
+				{Timestamp: 1000, Value: 4},
+				{Timestamp: 2000, Value: 3},
+			}}},
+			aggregation: aggregationCount,
+			expectedErr: "count at timestamp 2000 has value 3.000000 while was expecting 4.000000",
+		},
+		"vector result with multiple samples": {
+			result:      model.Vector{{Value: 8}, {Value: 8}},
+			aggregation: aggregationSum,
+			expectedErr: "expected 1 sample in the result but got 2",
+		},
+		"matrix result with multiple series": {
+			result:      model.Matrix{{}, {}},
+			aggregation: aggregationSum,
+			expectedErr: "expected 1 series in the result but got 2",
+		},
+		"matrix result with no samples": {
+			result:      model.Matrix{{}},
+			aggregation: aggregationSum,
+			expectedErr: ErrNoData.Error(),
+		},
+		"unsupported result type": {
+			result:      &model.String{Value: "8"},
+			aggregation: aggregationSum,
+			expectedErr: "unsupported result type *model.String",
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			err := verifyAggregation(testData.result, testData.aggregation, values)
+			if testData.expectedErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, testData.expectedErr)
+			}
+		})
+	}
+}
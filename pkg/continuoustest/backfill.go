@@ -0,0 +1,81 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// Backfill writes the input series, which can have many samples each (eg. a large historical range), splitting
+// the write by time window, so that each write only contains the samples of one window. Windows are aligned to
+// the window size and written in chronological order, so that the samples of each series are written in order.
+// The optional progress function is called after each window has been written, with the number of windows
+// written so far and the total number of windows. Returns the first write error, if any.
+func (c *Client) Backfill(ctx context.Context, series []prompb.TimeSeries, windowSize time.Duration, progress func(done, total int)) error {
+	if windowSize < time.Millisecond {
+		return errors.New("the backfill window size must be at least 1ms")
+	}
+
+	windows := partitionSeriesByTimeWindow(series, windowSize.Milliseconds())
+	for i, window := range windows {
+		if _, err := c.WriteSeries(ctx, window.series); err != nil {
+			return errors.Wrapf(err, "failed to backfill the window %d-%d (%d out of %d)", window.start, window.start+windowSize.Milliseconds(), i+1, len(windows))
+		}
+
+		if progress != nil {
+			progress(i+1, len(windows))
+		}
+	}
+
+	return nil
+}
+
+// backfillWindow is the samples of a time window, starting at start (inclusive) in milliseconds.
+type backfillWindow struct {
+	start  int64
+	series []prompb.TimeSeries
+}
+
+// partitionSeriesByTimeWindow splits the samples of the input series into time windows of the input size in
+// milliseconds, aligned to the window size, and returns the non-empty windows in chronological order. Each
+// window only contains the series with samples in the window.
+func partitionSeriesByTimeWindow(series []prompb.TimeSeries, windowSize int64) []backfillWindow {
+	byStart := map[int64]*backfillWindow{}
+
+	for _, s := range series {
+		// The samples of the series, by window start.
+		samples := map[int64][]prompb.Sample{}
+		for _, sample := range s.Samples {
+			start := windowStart(sample.Timestamp, windowSize)
+			samples[start] = append(samples[start], sample)
+		}
+
+		for start, windowSamples := range samples {
+			w, ok := byStart[start]
+			if !ok {
+				w = &backfillWindow{start: start}
+				byStart[start] = w
+			}
+			w.series = append(w.series, prompb.TimeSeries{Labels: s.Labels, Samples: windowSamples})
+		}
+	}
+
+	out := make([]backfillWindow, 0, len(byStart))
+	for _, w := range byStart {
+		out = append(out, *w)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].start < out[j].start })
+
+	return out
+}
+
+// windowStart returns the start of the window including the input timestamp, rounding down
+// negative timestamps too.
+func windowStart(ts, windowSize int64) int64 {
+	return ts - ((ts%windowSize)+windowSize)%windowSize
+}
@@ -0,0 +1,147 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/grafana/dskit/flagext"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_Backfill(t *testing.T) {
+	var (
+		receivedMx       sync.Mutex
+		receivedRequests []prompb.WriteRequest
+		failAfter        = -1
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		body, err := ioutil.ReadAll(request.Body)
+		require.NoError(t, err)
+
+		body, err = snappy.Decode(nil, body)
+		require.NoError(t, err)
+
+		req := prompb.WriteRequest{}
+		require.NoError(t, proto.Unmarshal(body, &req))
+
+		receivedMx.Lock()
+		defer receivedMx.Unlock()
+
+		if failAfter >= 0 && len(receivedRequests) >= failAfter {
+			writer.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		receivedRequests = append(receivedRequests, req)
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := ClientConfig{}
+	flagext.DefaultValues(&cfg)
+	require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+	require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+	c, err := NewClient(cfg, log.NewNopLogger(), nil)
+	require.NoError(t, err)
+
+	// Generate 2 series with a sample every minute over 2h30m, starting from an hour boundary.
+	start := time.Date(2022, 1, 1, 10, 0, 0, 0, time.UTC)
+	series := generateSineWaveSeries("test", start, 2)
+	for i := range series {
+		series[i].Samples = nil
+		for ts := start; ts.Before(start.Add(150 * time.Minute)); ts = ts.Add(time.Minute) {
+			series[i].Samples = append(series[i].Samples, prompb.Sample{Value: generateSineWaveValue(ts), Timestamp: ts.UnixMilli()})
+		}
+	}
+
+	t.Run("should write each time window in a dedicated request, reporting progress", func(t *testing.T) {
+		receivedMx.Lock()
+		receivedRequests = nil
+		failAfter = -1
+		receivedMx.Unlock()
+
+		var progress [][2]int
+		require.NoError(t, c.Backfill(context.Background(), series, time.Hour, func(done, total int) {
+			progress = append(progress, [2]int{done, total})
+		}))
+
+		assert.Equal(t, [][2]int{{1, 3}, {2, 3}, {3, 3}}, progress)
+
+		receivedMx.Lock()
+		defer receivedMx.Unlock()
+
+		require.Len(t, receivedRequests, 3)
+		for i, req := range receivedRequests {
+			windowStart := start.Add(time.Duration(i) * time.Hour).UnixMilli()
+			windowEnd := windowStart + time.Hour.Milliseconds()
+
+			require.Len(t, req.Timeseries, 2)
+			for _, s := range req.Timeseries {
+				expectedSamples := 60
+				if i == 2 {
+					expectedSamples = 30
+				}
+				require.Len(t, s.Samples, expectedSamples)
+
+				for j, sample := range s.Samples {
+					assert.GreaterOrEqual(t, sample.Timestamp, windowStart)
+					assert.Less(t, sample.Timestamp, windowEnd)
+					if j > 0 {
+						assert.Greater(t, sample.Timestamp, s.Samples[j-1].Timestamp)
+					}
+				}
+			}
+		}
+	})
+
+	t.Run("should stop at the first failed window", func(t *testing.T) {
+		receivedMx.Lock()
+		receivedRequests = nil
+		failAfter = 1
+		receivedMx.Unlock()
+
+		var progress [][2]int
+		err := c.Backfill(context.Background(), series, time.Hour, func(done, total int) {
+			progress = append(progress, [2]int{done, total})
+		})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "(2 out of 3)")
+		assert.Equal(t, [][2]int{{1, 3}}, progress)
+	})
+
+	t.Run("should fail on invalid window size", func(t *testing.T) {
+		assert.EqualError(t, c.Backfill(context.Background(), series, 0, nil), "the backfill window size must be at least 1ms")
+	})
+}
+
+func TestPartitionSeriesByTimeWindow(t *testing.T) {
+	seriesA := []prompb.Label{{Name: "__name__", Value: "a"}}
+	seriesB := []prompb.Label{{Name: "__name__", Value: "b"}}
+
+	input := []prompb.TimeSeries{{
+		Labels:  seriesA,
+		Samples: []prompb.Sample{{Timestamp: -5, Value: 1}, {Timestamp: 0, Value: 2}, {Timestamp: 9, Value: 3}, {Timestamp: 25, Value: 4}},
+	}, {
+		Labels:  seriesB,
+		Samples: []prompb.Sample{{Timestamp: 12, Value: 5}},
+	}}
+
+	assert.Equal(t, []backfillWindow{
+		{start: -10, series: []prompb.TimeSeries{{Labels: seriesA, Samples: []prompb.Sample{{Timestamp: -5, Value: 1}}}}},
+		{start: 0, series: []prompb.TimeSeries{{Labels: seriesA, Samples: []prompb.Sample{{Timestamp: 0, Value: 2}, {Timestamp: 9, Value: 3}}}}},
+		{start: 10, series: []prompb.TimeSeries{{Labels: seriesB, Samples: []prompb.Sample{{Timestamp: 12, Value: 5}}}}},
+		{start: 20, series: []prompb.TimeSeries{{Labels: seriesA, Samples: []prompb.Sample{{Timestamp: 25, Value: 4}}}}},
+	}, partitionSeriesByTimeWindow(input, 10))
+}
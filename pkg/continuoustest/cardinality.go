@@ -0,0 +1,124 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// LabelNamesCardinality is the response of the Mimir label names cardinality API.
+type LabelNamesCardinality struct {
+	LabelValuesCountTotal int                         `json:"label_values_count_total"`
+	LabelNamesCount       int                         `json:"label_names_count"`
+	Cardinality           []LabelNamesCardinalityItem `json:"cardinality"`
+}
+
+// LabelNamesCardinalityItem is the number of values of a label name.
+type LabelNamesCardinalityItem struct {
+	LabelName        string `json:"label_name"`
+	LabelValuesCount int    `json:"label_values_count"`
+}
+
+// LabelValuesCardinality is the response of the Mimir label values cardinality API.
+type LabelValuesCardinality struct {
+	SeriesCountTotal uint64                        `json:"series_count_total"`
+	Labels           []LabelValuesCardinalityLabel `json:"labels"`
+}
+
+// LabelValuesCardinalityLabel is the number of series of each value of a label name.
+type LabelValuesCardinalityLabel struct {
+	LabelName        string                        `json:"label_name"`
+	LabelValuesCount uint64                        `json:"label_values_count"`
+	SeriesCount      uint64                        `json:"series_count"`
+	Cardinality      []LabelValuesCardinalityValue `json:"cardinality"`
+}
+
+// LabelValuesCardinalityValue is the number of series of a label value.
+type LabelValuesCardinalityValue struct {
+	LabelValue  string `json:"label_value"`
+	SeriesCount uint64 `json:"series_count"`
+}
+
+// LabelNamesCardinality returns the number of values of the label names of the series matching the input
+// selector, if any. The limit, if positive, is the max number of label names returned.
+func (c *Client) LabelNamesCardinality(ctx context.Context, selector string, limit int) (LabelNamesCardinality, error) {
+	params := url.Values{}
+	setCardinalityParams(params, selector, limit)
+
+	out := LabelNamesCardinality{}
+	if err := c.doCardinalityRequest(ctx, "/api/v1/cardinality/label_names", params, &out); err != nil {
+		return LabelNamesCardinality{}, err
+	}
+	return out, nil
+}
+
+// LabelValuesCardinality returns the number of series of the values of the input label names, for the series
+// matching the input selector, if any. The limit, if positive, is the max number of values returned by label name.
+func (c *Client) LabelValuesCardinality(ctx context.Context, labelNames []string, selector string, limit int) (LabelValuesCardinality, error) {
+	if len(labelNames) == 0 {
+		return LabelValuesCardinality{}, errors.New("at least 1 label name is required")
+	}
+
+	params := url.Values{}
+	for _, name := range labelNames {
+		params.Add("label_names[]", name)
+	}
+	setCardinalityParams(params, selector, limit)
+
+	out := LabelValuesCardinality{}
+	if err := c.doCardinalityRequest(ctx, "/api/v1/cardinality/label_values", params, &out); err != nil {
+		return LabelValuesCardinality{}, err
+	}
+	return out, nil
+}
+
+func setCardinalityParams(params url.Values, selector string, limit int) {
+	if selector != "" {
+		params.Set("selector", selector)
+	}
+	if limit > 0 {
+		params.Set("limit", strconv.Itoa(limit))
+	}
+}
+
+// doCardinalityRequest posts the input params to the cardinality API at the input path, and decodes the
+// response into out. The request is sent for the tenant of the request and honors the read timeout.
+func (c *Client) doCardinalityRequest(ctx context.Context, path string, params url.Values, out interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, c.getRequestTimeout(ctx, c.cfg.ReadTimeout))
+	defer cancel()
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.cfg.ReadBaseEndpoint.String()+path, strings.NewReader(params.Encode()))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	httpResp, err := c.readRawClient.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer httpResp.Body.Close()
+
+	if httpResp.StatusCode/100 != 2 {
+		truncatedBody, err := io.ReadAll(io.LimitReader(httpResp.Body, maxErrMsgLen))
+		if err != nil {
+			return errors.Wrapf(err, "server returned HTTP status %s and client failed to read response body", httpResp.Status)
+		}
+
+		return fmt.Errorf("server returned HTTP status %s and body %q (truncated to %d bytes)", httpResp.Status, string(truncatedBody), maxErrMsgLen)
+	}
+
+	if err := json.NewDecoder(httpResp.Body).Decode(out); err != nil {
+		return errors.Wrap(err, "failed to decode cardinality response")
+	}
+	return nil
+}
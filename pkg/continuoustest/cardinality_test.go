@@ -0,0 +1,122 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/flagext"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+)
+
+func TestClient_Cardinality(t *testing.T) {
+	var (
+		receivedPath   string
+		receivedTenant string
+		receivedForm   url.Values
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		require.Equal(t, http.MethodPost, request.Method)
+		require.NoError(t, request.ParseForm())
+
+		receivedPath = request.URL.Path
+		receivedTenant = request.Header.Get("X-Scope-OrgID")
+		receivedForm = request.PostForm
+
+		writer.Header().Set("Content-Type", "application/json")
+
+		switch request.URL.Path {
+		case "/api/v1/cardinality/label_names":
+			_, _ = writer.Write([]byte(`{"label_values_count_total":12,"label_names_count":2,"cardinality":[{"label_name":"series_id","label_values_count":10},{"label_name":"__name__","label_values_count":2}]}`))
+		case "/api/v1/cardinality/label_values":
+			_, _ = writer.Write([]byte(`{"series_count_total":10,"labels":[{"label_name":"__name__","label_values_count":2,"series_count":10,"cardinality":[{"label_value":"metric_1","series_count":6},{"label_value":"metric_2","series_count":4}]}]}`))
+		default:
+			writer.WriteHeader(http.StatusBadRequest)
+			_, _ = writer.Write([]byte("invalid path"))
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := ClientConfig{}
+	flagext.DefaultValues(&cfg)
+	cfg.TenantID = "tenant-1"
+	require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+	require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+	c, err := NewClient(cfg, log.NewNopLogger(), nil)
+	require.NoError(t, err)
+
+	t.Run("LabelNamesCardinality", func(t *testing.T) {
+		actual, err := c.LabelNamesCardinality(context.Background(), `{job="test"}`, 10)
+		require.NoError(t, err)
+
+		assert.Equal(t, LabelNamesCardinality{
+			LabelValuesCountTotal: 12,
+			LabelNamesCount:       2,
+			Cardinality: []LabelNamesCardinalityItem{
+				{LabelName: "series_id", LabelValuesCount: 10},
+				{LabelName: "__name__", LabelValuesCount: 2},
+			},
+		}, actual)
+
+		assert.Equal(t, "/api/v1/cardinality/label_names", receivedPath)
+		assert.Equal(t, "tenant-1", receivedTenant)
+		assert.Equal(t, url.Values{"selector": {`{job="test"}`}, "limit": {"10"}}, receivedForm)
+	})
+
+	t.Run("LabelValuesCardinality", func(t *testing.T) {
+		actual, err := c.LabelValuesCardinality(user.InjectOrgID(context.Background(), "tenant-2"), []string{"__name__"}, "", 0)
+		require.NoError(t, err)
+
+		assert.Equal(t, LabelValuesCardinality{
+			SeriesCountTotal: 10,
+			Labels: []LabelValuesCardinalityLabel{{
+				LabelName:        "__name__",
+				LabelValuesCount: 2,
+				SeriesCount:      10,
+				Cardinality: []LabelValuesCardinalityValue{
+					{LabelValue: "metric_1", SeriesCount: 6},
+					{LabelValue: "metric_2", SeriesCount: 4},
+				},
+			}},
+		}, actual)
+
+		assert.Equal(t, "/api/v1/cardinality/label_values", receivedPath)
+		assert.Equal(t, "tenant-2", receivedTenant)
+		assert.Equal(t, url.Values{"label_names[]": {"__name__"}}, receivedForm)
+	})
+
+	t.Run("LabelValuesCardinality should fail without label names", func(t *testing.T) {
+		_, err := c.LabelValuesCardinality(context.Background(), nil, "", 0)
+		require.EqualError(t, err, "at least 1 label name is required")
+	})
+}
+
+func TestClient_Cardinality_ShouldReturnErrorOnNon2xxResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.WriteHeader(http.StatusBadRequest)
+		_, _ = writer.Write([]byte("cardinality analysis disabled"))
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := ClientConfig{}
+	flagext.DefaultValues(&cfg)
+	require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+	require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+	c, err := NewClient(cfg, log.NewNopLogger(), nil)
+	require.NoError(t, err)
+
+	_, err = c.LabelNamesCardinality(context.Background(), "", 0)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "400 Bad Request")
+	assert.Contains(t, err.Error(), "cardinality analysis disabled")
+}
@@ -0,0 +1,108 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ErrCircuitOpen is returned when a write is short-circuited because the circuit breaker is open.
+var ErrCircuitOpen = errors.New("the write circuit breaker is open")
+
+type circuitState int
+
+const (
+	circuitClosed circuitState = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+// circuitBreaker opens after a number of consecutive failures and rejects requests until the
+// cooldown period elapses. Then it lets a single trial request through: the circuit is closed
+// if the trial succeeds, or opened again for another cooldown period if it fails.
+type circuitBreaker struct {
+	failureThreshold int
+	cooldown         time.Duration
+	stateGauge       prometheus.Gauge
+
+	// Used to mock the time in tests.
+	now func() time.Time
+
+	mx                  sync.Mutex
+	state               circuitState
+	consecutiveFailures int
+	openedAt            time.Time
+}
+
+// newCircuitBreaker returns a circuitBreaker, or nil if failureThreshold is <= 0. A nil
+// circuitBreaker never rejects any request.
+func newCircuitBreaker(failureThreshold int, cooldown time.Duration, stateGauge prometheus.Gauge) *circuitBreaker {
+	if failureThreshold <= 0 {
+		return nil
+	}
+
+	return &circuitBreaker{
+		failureThreshold: failureThreshold,
+		cooldown:         cooldown,
+		stateGauge:       stateGauge,
+		now:              time.Now,
+	}
+}
+
+// allow returns whether a request is allowed to be sent.
+func (b *circuitBreaker) allow() bool {
+	if b == nil {
+		return true
+	}
+
+	b.mx.Lock()
+	defer b.mx.Unlock()
+
+	switch b.state {
+	case circuitOpen:
+		if b.now().Sub(b.openedAt) < b.cooldown {
+			return false
+		}
+
+		// Let a single trial request through.
+		b.setState(circuitHalfOpen)
+		return true
+	case circuitHalfOpen:
+		// A trial request is in progress.
+		return false
+	default:
+		return true
+	}
+}
+
+// record records the outcome of a request previously allowed.
+func (b *circuitBreaker) record(success bool) {
+	if b == nil {
+		return
+	}
+
+	b.mx.Lock()
+	defer b.mx.Unlock()
+
+	if success {
+		b.consecutiveFailures = 0
+		b.setState(circuitClosed)
+		return
+	}
+
+	b.consecutiveFailures++
+	if b.state == circuitHalfOpen || b.consecutiveFailures >= b.failureThreshold {
+		b.openedAt = b.now()
+		b.setState(circuitOpen)
+	}
+}
+
+// setState must be called with the lock held.
+func (b *circuitBreaker) setState(state circuitState) {
+	b.state = state
+	b.stateGauge.Set(float64(state))
+}
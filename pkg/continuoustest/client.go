@@ -4,84 +4,433 @@ package continuoustest
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/tls"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"hash/fnv"
 	"io"
+	"net"
 	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
+	"unicode/utf8"
 
 	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
 	"github.com/gogo/protobuf/proto"
 	"github.com/golang/snappy"
+	"github.com/grafana/dskit/backoff"
+	dstls "github.com/grafana/dskit/crypto/tls"
 	"github.com/grafana/dskit/flagext"
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/api"
 	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/prompb"
+	"github.com/weaveworks/common/user"
+	"go.uber.org/atomic"
+	"golang.org/x/sync/semaphore"
 
 	util_math "github.com/grafana/mimir/pkg/util/math"
 )
 
 const (
 	maxErrMsgLen = 256
+
+	// maxRetryAfterRetries is the max number of times a write request is retried honoring the Retry-After header.
+	maxRetryAfterRetries = 3
+
+	// maxConnectionErrorRetries is the max number of times a write request failed because the connection
+	// has been closed by the server (eg. HTTP/2 GOAWAY or connection reset) is retried.
+	maxConnectionErrorRetries = 1
+
+	operationWrite = "write"
+	operationRead  = "read"
+	operationOther = "other"
 )
 
+// ErrPayloadTooLarge is returned when the payload of a write request exceeds the max allowed size.
+var ErrPayloadTooLarge = errors.New("write request payload is too large")
+
+// ErrEmptyWrite is returned when writing no series and empty writes are not allowed.
+var ErrEmptyWrite = errors.New("no series to write")
+
 // MimirClient is the interface implemented by a client used to interact with Mimir.
+// The tenant ID injected in the context with user.InjectOrgID(), if any, overrides the configured one.
 type MimirClient interface {
 	// WriteSeries writes input series to Mimir. Returns the response status code and optionally
 	// an error. The error is always returned if request was not successful (eg. received a 4xx or 5xx error).
+	// The deadline set on the input context, if any, is honored as the overall budget for writing all series.
+	// Writing no series sends no request and, unless empty writes are disallowed, succeeds with status 200.
 	WriteSeries(ctx context.Context, series []prompb.TimeSeries) (statusCode int, err error)
 
 	// QueryRange performs a query for the given range.
 	QueryRange(ctx context.Context, query string, start, end time.Time, step time.Duration) (model.Matrix, error)
+
+	// Query performs an instant query at the given time. The result is a model.Vector, *model.Scalar,
+	// *model.String or model.Matrix, depending on the query expression.
+	Query(ctx context.Context, query string, ts time.Time) (model.Value, error)
+
+	// BuildInfo returns the build information and the features enabled in the target Mimir cluster.
+	BuildInfo(ctx context.Context) (BuildInfo, error)
+
+	// LabelValues returns the values of the input label, for the series matching the input selectors in the
+	// given time range. If limit is > 0, at most limit values are returned and truncated is true if the
+	// result has been truncated because of the limit.
+	LabelValues(ctx context.Context, label string, matches []string, start, end time.Time, limit int) (values model.LabelValues, truncated bool, err error)
 }
 
 type ClientConfig struct {
-	TenantID string
+	TenantID               string                 `yaml:"tenant_id"`
+	TenantIDFile           string                 `yaml:"tenant_id_file"`
+	TenantIDFileRefresh    time.Duration          `yaml:"tenant_id_file_refresh_interval"`
+	MetricsTenantAllowlist flagext.StringSliceCSV `yaml:"metrics_tenant_allowlist"`
+	TenantFromJWTClaim     string                 `yaml:"tenant_from_jwt_claim"`
+	JWT                    string                 `yaml:"jwt"`
+	JWTFile                string                 `yaml:"jwt_file"`
+	HeaderTemplates        flagext.StringSlice    `yaml:"header_template"`
+	SuccessRatioWindowSize int                    `yaml:"success_ratio_window_size"`
+	DialTimeout            time.Duration          `yaml:"dial_timeout"`
+	DisableKeepAlives      bool                   `yaml:"disable_keepalives"`
+	CloseIdleAfter         time.Duration          `yaml:"close_idle_connections_after"`
+	TLS                    dstls.ClientConfig     `yaml:",inline"`
+	Origin                 string                 `yaml:"origin"`
+	Referer                string                 `yaml:"referer"`
+	OriginOnReads          bool                   `yaml:"origin_on_reads"`
+	MetricsPrefix          string                 `yaml:"client_metrics_prefix"`
+	FaultInjection         FaultInjectionConfig   `yaml:"fault_injection"`
+	RetryBackoff           RetryBackoffConfig     `yaml:"retry_backoff"`
+
+	WriteBaseEndpoint       flagext.URLValue       `yaml:"write_endpoint"`
+	WriteShardedEndpoints   flagext.StringSliceCSV `yaml:"write_sharded_endpoints"`
+	WriteBatchSize          int                    `yaml:"write_batch_size"`
+	WriteMaxInflight        int                    `yaml:"write_max_inflight_requests"`
+	WriteMaxRequestSize     int                    `yaml:"write_max_request_size_bytes"`
+	WriteTimeout            time.Duration          `yaml:"write_timeout"`
+	WriteTotalTimeout       time.Duration          `yaml:"write_total_timeout"`
+	PauseOnUnhealthy        bool                   `yaml:"write_pause_on_unhealthy"`
+	PauseOnUnhealthyBackoff backoff.Config         `yaml:"write_pause_on_unhealthy_backoff"`
+	PauseOnUnhealthyAfter   int                    `yaml:"write_pause_on_unhealthy_after_failures"`
+	StrictWriteResponse     bool                   `yaml:"write_strict_response"`
+	AllowEmptyWrite         bool                   `yaml:"write_allow_empty"`
+	SnappyFramed            bool                   `yaml:"write_snappy_framed"`
+	SortLabels              bool                   `yaml:"write_sort_labels"`
+	WriteForceHTTP1         bool                   `yaml:"write_force_http1"`
+	WriteRetryAfterMaxWait  time.Duration          `yaml:"write_retry_after_max_wait"`
+	WriteCircuitThreshold   int                    `yaml:"write_circuit_breaker_failure_threshold"`
+	WriteCircuitCooldown    time.Duration          `yaml:"write_circuit_breaker_cooldown"`
+	ValidateBatch           bool                   `yaml:"write_validate_batch"`
+	MaxLabelValueLength     int                    `yaml:"write_max_label_value_length"`
+	MaxSampleAge            time.Duration          `yaml:"write_max_sample_age"`
+	MaxSeriesPerTenant      int                    `yaml:"write_max_series_per_tenant"`
+
+	ReadBaseEndpoint      flagext.URLValue       `yaml:"read_endpoint"`
+	ReadFailoverEndpoints flagext.StringSliceCSV `yaml:"read_failover_endpoints"`
+	ReadTimeout           time.Duration          `yaml:"read_timeout"`
+	QueryTimeout          time.Duration          `yaml:"query_timeout"`
+	ReadCompressRequest   bool                   `yaml:"read_compress_request"`
+	ReadMaxRetries        int                    `yaml:"read_max_retries"`
+
+	MaxP99Latency time.Duration `yaml:"max_p99_latency"`
+
+	// HTTPClient is an optional HTTP client used to send requests to Mimir. If set, it's used
+	// for the write path and its transport is used for the read path. If its transport is set, the
+	// dial timeout, keep-alives and TLS settings are ignored. It can't be set via CLI flags.
+	HTTPClient *http.Client `yaml:"-"`
+
+	// Backoff is an optional strategy computing the delay between retries of failed requests. If set, it
+	// overrides the strategy configured via RetryBackoff. It can't be set via CLI flags.
+	Backoff Backoff `yaml:"-"`
+
+	// WriteMalformed, if set, makes write requests deliberately malformed, to check the server rejects
+	// them with a 4xx error. For testing only: it can't be set via CLI flags, so that it can't be enabled
+	// accidentally on a running continuous test.
+	WriteMalformed WriteMalformedMode `yaml:"-"`
+
+	// WriteTransport is the transport used to write series: http (the default, if empty) sends remote write
+	// requests to the write endpoint, while kafka produces them to the Kafka topic configured in WriteKafka,
+	// to test the Kafka-based ingest path. It can't be set via CLI flags, because the kafka transport
+	// requires a Kafka producer to be provided.
+	WriteTransport string      `yaml:"-"`
+	WriteKafka     KafkaConfig `yaml:"-"`
+}
 
-	WriteBaseEndpoint flagext.URLValue
-	WriteBatchSize    int
-	WriteTimeout      time.Duration
+// WriteMalformedMode is the way write requests are made malformed.
+type WriteMalformedMode string
 
-	ReadBaseEndpoint flagext.URLValue
-	ReadTimeout      time.Duration
+const (
+	// WriteMalformedDisabled sends well-formed write requests.
+	WriteMalformedDisabled WriteMalformedMode = ""
+
+	// WriteMalformedMissingContentHeaders omits the Content-Type and Content-Encoding headers.
+	WriteMalformedMissingContentHeaders WriteMalformedMode = "missing-content-headers"
+
+	// WriteMalformedInvalidContentType sets a Content-Type header not matching the body.
+	WriteMalformedInvalidContentType WriteMalformedMode = "invalid-content-type"
+
+	// WriteMalformedInvalidContentEncoding sets a Content-Encoding header not matching the body.
+	WriteMalformedInvalidContentEncoding WriteMalformedMode = "invalid-content-encoding"
+)
+
+// setContentHeaders sets the content headers of a write request, malformed according to the mode.
+func (m WriteMalformedMode) setContentHeaders(header http.Header) {
+	switch m {
+	case WriteMalformedMissingContentHeaders:
+		return
+	case WriteMalformedInvalidContentType:
+		header.Set("Content-Encoding", "snappy")
+		header.Set("Content-Type", "text/plain")
+	case WriteMalformedInvalidContentEncoding:
+		header.Set("Content-Encoding", "gzip")
+		header.Set("Content-Type", "application/x-protobuf")
+	default:
+		header.Set("Content-Encoding", "snappy")
+		header.Set("Content-Type", "application/x-protobuf")
+	}
+}
+
+func (m WriteMalformedMode) validate() error {
+	switch m {
+	case WriteMalformedDisabled, WriteMalformedMissingContentHeaders, WriteMalformedInvalidContentType, WriteMalformedInvalidContentEncoding:
+		return nil
+	default:
+		return fmt.Errorf("unsupported malformed write mode %q", m)
+	}
 }
 
 func (cfg *ClientConfig) RegisterFlags(f *flag.FlagSet) {
-	f.StringVar(&cfg.TenantID, "tests.tenant-id", "anonymous", "The tenant ID to use to write and read metrics in tests.")
+	f.StringVar(&cfg.TenantID, "tests.tenant-id", defaultTenantID, "The tenant ID to use to write and read metrics in tests.")
+	f.StringVar(&cfg.TenantIDFile, "tests.tenant-id-file", "", "Path to a file containing the tenant ID to use to write and read metrics in tests. The file is periodically read again, so that the tenant ID can be rotated. Mutually exclusive with -tests.tenant-id.")
+	f.DurationVar(&cfg.TenantIDFileRefresh, "tests.tenant-id-file-refresh-interval", time.Minute, "How frequently the file configured with -tests.tenant-id-file is read again.")
+	f.Var(&cfg.MetricsTenantAllowlist, "tests.metrics-tenant-allowlist", "Comma-separated list of tenants which client request metrics are labelled with. The configured tenant is always included, while requests for any other tenant are tracked with the tenant label set to 'other'.")
+	f.StringVar(&cfg.TenantFromJWTClaim, "tests.tenant-from-jwt-claim", "", "If set, the tenant ID is read from this claim of the configured JWT, instead of using -tests.tenant-id.")
+	f.StringVar(&cfg.JWT, "tests.jwt", "", "The JWT to send as bearer token in the Authorization header. The JWT signature is not verified by the tool.")
+	f.Var(&cfg.HeaderTemplates, "tests.header-template", "An additional HTTP header to set on each request, in the form name=value. The value can reference the tenant ID of the request with {tenant}. This flag can be repeated to set multiple headers.")
+	f.DurationVar(&cfg.DialTimeout, "tests.dial-timeout", 30*time.Second, "The timeout when establishing a connection to Mimir.")
+	f.BoolVar(&cfg.DisableKeepAlives, "tests.disable-keepalives", false, "True to open a new connection for each request, instead of reusing connections, so that requests are spread across the backends behind a load balancer.")
+	f.DurationVar(&cfg.CloseIdleAfter, "tests.close-idle-connections-after", 0, "If set, connections idle for this long are closed, so that infrequent requests don't reuse connections which may have been dropped by the backends in the meanwhile. 0 to keep the default idle timeout.")
+	cfg.TLS.RegisterFlagsWithPrefix("tests", f)
+	f.StringVar(&cfg.Origin, "tests.origin", "", "If set, the Origin header to set on write requests, required by gateways enforcing CSRF protection.")
+	f.StringVar(&cfg.Referer, "tests.referer", "", "If set, the Referer header to set on write requests, required by gateways enforcing CSRF protection.")
+	f.BoolVar(&cfg.OriginOnReads, "tests.origin-on-reads", false, "True to set the Origin and Referer headers on read requests too.")
+	f.StringVar(&cfg.MetricsPrefix, "tests.client-metrics-prefix", "", "If set, the prefix prepended to the name of the metrics tracked by the client, to distinguish the metrics of multiple clients registered to the same registry.")
+	cfg.FaultInjection.RegisterFlagsWithPrefix("tests.fault-injection", f)
+	cfg.RetryBackoff.RegisterFlagsWithPrefix("tests.retry-backoff", f)
+	f.IntVar(&cfg.SuccessRatioWindowSize, "tests.success-ratio-window-size", 100, "The number of most recent write and read requests over which the success ratio is computed.")
+	f.StringVar(&cfg.JWTFile, "tests.jwt-file", "", "Path to a file containing the JWT to send as bearer token in the Authorization header. Mutually exclusive with -tests.jwt.")
 
 	f.Var(&cfg.WriteBaseEndpoint, "tests.write-endpoint", "The base endpoint on the write path. The URL should have no trailing slash. The specific API path is appended by the tool to the URL, for example /api/v1/push for the remote write API endpoint, so the configured URL must not include it.")
+	f.Var(&cfg.WriteShardedEndpoints, "tests.write-sharded-endpoints", "Comma-separated list of base endpoints on the write path. If set, writes for each tenant are consistently sent to one of these endpoints, picked by hashing the tenant ID, instead of -tests.write-endpoint.")
 	f.IntVar(&cfg.WriteBatchSize, "tests.write-batch-size", 1000, "The maximum number of series to write in a single request.")
+	f.IntVar(&cfg.WriteMaxInflight, "tests.write-max-inflight-requests", 0, "The maximum number of write requests in-flight at the same time, across all the tests sharing the client. Writes wait when the limit is reached. 0 to disable the limit.")
+	f.IntVar(&cfg.WriteMaxRequestSize, "tests.write-max-request-size-bytes", 0, "The maximum size, in bytes, of the uncompressed payload of a single write request. Batches exceeding it are automatically split into smaller ones. 0 to disable.")
 	f.DurationVar(&cfg.WriteTimeout, "tests.write-timeout", 5*time.Second, "The timeout for a single write request.")
+	f.DurationVar(&cfg.WriteTotalTimeout, "tests.write-total-timeout", 0, "If set, the timeout for writing all the batches of series of a single write, including retries. Unlike -tests.write-timeout, it applies to the whole write instead of each request. 0 to disable.")
+	f.BoolVar(&cfg.PauseOnUnhealthy, "tests.write-pause-on-unhealthy", false, "True to pause writes when write requests repeatedly fail with a 5xx error, polling the /ready endpoint on the write path with backoff and then retrying the failed request once it's ready.")
+	cfg.PauseOnUnhealthyBackoff.RegisterFlagsWithPrefix("tests.write-pause-on-unhealthy", f)
+	f.IntVar(&cfg.PauseOnUnhealthyAfter, "tests.write-pause-on-unhealthy-after-failures", 3, "The number of consecutive write requests failed with a 5xx error after which writes are paused, when -tests.write-pause-on-unhealthy is enabled. Transient failures below this number are returned without pausing writes.")
+	f.IntVar(&cfg.WriteCircuitThreshold, "tests.write-circuit-breaker-failure-threshold", 0, "The number of consecutive write requests failed with a network or 5xx error after which the circuit breaker opens, and writes fail without sending any request until the cooldown period elapses. 0 to disable the circuit breaker.")
+	f.DurationVar(&cfg.WriteCircuitCooldown, "tests.write-circuit-breaker-cooldown", time.Minute, "How long the write circuit breaker stays open before letting a trial request through.")
+	f.BoolVar(&cfg.ValidateBatch, "tests.write-validate-batch", false, "True to validate each batch of series before writing it, failing the write if the batch contains duplicate series, invalid label names, invalid UTF-8 label values, label values longer than -tests.write-max-label-value-length or samples older than -tests.write-max-sample-age.")
+	f.IntVar(&cfg.MaxLabelValueLength, "tests.write-max-label-value-length", 2048, "The maximum length of label values allowed when -tests.write-validate-batch is enabled. 0 to disable.")
+	f.DurationVar(&cfg.MaxSampleAge, "tests.write-max-sample-age", 0, "The maximum age of samples allowed when -tests.write-validate-batch is enabled. 0 to disable.")
+	f.IntVar(&cfg.MaxSeriesPerTenant, "tests.write-max-series-per-tenant", 0, "The maximum number of distinct series written for each tenant. New series exceeding the limit are not written, to avoid hitting the tenant series limit. 0 to disable, -1 to read the limit from the tenant limits endpoint on the read path.")
+	f.DurationVar(&cfg.WriteRetryAfterMaxWait, "tests.write-retry-after-max-wait", 10*time.Second, "The max time to wait before retrying a write request failed with HTTP status 429 or 503 and a Retry-After header. The wait time is the one requested by the header, capped to this value. 0 to not retry write requests based on the Retry-After header.")
+	f.BoolVar(&cfg.SortLabels, "tests.write-sort-labels", true, "True to sort the labels of each series by name before writing it, as required by Mimir. Set to false to preserve the input labels order, for example for negative testing or to write series with shuffled labels.")
+	f.BoolVar(&cfg.WriteForceHTTP1, "tests.write-force-http1", false, "True to force HTTP/1.1 for write requests, even if the server supports HTTP/2. Useful as a workaround for load balancers closing HTTP/2 connections with GOAWAY while requests are in-flight. Ignored if the HTTP client transport is customized.")
+	f.BoolVar(&cfg.SnappyFramed, "tests.write-snappy-framed", false, "True to compress write requests with the snappy framing format, instead of the snappy block format expected by Mimir. Useful to test interoperability with servers expecting framed snappy.")
+	f.BoolVar(&cfg.AllowEmptyWrite, "tests.write-allow-empty", true, "True to succeed with HTTP status 200, without sending any request, when writing no series. If false, writing no series fails with an error.")
+	f.BoolVar(&cfg.StrictWriteResponse, "tests.write-strict-response", false, "True to fail write requests which succeeded with a non-empty response body or an HTML content type, which are usually returned by misconfigured proxies. If false, a warning is logged instead.")
 
 	f.Var(&cfg.ReadBaseEndpoint, "tests.read-endpoint", "The base endpoint on the read path. The URL should have no trailing slash. The specific API path is appended by the tool to the URL, for example /api/v1/query_range for range query API, so the configured URL must not include it.")
+	f.Var(&cfg.ReadFailoverEndpoints, "tests.read-failover-endpoints", "Comma-separated list of base endpoints on the read path, in another region, to fail over to, in order, when a read request to -tests.read-endpoint fails with a network or 5xx error. Read requests failed because of the query itself are not failed over.")
 	f.DurationVar(&cfg.ReadTimeout, "tests.read-timeout", 30*time.Second, "The timeout for a single read request.")
+	f.DurationVar(&cfg.QueryTimeout, "tests.query-timeout", 0, "If set, the timeout sent to Mimir as the timeout parameter of range queries, to limit the query evaluation time on the server side. Unlike -tests.read-timeout, it doesn't affect the HTTP request timeout. 0 to not send it.")
+	f.BoolVar(&cfg.ReadCompressRequest, "tests.read-compress-request", false, "True to gzip the body of query requests sent with the POST method, setting the Content-Encoding header accordingly. The server must support compressed query requests.")
+	f.DurationVar(&cfg.MaxP99Latency, "tests.max-p99-latency", 0, "If set, the max p99 latency of write and read requests over the whole run. The latency is checked when the tool shuts down, exiting with a non-zero code if the p99 latency of any operation exceeds it. 0 to disable.")
+	f.IntVar(&cfg.ReadMaxRetries, "tests.read-max-retries", 0, "The max number of times a read request failed with a network or 5xx error is retried, waiting between retries according to the retry backoff. 0 to not retry read requests.")
 }
 
 type Client struct {
-	writeClient *http.Client
-	readClient  v1.API
-	cfg         ClientConfig
-	logger      log.Logger
+	tenantID      string
+	tenantIDFile  *tenantIDFileReader
+	writeClient   *http.Client
+	readClient    v1.API
+	readRawClient *http.Client
+	writeCircuit  *circuitBreaker
+	writeInflight *semaphore.Weighted
+	writeFailures *atomic.Int64 // Consecutive write requests failed with a 5xx error.
+	seriesCapper  *seriesCapper
+	retryBackoff  Backoff
+	latencies     *latencyTracker
+	metrics       *clientMetrics
+	rt            http.RoundTripper
+	cfg           ClientConfig
+	logger        log.Logger
 }
 
-func NewClient(cfg ClientConfig, logger log.Logger) (*Client, error) {
-	rt := http.DefaultTransport
-	rt = &clientRoundTripper{tenantID: cfg.TenantID, rt: rt}
-
+func NewClient(cfg ClientConfig, logger log.Logger, reg prometheus.Registerer) (*Client, error) {
 	// Ensure the required config has been set.
-	if cfg.WriteBaseEndpoint.URL == nil {
+	if cfg.WriteBaseEndpoint.URL == nil && len(cfg.WriteShardedEndpoints) == 0 {
 		return nil, errors.New("the write endpoint has not been set")
 	}
+	for _, endpoint := range cfg.WriteShardedEndpoints {
+		if _, err := url.Parse(endpoint); err != nil {
+			return nil, errors.Wrapf(err, "invalid sharded write endpoint %q", endpoint)
+		}
+	}
 	if cfg.ReadBaseEndpoint.URL == nil {
 		return nil, errors.New("the read endpoint has not been set")
 	}
+	for _, endpoint := range cfg.ReadFailoverEndpoints {
+		if _, err := url.Parse(endpoint); err != nil {
+			return nil, errors.Wrapf(err, "invalid failover read endpoint %q", endpoint)
+		}
+	}
+	if cfg.SuccessRatioWindowSize <= 0 {
+		return nil, errors.New("the success ratio window size must be greater than 0")
+	}
+	if cfg.PauseOnUnhealthy && cfg.PauseOnUnhealthyAfter <= 0 {
+		return nil, errors.New("the number of failures after which writes are paused on unhealthy write endpoint must be greater than 0")
+	}
+	if err := cfg.WriteMalformed.validate(); err != nil {
+		return nil, err
+	}
+	if err := validateWriteTransport(cfg.WriteTransport); err != nil {
+		return nil, err
+	}
+
+	if cfg.WriteTransport == writeTransportKafka {
+		if err := cfg.WriteKafka.validate(); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.WriteMalformed != WriteMalformedDisabled {
+		level.Warn(logger).Log("msg", "Write requests are deliberately malformed, for testing only", "mode", cfg.WriteMalformed)
+	}
+	if cfg.FaultInjection.Enabled {
+		if err := cfg.FaultInjection.validate(); err != nil {
+			return nil, err
+		}
+		level.Warn(logger).Log("msg", "Faults are injected in requests, for testing only", "error_rate", cfg.FaultInjection.ErrorRate, "latency", cfg.FaultInjection.Latency)
+	}
+
+	retryBackoff := cfg.Backoff
+	if retryBackoff == nil {
+		if err := cfg.RetryBackoff.validate(); err != nil {
+			return nil, err
+		}
+		retryBackoff = cfg.RetryBackoff.newBackoff()
+	}
+
+	jwt, err := loadJWT(cfg.JWT, cfg.JWTFile)
+	if err != nil {
+		return nil, err
+	}
+
+	// The tenant ID file can't be combined with a tenant ID other than the default one.
+	var tenantIDFile *tenantIDFileReader
+	if cfg.TenantIDFile != "" {
+		if cfg.TenantID != "" && cfg.TenantID != defaultTenantID {
+			return nil, errors.New("the tenant ID and the tenant ID file can't be both set")
+		}
+		if cfg.TenantFromJWTClaim != "" {
+			return nil, errors.New("the tenant ID file and the tenant ID from JWT claim can't be both set")
+		}
+
+		tenantIDFile, err = newTenantIDFileReader(cfg.TenantIDFile, cfg.TenantIDFileRefresh)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	tenantID := cfg.TenantID
+	if tenantIDFile != nil {
+		tenantID = tenantIDFile.get()
+	}
+	if cfg.TenantFromJWTClaim != "" {
+		if jwt == "" {
+			return nil, errors.New("the tenant ID can't be read from a JWT claim because no JWT has been configured")
+		}
+
+		tenantID, err = getJWTClaim(jwt, cfg.TenantFromJWTClaim)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read the tenant ID from the JWT")
+		}
+	}
+
+	headerTemplates, err := parseHeaderTemplates(cfg.HeaderTemplates)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsCfg, err := getTLSConfig(cfg.TLS)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid TLS config")
+	}
+
+	customTransport := cfg.HTTPClient != nil && cfg.HTTPClient.Transport != nil
+	rt := http.RoundTripper(newTransport(cfg, tlsCfg))
+	if customTransport {
+		rt = cfg.HTTPClient.Transport
+	}
+	if cfg.FaultInjection.Enabled {
+		rt = newFaultInjectionRoundTripper(cfg.FaultInjection, rt)
+	}
+	metricsTenants := map[string]struct{}{tenantID: {}}
+	for _, tenant := range cfg.MetricsTenantAllowlist {
+		metricsTenants[tenant] = struct{}{}
+	}
+
+	if cfg.MetricsPrefix != "" {
+		reg = prometheus.WrapRegistererWithPrefix(cfg.MetricsPrefix, reg)
+	}
+	metrics := newClientMetrics(reg)
+	latencies := newLatencyTracker()
+	crt := &clientRoundTripper{
+		tenantID:        tenantID,
+		tenantIDFile:    tenantIDFile,
+		jwt:             jwt,
+		headerTemplates: headerTemplates,
+		queryTimeout:    cfg.QueryTimeout,
+		origin:          cfg.Origin,
+		referer:         cfg.Referer,
+		originOnReads:   cfg.OriginOnReads,
+		compressReads:   cfg.ReadCompressRequest,
+		rt:              rt,
+		metrics:         metrics,
+		latencies:       latencies,
+		metricsTenants:  metricsTenants,
+		successRatios: map[string]*slidingWindowRatio{
+			operationWrite: newSlidingWindowRatio(cfg.SuccessRatioWindowSize),
+			operationRead:  newSlidingWindowRatio(cfg.SuccessRatioWindowSize),
+		},
+	}
+	rt = crt
+
+	// Only read requests are retried by the round tripper, because write retries depend on the write error.
+	// Each retry goes through all the read endpoints again, if failover is enabled.
+	readRT := rt
+	if len(cfg.ReadFailoverEndpoints) > 0 {
+		readRT = newReadFailoverRoundTripper(readRT, cfg.ReadBaseEndpoint.String(), cfg.ReadFailoverEndpoints, metrics.readServedBy, logger)
+	}
+	if cfg.ReadMaxRetries > 0 {
+		readRT = newReadRetryRoundTripper(readRT, retryBackoff, cfg.ReadMaxRetries, logger)
+	}
 
 	apiCfg := api.Config{
 		Address:      cfg.ReadBaseEndpoint.String(),
-		RoundTripper: rt,
+		RoundTripper: readRT,
 	}
 
 	readClient, err := api.NewClient(apiCfg)
@@ -89,17 +438,129 @@ func NewClient(cfg ClientConfig, logger log.Logger) (*Client, error) {
 		return nil, errors.Wrap(err, "failed to create read client")
 	}
 
+	// Honor the custom HTTP client settings (eg. timeout, redirect policy), if any, on the write path.
+	writeClient := &http.Client{}
+	if cfg.HTTPClient != nil {
+		*writeClient = *cfg.HTTPClient
+	}
+	// The auth and TLS settings are the same for the read and write paths, so they share the same round
+	// tripper, and so the same connections pool, if the read and write endpoints are the same host. Otherwise,
+	// or if HTTP/1.1 is forced on the write path, write requests go through a dedicated transport, while
+	// sharing the same headers and metrics. A custom transport is always shared.
+	writeRT := crt
+	if !customTransport && (cfg.WriteForceHTTP1 || !isSameHost(cfg.ReadBaseEndpoint.URL, cfg.WriteBaseEndpoint.URL)) {
+		writeTransport := newTransport(cfg, tlsCfg)
+		if cfg.WriteForceHTTP1 {
+			writeTransport = newHTTP1Transport(writeTransport)
+		}
+
+		dedicatedRT := *crt
+		dedicatedRT.rt = writeTransport
+		if cfg.FaultInjection.Enabled {
+			dedicatedRT.rt = newFaultInjectionRoundTripper(cfg.FaultInjection, dedicatedRT.rt)
+		}
+		writeRT = &dedicatedRT
+	}
+	// With the Kafka transport, remote write requests are produced to Kafka, while the other requests on the
+	// write path (eg. readiness checks) are still sent to the write endpoint, which is so still required.
+	if cfg.WriteTransport == writeTransportKafka {
+		kafkaRT := *writeRT
+		kafkaRT.rt = newKafkaRoundTripper(cfg.WriteKafka, writeRT.rt)
+		writeRT = &kafkaRT
+	}
+	writeClient.Transport = writeRT
+
+	// The number of in-flight write requests is unlimited if the semaphore is nil.
+	var writeInflight *semaphore.Weighted
+	if cfg.WriteMaxInflight > 0 {
+		writeInflight = semaphore.NewWeighted(int64(cfg.WriteMaxInflight))
+	}
+
 	return &Client{
-		writeClient: &http.Client{Transport: rt},
-		readClient:  v1.NewAPI(readClient),
-		cfg:         cfg,
-		logger:      logger,
+		tenantID:      tenantID,
+		tenantIDFile:  tenantIDFile,
+		writeClient:   writeClient,
+		readClient:    v1.NewAPI(readClient),
+		readRawClient: &http.Client{Transport: readRT},
+		writeCircuit:  newCircuitBreaker(cfg.WriteCircuitThreshold, cfg.WriteCircuitCooldown, metrics.writeCircuitState),
+		writeInflight: writeInflight,
+		writeFailures: atomic.NewInt64(0),
+		seriesCapper:  newSeriesCapper(logger, reg),
+		retryBackoff:  retryBackoff,
+		latencies:     latencies,
+		metrics:       metrics,
+		rt:            rt,
+		cfg:           cfg,
+		logger:        logger,
 	}, nil
 }
 
+// RoundTripper returns the HTTP round tripper used by the client, which sets the tenant ID and
+// the configured headers on each request.
+func (c *Client) RoundTripper() http.RoundTripper {
+	return c.rt
+}
+
+// LatencyPercentiles returns the latency percentiles of the requests sent so far, by operation
+// (write, read or other). The percentiles are computed on a uniform sample of the requests.
+func (c *Client) LatencyPercentiles() map[string]LatencyPercentiles {
+	return c.latencies.percentiles()
+}
+
+// CheckLatency returns an error wrapping ErrLatencyThresholdExceeded if the p99 latency of any
+// operation exceeds the configured max p99 latency. It's a no-op if the max p99 latency is not set.
+func (c *Client) CheckLatency() error {
+	if c.cfg.MaxP99Latency <= 0 {
+		return nil
+	}
+	return checkMaxP99Latency(c.LatencyPercentiles(), c.cfg.MaxP99Latency)
+}
+
+// newTransport returns a transport with the same settings of http.DefaultTransport, except the configured
+// dial timeout, keep-alives setting, idle connections timeout (if set) and the input TLS config (if not nil).
+func newTransport(cfg ClientConfig, tlsCfg *tls.Config) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{
+		Timeout:   cfg.DialTimeout,
+		KeepAlive: 30 * time.Second,
+	}).DialContext
+	transport.DisableKeepAlives = cfg.DisableKeepAlives
+	if cfg.CloseIdleAfter > 0 {
+		transport.IdleConnTimeout = cfg.CloseIdleAfter
+	}
+	if tlsCfg != nil {
+		transport.TLSClientConfig = tlsCfg
+	}
+	return transport
+}
+
+// CloseIdleConnections closes the connections which are not in use by any request, so that the next
+// requests open new connections. It can be called between infrequent test cycles.
+func (c *Client) CloseIdleConnections() {
+	c.writeClient.CloseIdleConnections()
+	c.readRawClient.CloseIdleConnections()
+}
+
+// isSameHost returns whether the input URLs have the same scheme and host, and so can share connections.
+// A nil URL, like the write endpoint when only sharded write endpoints are configured, matches any host.
+func isSameHost(a, b *url.URL) bool {
+	if a == nil || b == nil {
+		return true
+	}
+	return a.Scheme == b.Scheme && a.Host == b.Host
+}
+
+// newHTTP1Transport returns the input transport configured to only use HTTP/1.1.
+func newHTTP1Transport(transport *http.Transport) *http.Transport {
+	transport.ForceAttemptHTTP2 = false
+	// A non-nil empty map disables HTTP/2.
+	transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	return transport
+}
+
 // QueryRange implements MimirClient.
 func (c *Client) QueryRange(ctx context.Context, query string, start, end time.Time, step time.Duration) (model.Matrix, error) {
-	ctx, cancel := context.WithTimeout(ctx, c.cfg.ReadTimeout)
+	ctx, cancel := context.WithTimeout(ctx, c.getRequestTimeout(ctx, c.cfg.ReadTimeout))
 	defer cancel()
 
 	value, _, err := c.readClient.QueryRange(ctx, query, v1.Range{
@@ -111,6 +572,12 @@ func (c *Client) QueryRange(ctx context.Context, query string, start, end time.T
 		return nil, err
 	}
 
+	return toMatrix(value)
+}
+
+// toMatrix returns the input query result as matrix. The matrix is returned as is, without copying it,
+// because results of large range queries can be big.
+func toMatrix(value model.Value) (model.Matrix, error) {
 	if value.Type() != model.ValMatrix {
 		return nil, errors.New("was expecting to get a Matrix")
 	}
@@ -123,72 +590,1151 @@ func (c *Client) QueryRange(ctx context.Context, query string, start, end time.T
 	return matrix, nil
 }
 
-// WriteSeries implements MimirClient.
-func (c *Client) WriteSeries(ctx context.Context, series []prompb.TimeSeries) (int, error) {
-	lastStatusCode := 0
+// Query implements MimirClient.
+func (c *Client) Query(ctx context.Context, query string, ts time.Time) (model.Value, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.getRequestTimeout(ctx, c.cfg.ReadTimeout))
+	defer cancel()
 
-	// Honor the batch size.
-	for len(series) > 0 {
-		end := util_math.Min(len(series), c.cfg.WriteBatchSize)
-		batch := series[0:end]
-		series = series[end:]
+	params := url.Values{}
+	params.Set("query", query)
+	params.Set("time", formatQueryTime(ts))
 
-		var err error
-		lastStatusCode, err = c.sendWriteRequest(ctx, &prompb.WriteRequest{Timeseries: batch})
-		if err != nil {
-			return lastStatusCode, err
+	// The Prometheus API client doesn't support string results, so we decode the response ourselves.
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", c.cfg.ReadBaseEndpoint.String()+"/api/v1/query?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	httpResp, err := c.readRawClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+
+	resp := struct {
+		Status    string `json:"status"`
+		ErrorType string `json:"errorType"`
+		Error     string `json:"error"`
+		Data      struct {
+			ResultType model.ValueType `json:"resultType"`
+			Result     json.RawMessage `json:"result"`
+		} `json:"data"`
+	}{}
+	if err := json.NewDecoder(httpResp.Body).Decode(&resp); err != nil {
+		return nil, errors.Wrapf(err, "failed to decode response with HTTP status %s", httpResp.Status)
+	}
+	if resp.Status != "success" {
+		return nil, errors.Errorf("query failed with status %q, error type %q and error %q", resp.Status, resp.ErrorType, resp.Error)
+	}
+
+	var value model.Value
+	switch resp.Data.ResultType {
+	case model.ValVector:
+		value = &model.Vector{}
+	case model.ValScalar:
+		value = &model.Scalar{}
+	case model.ValString:
+		value = &model.String{}
+	case model.ValMatrix:
+		value = &model.Matrix{}
+	default:
+		return nil, fmt.Errorf("unexpected result type %s", resp.Data.ResultType)
+	}
+
+	if err := json.Unmarshal(resp.Data.Result, value); err != nil {
+		return nil, errors.Wrapf(err, "failed to decode %s result", resp.Data.ResultType)
+	}
+
+	// Return vectors and matrices by value, like the Prometheus API client does.
+	switch v := value.(type) {
+	case *model.Vector:
+		return *v, nil
+	case *model.Matrix:
+		return *v, nil
+	default:
+		return value, nil
+	}
+}
+
+// QueryVector performs an instant query and returns an error if the result is not a vector.
+func (c *Client) QueryVector(ctx context.Context, query string, ts time.Time) (model.Vector, error) {
+	value, err := c.Query(ctx, query, ts)
+	if err != nil {
+		return nil, err
+	}
+
+	vector, ok := value.(model.Vector)
+	if !ok {
+		return nil, fmt.Errorf("was expecting to get a %s but got %s", model.ValVector, value.Type())
+	}
+
+	return vector, nil
+}
+
+// QueryScalar performs an instant query and returns an error if the result is not a scalar.
+func (c *Client) QueryScalar(ctx context.Context, query string, ts time.Time) (*model.Scalar, error) {
+	value, err := c.Query(ctx, query, ts)
+	if err != nil {
+		return nil, err
+	}
+
+	scalar, ok := value.(*model.Scalar)
+	if !ok {
+		return nil, fmt.Errorf("was expecting to get a %s but got %s", model.ValScalar, value.Type())
+	}
+
+	return scalar, nil
+}
+
+// QueryString performs an instant query and returns an error if the result is not a string.
+func (c *Client) QueryString(ctx context.Context, query string, ts time.Time) (*model.String, error) {
+	value, err := c.Query(ctx, query, ts)
+	if err != nil {
+		return nil, err
+	}
+
+	str, ok := value.(*model.String)
+	if !ok {
+		return nil, fmt.Errorf("was expecting to get a %s but got %s", model.ValString, value.Type())
+	}
+
+	return str, nil
+}
+
+// QueryRangeRaw performs a range query and returns the raw response body and status code, without
+// parsing the response. An error is returned only if the request couldn't be executed or the
+// response body couldn't be read, not if the response status code is non-2xx.
+func (c *Client) QueryRangeRaw(ctx context.Context, query string, r v1.Range) ([]byte, int, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.getRequestTimeout(ctx, c.cfg.ReadTimeout))
+	defer cancel()
+
+	httpResp, err := c.doQueryRangeRequest(ctx, query, r, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer httpResp.Body.Close()
+
+	body, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, httpResp.StatusCode, errors.Wrap(err, "failed to read response body")
+	}
+
+	return body, httpResp.StatusCode, nil
+}
+
+// QueryRangeStream performs a range query and calls fn for each series in the result, as soon as it's
+// decoded from the response body, so that the whole result never needs to be held in memory. The
+// iteration is interrupted on the first error returned by fn.
+func (c *Client) QueryRangeStream(ctx context.Context, query string, r v1.Range, fn func(model.SampleStream) error) error {
+	return c.queryRangeStream(ctx, query, r, nil, fn)
+}
+
+func (c *Client) queryRangeStream(ctx context.Context, query string, r v1.Range, header http.Header, fn func(model.SampleStream) error) error {
+	ctx, cancel := context.WithTimeout(ctx, c.getRequestTimeout(ctx, c.cfg.ReadTimeout))
+	defer cancel()
+
+	httpResp, err := c.doQueryRangeRequest(ctx, query, r, header)
+	if err != nil {
+		return err
+	}
+	defer httpResp.Body.Close()
+
+	if err := decodeQueryRangeResponseStream(httpResp.Body, fn); err != nil {
+		if httpResp.StatusCode/100 != 2 {
+			return errors.Wrapf(err, "server returned HTTP status %s", httpResp.Status)
 		}
+		return err
 	}
 
-	return lastStatusCode, nil
+	return nil
 }
 
-func (c *Client) sendWriteRequest(ctx context.Context, req *prompb.WriteRequest) (int, error) {
-	data, err := proto.Marshal(req)
+// BuildInfo is the build information and the features enabled in the target Mimir cluster.
+type BuildInfo struct {
+	Application string            `json:"application"`
+	Version     string            `json:"version"`
+	Revision    string            `json:"revision"`
+	Branch      string            `json:"branch"`
+	GoVersion   string            `json:"goVersion"`
+	Features    map[string]string `json:"features"`
+}
+
+// BuildInfo returns the build information and the features enabled in the target Mimir cluster,
+// fetched from the read endpoint.
+func (c *Client) BuildInfo(ctx context.Context) (BuildInfo, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.getRequestTimeout(ctx, c.cfg.ReadTimeout))
+	defer cancel()
+
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", c.cfg.ReadBaseEndpoint.String()+"/api/v1/status/buildinfo", nil)
 	if err != nil {
-		return 0, err
+		return BuildInfo{}, err
+	}
+
+	httpResp, err := c.readRawClient.Do(httpReq)
+	if err != nil {
+		return BuildInfo{}, err
+	}
+	defer httpResp.Body.Close()
+
+	if httpResp.StatusCode/100 != 2 {
+		truncatedBody, err := io.ReadAll(io.LimitReader(httpResp.Body, maxErrMsgLen))
+		if err != nil {
+			return BuildInfo{}, errors.Wrapf(err, "server returned HTTP status %s and client failed to read response body", httpResp.Status)
+		}
+
+		return BuildInfo{}, fmt.Errorf("server returned HTTP status %s and body %q (truncated to %d bytes)", httpResp.Status, string(truncatedBody), maxErrMsgLen)
+	}
+
+	resp := struct {
+		Status string    `json:"status"`
+		Data   BuildInfo `json:"data"`
+	}{}
+	if err := json.NewDecoder(httpResp.Body).Decode(&resp); err != nil {
+		return BuildInfo{}, errors.Wrap(err, "failed to decode build info response")
 	}
 
-	ctx, cancel := context.WithTimeout(ctx, c.cfg.WriteTimeout)
+	return resp.Data, nil
+}
+
+// LabelValues implements MimirClient. The limit is sent to Mimir as the limit parameter: if Mimir reports
+// the result as truncated, or returns more values than the limit (eg. because the parameter is not
+// supported), the result is flagged as truncated.
+func (c *Client) LabelValues(ctx context.Context, label string, matches []string, start, end time.Time, limit int) (model.LabelValues, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.getRequestTimeout(ctx, c.cfg.ReadTimeout))
 	defer cancel()
 
-	compressed := snappy.Encode(nil, data)
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.cfg.WriteBaseEndpoint.String()+"/api/v1/push", bytes.NewReader(compressed))
+	params := url.Values{}
+	for _, m := range matches {
+		params.Add("match[]", m)
+	}
+	if !start.IsZero() {
+		params.Set("start", formatQueryTime(start))
+	}
+	if !end.IsZero() {
+		params.Set("end", formatQueryTime(end))
+	}
+	if limit > 0 {
+		params.Set("limit", strconv.Itoa(limit))
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", c.cfg.ReadBaseEndpoint.String()+"/api/v1/label/"+url.PathEscape(label)+"/values?"+params.Encode(), nil)
 	if err != nil {
-		// Errors from NewRequest are from unparseable URLs, so are not
-		// recoverable.
-		return 0, err
+		return nil, false, err
 	}
-	httpReq.Header.Add("Content-Encoding", "snappy")
-	httpReq.Header.Set("Content-Type", "application/x-protobuf")
-	httpReq.Header.Set("User-Agent", "mimir-continuous-test")
-	httpReq.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
 
-	httpResp, err := c.writeClient.Do(httpReq)
+	httpResp, err := c.readRawClient.Do(httpReq)
 	if err != nil {
-		return 0, err
+		return nil, false, err
 	}
 	defer httpResp.Body.Close()
 
 	if httpResp.StatusCode/100 != 2 {
 		truncatedBody, err := io.ReadAll(io.LimitReader(httpResp.Body, maxErrMsgLen))
 		if err != nil {
-			return httpResp.StatusCode, errors.Wrapf(err, "server returned HTTP status %s and client failed to read response body", httpResp.Status)
+			return nil, false, errors.Wrapf(err, "server returned HTTP status %s and client failed to read response body", httpResp.Status)
 		}
 
-		return httpResp.StatusCode, fmt.Errorf("server returned HTTP status %s and body %q (truncated to %d bytes)", httpResp.Status, string(truncatedBody), maxErrMsgLen)
+		return nil, false, fmt.Errorf("server returned HTTP status %s and body %q (truncated to %d bytes)", httpResp.Status, string(truncatedBody), maxErrMsgLen)
 	}
 
-	return httpResp.StatusCode, nil
+	resp := struct {
+		Status   string            `json:"status"`
+		Data     model.LabelValues `json:"data"`
+		Warnings []string          `json:"warnings"`
+	}{}
+	if err := json.NewDecoder(httpResp.Body).Decode(&resp); err != nil {
+		return nil, false, errors.Wrap(err, "failed to decode label values response")
+	}
+
+	truncated := false
+	for _, w := range resp.Warnings {
+		if strings.Contains(strings.ToLower(w), "truncated") {
+			truncated = true
+		}
+	}
+	if limit > 0 && len(resp.Data) > limit {
+		resp.Data = resp.Data[:limit]
+		truncated = true
+	}
+
+	return resp.Data, truncated, nil
 }
 
-type clientRoundTripper struct {
-	tenantID string
-	rt       http.RoundTripper
+// QueryRangeCacheProbe runs the same range query twice: first allowing the result to be served from
+// the query results cache, and then with the Cache-Control: no-store header to bypass it. Comparing
+// the two results allows to detect stale or wrongly cached (eg. empty) results.
+func (c *Client) QueryRangeCacheProbe(ctx context.Context, query string, r v1.Range) (cached, uncached model.Matrix, err error) {
+	cached, err = c.queryRangeMatrix(ctx, query, r, nil)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to run cacheable query")
+	}
+
+	uncached, err = c.queryRangeMatrix(ctx, query, r, http.Header{"Cache-Control": []string{"no-store"}})
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to run non-cacheable query")
+	}
+
+	return cached, uncached, nil
 }
 
-// RoundTrip add the tenant ID header required by Mimir.
-func (rt *clientRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
-	req.Header.Set("X-Scope-OrgID", rt.tenantID)
-	return rt.rt.RoundTrip(req)
+func (c *Client) queryRangeMatrix(ctx context.Context, query string, r v1.Range, header http.Header) (model.Matrix, error) {
+	matrix := model.Matrix{}
+	err := c.queryRangeStream(ctx, query, r, header, func(stream model.SampleStream) error {
+		matrix = append(matrix, &stream)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return matrix, nil
+}
+
+func (c *Client) doQueryRangeRequest(ctx context.Context, query string, r v1.Range, header http.Header) (*http.Response, error) {
+	params := url.Values{}
+	params.Set("query", query)
+	params.Set("start", formatQueryTime(r.Start))
+	params.Set("end", formatQueryTime(r.End))
+	params.Set("step", strconv.FormatFloat(r.Step.Seconds(), 'f', -1, 64))
+
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", c.cfg.ReadBaseEndpoint.String()+"/api/v1/query_range?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		httpReq.Header[name] = values
+	}
+
+	return c.readRawClient.Do(httpReq)
+}
+
+// formatQueryTime formats the input time the same way the Prometheus API client does.
+func formatQueryTime(t time.Time) string {
+	return strconv.FormatFloat(float64(t.Unix())+float64(t.Nanosecond())/1e9, 'f', -1, 64)
+}
+
+// WriteSeries implements MimirClient.
+func (c *Client) WriteSeries(ctx context.Context, series []prompb.TimeSeries) (int, error) {
+	series, _, err := c.capSeries(ctx, series)
+	if err != nil {
+		return 0, err
+	}
+
+	return c.writeSeries(ctx, series, nil)
+}
+
+// capSeries returns the input series without the ones exceeding the max number of series of the tenant
+// of the request, along with the index of each returned series in the input ones.
+func (c *Client) capSeries(ctx context.Context, series []prompb.TimeSeries) ([]prompb.TimeSeries, []int, error) {
+	tenantID := c.getTenantID(ctx)
+	maxSeries := 0
+
+	if c.cfg.MaxSeriesPerTenant != 0 {
+		var err error
+
+		maxSeries, err = c.seriesCapper.getMaxSeries(ctx, tenantID, c.cfg.MaxSeriesPerTenant, c.TenantLimits)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "failed to get the max number of series for the tenant")
+		}
+	}
+
+	capped, indexes := c.seriesCapper.apply(tenantID, maxSeries, series)
+	return capped, indexes, nil
+}
+
+// WriteSeriesWithTimestamps writes the input series like WriteSeries, and returns the timestamps, in
+// milliseconds, of the samples successfully written for each input series, so that the written samples
+// can be read back querying their exact timestamps. The timestamps of series not written because of
+// an error or because of the max number of series of the tenant are nil.
+func (c *Client) WriteSeriesWithTimestamps(ctx context.Context, series []prompb.TimeSeries) (int, [][]int64, error) {
+	timestamps := make([][]int64, len(series))
+
+	capped, indexes, err := c.capSeries(ctx, series)
+	if err != nil {
+		return 0, timestamps, err
+	}
+
+	statusCode, err := c.writeSeries(ctx, capped, func(offset int, batch []prompb.TimeSeries) {
+		for i, s := range batch {
+			ts := make([]int64, 0, len(s.Samples))
+			for _, sample := range s.Samples {
+				ts = append(ts, sample.Timestamp)
+			}
+			timestamps[indexes[offset+i]] = ts
+		}
+	})
+
+	return statusCode, timestamps, err
+}
+
+// WriteSeriesWithTimestampFunc writes the input series like WriteSeries, with the timestamp of each sample
+// replaced by the one returned by the input function, given the index of the series and of the sample within
+// the series. The timestamps are in milliseconds. The input series are not modified.
+func (c *Client) WriteSeriesWithTimestampFunc(ctx context.Context, series []prompb.TimeSeries, timestampFunc func(seriesIndex, sampleIndex int) int64) (int, error) {
+	return c.WriteSeries(ctx, withSampleTimestamps(series, timestampFunc))
+}
+
+// WriteSeriesForTenants writes each input series for the tenant at the same index in tenantIDs. Series are
+// grouped by tenant, and each group is written like WriteSeries with the tenant injected in the context, so
+// that a request is sent for each tenant. An empty tenant ID means the tenant configured in the client.
+// Groups are written in the order the tenants first appear, and the write of the remaining groups stops
+// on the first error.
+func (c *Client) WriteSeriesForTenants(ctx context.Context, series []prompb.TimeSeries, tenantIDs []string) (int, error) {
+	if len(series) != len(tenantIDs) {
+		return 0, fmt.Errorf("the number of series (%d) doesn't match the number of tenants (%d)", len(series), len(tenantIDs))
+	}
+
+	var (
+		tenants []string
+		groups  = map[string][]prompb.TimeSeries{}
+	)
+
+	for i, tenantID := range tenantIDs {
+		if _, ok := groups[tenantID]; !ok {
+			tenants = append(tenants, tenantID)
+		}
+		groups[tenantID] = append(groups[tenantID], series[i])
+	}
+
+	lastStatusCode := 0
+	for _, tenantID := range tenants {
+		tenantCtx := ctx
+		if tenantID != "" {
+			tenantCtx = user.InjectOrgID(ctx, tenantID)
+		}
+
+		var err error
+		lastStatusCode, err = c.WriteSeries(tenantCtx, groups[tenantID])
+		if err != nil {
+			return lastStatusCode, errors.Wrapf(err, "failed to write series for tenant %q", c.getTenantID(tenantCtx))
+		}
+	}
+
+	return lastStatusCode, nil
+}
+
+// writeSeries writes the input series in batches. The optional onBatchWritten function is called for each
+// batch successfully written, with the offset of the batch in the input series.
+func (c *Client) writeSeries(ctx context.Context, series []prompb.TimeSeries, onBatchWritten func(offset int, batch []prompb.TimeSeries)) (int, error) {
+	// No request is sent when there are no series to write, so the outcome is explicit instead
+	// of a status code 0 which could be mistaken for a failure.
+	if len(series) == 0 {
+		if c.cfg.AllowEmptyWrite {
+			return http.StatusOK, nil
+		}
+		return 0, ErrEmptyWrite
+	}
+
+	lastStatusCode := 0
+	offset := 0
+	batches := 0
+	totalSeries := len(series)
+
+	// The total timeout is applied to all batches, on top of the per-request timeout.
+	parentCtx := ctx
+	if c.cfg.WriteTotalTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.cfg.WriteTotalTimeout)
+		defer cancel()
+	}
+
+	// The number of retries of the current batch honoring the Retry-After header.
+	retryAfterRetries := 0
+
+	// The number of retries of the current batch failed because the connection has been closed by the server.
+	connectionErrorRetries := 0
+	var retryAfterErr *retryAfterError
+
+	// The backoff is shared across all batches, so that the overall number of
+	// retries is bounded when pausing writes on unhealthy write endpoint.
+	var unhealthyBackoff *backoff.Backoff
+
+	// The batch size may be reduced if the request payload is too large.
+	batchSize := c.cfg.WriteBatchSize
+
+	if c.cfg.SortLabels && !isLabelsOrderPreserved(ctx) {
+		series = sortSeriesLabels(series)
+	}
+
+	// Honor the batch size.
+	for len(series) > 0 {
+		end := util_math.Min(len(series), batchSize)
+		batch := series[0:end]
+
+		if c.cfg.ValidateBatch {
+			if err := validateSeriesBatch(batch, c.cfg.MaxLabelValueLength, c.cfg.MaxSampleAge, time.Now()); err != nil {
+				var validationErr *batchValidationError
+				if errors.As(err, &validationErr) {
+					c.metrics.rejectedBatches.WithLabelValues(validationErr.reason).Inc()
+				}
+				return 0, err
+			}
+		}
+
+		// Batches exceeding the max request size are split before being sent, so that the circuit breaker
+		// only records the outcome of the requests actually sent. A batch is only rejected if it can't be
+		// split any further, because the split batches are written anyway.
+		req := &prompb.WriteRequest{Timeseries: batch}
+		if err := c.checkWriteRequestSize(req); err != nil {
+			if len(batch) > 1 {
+				// Split the batch and retry.
+				batchSize = len(batch) / 2
+				continue
+			}
+
+			c.metrics.rejectedBatches.WithLabelValues(rejectReasonTooLarge).Inc()
+			return 0, err
+		}
+
+		if !c.writeCircuit.allow() {
+			return 0, ErrCircuitOpen
+		}
+
+		var err error
+		lastStatusCode, err = c.sendWriteRequest(ctx, req)
+
+		// Only network and 5xx errors are a symptom of an unhealthy cluster.
+		c.writeCircuit.record(err == nil || (lastStatusCode != 0 && lastStatusCode/100 != 5))
+
+		consecutiveFailures := int64(0)
+		if err != nil && lastStatusCode/100 == 5 {
+			consecutiveFailures = c.writeFailures.Inc()
+		} else {
+			c.writeFailures.Store(0)
+		}
+		if c.cfg.WriteRetryAfterMaxWait > 0 && retryAfterRetries < maxRetryAfterRetries && errors.As(err, &retryAfterErr) {
+			retryAfterRetries++
+
+			wait := retryAfterErr.retryAfter
+			if wait > c.cfg.WriteRetryAfterMaxWait {
+				wait = c.cfg.WriteRetryAfterMaxWait
+			}
+
+			level.Warn(c.logger).Log("msg", "Write request has been throttled, retrying after the time requested by the server", "status_code", lastStatusCode, "retry_after", wait, "err", err)
+			if waitWithContext(ctx, wait) {
+				// Retry the same batch.
+				continue
+			}
+		}
+		if connectionErrorRetries < maxConnectionErrorRetries && isConnectionClosedError(err) {
+			connectionErrorRetries++
+
+			// The request failed before getting a response, so retrying it on a new connection is expected to succeed.
+			delay := c.retryBackoff.NextDelay(connectionErrorRetries)
+			level.Warn(c.logger).Log("msg", "Write request failed because the connection has been closed by the server, retrying", "backoff", delay, "err", err)
+			if waitWithContext(ctx, delay) {
+				// Retry the same batch.
+				continue
+			}
+		}
+		// Writes are paused only on repeated 5xx errors, so that a transient failure doesn't pause them.
+		if err != nil && c.cfg.PauseOnUnhealthy && consecutiveFailures >= int64(c.cfg.PauseOnUnhealthyAfter) {
+			if unhealthyBackoff == nil {
+				unhealthyBackoff = backoff.New(ctx, c.cfg.PauseOnUnhealthyBackoff)
+			}
+
+			level.Warn(c.logger).Log("msg", "Pausing writes because the write endpoint looks unhealthy", "status_code", lastStatusCode, "err", err)
+			if c.waitUntilWriteEndpointReady(ctx, unhealthyBackoff) {
+				// Retry the same batch.
+				continue
+			}
+		}
+		if err != nil && ctx.Err() != nil && parentCtx.Err() == nil {
+			return lastStatusCode, errors.Wrapf(context.DeadlineExceeded, "write total timeout of %s exceeded after writing %d batches (%d out of %d series)", c.cfg.WriteTotalTimeout, batches, offset, totalSeries)
+		}
+		if err != nil {
+			return lastStatusCode, err
+		}
+
+		if onBatchWritten != nil {
+			onBatchWritten(offset, batch)
+		}
+
+		series = series[end:]
+		offset += end
+		batches++
+		retryAfterRetries = 0
+		if connectionErrorRetries > 0 {
+			c.retryBackoff.Reset()
+			connectionErrorRetries = 0
+		}
+	}
+
+	return lastStatusCode, nil
+}
+
+// The reasons a batch of series is rejected by the client-side validation.
+const (
+	rejectReasonDuplicate    = "duplicate"
+	rejectReasonInvalidLabel = "invalid_label"
+	rejectReasonTooLarge     = "too_large"
+	rejectReasonTooOld       = "too_old"
+)
+
+// batchValidationError is returned when a batch of series is rejected by the client-side validation.
+type batchValidationError struct {
+	reason string
+	msg    string
+}
+
+func (e *batchValidationError) Error() string {
+	return e.msg
+}
+
+func newBatchValidationError(reason, format string, args ...interface{}) error {
+	return &batchValidationError{reason: reason, msg: fmt.Sprintf(format, args...)}
+}
+
+// validateSeriesBatch returns an error if the input batch contains series with the same labels, regardless
+// of the labels order, series with invalid labels or samples older than maxSampleAge. Label values longer
+// than maxLabelValueLength are invalid, unless maxLabelValueLength is 0. The sample age is not checked if
+// maxSampleAge is 0.
+func validateSeriesBatch(batch []prompb.TimeSeries, maxLabelValueLength int, maxSampleAge time.Duration, now time.Time) error {
+	seen := make(map[string]struct{}, len(batch))
+
+	for _, series := range batch {
+		lbls := make(labels.Labels, 0, len(series.Labels))
+		for _, l := range series.Labels {
+			lbls = append(lbls, labels.Label{Name: l.Name, Value: l.Value})
+		}
+		sort.Sort(lbls)
+
+		key := lbls.String()
+		if _, ok := seen[key]; ok {
+			return newBatchValidationError(rejectReasonDuplicate, "the write request contains the duplicate series %s", key)
+		}
+		seen[key] = struct{}{}
+
+		for _, l := range lbls {
+			if !model.LabelName(l.Name).IsValid() {
+				return newBatchValidationError(rejectReasonInvalidLabel, "the series %s has the invalid label name %q", key, l.Name)
+			}
+			if !utf8.ValidString(l.Value) {
+				return newBatchValidationError(rejectReasonInvalidLabel, "the series %s has the label %s with an invalid UTF-8 value", key, l.Name)
+			}
+			if maxLabelValueLength > 0 && len(l.Value) > maxLabelValueLength {
+				return newBatchValidationError(rejectReasonInvalidLabel, "the series %s has the label %s with a value longer than %d bytes", key, l.Name, maxLabelValueLength)
+			}
+		}
+
+		if maxSampleAge > 0 {
+			minTimestamp := now.Add(-maxSampleAge).UnixMilli()
+			for _, sample := range series.Samples {
+				if sample.Timestamp < minTimestamp {
+					return newBatchValidationError(rejectReasonTooOld, "the series %s has a sample at timestamp %d older than %s", key, sample.Timestamp, maxSampleAge)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// retryAfterError is returned when a write request failed and the server requested to retry it after some time.
+type retryAfterError struct {
+	err        error
+	retryAfter time.Duration
+}
+
+func (e *retryAfterError) Error() string {
+	return e.err.Error()
+}
+
+func (e *retryAfterError) Unwrap() error {
+	return e.err
+}
+
+// parseRetryAfter parses the input Retry-After header value, which can be either a number of seconds or
+// an HTTP date, and returns the time to wait from now. Returns false if the value is invalid.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	date, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	if wait := date.Sub(now); wait > 0 {
+		return wait, true
+	}
+	return 0, true
+}
+
+// isConnectionClosedError returns whether the input error is caused by the server closing the connection
+// while the request was in-flight, like an HTTP/2 GOAWAY or a connection reset.
+func isConnectionClosedError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, syscall.ECONNRESET) {
+		return true
+	}
+
+	msg := err.Error()
+	return strings.Contains(msg, "http2: server sent GOAWAY") || strings.Contains(msg, "connection reset by peer")
+}
+
+// waitWithContext waits for the input duration. Returns false without waiting if the context deadline
+// would expire before, or if the context is canceled while waiting.
+func waitWithContext(ctx context.Context, wait time.Duration) bool {
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+		return false
+	}
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(wait):
+		return true
+	}
+}
+
+// waitUntilWriteEndpointReady polls the /ready endpoint on the write path until it's ready
+// or the backoff gives up. Returns whether the write endpoint became ready.
+func (c *Client) waitUntilWriteEndpointReady(ctx context.Context, b *backoff.Backoff) bool {
+	for b.Ongoing() {
+		b.Wait()
+
+		if c.isWriteEndpointReady(ctx) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *Client) isWriteEndpointReady(ctx context.Context) bool {
+	ctx, cancel := context.WithTimeout(ctx, c.getRequestTimeout(ctx, c.cfg.WriteTimeout))
+	defer cancel()
+
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", c.getWriteEndpoint(ctx)+"/ready", nil)
+	if err != nil {
+		return false
+	}
+
+	httpResp, err := c.writeClient.Do(httpReq)
+	if err != nil {
+		return false
+	}
+	defer httpResp.Body.Close()
+	_, _ = io.Copy(io.Discard, httpResp.Body)
+
+	return httpResp.StatusCode == http.StatusOK
+}
+
+// checkWriteRequestSize returns ErrPayloadTooLarge if the input write request exceeds the max request size.
+func (c *Client) checkWriteRequestSize(req *prompb.WriteRequest) error {
+	if size := req.Size(); c.cfg.WriteMaxRequestSize > 0 && size > c.cfg.WriteMaxRequestSize {
+		return errors.Wrapf(ErrPayloadTooLarge, "%d series with size %d bytes exceed the limit of %d bytes", len(req.Timeseries), size, c.cfg.WriteMaxRequestSize)
+	}
+	return nil
+}
+
+// sendWriteRequest sends the input write request. The request size is checked by writeSeries, which splits
+// the batches exceeding the max request size before sending them.
+func (c *Client) sendWriteRequest(ctx context.Context, req *prompb.WriteRequest) (int, error) {
+	data, err := proto.Marshal(req)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to marshal write request")
+	}
+
+	compressed, err := snappyEncode(data, c.cfg.SnappyFramed)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to compress write request")
+	}
+
+	return c.sendCompressedWriteRequest(ctx, compressed, countSamples(req))
+}
+
+// snappyEncode compresses the input data with the snappy block format, or the snappy framing format if framed is true.
+func snappyEncode(data []byte, framed bool) ([]byte, error) {
+	if !framed {
+		return snappy.Encode(nil, data), nil
+	}
+
+	buf := bytes.Buffer{}
+	w := snappy.NewBufferedWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// snappyFramedStreamHeader is the stream identifier chunk every stream in the snappy framing format starts with.
+var snappyFramedStreamHeader = []byte("\xff\x06\x00\x00sNaPpY")
+
+// ReplayWriteFile sends the snappy-compressed remote write request body stored in the input file, as is,
+// so that a captured write request can be faithfully reproduced. Both the snappy block format and the
+// snappy framing format are supported. The file content is decoded only to check it's a valid write
+// request. Returns the response status code and optionally an error.
+func (c *Client) ReplayWriteFile(ctx context.Context, path string) (int, error) {
+	compressed, err := os.ReadFile(path)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to read write request file")
+	}
+
+	data, err := snappyDecode(compressed)
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to decompress write request file %s", path)
+	}
+
+	req := &prompb.WriteRequest{}
+	if err := proto.Unmarshal(data, req); err != nil {
+		return 0, errors.Wrapf(err, "failed to unmarshal write request file %s", path)
+	}
+
+	return c.sendCompressedWriteRequest(ctx, compressed, countSamples(req))
+}
+
+// snappyDecode decompresses the input data, compressed with either the snappy block format or the snappy
+// framing format, detected from the stream header.
+func snappyDecode(compressed []byte) ([]byte, error) {
+	if !bytes.HasPrefix(compressed, snappyFramedStreamHeader) {
+		return snappy.Decode(nil, compressed)
+	}
+
+	return io.ReadAll(snappy.NewReader(bytes.NewReader(compressed)))
+}
+
+// sendCompressedWriteRequest sends the input snappy-compressed remote write request body, containing
+// numSamples samples.
+func (c *Client) sendCompressedWriteRequest(ctx context.Context, compressed []byte, numSamples int) (int, error) {
+	// Wait until the number of in-flight write requests is below the limit. The time spent waiting
+	// doesn't count in the request timeout.
+	if c.writeInflight != nil {
+		c.metrics.waitingRequests.Inc()
+		err := c.writeInflight.Acquire(ctx, 1)
+		c.metrics.waitingRequests.Dec()
+
+		if err != nil {
+			return 0, errors.Wrap(err, "failed to wait for in-flight write requests to complete")
+		}
+		defer c.writeInflight.Release(1)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.getRequestTimeout(ctx, c.cfg.WriteTimeout))
+	defer cancel()
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.getWriteEndpoint(ctx)+"/api/v1/push", bytes.NewReader(compressed))
+	if err != nil {
+		// Errors from NewRequest are from unparseable URLs, so are not
+		// recoverable.
+		return 0, err
+	}
+	c.cfg.WriteMalformed.setContentHeaders(httpReq.Header)
+	httpReq.Header.Set("User-Agent", "mimir-continuous-test")
+	httpReq.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+
+	httpResp, err := c.writeClient.Do(httpReq)
+	if err != nil {
+		return 0, err
+	}
+	defer httpResp.Body.Close()
+
+	if remaining, ok := parseWriteQuotaRemaining(httpResp.Header); ok {
+		c.metrics.writeQuotaLeft.Set(remaining)
+	}
+
+	if isPartialWriteStatusCode(httpResp.StatusCode) {
+		body, err := io.ReadAll(io.LimitReader(httpResp.Body, maxPartialWriteErrBodyLen))
+		if err != nil {
+			return httpResp.StatusCode, errors.Wrapf(err, "server returned HTTP status %s and client failed to read response body", httpResp.Status)
+		}
+
+		return httpResp.StatusCode, parsePartialWriteError(httpResp.StatusCode, string(body), numSamples)
+	}
+
+	if httpResp.StatusCode/100 != 2 {
+		truncatedBody, err := io.ReadAll(io.LimitReader(httpResp.Body, maxErrMsgLen))
+		if err != nil {
+			return httpResp.StatusCode, errors.Wrapf(err, "server returned HTTP status %s and client failed to read response body", httpResp.Status)
+		}
+
+		err = fmt.Errorf("server returned HTTP status %s and body %q (truncated to %d bytes)", httpResp.Status, string(truncatedBody), maxErrMsgLen)
+		if httpResp.StatusCode == http.StatusTooManyRequests || httpResp.StatusCode == http.StatusServiceUnavailable {
+			if retryAfter, ok := parseRetryAfter(httpResp.Header.Get("Retry-After"), time.Now()); ok {
+				err = &retryAfterError{err: err, retryAfter: retryAfter}
+			}
+		}
+
+		return httpResp.StatusCode, err
+	}
+
+	// Mimir returns an empty body on successful writes, so a non-empty body (eg. an HTML page)
+	// is a symptom of a misconfigured proxy in front of Mimir.
+	truncatedBody, err := io.ReadAll(io.LimitReader(httpResp.Body, maxErrMsgLen))
+	if err != nil {
+		return httpResp.StatusCode, errors.Wrapf(err, "server returned HTTP status %s and client failed to read response body", httpResp.Status)
+	}
+
+	if contentType := httpResp.Header.Get("Content-Type"); len(truncatedBody) > 0 || strings.Contains(contentType, "text/html") {
+		if c.cfg.StrictWriteResponse {
+			return httpResp.StatusCode, fmt.Errorf("server returned HTTP status %s with unexpected content type %q and body %q (truncated to %d bytes)", httpResp.Status, contentType, string(truncatedBody), maxErrMsgLen)
+		}
+
+		level.Warn(c.logger).Log("msg", "Write request succeeded but the server returned an unexpected response", "status_code", httpResp.StatusCode, "content_type", contentType, "body", string(truncatedBody))
+	}
+
+	// Warnings (eg. deprecations or soft limits) are reported on successful writes, so that they're
+	// noticed before turning into failures.
+	for _, w := range parseWarningHeaders(httpResp.Header) {
+		c.metrics.writeWarnings.Inc()
+		level.Warn(c.logger).Log("msg", "Write request succeeded with a warning from the server", "status_code", httpResp.StatusCode, "warning_code", w.Code, "warning", w.Text)
+	}
+
+	return httpResp.StatusCode, nil
+}
+
+// countSamples returns the number of samples in the input write request.
+func countSamples(req *prompb.WriteRequest) int {
+	numSamples := 0
+	for _, series := range req.Timeseries {
+		numSamples += len(series.Samples)
+	}
+	return numSamples
+}
+
+// getTenantID returns the tenant ID injected in the context, if any, or the configured one.
+func (c *Client) getTenantID(ctx context.Context) string {
+	if tenantID, err := user.ExtractOrgID(ctx); err == nil {
+		return tenantID
+	}
+	if c.tenantIDFile != nil {
+		return c.tenantIDFile.get()
+	}
+	return c.tenantID
+}
+
+type contextKey int
+
+// preserveLabelsOrderKey is the context key used to write series with the labels in the input order.
+const preserveLabelsOrderKey contextKey = iota
+
+// withPreservedLabelsOrder returns a context instructing the client to write series with the labels
+// in the input order, even if sorting labels is enabled.
+func withPreservedLabelsOrder(ctx context.Context) context.Context {
+	return context.WithValue(ctx, preserveLabelsOrderKey, true)
+}
+
+func isLabelsOrderPreserved(ctx context.Context) bool {
+	preserved, _ := ctx.Value(preserveLabelsOrderKey).(bool)
+	return preserved
+}
+
+// getWriteEndpoint returns the base endpoint on the write path for the tenant of the request.
+func (c *Client) getWriteEndpoint(ctx context.Context) string {
+	if len(c.cfg.WriteShardedEndpoints) == 0 {
+		return c.cfg.WriteBaseEndpoint.String()
+	}
+
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(c.getTenantID(ctx)))
+	return c.cfg.WriteShardedEndpoints[hash.Sum32()%uint32(len(c.cfg.WriteShardedEndpoints))]
+}
+
+// getRequestTimeout returns the timeout to use for a single request, guaranteeing it never exceeds the time
+// left before the deadline of the input context. The timeout is logged when capped, so that requests timing
+// out because of the overall budget of the caller can be told apart from the ones exceeding their own timeout.
+func (c *Client) getRequestTimeout(ctx context.Context, timeout time.Duration) time.Duration {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return timeout
+	}
+
+	if remaining := time.Until(deadline); remaining < timeout {
+		level.Debug(c.logger).Log("msg", "Request timeout capped to the time left before the deadline of the caller", "timeout", timeout, "capped_timeout", remaining)
+		return remaining
+	}
+	return timeout
+}
+
+// headerTemplate is an HTTP header whose value can reference the request tenant ID.
+type headerTemplate struct {
+	name  string
+	value string
+}
+
+func parseHeaderTemplates(templates []string) ([]headerTemplate, error) {
+	out := make([]headerTemplate, 0, len(templates))
+
+	for _, template := range templates {
+		parts := strings.SplitN(template, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, errors.Errorf("invalid header template %q: expected format is name=value", template)
+		}
+
+		out = append(out, headerTemplate{name: strings.TrimSpace(parts[0]), value: parts[1]})
+	}
+
+	return out, nil
+}
+
+// expand returns the header value with the tenant placeholder replaced by the input tenant ID.
+func (h headerTemplate) expand(tenantID string) string {
+	return strings.ReplaceAll(h.value, "{tenant}", tenantID)
+}
+
+type clientRoundTripper struct {
+	tenantID        string
+	tenantIDFile    *tenantIDFileReader
+	jwt             string
+	headerTemplates []headerTemplate
+	queryTimeout    time.Duration
+	origin          string
+	referer         string
+	originOnReads   bool
+	compressReads   bool
+	rt              http.RoundTripper
+
+	metrics *clientMetrics
+
+	// The latency of the requests, by operation.
+	latencies *latencyTracker
+
+	// The tenants which requests metrics are labelled with.
+	metricsTenants map[string]struct{}
+
+	// The success ratio of the most recent requests, by operation.
+	successRatios map[string]*slidingWindowRatio
+}
+
+// RoundTrip add the tenant ID header required by Mimir. The tenant ID injected in the request
+// context, if any, takes precedence over the configured one. The configured query timeout, if
+// any, is set as query parameter of range queries.
+func (rt *clientRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	tenantID, err := user.ExtractOrgID(req.Context())
+	if err != nil {
+		tenantID = rt.getTenantID()
+	}
+
+	req.Header.Set("X-Scope-OrgID", tenantID)
+	for _, h := range rt.headerTemplates {
+		req.Header.Set(h.name, h.expand(tenantID))
+	}
+	if rt.jwt != "" {
+		req.Header.Set("Authorization", "Bearer "+rt.jwt)
+	}
+	if rt.queryTimeout > 0 && strings.HasSuffix(req.URL.Path, "/api/v1/query_range") {
+		query := req.URL.Query()
+		query.Set("timeout", strconv.FormatFloat(rt.queryTimeout.Seconds(), 'f', -1, 64))
+		req.URL.RawQuery = query.Encode()
+	}
+
+	operation := getRequestOperation(req.URL.Path)
+	if operation == operationWrite || rt.originOnReads {
+		if rt.origin != "" {
+			req.Header.Set("Origin", rt.origin)
+		}
+		if rt.referer != "" {
+			req.Header.Set("Referer", rt.referer)
+		}
+	}
+	if rt.compressReads && operation == operationRead && req.Method == http.MethodPost && req.Body != nil {
+		if err := gzipRequestBody(req); err != nil {
+			return nil, err
+		}
+	}
+
+	inflightOperation := operation
+	if inflightOperation == "" {
+		inflightOperation = operationOther
+	}
+	inflight := rt.metrics.inflightRequests.WithLabelValues(inflightOperation)
+	inflight.Inc()
+
+	start := time.Now()
+	resp, err := rt.rt.RoundTrip(req)
+	inflight.Dec()
+	rt.latencies.observe(inflightOperation, time.Since(start))
+
+	statusCode := "error"
+	if err == nil {
+		statusCode = strconv.Itoa(resp.StatusCode)
+	}
+	rt.metrics.requestDuration.WithLabelValues(req.URL.Path, statusCode, rt.getMetricsTenantLabel(tenantID)).Observe(time.Since(start).Seconds())
+
+	if operation != "" {
+		success := err == nil && resp.StatusCode/100 == 2
+		rt.metrics.successRatio.WithLabelValues(operation).Set(rt.successRatios[operation].observe(success))
+	}
+
+	return resp, err
+}
+
+// CloseIdleConnections closes the idle connections of the wrapped round tripper, if supported.
+func (rt *clientRoundTripper) CloseIdleConnections() {
+	if closer, ok := rt.rt.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
+
+// gzipRequestBody replaces the body of the input request with its gzip-compressed version.
+func gzipRequestBody(req *http.Request) error {
+	body, err := io.ReadAll(req.Body)
+	_ = req.Body.Close()
+	if err != nil {
+		return errors.Wrap(err, "failed to read request body")
+	}
+
+	buf := bytes.Buffer{}
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write(body); err != nil {
+		return errors.Wrap(err, "failed to compress request body")
+	}
+	if err := gz.Close(); err != nil {
+		return errors.Wrap(err, "failed to compress request body")
+	}
+
+	compressed := buf.Bytes()
+	req.Body = io.NopCloser(bytes.NewReader(compressed))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(compressed)), nil
+	}
+	req.ContentLength = int64(len(compressed))
+	req.Header.Set("Content-Encoding", "gzip")
+	return nil
+}
+
+// getRequestOperation returns the operation tracked in the success ratio for the input request
+// path, or an empty string if the request is not tracked.
+func getRequestOperation(path string) string {
+	switch {
+	case strings.HasSuffix(path, "/api/v1/push"):
+		return operationWrite
+	case strings.HasSuffix(path, "/api/v1/query_range"), strings.HasSuffix(path, "/api/v1/query"):
+		return operationRead
+	default:
+		return ""
+	}
+}
+
+// getTenantID returns the configured tenant ID, read from the tenant ID file if set.
+func (rt *clientRoundTripper) getTenantID() string {
+	if rt.tenantIDFile != nil {
+		return rt.tenantIDFile.get()
+	}
+	return rt.tenantID
+}
+
+func (rt *clientRoundTripper) getMetricsTenantLabel(tenantID string) string {
+	if _, ok := rt.metricsTenants[tenantID]; ok {
+		return tenantID
+	}
+	// The configured tenant is always tracked, even if it has been rotated.
+	if rt.tenantIDFile != nil && tenantID == rt.tenantIDFile.get() {
+		return tenantID
+	}
+	return "other"
 }
@@ -3,10 +3,24 @@
 package continuoustest
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
 	"testing"
 	"time"
 
@@ -14,11 +28,18 @@ import (
 	"github.com/gogo/protobuf/proto"
 	"github.com/golang/snappy"
 	"github.com/grafana/dskit/flagext"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/prompb"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+	"go.uber.org/atomic"
+
+	"github.com/grafana/mimir/pkg/util/version"
 )
 
 func TestClient_WriteSeries(t *testing.T) {
@@ -51,7 +72,7 @@ func TestClient_WriteSeries(t *testing.T) {
 	require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
 	require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
 
-	c, err := NewClient(cfg, log.NewNopLogger())
+	c, err := NewClient(cfg, log.NewNopLogger(), nil)
 	require.NoError(t, err)
 
 	ctx := context.Background()
@@ -85,6 +106,21 @@ func TestClient_WriteSeries(t *testing.T) {
 		assert.Equal(t, series[20:22], receivedRequests[2].Timeseries)
 	})
 
+	t.Run("write series of multiple metric names in multiple batches", func(t *testing.T) {
+		receivedRequests = nil
+		nextStatusCode = http.StatusOK
+
+		// The batch size is honored regardless of the metric names.
+		series := generateMultiMetricSeries([]string{"metric_1", "metric_2", "metric_3"}, []prompb.Label{{Name: "instance", Value: "instance-1"}}, now, 4)
+		statusCode, err := c.WriteSeries(ctx, series)
+		require.NoError(t, err)
+		assert.Equal(t, 200, statusCode)
+
+		require.Len(t, receivedRequests, 2)
+		assert.Equal(t, series[0:10], receivedRequests[0].Timeseries)
+		assert.Equal(t, series[10:12], receivedRequests[1].Timeseries)
+	})
+
 	t.Run("request failed with 4xx error", func(t *testing.T) {
 		receivedRequests = nil
 		nextStatusCode = http.StatusBadRequest
@@ -106,17 +142,2575 @@ func TestClient_WriteSeries(t *testing.T) {
 	})
 }
 
-// ClientMock mocks MimirClient.
-type ClientMock struct {
-	mock.Mock
+func TestClient_WriteSeriesWithTimestamps(t *testing.T) {
+	var (
+		receivedRequests []prompb.WriteRequest
+		failRequestIdx   = -1
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		body, err := ioutil.ReadAll(request.Body)
+		require.NoError(t, err)
+		body, err = snappy.Decode(nil, body)
+		require.NoError(t, err)
+
+		var req prompb.WriteRequest
+		require.NoError(t, proto.Unmarshal(body, &req))
+
+		if len(receivedRequests) == failRequestIdx {
+			writer.WriteHeader(http.StatusInternalServerError)
+		}
+		receivedRequests = append(receivedRequests, req)
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := ClientConfig{}
+	flagext.DefaultValues(&cfg)
+	cfg.WriteBatchSize = 10
+	require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+	require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+	c, err := NewClient(cfg, log.NewNopLogger(), nil)
+	require.NoError(t, err)
+
+	// Generate series with multiple samples each, at different timestamps.
+	now := time.Now()
+	series := generateSineWaveSeries("test", now, 25)
+	for i := range series {
+		series[i].Samples = append(series[i].Samples, prompb.Sample{Value: 1, Timestamp: now.Add(time.Duration(i) * time.Second).UnixMilli()})
+	}
+
+	t.Run("should return the timestamps of the samples sent for each series", func(t *testing.T) {
+		receivedRequests = nil
+		failRequestIdx = -1
+
+		statusCode, timestamps, err := c.WriteSeriesWithTimestamps(context.Background(), series)
+		require.NoError(t, err)
+		assert.Equal(t, 200, statusCode)
+		require.Len(t, receivedRequests, 3)
+
+		var sent [][]int64
+		for _, req := range receivedRequests {
+			for _, s := range req.Timeseries {
+				var ts []int64
+				for _, sample := range s.Samples {
+					ts = append(ts, sample.Timestamp)
+				}
+				sent = append(sent, ts)
+			}
+		}
+
+		assert.Equal(t, sent, timestamps)
+		assert.Equal(t, []int64{now.UnixMilli(), now.Add(24 * time.Second).UnixMilli()}, timestamps[24])
+	})
+
+	t.Run("should not return the timestamps of series failed to be written", func(t *testing.T) {
+		receivedRequests = nil
+		failRequestIdx = 1
+
+		statusCode, timestamps, err := c.WriteSeriesWithTimestamps(context.Background(), series)
+		require.Error(t, err)
+		assert.Equal(t, 500, statusCode)
+		require.Len(t, receivedRequests, 2)
+		require.Len(t, timestamps, len(series))
+
+		for i := range series {
+			if i < cfg.WriteBatchSize {
+				assert.Equal(t, []int64{series[i].Samples[0].Timestamp, series[i].Samples[1].Timestamp}, timestamps[i])
+			} else {
+				assert.Nil(t, timestamps[i])
+			}
+		}
+	})
 }
 
-func (m *ClientMock) WriteSeries(ctx context.Context, series []prompb.TimeSeries) (int, error) {
-	args := m.Called(ctx, series)
-	return args.Int(0), args.Error(1)
+func TestClient_WriteSeriesWithTimestampFunc(t *testing.T) {
+	var receivedRequests []prompb.WriteRequest
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		body, err := ioutil.ReadAll(request.Body)
+		require.NoError(t, err)
+
+		body, err = snappy.Decode(nil, body)
+		require.NoError(t, err)
+
+		var req prompb.WriteRequest
+		require.NoError(t, proto.Unmarshal(body, &req))
+		receivedRequests = append(receivedRequests, req)
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := ClientConfig{}
+	flagext.DefaultValues(&cfg)
+	require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+	require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+	c, err := NewClient(cfg, log.NewNopLogger(), nil)
+	require.NoError(t, err)
+
+	// Generate 2 series with 3 samples each.
+	now := time.Now()
+	series := generateSineWaveSeries("test", now, 2)
+	for i := range series {
+		series[i].Samples = append(series[i].Samples, series[i].Samples[0], series[i].Samples[0])
+	}
+	input := withSampleTimestamps(series, func(_, _ int) int64 { return now.UnixMilli() })
+
+	// A burst of samples 1ms apart, followed by a gap, shifted by 1s for each series.
+	base := now.UnixMilli()
+	timestampFunc := func(seriesIndex, sampleIndex int) int64 {
+		offsets := []int64{0, 1, 60000}
+		return base + int64(seriesIndex)*1000 + offsets[sampleIndex]
+	}
+
+	statusCode, err := c.WriteSeriesWithTimestampFunc(context.Background(), input, timestampFunc)
+	require.NoError(t, err)
+	assert.Equal(t, 200, statusCode)
+
+	require.Len(t, receivedRequests, 1)
+	require.Len(t, receivedRequests[0].Timeseries, 2)
+
+	for seriesIndex, s := range receivedRequests[0].Timeseries {
+		assert.Equal(t, series[seriesIndex].Labels, s.Labels)
+		require.Len(t, s.Samples, 3)
+
+		for sampleIndex, sample := range s.Samples {
+			assert.Equal(t, timestampFunc(seriesIndex, sampleIndex), sample.Timestamp)
+			assert.Equal(t, series[seriesIndex].Samples[sampleIndex].Value, sample.Value)
+		}
+	}
+
+	// The input series should not be modified.
+	for _, s := range input {
+		for _, sample := range s.Samples {
+			assert.Equal(t, now.UnixMilli(), sample.Timestamp)
+		}
+	}
 }
 
-func (m *ClientMock) QueryRange(ctx context.Context, query string, start, end time.Time, step time.Duration) (model.Matrix, error) {
-	args := m.Called(ctx, query, start, end, step)
-	return args.Get(0).(model.Matrix), args.Error(1)
+func TestClient_WriteSeries_ShouldHandleEmptyInput(t *testing.T) {
+	requests := atomic.NewInt32(0)
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		requests.Inc()
+	}))
+	t.Cleanup(server.Close)
+
+	tests := map[string]struct {
+		allowEmptyWrite    bool
+		expectedStatusCode int
+		expectedErr        error
+	}{
+		"empty writes allowed": {
+			allowEmptyWrite:    true,
+			expectedStatusCode: 200,
+		},
+		"empty writes not allowed": {
+			allowEmptyWrite:    false,
+			expectedStatusCode: 0,
+			expectedErr:        ErrEmptyWrite,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			cfg := ClientConfig{}
+			flagext.DefaultValues(&cfg)
+			cfg.AllowEmptyWrite = testData.allowEmptyWrite
+			require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+			require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+			c, err := NewClient(cfg, log.NewNopLogger(), nil)
+			require.NoError(t, err)
+
+			for _, input := range [][]prompb.TimeSeries{nil, {}} {
+				statusCode, err := c.WriteSeries(context.Background(), input)
+				assert.Equal(t, testData.expectedErr, err)
+				assert.Equal(t, testData.expectedStatusCode, statusCode)
+
+				statusCode, timestamps, err := c.WriteSeriesWithTimestamps(context.Background(), input)
+				assert.Equal(t, testData.expectedErr, err)
+				assert.Equal(t, testData.expectedStatusCode, statusCode)
+				assert.Empty(t, timestamps)
+			}
+
+			// No request should have been sent.
+			assert.Equal(t, int32(0), requests.Load())
+		})
+	}
+}
+
+func TestClientConfig_AllowEmptyWriteShouldBeEnabledByDefault(t *testing.T) {
+	cfg := ClientConfig{}
+	flagext.DefaultValues(&cfg)
+	assert.True(t, cfg.AllowEmptyWrite)
+}
+
+func TestClient_WriteSeries_ShouldHonorRetryAfter(t *testing.T) {
+	tests := map[string]struct {
+		retryAfter       string
+		maxWait          time.Duration
+		ctxTimeout       time.Duration
+		expectedRequests int
+		expectedMinWait  time.Duration
+		expectedMaxWait  time.Duration
+		expectedErr      bool
+	}{
+		"should wait the time requested by the server before retrying": {
+			retryAfter:       "2",
+			maxWait:          10 * time.Second,
+			expectedRequests: 2,
+			expectedMinWait:  2 * time.Second,
+			expectedMaxWait:  5 * time.Second,
+		},
+		"should cap the time to wait before retrying": {
+			retryAfter:       "60",
+			maxWait:          100 * time.Millisecond,
+			expectedRequests: 2,
+			expectedMinWait:  100 * time.Millisecond,
+			expectedMaxWait:  5 * time.Second,
+		},
+		"should not retry if disabled": {
+			retryAfter:       "1",
+			maxWait:          0,
+			expectedRequests: 1,
+			expectedErr:      true,
+		},
+		"should not retry if the context deadline expires before the time to wait": {
+			retryAfter:       "2",
+			maxWait:          10 * time.Second,
+			ctxTimeout:       500 * time.Millisecond,
+			expectedRequests: 1,
+			expectedMaxWait:  500 * time.Millisecond,
+			expectedErr:      true,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			var receivedRequests atomic.Int32
+
+			server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+				// Throttle the first request only.
+				if receivedRequests.Inc() == 1 {
+					writer.Header().Set("Retry-After", testData.retryAfter)
+					writer.WriteHeader(http.StatusTooManyRequests)
+				}
+			}))
+			t.Cleanup(server.Close)
+
+			cfg := ClientConfig{}
+			flagext.DefaultValues(&cfg)
+			cfg.WriteRetryAfterMaxWait = testData.maxWait
+			require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+			require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+			c, err := NewClient(cfg, log.NewNopLogger(), nil)
+			require.NoError(t, err)
+
+			ctx := context.Background()
+			if testData.ctxTimeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, testData.ctxTimeout)
+				defer cancel()
+			}
+
+			start := time.Now()
+			statusCode, err := c.WriteSeries(ctx, generateSineWaveSeries("test", time.Now(), 1))
+			elapsed := time.Since(start)
+
+			if testData.expectedErr {
+				require.Error(t, err)
+				assert.Equal(t, http.StatusTooManyRequests, statusCode)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, http.StatusOK, statusCode)
+			}
+
+			assert.Equal(t, int32(testData.expectedRequests), receivedRequests.Load())
+			assert.GreaterOrEqual(t, elapsed, testData.expectedMinWait)
+			if testData.expectedMaxWait > 0 {
+				assert.Less(t, elapsed, testData.expectedMaxWait)
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2022, 4, 1, 10, 0, 0, 0, time.UTC)
+
+	tests := map[string]struct {
+		value        string
+		expectedWait time.Duration
+		expectedOK   bool
+	}{
+		"empty": {
+			value: "",
+		},
+		"seconds": {
+			value:        "2",
+			expectedWait: 2 * time.Second,
+			expectedOK:   true,
+		},
+		"negative seconds": {
+			value: "-1",
+		},
+		"HTTP date in the future": {
+			value:        now.Add(30 * time.Second).Format(http.TimeFormat),
+			expectedWait: 30 * time.Second,
+			expectedOK:   true,
+		},
+		"HTTP date in the past": {
+			value:        now.Add(-30 * time.Second).Format(http.TimeFormat),
+			expectedWait: 0,
+			expectedOK:   true,
+		},
+		"invalid": {
+			value: "soon",
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			wait, ok := parseRetryAfter(testData.value, now)
+			assert.Equal(t, testData.expectedOK, ok)
+			assert.Equal(t, testData.expectedWait, wait)
+		})
+	}
+}
+
+func TestClient_WriteSeries_ShouldRetryOnConnectionClosedErrors(t *testing.T) {
+	tests := map[string]struct {
+		err              error
+		failures         int
+		expectedAttempts int
+		expectedErr      bool
+	}{
+		"should retry on HTTP/2 GOAWAY": {
+			err:              errors.New(`http2: server sent GOAWAY and closed the connection; LastStreamID=1, ErrCode=NO_ERROR, debug=""`),
+			failures:         1,
+			expectedAttempts: 2,
+		},
+		"should retry on connection reset": {
+			err:              &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)},
+			failures:         1,
+			expectedAttempts: 2,
+		},
+		"should retry only once": {
+			err:              errors.New("http2: server sent GOAWAY and closed the connection"),
+			failures:         2,
+			expectedAttempts: 2,
+			expectedErr:      true,
+		},
+		"should not retry on other errors": {
+			err:              errors.New("dial tcp: connection refused"),
+			failures:         1,
+			expectedAttempts: 1,
+			expectedErr:      true,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {}))
+			t.Cleanup(server.Close)
+
+			attempts := 0
+
+			cfg := ClientConfig{}
+			flagext.DefaultValues(&cfg)
+			cfg.HTTPClient = &http.Client{
+				Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+					attempts++
+					if attempts <= testData.failures {
+						return nil, testData.err
+					}
+					return http.DefaultTransport.RoundTrip(req)
+				}),
+			}
+			require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+			require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+			c, err := NewClient(cfg, log.NewNopLogger(), nil)
+			require.NoError(t, err)
+
+			statusCode, err := c.WriteSeries(context.Background(), generateSineWaveSeries("test", time.Now(), 1))
+			if testData.expectedErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, 200, statusCode)
+			}
+			assert.Equal(t, testData.expectedAttempts, attempts)
+		})
+	}
+}
+
+func TestClient_WriteSeriesForTenants(t *testing.T) {
+	var receivedRequests []prompb.WriteRequest
+	var receivedTenants []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		body, err := ioutil.ReadAll(request.Body)
+		require.NoError(t, err)
+		body, err = snappy.Decode(nil, body)
+		require.NoError(t, err)
+
+		var req prompb.WriteRequest
+		require.NoError(t, proto.Unmarshal(body, &req))
+		receivedRequests = append(receivedRequests, req)
+		receivedTenants = append(receivedTenants, request.Header.Get("X-Scope-OrgID"))
+
+		if request.Header.Get("X-Scope-OrgID") == "failing" {
+			writer.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := ClientConfig{}
+	flagext.DefaultValues(&cfg)
+	cfg.TenantID = "default"
+	require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+	require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+	c, err := NewClient(cfg, log.NewNopLogger(), nil)
+	require.NoError(t, err)
+
+	series := generateSineWaveSeries("test", time.Now(), 5)
+
+	t.Run("should send a request for each tenant", func(t *testing.T) {
+		receivedRequests, receivedTenants = nil, nil
+
+		statusCode, err := c.WriteSeriesForTenants(context.Background(), series, []string{"tenant-1", "tenant-2", "tenant-1", "tenant-2", "tenant-1"})
+		require.NoError(t, err)
+		assert.Equal(t, 200, statusCode)
+
+		assert.Equal(t, []string{"tenant-1", "tenant-2"}, receivedTenants)
+		require.Len(t, receivedRequests, 2)
+		assert.Equal(t, []prompb.TimeSeries{series[0], series[2], series[4]}, receivedRequests[0].Timeseries)
+		assert.Equal(t, []prompb.TimeSeries{series[1], series[3]}, receivedRequests[1].Timeseries)
+	})
+
+	t.Run("should write series with empty tenant for the configured tenant", func(t *testing.T) {
+		receivedRequests, receivedTenants = nil, nil
+
+		_, err := c.WriteSeriesForTenants(context.Background(), series[:2], []string{"", "tenant-1"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"default", "tenant-1"}, receivedTenants)
+	})
+
+	t.Run("should stop on the first failed tenant", func(t *testing.T) {
+		receivedRequests, receivedTenants = nil, nil
+
+		statusCode, err := c.WriteSeriesForTenants(context.Background(), series[:3], []string{"failing", "tenant-1", "failing"})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `failed to write series for tenant "failing"`)
+		assert.Equal(t, 500, statusCode)
+		assert.Equal(t, []string{"failing"}, receivedTenants)
+	})
+
+	t.Run("should fail if the number of series and tenants don't match", func(t *testing.T) {
+		_, err := c.WriteSeriesForTenants(context.Background(), series, []string{"tenant-1"})
+		require.EqualError(t, err, "the number of series (5) doesn't match the number of tenants (1)")
+	})
+}
+
+func TestClient_WriteSeries_ShouldLimitInflightRequests(t *testing.T) {
+	const (
+		maxInflight = 2
+		numWriters  = 10
+	)
+
+	var (
+		inflight    atomic.Int32
+		maxObserved atomic.Int32
+		unblock     = make(chan struct{})
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		curr := inflight.Inc()
+		defer inflight.Dec()
+
+		for prev := maxObserved.Load(); curr > prev && !maxObserved.CAS(prev, curr); prev = maxObserved.Load() {
+		}
+
+		// Hold the request until the test unblocks it, or for a short time once unblocked.
+		select {
+		case <-unblock:
+			time.Sleep(10 * time.Millisecond)
+		case <-request.Context().Done():
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := ClientConfig{}
+	flagext.DefaultValues(&cfg)
+	cfg.WriteMaxInflight = maxInflight
+	require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+	require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+	c, err := NewClient(cfg, log.NewNopLogger(), nil)
+	require.NoError(t, err)
+
+	t.Run("should fail if the context expires while waiting", func(t *testing.T) {
+		// Saturate the in-flight requests.
+		wg := sync.WaitGroup{}
+		for i := 0; i < maxInflight; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, _ = c.WriteSeries(context.Background(), generateSineWaveSeries("test", time.Now(), 1))
+			}()
+		}
+		require.Eventually(t, func() bool { return inflight.Load() == maxInflight }, time.Second, time.Millisecond)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		_, err := c.WriteSeries(ctx, generateSineWaveSeries("test", time.Now(), 1))
+		require.Error(t, err)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Equal(t, int32(maxInflight), inflight.Load())
+
+		close(unblock)
+		wg.Wait()
+	})
+
+	t.Run("should not send more than the max in-flight requests concurrently", func(t *testing.T) {
+		maxObserved.Store(0)
+
+		wg := sync.WaitGroup{}
+		for i := 0; i < numWriters; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := c.WriteSeries(context.Background(), generateSineWaveSeries("test", time.Now(), 1))
+				assert.NoError(t, err)
+			}()
+		}
+		wg.Wait()
+
+		assert.Equal(t, int32(maxInflight), maxObserved.Load())
+	})
+}
+
+func TestClient_ShouldTrackInflightAndWaitingRequests(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		<-release
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := ClientConfig{}
+	flagext.DefaultValues(&cfg)
+	cfg.WriteMaxInflight = 1
+	require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+	require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+	c, err := NewClient(cfg, log.NewNopLogger(), prometheus.NewPedanticRegistry())
+	require.NoError(t, err)
+
+	inflightWrites := c.metrics.inflightRequests.WithLabelValues(operationWrite)
+
+	// Run 2 concurrent writes: the first one is in-flight while the second one waits.
+	wg := sync.WaitGroup{}
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := c.WriteSeries(context.Background(), generateSineWaveSeries("test", time.Now(), 1))
+			assert.NoError(t, err)
+		}()
+	}
+
+	require.Eventually(t, func() bool {
+		return testutil.ToFloat64(inflightWrites) == 1 && testutil.ToFloat64(c.metrics.waitingRequests) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	// Complete the slow requests.
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, 0.0, testutil.ToFloat64(inflightWrites))
+	assert.Equal(t, 0.0, testutil.ToFloat64(c.metrics.waitingRequests))
+}
+
+func TestClient_WriteSeries_ShouldHonorSnappyFraming(t *testing.T) {
+	for _, framed := range []bool{false, true} {
+		t.Run(fmt.Sprintf("framed=%t", framed), func(t *testing.T) {
+			var receivedRequests []prompb.WriteRequest
+
+			server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+				// Decode the body with the snappy format expected by the server.
+				var (
+					body []byte
+					err  error
+				)
+				if framed {
+					body, err = ioutil.ReadAll(snappy.NewReader(request.Body))
+				} else {
+					body, err = ioutil.ReadAll(request.Body)
+					require.NoError(t, err)
+					body, err = snappy.Decode(nil, body)
+				}
+				require.NoError(t, err)
+
+				var req prompb.WriteRequest
+				require.NoError(t, proto.Unmarshal(body, &req))
+				receivedRequests = append(receivedRequests, req)
+			}))
+			t.Cleanup(server.Close)
+
+			cfg := ClientConfig{}
+			flagext.DefaultValues(&cfg)
+			cfg.SnappyFramed = framed
+			require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+			require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+			c, err := NewClient(cfg, log.NewNopLogger(), nil)
+			require.NoError(t, err)
+
+			series := generateSineWaveSeries("test", time.Now(), 10)
+			statusCode, err := c.WriteSeries(context.Background(), series)
+			require.NoError(t, err)
+			assert.Equal(t, 200, statusCode)
+
+			require.Len(t, receivedRequests, 1)
+			assert.Equal(t, series, receivedRequests[0].Timeseries)
+		})
+	}
+}
+
+func TestClient_WriteSeries_ShouldSendMalformedRequestsIfConfigured(t *testing.T) {
+	tests := map[WriteMalformedMode]struct {
+		expectedContentType     string
+		expectedContentEncoding string
+	}{
+		WriteMalformedDisabled: {
+			expectedContentType:     "application/x-protobuf",
+			expectedContentEncoding: "snappy",
+		},
+		WriteMalformedMissingContentHeaders: {
+			expectedContentType:     "",
+			expectedContentEncoding: "",
+		},
+		WriteMalformedInvalidContentType: {
+			expectedContentType:     "text/plain",
+			expectedContentEncoding: "snappy",
+		},
+		WriteMalformedInvalidContentEncoding: {
+			expectedContentType:     "application/x-protobuf",
+			expectedContentEncoding: "gzip",
+		},
+	}
+
+	for mode, testData := range tests {
+		t.Run(fmt.Sprintf("mode=%q", mode), func(t *testing.T) {
+			var receivedHeaders []http.Header
+
+			server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+				receivedHeaders = append(receivedHeaders, request.Header)
+
+				// Reject the request like Mimir does when the content headers are not the expected ones.
+				if request.Header.Get("Content-Encoding") != "snappy" || request.Header.Get("Content-Type") != "application/x-protobuf" {
+					writer.WriteHeader(http.StatusBadRequest)
+				}
+			}))
+			t.Cleanup(server.Close)
+
+			cfg := ClientConfig{}
+			flagext.DefaultValues(&cfg)
+			cfg.WriteMalformed = mode
+			require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+			require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+			c, err := NewClient(cfg, log.NewNopLogger(), nil)
+			require.NoError(t, err)
+
+			statusCode, err := c.WriteSeries(context.Background(), generateSineWaveSeries("test", time.Now(), 1))
+			if mode == WriteMalformedDisabled {
+				require.NoError(t, err)
+				assert.Equal(t, http.StatusOK, statusCode)
+			} else {
+				require.Error(t, err)
+				assert.Equal(t, http.StatusBadRequest, statusCode)
+			}
+
+			require.Len(t, receivedHeaders, 1)
+			assert.Equal(t, testData.expectedContentType, receivedHeaders[0].Get("Content-Type"))
+			assert.Equal(t, testData.expectedContentEncoding, receivedHeaders[0].Get("Content-Encoding"))
+		})
+	}
+}
+
+func TestNewClient_ShouldFailOnUnsupportedMalformedWriteMode(t *testing.T) {
+	cfg := ClientConfig{}
+	flagext.DefaultValues(&cfg)
+	cfg.WriteMalformed = "unknown"
+	require.NoError(t, cfg.WriteBaseEndpoint.Set("http://localhost"))
+	require.NoError(t, cfg.ReadBaseEndpoint.Set("http://localhost"))
+
+	_, err := NewClient(cfg, log.NewNopLogger(), nil)
+	assert.EqualError(t, err, `unsupported malformed write mode "unknown"`)
+}
+
+func TestClient_WriteSeries_ShouldSortLabels(t *testing.T) {
+	unsorted := []prompb.Label{{Name: "series_id", Value: "0"}, {Name: "__name__", Value: "test"}, {Name: "cluster", Value: "test"}}
+	sorted := []prompb.Label{{Name: "__name__", Value: "test"}, {Name: "cluster", Value: "test"}, {Name: "series_id", Value: "0"}}
+
+	tests := map[string]struct {
+		sortLabels          bool
+		preserveLabelsOrder bool
+		expectedLabels      []prompb.Label
+	}{
+		"should sort labels when enabled": {
+			sortLabels:     true,
+			expectedLabels: sorted,
+		},
+		"should preserve labels order when disabled": {
+			sortLabels:     false,
+			expectedLabels: unsorted,
+		},
+		"should preserve labels order when requested via context, even if enabled": {
+			sortLabels:          true,
+			preserveLabelsOrder: true,
+			expectedLabels:      unsorted,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			var receivedRequests []prompb.WriteRequest
+
+			server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+				body, err := ioutil.ReadAll(request.Body)
+				require.NoError(t, err)
+				body, err = snappy.Decode(nil, body)
+				require.NoError(t, err)
+
+				var req prompb.WriteRequest
+				require.NoError(t, proto.Unmarshal(body, &req))
+				receivedRequests = append(receivedRequests, req)
+			}))
+			t.Cleanup(server.Close)
+
+			cfg := ClientConfig{}
+			flagext.DefaultValues(&cfg)
+			cfg.SortLabels = testData.sortLabels
+			require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+			require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+			c, err := NewClient(cfg, log.NewNopLogger(), nil)
+			require.NoError(t, err)
+
+			series := []prompb.TimeSeries{{
+				Labels:  append([]prompb.Label{}, unsorted...),
+				Samples: []prompb.Sample{{Value: 1, Timestamp: time.Now().UnixMilli()}},
+			}}
+
+			ctx := context.Background()
+			if testData.preserveLabelsOrder {
+				ctx = withPreservedLabelsOrder(ctx)
+			}
+
+			_, err = c.WriteSeries(ctx, series)
+			require.NoError(t, err)
+
+			require.Len(t, receivedRequests, 1)
+			assert.Equal(t, testData.expectedLabels, receivedRequests[0].Timeseries[0].Labels)
+
+			// The input series must not be modified.
+			assert.Equal(t, unsorted, series[0].Labels)
+		})
+	}
+}
+
+func TestClient_WriteSeries_ShouldPauseOnUnhealthyWriteEndpoint(t *testing.T) {
+	var (
+		pushRequests      int
+		pushFailuresLeft  int
+		readyRequests     int
+		notReadyPollsLeft int
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		switch request.URL.Path {
+		case "/api/v1/push":
+			pushRequests++
+			if pushFailuresLeft > 0 {
+				pushFailuresLeft--
+				writer.WriteHeader(http.StatusServiceUnavailable)
+			}
+		case "/ready":
+			readyRequests++
+			if notReadyPollsLeft > 0 {
+				notReadyPollsLeft--
+				writer.WriteHeader(http.StatusServiceUnavailable)
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := ClientConfig{}
+	flagext.DefaultValues(&cfg)
+	cfg.PauseOnUnhealthy = true
+	cfg.PauseOnUnhealthyBackoff.MinBackoff = 10 * time.Millisecond
+	cfg.PauseOnUnhealthyBackoff.MaxBackoff = 10 * time.Millisecond
+	cfg.PauseOnUnhealthyBackoff.MaxRetries = 3
+	cfg.PauseOnUnhealthyAfter = 2
+	require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+	require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+	c, err := NewClient(cfg, log.NewNopLogger(), nil)
+	require.NoError(t, err)
+
+	t.Run("should not pause writes on a single transient 5xx error", func(t *testing.T) {
+		c.writeFailures.Store(0)
+		pushRequests, pushFailuresLeft = 0, 1
+		readyRequests, notReadyPollsLeft = 0, 0
+
+		statusCode, err := c.WriteSeries(context.Background(), generateSineWaveSeries("test", time.Now(), 1))
+		require.Error(t, err)
+		assert.Equal(t, 503, statusCode)
+
+		// A successful write resets the consecutive failures, so the next 5xx error doesn't pause writes either.
+		_, err = c.WriteSeries(context.Background(), generateSineWaveSeries("test", time.Now(), 1))
+		require.NoError(t, err)
+
+		pushFailuresLeft = 1
+		_, err = c.WriteSeries(context.Background(), generateSineWaveSeries("test", time.Now(), 1))
+		require.Error(t, err)
+
+		assert.Equal(t, 3, pushRequests)
+		assert.Equal(t, 0, readyRequests)
+	})
+
+	t.Run("should resume writing once the write endpoint becomes ready", func(t *testing.T) {
+		c.writeFailures.Store(0)
+		pushRequests, pushFailuresLeft = 0, 2
+		readyRequests, notReadyPollsLeft = 0, 1
+
+		// The first failure is returned without pausing writes.
+		_, err := c.WriteSeries(context.Background(), generateSineWaveSeries("test", time.Now(), 1))
+		require.Error(t, err)
+		assert.Equal(t, 0, readyRequests)
+
+		statusCode, err := c.WriteSeries(context.Background(), generateSineWaveSeries("test", time.Now(), 1))
+		require.NoError(t, err)
+		assert.Equal(t, 200, statusCode)
+		assert.Equal(t, 3, pushRequests)
+		assert.Equal(t, 2, readyRequests)
+	})
+
+	t.Run("should give up once the backoff is exhausted", func(t *testing.T) {
+		c.writeFailures.Store(0)
+		pushRequests, pushFailuresLeft = 0, 2
+		readyRequests, notReadyPollsLeft = 0, 100
+
+		_, err := c.WriteSeries(context.Background(), generateSineWaveSeries("test", time.Now(), 1))
+		require.Error(t, err)
+
+		statusCode, err := c.WriteSeries(context.Background(), generateSineWaveSeries("test", time.Now(), 1))
+		require.Error(t, err)
+		assert.Equal(t, 503, statusCode)
+		assert.Equal(t, 2, pushRequests)
+		assert.Equal(t, 3, readyRequests)
+	})
+}
+
+func TestClient_WriteSeries_ShouldValidateSuccessfulResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.Header().Set("Content-Type", "text/html; charset=utf-8")
+		writer.WriteHeader(http.StatusOK)
+		_, _ = writer.Write([]byte("<html><body>Login</body></html>"))
+	}))
+	t.Cleanup(server.Close)
+
+	for _, strict := range []bool{false, true} {
+		t.Run(fmt.Sprintf("strict=%t", strict), func(t *testing.T) {
+			cfg := ClientConfig{}
+			flagext.DefaultValues(&cfg)
+			cfg.StrictWriteResponse = strict
+			require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+			require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+			c, err := NewClient(cfg, log.NewNopLogger(), nil)
+			require.NoError(t, err)
+
+			statusCode, err := c.WriteSeries(context.Background(), generateSineWaveSeries("test", time.Now(), 1))
+			assert.Equal(t, 200, statusCode)
+
+			if strict {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), `unexpected content type "text/html; charset=utf-8"`)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestClient_WriteSeries_ShouldSplitTooLargeBatches(t *testing.T) {
+	var receivedRequests []prompb.WriteRequest
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		body, err := ioutil.ReadAll(request.Body)
+		require.NoError(t, err)
+
+		body, err = snappy.Decode(nil, body)
+		require.NoError(t, err)
+
+		var req prompb.WriteRequest
+		require.NoError(t, proto.Unmarshal(body, &req))
+		receivedRequests = append(receivedRequests, req)
+	}))
+	t.Cleanup(server.Close)
+
+	now := time.Now()
+	series := generateSineWaveSeries("test", now, 10)
+	seriesSize := (&prompb.WriteRequest{Timeseries: series[:1]}).Size()
+
+	cfg := ClientConfig{}
+	flagext.DefaultValues(&cfg)
+	cfg.WriteBatchSize = 10
+	require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+	require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+	t.Run("should split the batch until it fits the max request size", func(t *testing.T) {
+		receivedRequests = nil
+		cfg.WriteMaxRequestSize = 3 * seriesSize
+
+		c, err := NewClient(cfg, log.NewNopLogger(), nil)
+		require.NoError(t, err)
+
+		statusCode, err := c.WriteSeries(context.Background(), series)
+		require.NoError(t, err)
+		assert.Equal(t, 200, statusCode)
+
+		// The batch of 10 series is first split into 5, and then into 2.
+		require.Len(t, receivedRequests, 5)
+
+		var actual []prompb.TimeSeries
+		for _, req := range receivedRequests {
+			assert.LessOrEqual(t, req.Size(), cfg.WriteMaxRequestSize)
+			actual = append(actual, req.Timeseries...)
+		}
+		assert.Equal(t, series, actual)
+
+		// The batches of 10 and 5 series have been split, so no batch has been rejected.
+		assert.Equal(t, 0.0, testutil.ToFloat64(c.metrics.rejectedBatches.WithLabelValues(rejectReasonTooLarge)))
+	})
+
+	t.Run("should fail if a single series exceeds the max request size", func(t *testing.T) {
+		receivedRequests = nil
+		cfg.WriteMaxRequestSize = seriesSize - 1
+
+		c, err := NewClient(cfg, log.NewNopLogger(), nil)
+		require.NoError(t, err)
+
+		_, err = c.WriteSeries(context.Background(), series)
+		require.Error(t, err)
+		assert.ErrorIs(t, err, ErrPayloadTooLarge)
+		assert.Empty(t, receivedRequests)
+
+		// The batches of 10, 5 and 2 series have been split, while the batch of 1 series has been rejected.
+		assert.Equal(t, 1.0, testutil.ToFloat64(c.metrics.rejectedBatches.WithLabelValues(rejectReasonTooLarge)))
+	})
+}
+
+func TestClient_WriteSeries_ShouldShortCircuitWritesWhenCircuitIsOpen(t *testing.T) {
+	var (
+		receivedRequests = 0
+		statusCode       = http.StatusInternalServerError
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		receivedRequests++
+		writer.WriteHeader(statusCode)
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := ClientConfig{}
+	flagext.DefaultValues(&cfg)
+	cfg.WriteCircuitThreshold = 3
+	cfg.WriteCircuitCooldown = time.Minute
+	require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+	require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+	c, err := NewClient(cfg, log.NewNopLogger(), prometheus.NewPedanticRegistry())
+	require.NoError(t, err)
+
+	now := time.Now()
+	c.writeCircuit.now = func() time.Time { return now }
+
+	series := generateSineWaveSeries("test", now, 1)
+	getCircuitState := func() float64 {
+		return testutil.ToFloat64(c.writeCircuit.stateGauge)
+	}
+
+	// The circuit should open after the configured number of consecutive failures.
+	for i := 0; i < cfg.WriteCircuitThreshold; i++ {
+		assert.Equal(t, float64(circuitClosed), getCircuitState())
+
+		actualStatusCode, err := c.WriteSeries(context.Background(), series)
+		require.Error(t, err)
+		assert.NotErrorIs(t, err, ErrCircuitOpen)
+		assert.Equal(t, http.StatusInternalServerError, actualStatusCode)
+	}
+
+	assert.Equal(t, 3, receivedRequests)
+	assert.Equal(t, float64(circuitOpen), getCircuitState())
+
+	// Writes should be short-circuited until the cooldown elapses.
+	now = now.Add(cfg.WriteCircuitCooldown - time.Second)
+	_, err = c.WriteSeries(context.Background(), series)
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.Equal(t, 3, receivedRequests)
+
+	// Once the cooldown elapsed, a failed trial request should open the circuit again.
+	now = now.Add(time.Second)
+	_, err = c.WriteSeries(context.Background(), series)
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, ErrCircuitOpen)
+	assert.Equal(t, 4, receivedRequests)
+	assert.Equal(t, float64(circuitOpen), getCircuitState())
+
+	_, err = c.WriteSeries(context.Background(), series)
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.Equal(t, 4, receivedRequests)
+
+	// Once the cooldown elapsed again, a successful trial request should close the circuit.
+	now = now.Add(cfg.WriteCircuitCooldown)
+	statusCode = http.StatusOK
+
+	for i := 0; i < 2; i++ {
+		actualStatusCode, err := c.WriteSeries(context.Background(), series)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, actualStatusCode)
+		assert.Equal(t, float64(circuitClosed), getCircuitState())
+	}
+
+	assert.Equal(t, 6, receivedRequests)
+}
+
+func TestClient_WriteSeries_ShouldNotRecordPayloadTooLargeAsSuccessInCircuit(t *testing.T) {
+	receivedRequests := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		receivedRequests++
+		writer.WriteHeader(http.StatusInternalServerError)
+	}))
+	t.Cleanup(server.Close)
+
+	now := time.Now()
+	series := generateSineWaveSeries("test", now, 2)
+
+	cfg := ClientConfig{}
+	flagext.DefaultValues(&cfg)
+	cfg.WriteCircuitThreshold = 3
+	cfg.WriteCircuitCooldown = time.Minute
+	cfg.WriteMaxRequestSize = (&prompb.WriteRequest{Timeseries: series[:1]}).Size()
+	require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+	require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+	c, err := NewClient(cfg, log.NewNopLogger(), prometheus.NewPedanticRegistry())
+	require.NoError(t, err)
+	c.writeCircuit.now = func() time.Time { return now }
+
+	// Open the circuit.
+	for i := 0; i < cfg.WriteCircuitThreshold; i++ {
+		_, err := c.WriteSeries(context.Background(), series[:1])
+		require.Error(t, err)
+	}
+	require.Equal(t, float64(circuitOpen), testutil.ToFloat64(c.writeCircuit.stateGauge))
+
+	// Once the cooldown elapsed, the batch exceeding the max request size is split, and the first
+	// re-split request is the trial request. Since it fails, the circuit should open again.
+	now = now.Add(cfg.WriteCircuitCooldown)
+	_, err = c.WriteSeries(context.Background(), series)
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, ErrCircuitOpen)
+	assert.Equal(t, cfg.WriteCircuitThreshold+1, receivedRequests)
+	assert.Equal(t, float64(circuitOpen), testutil.ToFloat64(c.writeCircuit.stateGauge))
+
+	_, err = c.WriteSeries(context.Background(), series[:1])
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+}
+
+func TestClient_WriteSeries_ShouldNotOpenCircuitOn4xxErrors(t *testing.T) {
+	receivedRequests := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		receivedRequests++
+		writer.WriteHeader(http.StatusBadRequest)
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := ClientConfig{}
+	flagext.DefaultValues(&cfg)
+	cfg.WriteCircuitThreshold = 1
+	require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+	require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+	c, err := NewClient(cfg, log.NewNopLogger(), nil)
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		_, err := c.WriteSeries(context.Background(), generateSineWaveSeries("test", time.Now(), 1))
+		require.Error(t, err)
+		assert.NotErrorIs(t, err, ErrCircuitOpen)
+	}
+
+	assert.Equal(t, 3, receivedRequests)
+}
+
+func TestClient_WriteSeries_ShouldValidateBatch(t *testing.T) {
+	receivedRequests := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		receivedRequests++
+	}))
+	t.Cleanup(server.Close)
+
+	now := time.Now()
+	duplicate := generateSineWaveSeries("test", now, 1)[0]
+	duplicate.Labels = []prompb.Label{duplicate.Labels[1], duplicate.Labels[0]}
+
+	withLabel := func(name, value string) []prompb.TimeSeries {
+		series := generateSineWaveSeries("test", now, 2)
+		series[1].Labels = append(series[1].Labels, prompb.Label{Name: name, Value: value})
+		return series
+	}
+
+	tests := map[string]struct {
+		validateBatch  bool
+		series         []prompb.TimeSeries
+		expectedErr    string
+		expectedReason string
+	}{
+		"should succeed if the batch has no duplicate series": {
+			validateBatch: true,
+			series:        generateSineWaveSeries("test", now, 10),
+		},
+		"should fail if the batch has duplicate series": {
+			validateBatch:  true,
+			series:         append(generateSineWaveSeries("test", now, 10), duplicate),
+			expectedErr:    `the write request contains the duplicate series {__name__="test", series_id="0"}`,
+			expectedReason: rejectReasonDuplicate,
+		},
+		"should fail if the batch has a series with an invalid label name": {
+			validateBatch:  true,
+			series:         withLabel("invalid-name", "value"),
+			expectedErr:    `the series {__name__="test", invalid-name="value", series_id="1"} has the invalid label name "invalid-name"`,
+			expectedReason: rejectReasonInvalidLabel,
+		},
+		"should fail if the batch has a series with an invalid UTF-8 label value": {
+			validateBatch:  true,
+			series:         withLabel("label", "\xff"),
+			expectedErr:    `the series {__name__="test", label="\xff", series_id="1"} has the label label with an invalid UTF-8 value`,
+			expectedReason: rejectReasonInvalidLabel,
+		},
+		"should fail if the batch has a series with a too long label value": {
+			validateBatch:  true,
+			series:         withLabel("label", strings.Repeat("x", 11)),
+			expectedErr:    `the series {__name__="test", label="xxxxxxxxxxx", series_id="1"} has the label label with a value longer than 10 bytes`,
+			expectedReason: rejectReasonInvalidLabel,
+		},
+		"should succeed if the batch has a series with a label value as long as the limit": {
+			validateBatch: true,
+			series:        withLabel("label", strings.Repeat("x", 10)),
+		},
+		"should fail if the batch has a series with a too old sample": {
+			validateBatch:  true,
+			series:         generateSineWaveSeries("test", now.Add(-2*time.Hour), 1),
+			expectedErr:    fmt.Sprintf(`the series {__name__="test", series_id="0"} has a sample at timestamp %d older than 1h0m0s`, now.Add(-2*time.Hour).UnixMilli()),
+			expectedReason: rejectReasonTooOld,
+		},
+		"should succeed if the batch has samples more recent than the max age": {
+			validateBatch: true,
+			series:        generateSineWaveSeries("test", now.Add(-30*time.Minute), 1),
+		},
+		"should not detect invalid labels if the validation is disabled": {
+			validateBatch: false,
+			series:        withLabel("invalid-name", strings.Repeat("x", 11)),
+		},
+		"should not detect duplicate series if the validation is disabled": {
+			validateBatch: false,
+			series:        append(generateSineWaveSeries("test", now, 10), duplicate),
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			receivedRequests = 0
+
+			cfg := ClientConfig{}
+			flagext.DefaultValues(&cfg)
+			cfg.ValidateBatch = testData.validateBatch
+			cfg.MaxLabelValueLength = 10
+			cfg.MaxSampleAge = time.Hour
+			require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+			require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+			c, err := NewClient(cfg, log.NewNopLogger(), nil)
+			require.NoError(t, err)
+
+			_, err = c.WriteSeries(context.Background(), testData.series)
+			if testData.expectedErr != "" {
+				require.EqualError(t, err, testData.expectedErr)
+				assert.Equal(t, 0, receivedRequests)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, 1, receivedRequests)
+			}
+
+			// The rejected batch, if any, should be tracked by reason.
+			for _, reason := range []string{rejectReasonDuplicate, rejectReasonInvalidLabel, rejectReasonTooLarge, rejectReasonTooOld} {
+				expected := 0.0
+				if reason == testData.expectedReason {
+					expected = 1
+				}
+				assert.Equal(t, expected, testutil.ToFloat64(c.metrics.rejectedBatches.WithLabelValues(reason)), "reason: %s", reason)
+			}
+		})
+	}
+}
+
+func TestClient_ReplayWriteFile(t *testing.T) {
+	var (
+		receivedBody    []byte
+		receivedHeaders http.Header
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		var err error
+		receivedBody, err = ioutil.ReadAll(request.Body)
+		require.NoError(t, err)
+		receivedHeaders = request.Header
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := ClientConfig{}
+	flagext.DefaultValues(&cfg)
+	cfg.TenantID = "tenant-1"
+	cfg.JWT = "token"
+	require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+	require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+	c, err := NewClient(cfg, log.NewNopLogger(), nil)
+	require.NoError(t, err)
+
+	// Write a captured request to a fixture file. The labels are intentionally unsorted, to check
+	// the request is sent as is.
+	req := &prompb.WriteRequest{Timeseries: []prompb.TimeSeries{{
+		Labels:  []prompb.Label{{Name: "series_id", Value: "0"}, {Name: "__name__", Value: "test"}},
+		Samples: []prompb.Sample{{Value: 1, Timestamp: 1000}},
+	}}}
+	data, err := proto.Marshal(req)
+	require.NoError(t, err)
+	captured := snappy.Encode(nil, data)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "request.snappy")
+	require.NoError(t, os.WriteFile(path, captured, 0o600))
+
+	t.Run("should post the captured request unchanged", func(t *testing.T) {
+		statusCode, err := c.ReplayWriteFile(context.Background(), path)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, statusCode)
+
+		assert.Equal(t, captured, receivedBody)
+		assert.Equal(t, "snappy", receivedHeaders.Get("Content-Encoding"))
+		assert.Equal(t, "tenant-1", receivedHeaders.Get("X-Scope-OrgID"))
+		assert.Equal(t, "Bearer token", receivedHeaders.Get("Authorization"))
+	})
+
+	t.Run("should post the captured request compressed with the snappy framing format unchanged", func(t *testing.T) {
+		receivedBody = nil
+
+		framed, err := snappyEncode(data, true)
+		require.NoError(t, err)
+
+		framedPath := filepath.Join(dir, "request-framed.snappy")
+		require.NoError(t, os.WriteFile(framedPath, framed, 0o600))
+
+		statusCode, err := c.ReplayWriteFile(context.Background(), framedPath)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, statusCode)
+		assert.Equal(t, framed, receivedBody)
+	})
+
+	t.Run("should fail on invalid capture", func(t *testing.T) {
+		receivedBody = nil
+
+		invalidPath := filepath.Join(dir, "invalid.snappy")
+		require.NoError(t, os.WriteFile(invalidPath, []byte("invalid"), 0o600))
+
+		_, err := c.ReplayWriteFile(context.Background(), invalidPath)
+		require.Error(t, err)
+		assert.Nil(t, receivedBody)
+	})
+}
+
+func TestClient_WriteSeries_ShouldHonorParentContextDeadline(t *testing.T) {
+	done := make(chan struct{})
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		// Block until the test has completed.
+		<-done
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { close(done) })
+
+	cfg := ClientConfig{}
+	flagext.DefaultValues(&cfg)
+	cfg.WriteTimeout = time.Minute
+	require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+	require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+	c, err := NewClient(cfg, log.NewNopLogger(), nil)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	startTime := time.Now()
+	_, err = c.WriteSeries(ctx, generateSineWaveSeries("test", time.Now(), 1))
+	require.Error(t, err)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(startTime), cfg.WriteTimeout)
+}
+
+func TestClient_WriteSeries_ShouldHonorTotalTimeout(t *testing.T) {
+	const requestDelay = 50 * time.Millisecond
+
+	received := atomic.NewInt32(0)
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		time.Sleep(requestDelay)
+		received.Inc()
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := ClientConfig{}
+	flagext.DefaultValues(&cfg)
+	cfg.WriteBatchSize = 1
+	cfg.WriteTimeout = time.Minute
+	cfg.WriteTotalTimeout = 5 * requestDelay / 2
+	require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+	require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+	c, err := NewClient(cfg, log.NewNopLogger(), nil)
+	require.NoError(t, err)
+
+	startTime := time.Now()
+	_, err = c.WriteSeries(context.Background(), generateSineWaveSeries("test", time.Now(), 20))
+	require.Error(t, err)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(startTime), cfg.WriteTimeout)
+
+	// Only some of the batches should have been written before the deadline.
+	assert.Regexp(t, `write total timeout of 125ms exceeded after writing [0-2] batches \([0-2] out of 20 series\)`, err.Error())
+	assert.Less(t, received.Load(), int32(20))
+}
+
+func TestClient_ShouldUseCustomHTTPClient(t *testing.T) {
+	var receivedPaths []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		assert.Equal(t, "user-1", request.Header.Get("X-Scope-OrgID"))
+
+		if request.URL.Path == "/api/v1/query_range" {
+			writer.Header().Set("Content-Type", "application/json")
+			_, _ = writer.Write([]byte(`{"status":"success","data":{"resultType":"matrix","result":[]}}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := ClientConfig{}
+	flagext.DefaultValues(&cfg)
+	cfg.TenantID = "user-1"
+	cfg.HTTPClient = &http.Client{
+		Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			receivedPaths = append(receivedPaths, req.URL.Path)
+			return http.DefaultTransport.RoundTrip(req)
+		}),
+	}
+	require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+	require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+	c, err := NewClient(cfg, log.NewNopLogger(), nil)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	now := time.Now()
+
+	_, err = c.WriteSeries(ctx, generateSineWaveSeries("test", now, 1))
+	require.NoError(t, err)
+
+	_, err = c.QueryRange(ctx, "test", now.Add(-time.Minute), now, time.Minute)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"/api/v1/push", "/api/v1/query_range"}, receivedPaths)
+}
+
+func TestClient_ShouldHonorDisableKeepAlives(t *testing.T) {
+	const numRequests = 3
+
+	tests := map[string]struct {
+		disableKeepAlives      bool
+		expectedNewConnections int
+	}{
+		"keep-alives enabled": {
+			disableKeepAlives:      false,
+			expectedNewConnections: 1,
+		},
+		"keep-alives disabled": {
+			disableKeepAlives:      true,
+			expectedNewConnections: numRequests,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			var (
+				newConnectionsMx sync.Mutex
+				newConnections   int
+			)
+
+			server := httptest.NewUnstartedServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(http.StatusOK)
+			}))
+			server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+				if state == http.StateNew {
+					newConnectionsMx.Lock()
+					newConnections++
+					newConnectionsMx.Unlock()
+				}
+			}
+			server.Start()
+			t.Cleanup(server.Close)
+
+			cfg := ClientConfig{}
+			flagext.DefaultValues(&cfg)
+			cfg.DisableKeepAlives = testData.disableKeepAlives
+			require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+			require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+			c, err := NewClient(cfg, log.NewNopLogger(), nil)
+			require.NoError(t, err)
+
+			for i := 0; i < numRequests; i++ {
+				_, err := c.WriteSeries(context.Background(), generateSineWaveSeries("test", time.Now(), 1))
+				require.NoError(t, err)
+			}
+
+			newConnectionsMx.Lock()
+			defer newConnectionsMx.Unlock()
+			assert.Equal(t, testData.expectedNewConnections, newConnections)
+		})
+	}
+}
+
+func TestClient_ShouldCloseIdleConnections(t *testing.T) {
+	tests := map[string]struct {
+		closeIdleAfter time.Duration
+		closeManually  bool
+	}{
+		"should close idle connections after the configured timeout": {
+			closeIdleAfter: 100 * time.Millisecond,
+		},
+		"should close idle connections on demand": {
+			closeManually: true,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			var (
+				connStatesMx sync.Mutex
+				connStates   []http.ConnState
+			)
+
+			server := httptest.NewUnstartedServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {}))
+			server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+				connStatesMx.Lock()
+				connStates = append(connStates, state)
+				connStatesMx.Unlock()
+			}
+			server.Start()
+			t.Cleanup(server.Close)
+
+			isClosed := func() bool {
+				connStatesMx.Lock()
+				defer connStatesMx.Unlock()
+				return len(connStates) > 0 && connStates[len(connStates)-1] == http.StateClosed
+			}
+
+			cfg := ClientConfig{}
+			flagext.DefaultValues(&cfg)
+			cfg.CloseIdleAfter = testData.closeIdleAfter
+			require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+			require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+			c, err := NewClient(cfg, log.NewNopLogger(), nil)
+			require.NoError(t, err)
+
+			_, err = c.WriteSeries(context.Background(), generateSineWaveSeries("test", time.Now(), 1))
+			require.NoError(t, err)
+
+			// The connection should be kept open right after the request.
+			assert.False(t, isClosed())
+
+			if testData.closeManually {
+				c.CloseIdleConnections()
+			}
+
+			require.Eventually(t, isClosed, time.Second, 10*time.Millisecond)
+		})
+	}
+}
+
+func TestClient_ShouldHonorWriteForceHTTP1(t *testing.T) {
+	for _, forceHTTP1 := range []bool{false, true} {
+		t.Run(fmt.Sprintf("force HTTP/1.1=%t", forceHTTP1), func(t *testing.T) {
+			var (
+				receivedMx     sync.Mutex
+				receivedProtos = map[string]int{}
+			)
+
+			server := httptest.NewUnstartedServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+				receivedMx.Lock()
+				receivedProtos[request.URL.Path] = request.ProtoMajor
+				receivedMx.Unlock()
+
+				if request.URL.Path != "/api/v1/push" {
+					writer.Header().Set("Content-Type", "application/json")
+					_, _ = writer.Write([]byte(`{"status":"success","data":{"resultType":"matrix","result":[]}}`))
+				}
+			}))
+			server.EnableHTTP2 = true
+			server.StartTLS()
+			t.Cleanup(server.Close)
+
+			cfg := ClientConfig{}
+			flagext.DefaultValues(&cfg)
+			cfg.WriteForceHTTP1 = forceHTTP1
+			cfg.TLS.InsecureSkipVerify = true
+			require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+			require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+			c, err := NewClient(cfg, log.NewNopLogger(), nil)
+			require.NoError(t, err)
+
+			_, err = c.WriteSeries(context.Background(), generateSineWaveSeries("test", time.Now(), 1))
+			require.NoError(t, err)
+
+			_, err = c.QueryRange(context.Background(), "test", time.Unix(1000, 0), time.Unix(2000, 0), 20*time.Second)
+			require.NoError(t, err)
+
+			receivedMx.Lock()
+			defer receivedMx.Unlock()
+
+			expectedWriteProto := 2
+			if forceHTTP1 {
+				expectedWriteProto = 1
+			}
+			assert.Equal(t, expectedWriteProto, receivedProtos["/api/v1/push"])
+
+			// The read path should not be affected.
+			assert.Equal(t, 2, receivedProtos["/api/v1/query_range"])
+		})
+	}
+}
+
+func TestClient_ShouldShareConnectionsBetweenReadAndWritePaths(t *testing.T) {
+	var (
+		newConnectionsMx sync.Mutex
+		newConnections   int
+	)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.URL.Path != "/api/v1/push" {
+			writer.Header().Set("Content-Type", "application/json")
+			_, _ = writer.Write([]byte(`{"status":"success","data":{"resultType":"matrix","result":[]}}`))
+		}
+	}))
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			newConnectionsMx.Lock()
+			newConnections++
+			newConnectionsMx.Unlock()
+		}
+	}
+	server.Start()
+	t.Cleanup(server.Close)
+
+	cfg := ClientConfig{}
+	flagext.DefaultValues(&cfg)
+	require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+	require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+	c, err := NewClient(cfg, log.NewNopLogger(), nil)
+	require.NoError(t, err)
+
+	// The read and write paths share the same round tripper, and so the same connections pool.
+	assert.Same(t, c.rt, c.writeClient.Transport)
+	assert.Same(t, c.rt, c.readRawClient.Transport)
+
+	for i := 0; i < 3; i++ {
+		_, err := c.WriteSeries(context.Background(), generateSineWaveSeries("test", time.Now(), 1))
+		require.NoError(t, err)
+
+		_, err = c.QueryRange(context.Background(), "test", time.Unix(1000, 0), time.Unix(2000, 0), 20*time.Second)
+		require.NoError(t, err)
+	}
+
+	newConnectionsMx.Lock()
+	defer newConnectionsMx.Unlock()
+	assert.Equal(t, 1, newConnections)
+}
+
+func TestClient_ShouldUseDedicatedWriteTransportIfEndpointsAreDifferentHosts(t *testing.T) {
+	tests := map[string]struct {
+		readEndpoint    string
+		writeEndpoint   string
+		customTransport bool
+		expectShared    bool
+	}{
+		"same host": {
+			readEndpoint:  "http://mimir:8080/prometheus",
+			writeEndpoint: "http://mimir:8080",
+			expectShared:  true,
+		},
+		"different hosts": {
+			readEndpoint:  "http://mimir-read:8080/prometheus",
+			writeEndpoint: "http://mimir-write:8080",
+			expectShared:  false,
+		},
+		"same host on a different port": {
+			readEndpoint:  "http://mimir:8080",
+			writeEndpoint: "http://mimir:9090",
+			expectShared:  false,
+		},
+		"different hosts with a custom transport": {
+			readEndpoint:    "http://mimir-read:8080",
+			writeEndpoint:   "http://mimir-write:8080",
+			customTransport: true,
+			expectShared:    true,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			cfg := ClientConfig{}
+			flagext.DefaultValues(&cfg)
+			require.NoError(t, cfg.WriteBaseEndpoint.Set(testData.writeEndpoint))
+			require.NoError(t, cfg.ReadBaseEndpoint.Set(testData.readEndpoint))
+			if testData.customTransport {
+				cfg.HTTPClient = &http.Client{Transport: http.DefaultTransport.(*http.Transport).Clone()}
+			}
+
+			c, err := NewClient(cfg, log.NewNopLogger(), nil)
+			require.NoError(t, err)
+
+			if testData.expectShared {
+				assert.Same(t, c.rt, c.writeClient.Transport)
+				return
+			}
+
+			// The write path should use a dedicated transport, while sharing the same headers and metrics.
+			assert.NotSame(t, c.rt, c.writeClient.Transport)
+			writeRT, ok := c.writeClient.Transport.(*clientRoundTripper)
+			require.True(t, ok)
+			assert.NotSame(t, c.rt.(*clientRoundTripper).rt, writeRT.rt)
+			assert.Same(t, c.metrics, writeRT.metrics)
+		})
+	}
+}
+
+func TestClient_QueryRangeRaw(t *testing.T) {
+	const responseBody = `{"status":"success","data":{"resultType":"matrix","result":[]}}`
+
+	var receivedRequest *http.Request
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		receivedRequest = request
+
+		writer.Header().Set("Content-Type", "application/json")
+		writer.WriteHeader(http.StatusTeapot)
+		_, _ = writer.Write([]byte(responseBody))
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := ClientConfig{}
+	flagext.DefaultValues(&cfg)
+	require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+	require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+	c, err := NewClient(cfg, log.NewNopLogger(), nil)
+	require.NoError(t, err)
+
+	body, statusCode, err := c.QueryRangeRaw(context.Background(), "sum(test)", v1.Range{
+		Start: time.Unix(1000, 0),
+		End:   time.Unix(2000, 500*int64(time.Millisecond)),
+		Step:  20 * time.Second,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusTeapot, statusCode)
+	assert.Equal(t, responseBody, string(body))
+
+	require.NotNil(t, receivedRequest)
+	assert.Equal(t, "/api/v1/query_range", receivedRequest.URL.Path)
+	assert.Equal(t, "sum(test)", receivedRequest.URL.Query().Get("query"))
+	assert.Equal(t, "1000", receivedRequest.URL.Query().Get("start"))
+	assert.Equal(t, "2000.5", receivedRequest.URL.Query().Get("end"))
+	assert.Equal(t, "20", receivedRequest.URL.Query().Get("step"))
+	assert.Equal(t, "anonymous", receivedRequest.Header.Get("X-Scope-OrgID"))
+}
+
+func TestClient_QueryRange_ShouldCompressRequestBodyIfEnabled(t *testing.T) {
+	for _, compress := range []bool{false, true} {
+		t.Run(fmt.Sprintf("compress=%t", compress), func(t *testing.T) {
+			var (
+				receivedMethod   string
+				receivedEncoding string
+				receivedQuery    string
+			)
+
+			server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+				receivedMethod = request.Method
+				receivedEncoding = request.Header.Get("Content-Encoding")
+
+				body := io.Reader(request.Body)
+				if receivedEncoding == "gzip" {
+					gz, err := gzip.NewReader(request.Body)
+					require.NoError(t, err)
+					body = gz
+				}
+
+				decoded, err := ioutil.ReadAll(body)
+				require.NoError(t, err)
+
+				values, err := url.ParseQuery(string(decoded))
+				require.NoError(t, err)
+				receivedQuery = values.Get("query")
+
+				writer.Header().Set("Content-Type", "application/json")
+				_, _ = writer.Write([]byte(`{"status":"success","data":{"resultType":"matrix","result":[]}}`))
+			}))
+			t.Cleanup(server.Close)
+
+			cfg := ClientConfig{}
+			flagext.DefaultValues(&cfg)
+			cfg.ReadCompressRequest = compress
+			require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+			require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+			c, err := NewClient(cfg, log.NewNopLogger(), nil)
+			require.NoError(t, err)
+
+			_, err = c.QueryRange(context.Background(), `sum(test{job=~"a|b"})`, time.Unix(1000, 0), time.Unix(2000, 0), 20*time.Second)
+			require.NoError(t, err)
+
+			assert.Equal(t, http.MethodPost, receivedMethod)
+			assert.Equal(t, `sum(test{job=~"a|b"})`, receivedQuery)
+			if compress {
+				assert.Equal(t, "gzip", receivedEncoding)
+			} else {
+				assert.Empty(t, receivedEncoding)
+			}
+		})
+	}
+}
+
+func TestClient_Query(t *testing.T) {
+	var responseBody string
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		require.Equal(t, "/api/v1/query", request.URL.Path)
+
+		writer.Header().Set("Content-Type", "application/json")
+		_, _ = writer.Write([]byte(responseBody))
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := ClientConfig{}
+	flagext.DefaultValues(&cfg)
+	require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+	require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+	c, err := NewClient(cfg, log.NewNopLogger(), nil)
+	require.NoError(t, err)
+
+	ts := time.Unix(1000, 0)
+
+	t.Run("scalar", func(t *testing.T) {
+		responseBody = `{"status":"success","data":{"resultType":"scalar","result":[1000,"3.5"]}}`
+		expected := &model.Scalar{Timestamp: 1000000, Value: 3.5}
+
+		value, err := c.Query(context.Background(), "scalar(test)", ts)
+		require.NoError(t, err)
+		assert.Equal(t, expected, value)
+
+		scalar, err := c.QueryScalar(context.Background(), "scalar(test)", ts)
+		require.NoError(t, err)
+		assert.Equal(t, expected, scalar)
+
+		_, err = c.QueryVector(context.Background(), "scalar(test)", ts)
+		assert.EqualError(t, err, "was expecting to get a vector but got scalar")
+	})
+
+	t.Run("string", func(t *testing.T) {
+		responseBody = `{"status":"success","data":{"resultType":"string","result":[1000,"hello"]}}`
+		expected := &model.String{Timestamp: 1000000, Value: "hello"}
+
+		value, err := c.Query(context.Background(), `"hello"`, ts)
+		require.NoError(t, err)
+		assert.Equal(t, expected, value)
+
+		str, err := c.QueryString(context.Background(), `"hello"`, ts)
+		require.NoError(t, err)
+		assert.Equal(t, expected, str)
+
+		_, err = c.QueryVector(context.Background(), `"hello"`, ts)
+		assert.EqualError(t, err, "was expecting to get a vector but got string")
+	})
+
+	t.Run("vector", func(t *testing.T) {
+		responseBody = `{"status":"success","data":{"resultType":"vector","result":[{"metric":{"__name__":"test"},"value":[1000,"1"]}]}}`
+		expected := model.Vector{{Metric: model.Metric{"__name__": "test"}, Timestamp: 1000000, Value: 1}}
+
+		vector, err := c.QueryVector(context.Background(), "test", ts)
+		require.NoError(t, err)
+		assert.Equal(t, expected, vector)
+
+		_, err = c.QueryScalar(context.Background(), "test", ts)
+		assert.EqualError(t, err, "was expecting to get a scalar but got vector")
+	})
+}
+
+func TestClient_QueryRangeCacheProbe(t *testing.T) {
+	var receivedCacheControl []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		receivedCacheControl = append(receivedCacheControl, request.Header.Get("Cache-Control"))
+
+		// Simulate a stale cached empty result, returned unless the cache is bypassed.
+		writer.Header().Set("Content-Type", "application/json")
+		if request.Header.Get("Cache-Control") == "no-store" {
+			_, _ = writer.Write([]byte(`{"status":"success","data":{"resultType":"matrix","result":[{"metric":{"__name__":"test"},"values":[[1000,"1"],[1020,"2"]]}]}}`))
+		} else {
+			_, _ = writer.Write([]byte(`{"status":"success","data":{"resultType":"matrix","result":[]}}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := ClientConfig{}
+	flagext.DefaultValues(&cfg)
+	require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+	require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+	c, err := NewClient(cfg, log.NewNopLogger(), nil)
+	require.NoError(t, err)
+
+	cached, uncached, err := c.QueryRangeCacheProbe(context.Background(), "test", v1.Range{Start: time.Unix(1000, 0), End: time.Unix(1020, 0), Step: 20 * time.Second})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"", "no-store"}, receivedCacheControl)
+	assert.Equal(t, model.Matrix{}, cached)
+	assert.Equal(t, model.Matrix{{
+		Metric: model.Metric{"__name__": "test"},
+		Values: []model.SamplePair{{Timestamp: 1000000, Value: 1}, {Timestamp: 1020000, Value: 2}},
+	}}, uncached)
+}
+
+func TestClient_QueryRange_ShouldSendQueryTimeout(t *testing.T) {
+	var receivedRequests []*http.Request
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		require.NoError(t, request.ParseForm())
+		receivedRequests = append(receivedRequests, request)
+
+		writer.Header().Set("Content-Type", "application/json")
+		_, _ = writer.Write([]byte(`{"status":"success","data":{"resultType":"matrix","result":[]}}`))
+	}))
+	t.Cleanup(server.Close)
+
+	for _, queryTimeout := range []time.Duration{0, 90 * time.Second, 1500 * time.Millisecond} {
+		t.Run(queryTimeout.String(), func(t *testing.T) {
+			receivedRequests = nil
+
+			cfg := ClientConfig{}
+			flagext.DefaultValues(&cfg)
+			cfg.QueryTimeout = queryTimeout
+			require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+			require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+			c, err := NewClient(cfg, log.NewNopLogger(), nil)
+			require.NoError(t, err)
+
+			_, err = c.QueryRange(context.Background(), "sum(test)", time.Unix(1000, 0), time.Unix(2000, 0), 20*time.Second)
+			require.NoError(t, err)
+
+			_, _, err = c.QueryRangeRaw(context.Background(), "sum(test)", v1.Range{Start: time.Unix(1000, 0), End: time.Unix(2000, 0), Step: 20 * time.Second})
+			require.NoError(t, err)
+
+			require.Len(t, receivedRequests, 2)
+			for _, req := range receivedRequests {
+				assert.Equal(t, "sum(test)", req.Form.Get("query"))
+
+				if queryTimeout == 0 {
+					assert.NotContains(t, req.Form, "timeout")
+				} else {
+					actual, err := strconv.ParseFloat(req.Form.Get("timeout"), 64)
+					require.NoError(t, err)
+					assert.Equal(t, queryTimeout.Seconds(), actual)
+				}
+			}
+		})
+	}
+}
+
+func TestClient_BuildInfo(t *testing.T) {
+	t.Run("should parse the build info returned by the server", func(t *testing.T) {
+		var receivedPath string
+
+		buildInfoHandler := version.BuildInfoHandler("Grafana Mimir", version.BuildInfoFeatures{
+			QuerySharding:  "true",
+			RulerConfigAPI: "false",
+		})
+
+		server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			receivedPath = request.URL.Path
+			buildInfoHandler.ServeHTTP(writer, request)
+		}))
+		t.Cleanup(server.Close)
+
+		cfg := ClientConfig{}
+		flagext.DefaultValues(&cfg)
+		require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+		require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+		c, err := NewClient(cfg, log.NewNopLogger(), nil)
+		require.NoError(t, err)
+
+		info, err := c.BuildInfo(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "/api/v1/status/buildinfo", receivedPath)
+		assert.Equal(t, BuildInfo{
+			Application: "Grafana Mimir",
+			Version:     version.Version,
+			Revision:    version.Revision,
+			Branch:      version.Branch,
+			GoVersion:   version.GoVersion,
+			Features: map[string]string{
+				"query_sharding":   "true",
+				"ruler_config_api": "false",
+			},
+		}, info)
+	})
+
+	t.Run("should return error on non-2xx response", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			http.Error(writer, "not found", http.StatusNotFound)
+		}))
+		t.Cleanup(server.Close)
+
+		cfg := ClientConfig{}
+		flagext.DefaultValues(&cfg)
+		require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+		require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+		c, err := NewClient(cfg, log.NewNopLogger(), nil)
+		require.NoError(t, err)
+
+		_, err = c.BuildInfo(context.Background())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "404")
+	})
+}
+
+func TestClient_LabelValues(t *testing.T) {
+	tests := map[string]struct {
+		limit             int
+		response          string
+		expectedValues    model.LabelValues
+		expectedTruncated bool
+	}{
+		"should return all values if the limit is disabled": {
+			response:       `{"status":"success","data":["a","b","c"]}`,
+			expectedValues: model.LabelValues{"a", "b", "c"},
+		},
+		"should not flag the result as truncated if the server returns less values than the limit": {
+			limit:          3,
+			response:       `{"status":"success","data":["a","b"]}`,
+			expectedValues: model.LabelValues{"a", "b"},
+		},
+		"should flag the result as truncated if the server reports it": {
+			limit:             2,
+			response:          `{"status":"success","data":["a","b"],"warnings":["results truncated due to limit"]}`,
+			expectedValues:    model.LabelValues{"a", "b"},
+			expectedTruncated: true,
+		},
+		"should truncate the result if the server returns more values than the limit": {
+			limit:             2,
+			response:          `{"status":"success","data":["a","b","c"]}`,
+			expectedValues:    model.LabelValues{"a", "b"},
+			expectedTruncated: true,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			var receivedRequest *http.Request
+
+			server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+				receivedRequest = request
+				writer.Header().Set("Content-Type", "application/json")
+				_, _ = writer.Write([]byte(testData.response))
+			}))
+			t.Cleanup(server.Close)
+
+			cfg := ClientConfig{}
+			flagext.DefaultValues(&cfg)
+			require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+			require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+			c, err := NewClient(cfg, log.NewNopLogger(), nil)
+			require.NoError(t, err)
+
+			start, end := time.Unix(1000, 0), time.Unix(2000, 0)
+			values, truncated, err := c.LabelValues(context.Background(), "series_id", []string{`{__name__="test"}`}, start, end, testData.limit)
+			require.NoError(t, err)
+			assert.Equal(t, testData.expectedValues, values)
+			assert.Equal(t, testData.expectedTruncated, truncated)
+
+			require.NotNil(t, receivedRequest)
+			assert.Equal(t, "/api/v1/label/series_id/values", receivedRequest.URL.Path)
+			assert.Equal(t, []string{`{__name__="test"}`}, receivedRequest.URL.Query()["match[]"])
+			assert.Equal(t, "1000", receivedRequest.URL.Query().Get("start"))
+			assert.Equal(t, "2000", receivedRequest.URL.Query().Get("end"))
+
+			if testData.limit > 0 {
+				assert.Equal(t, strconv.Itoa(testData.limit), receivedRequest.URL.Query().Get("limit"))
+			} else {
+				assert.False(t, receivedRequest.URL.Query().Has("limit"))
+			}
+		})
+	}
+
+	t.Run("should return error on non-2xx response", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			http.Error(writer, "bad request", http.StatusBadRequest)
+		}))
+		t.Cleanup(server.Close)
+
+		cfg := ClientConfig{}
+		flagext.DefaultValues(&cfg)
+		require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+		require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+		c, err := NewClient(cfg, log.NewNopLogger(), nil)
+		require.NoError(t, err)
+
+		_, _, err = c.LabelValues(context.Background(), "series_id", nil, time.Time{}, time.Time{}, 10)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "400")
+	})
+}
+
+func TestClient_ShouldTrackRequestMetricsByTenant(t *testing.T) {
+	var receivedTenants []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		receivedTenants = append(receivedTenants, request.Header.Get("X-Scope-OrgID"))
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := ClientConfig{}
+	flagext.DefaultValues(&cfg)
+	cfg.TenantID = "tenant-1"
+	cfg.MetricsTenantAllowlist = []string{"tenant-2"}
+	require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+	require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+	reg := prometheus.NewPedanticRegistry()
+	c, err := NewClient(cfg, log.NewNopLogger(), reg)
+	require.NoError(t, err)
+
+	series := generateSineWaveSeries("test", time.Now(), 1)
+	for _, ctx := range []context.Context{
+		context.Background(),
+		user.InjectOrgID(context.Background(), "tenant-2"),
+		user.InjectOrgID(context.Background(), "tenant-2"),
+		user.InjectOrgID(context.Background(), "tenant-3"),
+	} {
+		_, err = c.WriteSeries(ctx, series)
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, []string{"tenant-1", "tenant-2", "tenant-2", "tenant-3"}, receivedTenants)
+
+	// Count the tracked requests by tenant.
+	families, err := reg.Gather()
+	require.NoError(t, err)
+
+	actual := map[string]uint64{}
+	for _, family := range families {
+		if family.GetName() != "mimir_continuous_test_client_request_duration_seconds" {
+			continue
+		}
+
+		for _, metric := range family.GetMetric() {
+			for _, pair := range metric.GetLabel() {
+				if pair.GetName() == "tenant" {
+					actual[pair.GetValue()] += metric.GetHistogram().GetSampleCount()
+				}
+			}
+		}
+	}
+
+	assert.Equal(t, map[string]uint64{"tenant-1": 1, "tenant-2": 2, "other": 1}, actual)
+}
+
+func TestClient_ShouldTrackSuccessRatio(t *testing.T) {
+	var writeStatusCodes []int
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.URL.Path == "/api/v1/push" {
+			statusCode := writeStatusCodes[0]
+			writeStatusCodes = writeStatusCodes[1:]
+			writer.WriteHeader(statusCode)
+			return
+		}
+
+		writer.Header().Set("Content-Type", "application/json")
+		_, _ = writer.Write([]byte(`{"status":"success","data":{"resultType":"matrix","result":[]}}`))
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := ClientConfig{}
+	flagext.DefaultValues(&cfg)
+	cfg.SuccessRatioWindowSize = 4
+	require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+	require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+	reg := prometheus.NewPedanticRegistry()
+	c, err := NewClient(cfg, log.NewNopLogger(), reg)
+	require.NoError(t, err)
+
+	// The oldest 2 outcomes fall out of the window.
+	writeStatusCodes = []int{500, 500, 200, 500, 200, 200}
+	series := generateSineWaveSeries("test", time.Now(), 1)
+	for range writeStatusCodes {
+		_, _ = c.WriteSeries(context.Background(), series)
+	}
+
+	_, err = c.QueryRange(context.Background(), "sum(test)", time.Unix(1000, 0), time.Unix(2000, 0), 20*time.Second)
+	require.NoError(t, err)
+
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP mimir_continuous_test_client_success_ratio Ratio of successful requests over the most recent requests, by operation.
+		# TYPE mimir_continuous_test_client_success_ratio gauge
+		mimir_continuous_test_client_success_ratio{operation="read"} 1
+		mimir_continuous_test_client_success_ratio{operation="write"} 0.75
+	`), "mimir_continuous_test_client_success_ratio"))
+}
+
+func TestClient_ShouldRegisterMetricsWithCustomPrefix(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := ClientConfig{}
+	flagext.DefaultValues(&cfg)
+	require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+	require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+	// Register two clients to the same registry, one with the default and one with a custom prefix.
+	reg := prometheus.NewPedanticRegistry()
+	defaultClient, err := NewClient(cfg, log.NewNopLogger(), reg)
+	require.NoError(t, err)
+
+	cfg.MetricsPrefix = "custom_"
+	customClient, err := NewClient(cfg, log.NewNopLogger(), reg)
+	require.NoError(t, err)
+
+	series := generateSineWaveSeries("test", time.Now(), 1)
+	_, err = defaultClient.WriteSeries(context.Background(), series)
+	require.NoError(t, err)
+	_, err = customClient.WriteSeries(context.Background(), series)
+	require.NoError(t, err)
+
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP custom_mimir_continuous_test_client_success_ratio Ratio of successful requests over the most recent requests, by operation.
+		# TYPE custom_mimir_continuous_test_client_success_ratio gauge
+		custom_mimir_continuous_test_client_success_ratio{operation="write"} 1
+
+		# HELP mimir_continuous_test_client_success_ratio Ratio of successful requests over the most recent requests, by operation.
+		# TYPE mimir_continuous_test_client_success_ratio gauge
+		mimir_continuous_test_client_success_ratio{operation="write"} 1
+	`), "mimir_continuous_test_client_success_ratio", "custom_mimir_continuous_test_client_success_ratio"))
+
+	families, err := reg.Gather()
+	require.NoError(t, err)
+
+	var names []string
+	for _, m := range families {
+		names = append(names, m.GetName())
+	}
+	assert.Contains(t, names, "custom_mimir_continuous_test_client_request_duration_seconds")
+	assert.Contains(t, names, "custom_mimir_continuous_test_client_write_circuit_state")
+}
+
+func TestClient_ShouldSetHeadersFromTemplates(t *testing.T) {
+	var receivedHeaders http.Header
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		receivedHeaders = request.Header.Clone()
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := ClientConfig{}
+	flagext.DefaultValues(&cfg)
+	cfg.TenantID = "tenant-1"
+	cfg.HeaderTemplates = []string{"X-Mimir-Route=shard-{tenant}", "X-Static= static-value"}
+	require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+	require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+	c, err := NewClient(cfg, log.NewNopLogger(), nil)
+	require.NoError(t, err)
+
+	series := generateSineWaveSeries("test", time.Now(), 1)
+
+	_, err = c.WriteSeries(context.Background(), series)
+	require.NoError(t, err)
+	assert.Equal(t, "shard-tenant-1", receivedHeaders.Get("X-Mimir-Route"))
+	assert.Equal(t, "static-value", receivedHeaders.Get("X-Static"))
+
+	_, err = c.WriteSeries(user.InjectOrgID(context.Background(), "tenant-2"), series)
+	require.NoError(t, err)
+	assert.Equal(t, "shard-tenant-2", receivedHeaders.Get("X-Mimir-Route"))
+
+	t.Run("should fail on invalid header template", func(t *testing.T) {
+		cfg.HeaderTemplates = []string{"invalid"}
+
+		_, err := NewClient(cfg, log.NewNopLogger(), nil)
+		require.EqualError(t, err, `invalid header template "invalid": expected format is name=value`)
+	})
+}
+
+func TestClient_ShouldSetOriginHeaders(t *testing.T) {
+	receivedHeaders := map[string]http.Header{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		receivedHeaders[request.URL.Path] = request.Header.Clone()
+
+		if request.URL.Path == "/api/v1/query_range" {
+			writer.Header().Set("Content-Type", "application/json")
+			_, _ = writer.Write([]byte(`{"status":"success","data":{"resultType":"matrix","result":[]}}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	tests := map[string]struct {
+		origin, referer string
+		originOnReads   bool
+		expectedWrite   [2]string
+		expectedRead    [2]string
+	}{
+		"not configured": {},
+		"configured on writes only": {
+			origin:        "https://mimir.example.com",
+			referer:       "https://mimir.example.com/continuous-test",
+			expectedWrite: [2]string{"https://mimir.example.com", "https://mimir.example.com/continuous-test"},
+		},
+		"configured on writes and reads": {
+			origin:        "https://mimir.example.com",
+			referer:       "https://mimir.example.com/continuous-test",
+			originOnReads: true,
+			expectedWrite: [2]string{"https://mimir.example.com", "https://mimir.example.com/continuous-test"},
+			expectedRead:  [2]string{"https://mimir.example.com", "https://mimir.example.com/continuous-test"},
+		},
+		"only origin configured": {
+			origin:        "https://mimir.example.com",
+			expectedWrite: [2]string{"https://mimir.example.com", ""},
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			receivedHeaders = map[string]http.Header{}
+
+			cfg := ClientConfig{}
+			flagext.DefaultValues(&cfg)
+			cfg.Origin = testData.origin
+			cfg.Referer = testData.referer
+			cfg.OriginOnReads = testData.originOnReads
+			require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+			require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+			c, err := NewClient(cfg, log.NewNopLogger(), nil)
+			require.NoError(t, err)
+
+			now := time.Now()
+
+			_, err = c.WriteSeries(context.Background(), generateSineWaveSeries("test", now, 1))
+			require.NoError(t, err)
+
+			_, err = c.QueryRange(context.Background(), "test", now.Add(-time.Minute), now, time.Minute)
+			require.NoError(t, err)
+
+			write := receivedHeaders["/api/v1/push"]
+			assert.Equal(t, testData.expectedWrite, [2]string{write.Get("Origin"), write.Get("Referer")})
+
+			read := receivedHeaders["/api/v1/query_range"]
+			assert.Equal(t, testData.expectedRead, [2]string{read.Get("Origin"), read.Get("Referer")})
+		})
+	}
+}
+
+func TestClient_WriteSeries_ShouldShardWriteEndpointsByTenant(t *testing.T) {
+	const numServers = 3
+
+	var (
+		urls            []string
+		receivedTenants = make([][]string, numServers)
+	)
+
+	for i := 0; i < numServers; i++ {
+		i := i
+
+		server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			receivedTenants[i] = append(receivedTenants[i], request.Header.Get("X-Scope-OrgID"))
+		}))
+		t.Cleanup(server.Close)
+
+		urls = append(urls, server.URL)
+	}
+
+	cfg := ClientConfig{}
+	flagext.DefaultValues(&cfg)
+	cfg.WriteShardedEndpoints = urls
+	require.NoError(t, cfg.ReadBaseEndpoint.Set(urls[0]))
+
+	c, err := NewClient(cfg, log.NewNopLogger(), nil)
+	require.NoError(t, err)
+
+	series := generateSineWaveSeries("test", time.Now(), 1)
+	tenants := []string{"tenant-1", "tenant-2", "tenant-3", "tenant-4", "tenant-5", "tenant-6"}
+
+	for run := 0; run < 3; run++ {
+		for _, tenant := range tenants {
+			_, err := c.WriteSeries(user.InjectOrgID(context.Background(), tenant), series)
+			require.NoError(t, err)
+		}
+	}
+
+	// Each tenant should have been consistently written to the same endpoint.
+	tenantEndpoints := map[string]int{}
+	for endpoint, received := range receivedTenants {
+		for _, tenant := range received {
+			if prev, ok := tenantEndpoints[tenant]; ok {
+				assert.Equal(t, prev, endpoint, "tenant %s has been written to multiple endpoints", tenant)
+			}
+			tenantEndpoints[tenant] = endpoint
+		}
+	}
+
+	assert.Len(t, tenantEndpoints, len(tenants))
+
+	// Tenants should be spread across more than one endpoint.
+	assert.Greater(t, len(uniqueInts(tenantEndpoints)), 1)
+}
+
+func uniqueInts(values map[string]int) map[int]struct{} {
+	out := map[int]struct{}{}
+	for _, v := range values {
+		out[v] = struct{}{}
+	}
+	return out
+}
+
+func TestClient_getRequestTimeout(t *testing.T) {
+	newClient := func(t *testing.T, logs *bytes.Buffer) *Client {
+		cfg := ClientConfig{}
+		flagext.DefaultValues(&cfg)
+		require.NoError(t, cfg.WriteBaseEndpoint.Set("http://localhost"))
+		require.NoError(t, cfg.ReadBaseEndpoint.Set("http://localhost"))
+
+		c, err := NewClient(cfg, log.NewLogfmtLogger(logs), nil)
+		require.NoError(t, err)
+		return c
+	}
+
+	t.Run("should return the request timeout if the context has no deadline", func(t *testing.T) {
+		logs := &bytes.Buffer{}
+		c := newClient(t, logs)
+
+		assert.Equal(t, 5*time.Second, c.getRequestTimeout(context.Background(), 5*time.Second))
+		assert.Empty(t, logs.String())
+	})
+
+	t.Run("should return the request timeout if shorter than the time left before the context deadline", func(t *testing.T) {
+		logs := &bytes.Buffer{}
+		c := newClient(t, logs)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+		defer cancel()
+
+		assert.Equal(t, 5*time.Second, c.getRequestTimeout(ctx, 5*time.Second))
+		assert.Empty(t, logs.String())
+	})
+
+	t.Run("should log the timeout capped to the time left before the context deadline if shorter than the request timeout", func(t *testing.T) {
+		logs := &bytes.Buffer{}
+		c := newClient(t, logs)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		actual := c.getRequestTimeout(ctx, 5*time.Second)
+		assert.LessOrEqual(t, actual, time.Second)
+		assert.Greater(t, actual, time.Duration(0))
+		assert.Contains(t, logs.String(), "Request timeout capped to the time left before the deadline of the caller")
+		assert.Contains(t, logs.String(), "timeout=5s")
+	})
+}
+
+func TestToMatrix(t *testing.T) {
+	t.Run("should return the input matrix without copying it", func(t *testing.T) {
+		input := generateLargeMatrix(100, 10)
+		value := model.Value(input)
+
+		var (
+			actual model.Matrix
+			err    error
+		)
+		allocs := testing.AllocsPerRun(100, func() {
+			actual, err = toMatrix(value)
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 0.0, allocs)
+		require.Len(t, actual, len(input))
+		assert.Same(t, &input[0], &actual[0])
+	})
+
+	t.Run("should fail if the input value is not a matrix", func(t *testing.T) {
+		_, err := toMatrix(model.Vector{})
+		require.EqualError(t, err, "was expecting to get a Matrix")
+	})
+}
+
+func BenchmarkClient_QueryRange(b *testing.B) {
+	body, err := json.Marshal(map[string]interface{}{
+		"status": "success",
+		"data": map[string]interface{}{
+			"resultType": "matrix",
+			"result":     generateLargeMatrix(1000, 100),
+		},
+	})
+	require.NoError(b, err)
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.Header().Set("Content-Type", "application/json")
+		_, _ = writer.Write(body)
+	}))
+	b.Cleanup(server.Close)
+
+	cfg := ClientConfig{}
+	flagext.DefaultValues(&cfg)
+	require.NoError(b, cfg.WriteBaseEndpoint.Set(server.URL))
+	require.NoError(b, cfg.ReadBaseEndpoint.Set(server.URL))
+
+	c, err := NewClient(cfg, log.NewNopLogger(), nil)
+	require.NoError(b, err)
+
+	ctx := context.Background()
+	now := time.Now()
+
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		matrix, err := c.QueryRange(ctx, "test", now.Add(-time.Hour), now, time.Minute)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if len(matrix) != 1000 {
+			b.Fatalf("unexpected number of series: %d", len(matrix))
+		}
+	}
+}
+
+func BenchmarkToMatrix(b *testing.B) {
+	input := model.Value(generateLargeMatrix(1000, 100))
+
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := toMatrix(input); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// generateLargeMatrix returns a matrix with the input number of series and samples per series.
+func generateLargeMatrix(numSeries, numSamples int) model.Matrix {
+	out := make(model.Matrix, 0, numSeries)
+
+	for i := 0; i < numSeries; i++ {
+		values := make([]model.SamplePair, 0, numSamples)
+		for j := 0; j < numSamples; j++ {
+			values = append(values, model.SamplePair{Timestamp: model.Time(j * 60000), Value: model.SampleValue(j)})
+		}
+
+		out = append(out, &model.SampleStream{
+			Metric: model.Metric{"__name__": "test", "series_id": model.LabelValue(strconv.Itoa(i))},
+			Values: values,
+		})
+	}
+
+	return out
+}
+
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// ClientMock mocks MimirClient.
+type ClientMock struct {
+	mock.Mock
+}
+
+func (m *ClientMock) WriteSeries(ctx context.Context, series []prompb.TimeSeries) (int, error) {
+	args := m.Called(ctx, series)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *ClientMock) QueryRange(ctx context.Context, query string, start, end time.Time, step time.Duration) (model.Matrix, error) {
+	args := m.Called(ctx, query, start, end, step)
+	return args.Get(0).(model.Matrix), args.Error(1)
+}
+
+func (m *ClientMock) Query(ctx context.Context, query string, ts time.Time) (model.Value, error) {
+	args := m.Called(ctx, query, ts)
+	if v := args.Get(0); v != nil {
+		return v.(model.Value), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *ClientMock) BuildInfo(ctx context.Context) (BuildInfo, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(BuildInfo), args.Error(1)
+}
+
+func (m *ClientMock) LabelValues(ctx context.Context, label string, matches []string, start, end time.Time, limit int) (model.LabelValues, bool, error) {
+	args := m.Called(ctx, label, matches, start, end, limit)
+	return args.Get(0).(model.LabelValues), args.Bool(1), args.Error(2)
 }
@@ -0,0 +1,63 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"flag"
+	"os"
+	"strings"
+
+	"github.com/go-kit/log"
+	"github.com/pkg/errors"
+)
+
+// clientEnvPrefix is the prefix of the environment variables read by ClientConfigFromEnv.
+const clientEnvPrefix = "MIMIR_TEST_"
+
+// NewClientFromEnv creates a client configured from MIMIR_TEST_* environment variables,
+// as documented by ClientConfigFromEnv.
+func NewClientFromEnv(logger log.Logger) (*Client, error) {
+	cfg, err := ClientConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	return NewClient(cfg, logger, nil)
+}
+
+// ClientConfigFromEnv returns the client config read from environment variables. Each CLI flag of
+// the client config can be set with an environment variable named after the flag, without the tests.
+// prefix, upper-cased, with dashes and dots replaced by underscores and prefixed with MIMIR_TEST_. For
+// example, -tests.write-endpoint is read from MIMIR_TEST_WRITE_ENDPOINT. Options not set via
+// environment variables have the flag default value.
+func ClientConfigFromEnv() (ClientConfig, error) {
+	cfg := ClientConfig{}
+
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	cfg.RegisterFlags(fs)
+
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil {
+			return
+		}
+
+		value, ok := os.LookupEnv(flagToEnvName(f.Name))
+		if !ok {
+			return
+		}
+
+		if setErr := fs.Set(f.Name, value); setErr != nil {
+			err = errors.Wrapf(setErr, "invalid value %q for environment variable %s", value, flagToEnvName(f.Name))
+		}
+	})
+
+	return cfg, err
+}
+
+// flagToEnvName returns the name of the environment variable used to set the input client config flag.
+func flagToEnvName(flagName string) string {
+	name := strings.TrimPrefix(flagName, "tests.")
+	name = strings.NewReplacer("-", "_", ".", "_").Replace(name)
+	return clientEnvPrefix + strings.ToUpper(name)
+}
@@ -0,0 +1,61 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/flagext"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFlagToEnvName(t *testing.T) {
+	assert.Equal(t, "MIMIR_TEST_WRITE_ENDPOINT", flagToEnvName("tests.write-endpoint"))
+	assert.Equal(t, "MIMIR_TEST_TENANT_ID", flagToEnvName("tests.tenant-id"))
+	assert.Equal(t, "MIMIR_TEST_WRITE_PAUSE_ON_UNHEALTHY_BACKOFF_MIN_PERIOD", flagToEnvName("tests.write-pause-on-unhealthy.backoff-min-period"))
+}
+
+func TestClientConfigFromEnv(t *testing.T) {
+	t.Run("should read the config from environment variables, falling back to defaults", func(t *testing.T) {
+		t.Setenv("MIMIR_TEST_WRITE_ENDPOINT", "http://distributor:8080")
+		t.Setenv("MIMIR_TEST_READ_ENDPOINT", "http://query-frontend:8080/prometheus")
+		t.Setenv("MIMIR_TEST_TENANT_ID", "tenant-1")
+		t.Setenv("MIMIR_TEST_WRITE_TIMEOUT", "10s")
+		t.Setenv("MIMIR_TEST_WRITE_BATCH_SIZE", "500")
+		t.Setenv("MIMIR_TEST_METRICS_TENANT_ALLOWLIST", "tenant-2,tenant-3")
+
+		cfg, err := ClientConfigFromEnv()
+		require.NoError(t, err)
+
+		expected := ClientConfig{}
+		flagext.DefaultValues(&expected)
+		require.NoError(t, expected.WriteBaseEndpoint.Set("http://distributor:8080"))
+		require.NoError(t, expected.ReadBaseEndpoint.Set("http://query-frontend:8080/prometheus"))
+		expected.TenantID = "tenant-1"
+		expected.WriteTimeout = 10 * time.Second
+		expected.WriteBatchSize = 500
+		expected.MetricsTenantAllowlist = []string{"tenant-2", "tenant-3"}
+
+		assert.Equal(t, expected, cfg)
+
+		c, err := NewClientFromEnv(log.NewNopLogger())
+		require.NoError(t, err)
+		assert.Equal(t, expected, c.cfg)
+	})
+
+	t.Run("should fail on invalid value", func(t *testing.T) {
+		t.Setenv("MIMIR_TEST_READ_TIMEOUT", "invalid")
+
+		_, err := ClientConfigFromEnv()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "MIMIR_TEST_READ_TIMEOUT")
+	})
+
+	t.Run("should fail creating the client if the endpoints are not set", func(t *testing.T) {
+		_, err := NewClientFromEnv(log.NewNopLogger())
+		require.EqualError(t, err, "the write endpoint has not been set")
+	})
+}
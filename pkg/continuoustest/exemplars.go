@@ -0,0 +1,135 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// exemplarTraceIDLabel is the exemplar label linking the exemplar to a trace.
+const exemplarTraceIDLabel = "trace_id"
+
+// ErrExemplarMismatch is returned by VerifyExemplars when the queried exemplars don't match the written ones.
+var ErrExemplarMismatch = errors.New("the queried exemplars don't match the written ones")
+
+// VerifyExemplars checks the exemplars returned by an exemplars query against the exemplars of the written series.
+// Each written exemplar must be returned with the same trace ID and value, and a timestamp within maxDrift from
+// both the written exemplar and a float sample of the series. Each queried exemplar must carry a valid trace ID.
+// Returns ErrExemplarMismatch reporting the first divergence.
+func VerifyExemplars(written []prompb.TimeSeries, queried []v1.ExemplarQueryResult, maxDrift time.Duration) error {
+	driftMs := maxDrift.Milliseconds()
+
+	// Index the queried exemplars by series, checking each of them is linked to a valid trace.
+	queriedBySeries := make(map[model.Fingerprint][]v1.Exemplar, len(queried))
+	for _, result := range queried {
+		for _, e := range result.Exemplars {
+			if err := validateExemplarTraceID(e.Labels); err != nil {
+				return errors.Wrapf(ErrExemplarMismatch, "series %s: exemplar at %d: %s", result.SeriesLabels.String(), int64(e.Timestamp), err.Error())
+			}
+		}
+
+		fp := result.SeriesLabels.Fingerprint()
+		queriedBySeries[fp] = append(queriedBySeries[fp], result.Exemplars...)
+	}
+
+	for _, series := range written {
+		if len(series.Exemplars) == 0 {
+			continue
+		}
+
+		seriesLabels := prompbLabelsToLabelSet(series.Labels)
+		actual := queriedBySeries[seriesLabels.Fingerprint()]
+
+		for _, expected := range series.Exemplars {
+			traceID := getExemplarLabel(expected.Labels, exemplarTraceIDLabel)
+
+			e, ok := findExemplarByTraceID(actual, traceID)
+			if !ok {
+				return errors.Wrapf(ErrExemplarMismatch, "series %s: exemplar with trace ID %q at %d is missing", seriesLabels.String(), traceID, expected.Timestamp)
+			}
+
+			if drift := absInt64(int64(e.Timestamp) - expected.Timestamp); drift > driftMs {
+				return errors.Wrapf(ErrExemplarMismatch, "series %s: exemplar with trace ID %q has timestamp %d while %d was written (max drift: %s)", seriesLabels.String(), traceID, int64(e.Timestamp), expected.Timestamp, maxDrift)
+			}
+
+			if !sameSampleValue(float64(e.Value), expected.Value) {
+				return errors.Wrapf(ErrExemplarMismatch, "series %s: exemplar with trace ID %q has value %v while %v was written", seriesLabels.String(), traceID, float64(e.Value), expected.Value)
+			}
+
+			if !hasSampleWithinDrift(series.Samples, int64(e.Timestamp), driftMs) {
+				return errors.Wrapf(ErrExemplarMismatch, "series %s: exemplar with trace ID %q at %d doesn't match the timestamp of any sample (max drift: %s)", seriesLabels.String(), traceID, int64(e.Timestamp), maxDrift)
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateExemplarTraceID returns an error if the input exemplar labels don't carry a valid trace ID, which
+// is expected to be a non-zero 64 or 128 bit hex-encoded ID.
+func validateExemplarTraceID(labels model.LabelSet) error {
+	traceID, ok := labels[exemplarTraceIDLabel]
+	if !ok {
+		return fmt.Errorf("the %s label is missing", exemplarTraceIDLabel)
+	}
+
+	id, err := hex.DecodeString(string(traceID))
+	if err != nil || (len(id) != 8 && len(id) != 16) {
+		return fmt.Errorf("the trace ID %q is not a 16 or 32 characters hex string", traceID)
+	}
+	if strings.Trim(string(traceID), "0") == "" {
+		return fmt.Errorf("the trace ID %q is all zeros", traceID)
+	}
+
+	return nil
+}
+
+func findExemplarByTraceID(exemplars []v1.Exemplar, traceID string) (v1.Exemplar, bool) {
+	for _, e := range exemplars {
+		if string(e.Labels[exemplarTraceIDLabel]) == traceID {
+			return e, true
+		}
+	}
+	return v1.Exemplar{}, false
+}
+
+func getExemplarLabel(labels []prompb.Label, name string) string {
+	for _, l := range labels {
+		if l.Name == name {
+			return l.Value
+		}
+	}
+	return ""
+}
+
+func hasSampleWithinDrift(samples []prompb.Sample, ts, driftMs int64) bool {
+	for _, s := range samples {
+		if absInt64(s.Timestamp-ts) <= driftMs {
+			return true
+		}
+	}
+	return false
+}
+
+func prompbLabelsToLabelSet(labels []prompb.Label) model.LabelSet {
+	out := make(model.LabelSet, len(labels))
+	for _, l := range labels {
+		out[model.LabelName(l.Name)] = model.LabelValue(l.Value)
+	}
+	return out
+}
+
+func absInt64(v int64) int64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,151 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyExemplars(t *testing.T) {
+	const (
+		traceID1 = "4bf92f3577b34da6a3ce929d0e0e4736"
+		traceID2 = "00f067aa0ba902b7"
+		maxDrift = time.Second
+	)
+
+	written := []prompb.TimeSeries{{
+		Labels:  []prompb.Label{{Name: "__name__", Value: "metric"}, {Name: "job", Value: "test"}},
+		Samples: []prompb.Sample{{Timestamp: 10000, Value: 1}, {Timestamp: 20000, Value: 2}},
+		Exemplars: []prompb.Exemplar{
+			{Labels: []prompb.Label{{Name: exemplarTraceIDLabel, Value: traceID1}}, Timestamp: 10000, Value: 1},
+			{Labels: []prompb.Label{{Name: exemplarTraceIDLabel, Value: traceID2}}, Timestamp: 20000, Value: 2},
+		},
+	}, {
+		// Series without exemplars are skipped.
+		Labels:  []prompb.Label{{Name: "__name__", Value: "other"}},
+		Samples: []prompb.Sample{{Timestamp: 10000, Value: 1}},
+	}}
+
+	seriesLabels := model.LabelSet{"__name__": "metric", "job": "test"}
+	exemplar := func(traceID string, ts int64, value float64) v1.Exemplar {
+		return v1.Exemplar{Labels: model.LabelSet{exemplarTraceIDLabel: model.LabelValue(traceID)}, Timestamp: model.Time(ts), Value: model.SampleValue(value)}
+	}
+
+	tests := map[string]struct {
+		queried       []v1.ExemplarQueryResult
+		expectedError string
+	}{
+		"matching exemplars": {
+			queried: []v1.ExemplarQueryResult{{
+				SeriesLabels: seriesLabels,
+				Exemplars:    []v1.Exemplar{exemplar(traceID1, 10000, 1), exemplar(traceID2, 20000, 2)},
+			}},
+		},
+		"matching exemplars with timestamp drift within the limit": {
+			queried: []v1.ExemplarQueryResult{{
+				SeriesLabels: seriesLabels,
+				Exemplars:    []v1.Exemplar{exemplar(traceID1, 10500, 1), exemplar(traceID2, 19000, 2)},
+			}},
+		},
+		"missing exemplar": {
+			queried: []v1.ExemplarQueryResult{{
+				SeriesLabels: seriesLabels,
+				Exemplars:    []v1.Exemplar{exemplar(traceID1, 10000, 1)},
+			}},
+			expectedError: `exemplar with trace ID "00f067aa0ba902b7" at 20000 is missing`,
+		},
+		"exemplar returned for a different series": {
+			queried: []v1.ExemplarQueryResult{{
+				SeriesLabels: model.LabelSet{"__name__": "metric", "job": "other"},
+				Exemplars:    []v1.Exemplar{exemplar(traceID1, 10000, 1), exemplar(traceID2, 20000, 2)},
+			}},
+			expectedError: `exemplar with trace ID "4bf92f3577b34da6a3ce929d0e0e4736" at 10000 is missing`,
+		},
+		"mismatching timestamp": {
+			queried: []v1.ExemplarQueryResult{{
+				SeriesLabels: seriesLabels,
+				Exemplars:    []v1.Exemplar{exemplar(traceID1, 12000, 1), exemplar(traceID2, 20000, 2)},
+			}},
+			expectedError: `exemplar with trace ID "4bf92f3577b34da6a3ce929d0e0e4736" has timestamp 12000 while 10000 was written`,
+		},
+		"mismatching value": {
+			queried: []v1.ExemplarQueryResult{{
+				SeriesLabels: seriesLabels,
+				Exemplars:    []v1.Exemplar{exemplar(traceID1, 10000, 1), exemplar(traceID2, 20000, 3)},
+			}},
+			expectedError: `exemplar with trace ID "00f067aa0ba902b7" has value 3 while 2 was written`,
+		},
+		"missing trace ID": {
+			queried: []v1.ExemplarQueryResult{{
+				SeriesLabels: seriesLabels,
+				Exemplars:    []v1.Exemplar{{Labels: model.LabelSet{"span_id": "00f067aa0ba902b7"}, Timestamp: 10000, Value: 1}},
+			}},
+			expectedError: "the trace_id label is missing",
+		},
+		"trace ID with invalid length": {
+			queried: []v1.ExemplarQueryResult{{
+				SeriesLabels: seriesLabels,
+				Exemplars:    []v1.Exemplar{exemplar("4bf92f35", 10000, 1)},
+			}},
+			expectedError: `the trace ID "4bf92f35" is not a 16 or 32 characters hex string`,
+		},
+		"trace ID not hex encoded": {
+			queried: []v1.ExemplarQueryResult{{
+				SeriesLabels: seriesLabels,
+				Exemplars:    []v1.Exemplar{exemplar("zzf067aa0ba902b7", 10000, 1)},
+			}},
+			expectedError: `the trace ID "zzf067aa0ba902b7" is not a 16 or 32 characters hex string`,
+		},
+		"all zeros trace ID": {
+			queried: []v1.ExemplarQueryResult{{
+				SeriesLabels: seriesLabels,
+				Exemplars:    []v1.Exemplar{exemplar("0000000000000000", 10000, 1)},
+			}},
+			expectedError: `the trace ID "0000000000000000" is all zeros`,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			err := VerifyExemplars(written, testData.queried, maxDrift)
+
+			if testData.expectedError == "" {
+				require.NoError(t, err)
+				return
+			}
+
+			require.Error(t, err)
+			assert.True(t, errors.Is(err, ErrExemplarMismatch))
+			assert.Contains(t, err.Error(), testData.expectedError)
+		})
+	}
+}
+
+func TestVerifyExemplars_ShouldFailIfExemplarIsNotLinkedToSample(t *testing.T) {
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+
+	// The exemplar has been written at a timestamp far from any sample of the series.
+	written := []prompb.TimeSeries{{
+		Labels:    []prompb.Label{{Name: "__name__", Value: "metric"}},
+		Samples:   []prompb.Sample{{Timestamp: 10000, Value: 1}},
+		Exemplars: []prompb.Exemplar{{Labels: []prompb.Label{{Name: exemplarTraceIDLabel, Value: traceID}}, Timestamp: 30000, Value: 1}},
+	}}
+
+	queried := []v1.ExemplarQueryResult{{
+		SeriesLabels: model.LabelSet{"__name__": "metric"},
+		Exemplars:    []v1.Exemplar{{Labels: model.LabelSet{exemplarTraceIDLabel: traceID}, Timestamp: 30000, Value: 1}},
+	}}
+
+	err := VerifyExemplars(written, queried, time.Second)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrExemplarMismatch))
+	assert.Contains(t, err.Error(), "doesn't match the timestamp of any sample")
+}
@@ -0,0 +1,89 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"flag"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrInjectedFault is the error returned by requests failed by the fault injection.
+var ErrInjectedFault = errors.New("fault injected by the client")
+
+// FaultInjectionConfig configures faults injected in the requests sent by the client, to test the
+// resilience of the client configuration (eg. retries and circuit breaker). For non-production use only.
+type FaultInjectionConfig struct {
+	Enabled   bool          `yaml:"enabled"`
+	ErrorRate float64       `yaml:"error_rate"`
+	Latency   time.Duration `yaml:"latency"`
+}
+
+// RegisterFlagsWithPrefix registers flags with the given prefix.
+func (cfg *FaultInjectionConfig) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
+	f.BoolVar(&cfg.Enabled, prefix+".enabled", false, "True to inject faults in the requests sent by the client, to test the resilience of the client configuration. Never enable it when testing a production cluster.")
+	f.Float64Var(&cfg.ErrorRate, prefix+".error-rate", 0, "The fraction of requests, between 0 and 1, failed with an injected error without being sent to the server.")
+	f.DurationVar(&cfg.Latency, prefix+".latency", 0, "The latency added to each request.")
+}
+
+func (cfg *FaultInjectionConfig) validate() error {
+	if cfg.ErrorRate < 0 || cfg.ErrorRate > 1 {
+		return errors.New("the fault injection error rate must be between 0 and 1")
+	}
+	if cfg.Latency < 0 {
+		return errors.New("the fault injection latency must not be negative")
+	}
+	return nil
+}
+
+// faultInjectionRoundTripper adds latency to the requests, and fails them at the configured rate.
+type faultInjectionRoundTripper struct {
+	cfg FaultInjectionConfig
+	rt  http.RoundTripper
+
+	rndMx sync.Mutex
+	rnd   *rand.Rand
+}
+
+func newFaultInjectionRoundTripper(cfg FaultInjectionConfig, rt http.RoundTripper) *faultInjectionRoundTripper {
+	return &faultInjectionRoundTripper{
+		cfg: cfg,
+		rt:  rt,
+		rnd: rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+func (rt *faultInjectionRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if rt.cfg.Latency > 0 {
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(rt.cfg.Latency):
+		}
+	}
+
+	rt.rndMx.Lock()
+	fail := rt.rnd.Float64() < rt.cfg.ErrorRate
+	rt.rndMx.Unlock()
+
+	if fail {
+		// The request body is expected to be closed by the round tripper, even on error.
+		if req.Body != nil {
+			_ = req.Body.Close()
+		}
+		return nil, ErrInjectedFault
+	}
+
+	return rt.rt.RoundTrip(req)
+}
+
+// CloseIdleConnections closes the idle connections of the wrapped round tripper, if supported.
+func (rt *faultInjectionRoundTripper) CloseIdleConnections() {
+	if closer, ok := rt.rt.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
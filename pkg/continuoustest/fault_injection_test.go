@@ -0,0 +1,130 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/flagext"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+)
+
+func TestFaultInjectionRoundTripper(t *testing.T) {
+	received := atomic.NewInt32(0)
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		received.Inc()
+	}))
+	t.Cleanup(server.Close)
+
+	t.Run("should add the configured latency", func(t *testing.T) {
+		received.Store(0)
+		rt := newFaultInjectionRoundTripper(FaultInjectionConfig{Enabled: true, Latency: 100 * time.Millisecond}, http.DefaultTransport)
+
+		req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+		require.NoError(t, err)
+
+		start := time.Now()
+		res, err := rt.RoundTrip(req)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+
+		assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+		assert.Equal(t, int32(1), received.Load())
+	})
+
+	t.Run("should fail requests at the configured error rate", func(t *testing.T) {
+		const numRequests = 1000
+
+		for _, errorRate := range []float64{0, 0.3, 1} {
+			received.Store(0)
+			rt := newFaultInjectionRoundTripper(FaultInjectionConfig{Enabled: true, ErrorRate: errorRate}, http.DefaultTransport)
+
+			failed := 0
+			for i := 0; i < numRequests; i++ {
+				req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+				require.NoError(t, err)
+
+				res, err := rt.RoundTrip(req)
+				if err != nil {
+					require.True(t, errors.Is(err, ErrInjectedFault))
+					failed++
+					continue
+				}
+				require.NoError(t, res.Body.Close())
+			}
+
+			// Injected errors are not sent to the server.
+			assert.Equal(t, int32(numRequests-failed), received.Load())
+			assert.InDelta(t, errorRate, float64(failed)/numRequests, 0.1, "error rate: %f", errorRate)
+		}
+	})
+
+	t.Run("should stop waiting if the request context is canceled", func(t *testing.T) {
+		rt := newFaultInjectionRoundTripper(FaultInjectionConfig{Enabled: true, Latency: time.Minute}, http.DefaultTransport)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+		require.NoError(t, err)
+
+		_, err = rt.RoundTrip(req)
+		require.True(t, errors.Is(err, context.DeadlineExceeded))
+	})
+}
+
+func TestClient_FaultInjection(t *testing.T) {
+	received := atomic.NewInt32(0)
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		received.Inc()
+	}))
+	t.Cleanup(server.Close)
+
+	newClient := func(t *testing.T, faultInjection FaultInjectionConfig) (*Client, error) {
+		cfg := ClientConfig{}
+		flagext.DefaultValues(&cfg)
+		cfg.FaultInjection = faultInjection
+		require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+		require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+		return NewClient(cfg, log.NewNopLogger(), nil)
+	}
+
+	t.Run("should inject faults if enabled", func(t *testing.T) {
+		received.Store(0)
+
+		c, err := newClient(t, FaultInjectionConfig{Enabled: true, ErrorRate: 1})
+		require.NoError(t, err)
+
+		_, err = c.WriteSeries(context.Background(), generateSineWaveSeries("test", time.Now(), 1))
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, ErrInjectedFault))
+		assert.Equal(t, int32(0), received.Load())
+	})
+
+	t.Run("should not inject faults if not enabled", func(t *testing.T) {
+		received.Store(0)
+
+		c, err := newClient(t, FaultInjectionConfig{Enabled: false, ErrorRate: 1})
+		require.NoError(t, err)
+
+		_, err = c.WriteSeries(context.Background(), generateSineWaveSeries("test", time.Now(), 1))
+		require.NoError(t, err)
+		assert.Equal(t, int32(1), received.Load())
+	})
+
+	t.Run("should fail on invalid config", func(t *testing.T) {
+		_, err := newClient(t, FaultInjectionConfig{Enabled: true, ErrorRate: 1.5})
+		require.EqualError(t, err, "the fault injection error rate must be between 0 and 1")
+	})
+}
@@ -0,0 +1,93 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"github.com/weaveworks/common/user"
+)
+
+// TenantsLimitError is returned when a federated query is rejected by Mimir because of the
+// number of tenants it queries.
+type TenantsLimitError struct {
+	NumTenants int
+	StatusCode int
+	Message    string
+}
+
+func (e *TenantsLimitError) Error() string {
+	return fmt.Sprintf("federated query across %d tenants rejected with HTTP status %d: %s", e.NumTenants, e.StatusCode, e.Message)
+}
+
+// federatedTenantIDs returns n tenant IDs, named after the input base tenant ID with a numeric suffix.
+func federatedTenantIDs(base string, n int) []string {
+	out := make([]string, 0, n)
+	for i := 1; i <= n; i++ {
+		out = append(out, base+"-"+strconv.Itoa(i))
+	}
+	return out
+}
+
+// injectFederatedTenants returns a context with the input tenant IDs injected, joined the way
+// expected by Mimir tenant federation.
+func injectFederatedTenants(ctx context.Context, tenantIDs []string) context.Context {
+	return user.InjectOrgID(ctx, strings.Join(tenantIDs, "|"))
+}
+
+// QueryRangeFederated performs a range query across the input tenants, using tenant federation.
+// Returns a *TenantsLimitError if the query is rejected with a 4xx error mentioning the tenants,
+// which is how Mimir rejects federated queries exceeding the max number of tenants.
+func (c *Client) QueryRangeFederated(ctx context.Context, tenantIDs []string, query string, r v1.Range) (model.Matrix, error) {
+	ctx, cancel := context.WithTimeout(injectFederatedTenants(ctx, tenantIDs), c.getRequestTimeout(ctx, c.cfg.ReadTimeout))
+	defer cancel()
+
+	httpResp, err := c.doQueryRangeRequest(ctx, query, r, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+
+	if httpResp.StatusCode/100 == 4 {
+		truncatedBody, err := io.ReadAll(io.LimitReader(httpResp.Body, maxErrMsgLen))
+		if err != nil {
+			return nil, errors.Wrapf(err, "server returned HTTP status %s and client failed to read response body", httpResp.Status)
+		}
+
+		// The error message is in the response body, which is JSON-encoded by the Prometheus API.
+		message := string(truncatedBody)
+		apiErr := struct {
+			Error string `json:"error"`
+		}{}
+		if json.Unmarshal(truncatedBody, &apiErr) == nil && apiErr.Error != "" {
+			message = apiErr.Error
+		}
+
+		if strings.Contains(strings.ToLower(message), "tenant") {
+			return nil, &TenantsLimitError{NumTenants: len(tenantIDs), StatusCode: httpResp.StatusCode, Message: message}
+		}
+		return nil, fmt.Errorf("server returned HTTP status %s and body %q (truncated to %d bytes)", httpResp.Status, string(truncatedBody), maxErrMsgLen)
+	}
+
+	matrix := model.Matrix{}
+	err = decodeQueryRangeResponseStream(httpResp.Body, func(stream model.SampleStream) error {
+		matrix = append(matrix, &stream)
+		return nil
+	})
+	if err != nil {
+		if httpResp.StatusCode/100 != 2 {
+			return nil, errors.Wrapf(err, "server returned HTTP status %s", httpResp.Status)
+		}
+		return nil, err
+	}
+
+	return matrix, nil
+}
@@ -0,0 +1,84 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/flagext"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFederatedTenantIDs(t *testing.T) {
+	assert.Empty(t, federatedTenantIDs("tenant", 0))
+	assert.Equal(t, []string{"tenant-1", "tenant-2", "tenant-3"}, federatedTenantIDs("tenant", 3))
+}
+
+func TestClient_QueryRangeFederated(t *testing.T) {
+	const maxTenants = 3
+
+	var receivedOrgIDs []string
+
+	// Mock a server rejecting federated queries exceeding the max number of tenants.
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		orgID := request.Header.Get("X-Scope-OrgID")
+		receivedOrgIDs = append(receivedOrgIDs, orgID)
+
+		writer.Header().Set("Content-Type", "application/json")
+		if numTenants := len(strings.Split(orgID, "|")); numTenants > maxTenants {
+			writer.WriteHeader(http.StatusBadRequest)
+			_, _ = writer.Write([]byte(fmt.Sprintf(`{"status":"error","errorType":"bad_data","error":"too many tenants, max: %d, actual: %d"}`, maxTenants, numTenants)))
+			return
+		}
+
+		_, _ = writer.Write([]byte(`{"status":"success","data":{"resultType":"matrix","result":[{"metric":{"__name__":"test"},"values":[[1000,"1"]]}]}}`))
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := ClientConfig{}
+	flagext.DefaultValues(&cfg)
+	require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+	require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+	c, err := NewClient(cfg, log.NewNopLogger(), nil)
+	require.NoError(t, err)
+
+	r := v1.Range{Start: time.Unix(1000, 0), End: time.Unix(1000, 0), Step: time.Minute}
+
+	t.Run("should query all tenants if the number of tenants is within the limit", func(t *testing.T) {
+		receivedOrgIDs = nil
+
+		matrix, err := c.QueryRangeFederated(context.Background(), federatedTenantIDs("tenant", maxTenants), "test", r)
+		require.NoError(t, err)
+		assert.Len(t, matrix, 1)
+		assert.Equal(t, model.SampleValue(1), matrix[0].Values[0].Value)
+		assert.Equal(t, []string{"tenant-1|tenant-2|tenant-3"}, receivedOrgIDs)
+	})
+
+	t.Run("should return a typed error if the number of tenants exceeds the limit", func(t *testing.T) {
+		receivedOrgIDs = nil
+
+		_, err := c.QueryRangeFederated(context.Background(), federatedTenantIDs("tenant", maxTenants+2), "test", r)
+		require.Error(t, err)
+		assert.Equal(t, []string{"tenant-1|tenant-2|tenant-3|tenant-4|tenant-5"}, receivedOrgIDs)
+
+		var limitErr *TenantsLimitError
+		require.True(t, errors.As(err, &limitErr))
+		assert.Equal(t, &TenantsLimitError{
+			NumTenants: 5,
+			StatusCode: http.StatusBadRequest,
+			Message:    "too many tenants, max: 3, actual: 5",
+		}, limitErr)
+	})
+}
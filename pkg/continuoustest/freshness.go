@@ -0,0 +1,94 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/grafana/dskit/backoff"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/common/model"
+)
+
+type FreshnessVerifierConfig struct {
+	MaxWait time.Duration
+	Backoff backoff.Config
+}
+
+func (cfg *FreshnessVerifierConfig) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
+	f.DurationVar(&cfg.MaxWait, prefix+".max-wait", time.Minute, "The maximum time to wait for written data to become queryable.")
+	cfg.Backoff.RegisterFlagsWithPrefix(prefix, f)
+}
+
+// FreshnessVerifier waits until written data becomes queryable, polling Mimir with backoff, and tracks
+// how long it took. The tracked latency is a measure of the ingestion freshness.
+type FreshnessVerifier struct {
+	cfg    FreshnessVerifierConfig
+	client MimirClient
+
+	writeToReadLatency prometheus.Histogram
+}
+
+func NewFreshnessVerifier(cfg FreshnessVerifierConfig, client MimirClient, reg prometheus.Registerer) *FreshnessVerifier {
+	return &FreshnessVerifier{
+		cfg:    cfg,
+		client: client,
+		writeToReadLatency: promauto.With(reg).NewHistogram(prometheus.HistogramOpts{
+			Name:    "mimir_continuous_test_write_to_read_latency_seconds",
+			Help:    "Time elapsed between a successful write and the written sample becoming queryable.",
+			Buckets: prometheus.ExponentialBuckets(0.01, 2, 14),
+		}),
+	}
+}
+
+// WaitUntilQueryable polls the input instant query at the sample timestamp until the result includes the
+// sample with the input value, or the max wait expires. The query is expected to select the written series.
+// On success, the time elapsed since writtenAt is returned and tracked in the write-to-read latency histogram.
+func (v *FreshnessVerifier) WaitUntilQueryable(ctx context.Context, query string, ts time.Time, value float64, writtenAt time.Time) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, v.cfg.MaxWait)
+	defer cancel()
+
+	var (
+		b       = backoff.New(ctx, v.cfg.Backoff)
+		lastErr error
+	)
+
+	for b.Ongoing() {
+		result, err := v.client.Query(ctx, query, ts)
+		if err == nil && vectorHasSample(result, ts, value) {
+			latency := time.Since(writtenAt)
+			v.writeToReadLatency.Observe(latency.Seconds())
+			return latency, nil
+		}
+		if err != nil {
+			lastErr = err
+		}
+
+		b.Wait()
+	}
+
+	if lastErr != nil {
+		return 0, errors.Wrapf(lastErr, "sample at timestamp %d with value %f not queryable within %s", ts.UnixMilli(), value, v.cfg.MaxWait)
+	}
+	return 0, fmt.Errorf("sample at timestamp %d with value %f not queryable within %s", ts.UnixMilli(), value, v.cfg.MaxWait)
+}
+
+// vectorHasSample returns whether the input instant query result has a sample with the input timestamp and value.
+func vectorHasSample(result model.Value, ts time.Time, value float64) bool {
+	vector, ok := result.(model.Vector)
+	if !ok {
+		return false
+	}
+
+	for _, sample := range vector {
+		if sample.Timestamp.Time().Equal(ts) && compareSampleValues(float64(sample.Value), value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,102 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/grafana/dskit/backoff"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFreshnessVerifier_WaitUntilQueryable(t *testing.T) {
+	const (
+		query = `test{series_id="0"}`
+		value = 1.5
+	)
+
+	cfg := FreshnessVerifierConfig{
+		MaxWait: time.Second,
+		Backoff: backoff.Config{MinBackoff: 10 * time.Millisecond, MaxBackoff: 20 * time.Millisecond},
+	}
+
+	ts := time.Now().Truncate(time.Second)
+	sample := model.Vector{{
+		Metric:    model.Metric{"__name__": "test", "series_id": "0"},
+		Timestamp: model.TimeFromUnixNano(ts.UnixNano()),
+		Value:     value,
+	}}
+
+	t.Run("should track the latency once the sample becomes queryable", func(t *testing.T) {
+		// The sample becomes queryable at the 4th attempt, after waiting for the backoff 3 times.
+		const notVisibleAttempts = 3
+		minLatency := notVisibleAttempts * cfg.Backoff.MinBackoff
+		writtenAt := time.Now()
+
+		client := &ClientMock{}
+		client.On("Query", mock.Anything, query, ts).Return(model.Vector{}, nil).Times(notVisibleAttempts)
+		client.On("Query", mock.Anything, query, ts).Return(sample, nil).Once()
+
+		reg := prometheus.NewPedanticRegistry()
+		v := NewFreshnessVerifier(cfg, client, reg)
+
+		latency, err := v.WaitUntilQueryable(context.Background(), query, ts, value, writtenAt)
+		require.NoError(t, err)
+		assert.GreaterOrEqual(t, latency, minLatency)
+		client.AssertNumberOfCalls(t, "Query", notVisibleAttempts+1)
+
+		families, err := reg.Gather()
+		require.NoError(t, err)
+		require.Len(t, families, 1)
+		assert.Equal(t, "mimir_continuous_test_write_to_read_latency_seconds", families[0].GetName())
+
+		histogram := families[0].GetMetric()[0].GetHistogram()
+		assert.Equal(t, uint64(1), histogram.GetSampleCount())
+		assert.InDelta(t, latency.Seconds(), histogram.GetSampleSum(), 1e-9)
+		assert.GreaterOrEqual(t, histogram.GetSampleSum(), minLatency.Seconds())
+	})
+
+	t.Run("should fail and not track the latency if the sample never becomes queryable", func(t *testing.T) {
+		client := &ClientMock{}
+		client.On("Query", mock.Anything, query, ts).Return(model.Vector{}, nil)
+
+		reg := prometheus.NewPedanticRegistry()
+		v := NewFreshnessVerifier(FreshnessVerifierConfig{MaxWait: 100 * time.Millisecond, Backoff: cfg.Backoff}, client, reg)
+
+		_, err := v.WaitUntilQueryable(context.Background(), query, ts, value, time.Now())
+		require.Error(t, err)
+
+		families, err := reg.Gather()
+		require.NoError(t, err)
+		require.Len(t, families, 1)
+		assert.Equal(t, uint64(0), families[0].GetMetric()[0].GetHistogram().GetSampleCount())
+	})
+
+	t.Run("should report the last query error on timeout", func(t *testing.T) {
+		client := &ClientMock{}
+		client.On("Query", mock.Anything, query, ts).Return(nil, errors.New("query failed"))
+
+		v := NewFreshnessVerifier(FreshnessVerifierConfig{MaxWait: 100 * time.Millisecond, Backoff: cfg.Backoff}, client, nil)
+
+		_, err := v.WaitUntilQueryable(context.Background(), query, ts, value, time.Now())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "query failed")
+	})
+
+	t.Run("should ignore samples with a different value", func(t *testing.T) {
+		client := &ClientMock{}
+		client.On("Query", mock.Anything, query, ts).Return(model.Vector{{Timestamp: sample[0].Timestamp, Value: value + 1}}, nil)
+
+		v := NewFreshnessVerifier(FreshnessVerifierConfig{MaxWait: 100 * time.Millisecond, Backoff: cfg.Backoff}, client, nil)
+
+		_, err := v.WaitUntilQueryable(context.Background(), query, ts, value, time.Now())
+		require.Error(t, err)
+	})
+}
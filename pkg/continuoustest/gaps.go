@@ -0,0 +1,45 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"time"
+
+	"github.com/prometheus/common/model"
+)
+
+// Gap is a time range of a series in which the expected samples are missing.
+type Gap struct {
+	Metric model.Metric
+
+	// Start is the timestamp of the last sample before the gap.
+	Start time.Time
+
+	// End is the timestamp of the first sample after the gap.
+	End time.Time
+}
+
+// FindGaps returns the gaps found in each series of the input matrix, in order. A gap is detected when
+// the distance between two consecutive samples is greater than the expected step plus the input tolerance.
+// Gaps at the beginning and end of the queried time range can't be detected, because the range is unknown.
+func FindGaps(m model.Matrix, step time.Duration, tolerance time.Duration) []Gap {
+	var gaps []Gap
+	maxDistance := model.Time((step + tolerance).Milliseconds())
+
+	for _, series := range m {
+		for i := 1; i < len(series.Values); i++ {
+			prev, curr := series.Values[i-1].Timestamp, series.Values[i].Timestamp
+			if timeDistance(prev, curr) <= maxDistance {
+				continue
+			}
+
+			gaps = append(gaps, Gap{
+				Metric: series.Metric,
+				Start:  prev.Time(),
+				End:    curr.Time(),
+			})
+		}
+	}
+
+	return gaps
+}
@@ -0,0 +1,66 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindGaps(t *testing.T) {
+	const step = 20 * time.Second
+
+	// newSeries returns a series with a sample at each input offset, in steps, from the base timestamp.
+	base := time.Unix(1000, 0)
+	newSeries := func(name string, offsets ...int) *model.SampleStream {
+		values := make([]model.SamplePair, 0, len(offsets))
+		for _, offset := range offsets {
+			values = append(values, model.SamplePair{Timestamp: model.TimeFromUnixNano(base.Add(time.Duration(offset) * step).UnixNano()), Value: 1})
+		}
+		return &model.SampleStream{Metric: model.Metric{"__name__": model.LabelValue(name)}, Values: values}
+	}
+
+	tests := map[string]struct {
+		matrix    model.Matrix
+		tolerance time.Duration
+		expected  []Gap
+	}{
+		"empty matrix": {
+			matrix: model.Matrix{},
+		},
+		"contiguous series": {
+			matrix: model.Matrix{newSeries("series_1", 0, 1, 2, 3, 4)},
+		},
+		"series with a hole": {
+			matrix: model.Matrix{newSeries("series_1", 0, 1, 4, 5)},
+			expected: []Gap{
+				{Metric: model.Metric{"__name__": "series_1"}, Start: base.Add(step), End: base.Add(4 * step)},
+			},
+		},
+		"multiple series with multiple holes": {
+			matrix: model.Matrix{
+				newSeries("series_1", 0, 2, 3, 5),
+				newSeries("series_2", 0, 1, 2, 3, 4, 5),
+				newSeries("series_3", 0, 3),
+			},
+			expected: []Gap{
+				{Metric: model.Metric{"__name__": "series_1"}, Start: base, End: base.Add(2 * step)},
+				{Metric: model.Metric{"__name__": "series_1"}, Start: base.Add(3 * step), End: base.Add(5 * step)},
+				{Metric: model.Metric{"__name__": "series_3"}, Start: base, End: base.Add(3 * step)},
+			},
+		},
+		"hole within the tolerance": {
+			matrix:    model.Matrix{newSeries("series_1", 0, 1, 3, 4)},
+			tolerance: step,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			assert.Equal(t, testData.expected, FindGaps(testData.matrix, step, testData.tolerance))
+		})
+	}
+}
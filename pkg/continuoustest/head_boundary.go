@@ -0,0 +1,133 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"context"
+	"flag"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// ErrHeadBoundaryMismatch is returned by CheckHeadBoundarySeries when the samples written around the
+// head-block boundary are missing or have unexpected values.
+var ErrHeadBoundaryMismatch = errors.New("the samples around the head-block boundary don't match the written ones")
+
+// HeadBoundaryCheckConfig configures the samples written around the boundary between the TSDB head and
+// the blocks flushed from it.
+type HeadBoundaryCheckConfig struct {
+	BlockRange time.Duration
+	Margin     time.Duration
+	Step       time.Duration
+	Tolerance  float64
+}
+
+// RegisterFlagsWithPrefix registers flags with the given prefix.
+func (cfg *HeadBoundaryCheckConfig) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
+	f.DurationVar(&cfg.BlockRange, prefix+".block-range", 2*time.Hour, "The TSDB block range configured in Mimir. Blocks are cut from the head at multiples of the block range, so the boundary is the most recent multiple of it.")
+	f.DurationVar(&cfg.Margin, prefix+".margin", 10*time.Minute, "How far before and after the boundary samples are written. It must be lower than the out-of-order and the creation grace periods configured in Mimir, so that samples before the boundary can be written once it has been crossed.")
+	f.DurationVar(&cfg.Step, prefix+".step", time.Minute, "The interval between samples written around the boundary, and the step of the range query reading them back.")
+	f.Float64Var(&cfg.Tolerance, prefix+".tolerance", 1e-6, "The max relative difference between the expected and actual values of the samples read back.")
+}
+
+func (cfg *HeadBoundaryCheckConfig) validate() error {
+	if cfg.BlockRange <= 0 || cfg.Step <= 0 {
+		return errors.New("the block range and step must be greater than 0")
+	}
+	if cfg.Margin < cfg.Step || cfg.Margin*2 > cfg.BlockRange {
+		return errors.New("the margin must be at least the step and at most half of the block range")
+	}
+	return nil
+}
+
+// Boundary returns the most recent head-block boundary, such that samples can be written up to the margin
+// after it without being in the future.
+func (cfg *HeadBoundaryCheckConfig) Boundary(now time.Time) time.Time {
+	return now.Add(-cfg.Margin).Truncate(cfg.BlockRange)
+}
+
+// timestamps returns the timestamps of the samples written around the input boundary, in order.
+func (cfg *HeadBoundaryCheckConfig) timestamps(boundary time.Time) []time.Time {
+	var out []time.Time
+	for ts := boundary.Add(-cfg.Margin); !ts.After(boundary.Add(cfg.Margin)); ts = ts.Add(cfg.Step) {
+		out = append(out, ts)
+	}
+	return out
+}
+
+// WriteHeadBoundarySeries writes a series for the input metric, with a sine wave sample every step from the
+// margin before to the margin after the input boundary, so that the samples straddle the boundary between
+// the TSDB head and the block which will be flushed from it.
+func (c *Client) WriteHeadBoundarySeries(ctx context.Context, cfg HeadBoundaryCheckConfig, metricName string, boundary time.Time) error {
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+
+	timestamps := cfg.timestamps(boundary)
+	samples := make([]prompb.Sample, 0, len(timestamps))
+	for _, ts := range timestamps {
+		samples = append(samples, prompb.Sample{Value: generateSineWaveValue(ts), Timestamp: ts.UnixMilli()})
+	}
+
+	_, err := c.WriteSeries(ctx, []prompb.TimeSeries{{
+		Labels:  []prompb.Label{{Name: "__name__", Value: metricName}},
+		Samples: samples,
+	}})
+	if err != nil {
+		return errors.Wrapf(err, "failed to write the samples around the head-block boundary %d", boundary.UnixMilli())
+	}
+
+	return nil
+}
+
+// CheckHeadBoundarySeries reads back the series written by WriteHeadBoundarySeries for the input boundary and
+// checks both the samples before the boundary, which are flushed to a block once the head is compacted, and
+// the ones after it, which are still in the head, are queryable with the written values. Fails with
+// ErrHeadBoundaryMismatch if any sample is missing or has an unexpected value.
+func (c *Client) CheckHeadBoundarySeries(ctx context.Context, cfg HeadBoundaryCheckConfig, metricName string, boundary time.Time) error {
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+
+	start, end := boundary.Add(-cfg.Margin), boundary.Add(cfg.Margin)
+	matrix, err := c.QueryRange(ctx, metricName, start, end, cfg.Step)
+	if err != nil {
+		return errors.Wrapf(err, "failed to query the range %d-%d around the head-block boundary", start.UnixMilli(), end.UnixMilli())
+	}
+
+	return verifyHeadBoundaryMatrix(matrix, cfg.timestamps(boundary), boundary, cfg.Tolerance)
+}
+
+// verifyHeadBoundaryMatrix checks the input matrix has a single series with a sine wave sample at each of
+// the input timestamps. Errors report which side of the boundary the first mismatching sample is on.
+func verifyHeadBoundaryMatrix(matrix model.Matrix, timestamps []time.Time, boundary time.Time, tolerance float64) error {
+	if len(matrix) != 1 {
+		return errors.Wrapf(ErrHeadBoundaryMismatch, "expected 1 series in the result but got %d", len(matrix))
+	}
+
+	actual := make(map[model.Time]float64, len(matrix[0].Values))
+	for _, sample := range matrix[0].Values {
+		actual[sample.Timestamp] = float64(sample.Value)
+	}
+
+	for _, ts := range timestamps {
+		side := "after the boundary, in the head"
+		if ts.Before(boundary) {
+			side = "before the boundary, flushed to a block"
+		}
+
+		value, ok := actual[model.TimeFromUnixNano(ts.UnixNano())]
+		if !ok {
+			return errors.Wrapf(ErrHeadBoundaryMismatch, "the sample at timestamp %d (%s), %s, is missing", ts.UnixMilli(), ts.UTC().String(), side)
+		}
+
+		if expected := generateSineWaveValue(ts); !compareSampleValuesWithMixedTolerance(value, expected, tolerance) {
+			return errors.Wrapf(ErrHeadBoundaryMismatch, "the sample at timestamp %d (%s), %s, has value %f while was expecting %f", ts.UnixMilli(), ts.UTC().String(), side, value, expected)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,205 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/grafana/dskit/flagext"
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeadBoundaryCheckConfig_Boundary(t *testing.T) {
+	cfg := HeadBoundaryCheckConfig{BlockRange: 2 * time.Hour, Margin: 10 * time.Minute, Step: time.Minute}
+
+	tests := map[string]struct {
+		now      time.Time
+		expected time.Time
+	}{
+		"margin elapsed since the most recent boundary": {
+			now:      time.Date(2022, 1, 1, 10, 30, 0, 0, time.UTC),
+			expected: time.Date(2022, 1, 1, 10, 0, 0, 0, time.UTC),
+		},
+		"margin not elapsed yet since the most recent boundary": {
+			now:      time.Date(2022, 1, 1, 10, 5, 0, 0, time.UTC),
+			expected: time.Date(2022, 1, 1, 8, 0, 0, 0, time.UTC),
+		},
+		"exactly the margin after the most recent boundary": {
+			now:      time.Date(2022, 1, 1, 10, 10, 0, 0, time.UTC),
+			expected: time.Date(2022, 1, 1, 10, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			assert.Equal(t, testData.expected.UnixMilli(), cfg.Boundary(testData.now).UnixMilli())
+		})
+	}
+}
+
+func TestHeadBoundaryCheckConfig_Validate(t *testing.T) {
+	tests := map[string]struct {
+		cfg         HeadBoundaryCheckConfig
+		expectedErr bool
+	}{
+		"valid": {
+			cfg: HeadBoundaryCheckConfig{BlockRange: 2 * time.Hour, Margin: 10 * time.Minute, Step: time.Minute},
+		},
+		"zero step": {
+			cfg:         HeadBoundaryCheckConfig{BlockRange: 2 * time.Hour, Margin: 10 * time.Minute},
+			expectedErr: true,
+		},
+		"margin lower than the step": {
+			cfg:         HeadBoundaryCheckConfig{BlockRange: 2 * time.Hour, Margin: time.Second, Step: time.Minute},
+			expectedErr: true,
+		},
+		"margin greater than half block range": {
+			cfg:         HeadBoundaryCheckConfig{BlockRange: 2 * time.Hour, Margin: 90 * time.Minute, Step: time.Minute},
+			expectedErr: true,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			if testData.expectedErr {
+				assert.Error(t, testData.cfg.validate())
+			} else {
+				assert.NoError(t, testData.cfg.validate())
+			}
+		})
+	}
+}
+
+func TestClient_WriteAndCheckHeadBoundarySeries(t *testing.T) {
+	boundary := time.Date(2022, 1, 1, 10, 0, 0, 0, time.UTC)
+	cfg := HeadBoundaryCheckConfig{BlockRange: 2 * time.Hour, Margin: 5 * time.Minute, Step: time.Minute, Tolerance: 1e-6}
+
+	tests := map[string]struct {
+		// mutateBlock and mutateHead modify the samples returned from the block (before the boundary)
+		// and the head (after the boundary), emulating data lost or corrupted on either side.
+		mutateBlock   func([]prompb.Sample) []prompb.Sample
+		mutateHead    func([]prompb.Sample) []prompb.Sample
+		expectedErr   bool
+		expectedInErr string
+	}{
+		"should succeed if samples are queryable from both the block and the head": {},
+		"should fail if the samples flushed to the block are missing": {
+			mutateBlock:   func([]prompb.Sample) []prompb.Sample { return nil },
+			expectedErr:   true,
+			expectedInErr: "before the boundary, flushed to a block, is missing",
+		},
+		"should fail if the last sample flushed to the block is missing": {
+			mutateBlock:   func(s []prompb.Sample) []prompb.Sample { return s[:len(s)-1] },
+			expectedErr:   true,
+			expectedInErr: "before the boundary, flushed to a block, is missing",
+		},
+		"should fail if the samples in the head have unexpected values": {
+			mutateHead: func(s []prompb.Sample) []prompb.Sample {
+				s[0].Value += 1
+				return s
+			},
+			expectedErr:   true,
+			expectedInErr: "after the boundary, in the head, has value",
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			var (
+				writtenMx sync.Mutex
+				written   []prompb.Sample
+			)
+
+			server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+				if request.URL.Path == "/api/v1/push" {
+					body, err := ioutil.ReadAll(request.Body)
+					require.NoError(t, err)
+					body, err = snappy.Decode(nil, body)
+					require.NoError(t, err)
+
+					req := prompb.WriteRequest{}
+					require.NoError(t, proto.Unmarshal(body, &req))
+					require.Len(t, req.Timeseries, 1)
+
+					writtenMx.Lock()
+					written = append(written, req.Timeseries[0].Samples...)
+					writtenMx.Unlock()
+					return
+				}
+
+				// Split the written samples between the block and the head, and apply the mutations.
+				writtenMx.Lock()
+				var block, head []prompb.Sample
+				for _, s := range written {
+					if s.Timestamp < boundary.UnixMilli() {
+						block = append(block, s)
+					} else {
+						head = append(head, s)
+					}
+				}
+				writtenMx.Unlock()
+
+				if testData.mutateBlock != nil {
+					block = testData.mutateBlock(block)
+				}
+				if testData.mutateHead != nil {
+					head = testData.mutateHead(head)
+				}
+
+				values := make([][]interface{}, 0, len(block)+len(head))
+				for _, s := range append(block, head...) {
+					values = append(values, []interface{}{float64(s.Timestamp) / 1000, strconv.FormatFloat(s.Value, 'f', -1, 64)})
+				}
+
+				writer.Header().Set("Content-Type", "application/json")
+				require.NoError(t, json.NewEncoder(writer).Encode(map[string]interface{}{
+					"status": "success",
+					"data": map[string]interface{}{
+						"resultType": "matrix",
+						"result":     []interface{}{map[string]interface{}{"metric": map[string]string{"__name__": "test"}, "values": values}},
+					},
+				}))
+			}))
+			t.Cleanup(server.Close)
+
+			clientCfg := ClientConfig{}
+			flagext.DefaultValues(&clientCfg)
+			require.NoError(t, clientCfg.WriteBaseEndpoint.Set(server.URL))
+			require.NoError(t, clientCfg.ReadBaseEndpoint.Set(server.URL))
+
+			c, err := NewClient(clientCfg, log.NewNopLogger(), nil)
+			require.NoError(t, err)
+
+			require.NoError(t, c.WriteHeadBoundarySeries(context.Background(), cfg, "test", boundary))
+
+			writtenMx.Lock()
+			require.Len(t, written, 11)
+			assert.Equal(t, boundary.Add(-cfg.Margin).UnixMilli(), written[0].Timestamp)
+			assert.Equal(t, boundary.Add(cfg.Margin).UnixMilli(), written[len(written)-1].Timestamp)
+			writtenMx.Unlock()
+
+			err = c.CheckHeadBoundarySeries(context.Background(), cfg, "test", boundary)
+			if testData.expectedErr {
+				require.Error(t, err)
+				assert.True(t, errors.Is(err, ErrHeadBoundaryMismatch))
+				assert.Contains(t, err.Error(), testData.expectedInErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// generateHistogramSeries returns the series of a classic histogram, with the input bucket upper bounds,
+// which observed the input values. The series are the cumulative _bucket series (including the +Inf
+// bucket), the _count and the _sum ones, each with a single sample at the input timestamp.
+func generateHistogramSeries(name string, t time.Time, bounds []float64, observations []float64) []prompb.TimeSeries {
+	sortedBounds := append([]float64{}, bounds...)
+	sort.Float64s(sortedBounds)
+	if len(sortedBounds) == 0 || !math.IsInf(sortedBounds[len(sortedBounds)-1], +1) {
+		sortedBounds = append(sortedBounds, math.Inf(+1))
+	}
+
+	sum := 0.0
+	for _, v := range observations {
+		sum += v
+	}
+
+	newSeries := func(metricName string, extra []prompb.Label, value float64) prompb.TimeSeries {
+		return prompb.TimeSeries{
+			Labels:  append([]prompb.Label{{Name: "__name__", Value: metricName}}, extra...),
+			Samples: []prompb.Sample{{Value: value, Timestamp: t.UnixMilli()}},
+		}
+	}
+
+	out := make([]prompb.TimeSeries, 0, len(sortedBounds)+2)
+	for _, bound := range sortedBounds {
+		count := 0
+		for _, v := range observations {
+			if v <= bound {
+				count++
+			}
+		}
+
+		le := strconv.FormatFloat(bound, 'f', -1, 64)
+		out = append(out, newSeries(name+"_bucket", []prompb.Label{{Name: "le", Value: le}}, float64(count)))
+	}
+	out = append(out, newSeries(name+"_count", nil, float64(len(observations))))
+	out = append(out, newSeries(name+"_sum", nil, sum))
+
+	return out
+}
+
+// expectedQuantile returns the exact q-quantile of the input values, using the nearest-rank method.
+func expectedQuantile(q float64, values []float64) (float64, error) {
+	if len(values) == 0 {
+		return 0, errors.New("the quantile of no values is undefined")
+	}
+	if q < 0 || q > 1 {
+		return 0, fmt.Errorf("invalid quantile %f", q)
+	}
+
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+
+	rank := int(math.Ceil(q*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	return sorted[rank], nil
+}
+
+// checkHistogramQuantile writes a classic histogram with the input bucket upper bounds which observed the
+// input values, then queries histogram_quantile() at the time the histogram has been written and checks
+// the result is within the input tolerance of the exact quantile of the observed values. The accuracy of
+// histogram_quantile() depends on the bucket layout, so the tolerance should be at least the bucket width
+// around the expected quantile.
+func checkHistogramQuantile(ctx context.Context, client MimirClient, name string, ts time.Time, bounds []float64, observations []float64, q, tolerance float64) error {
+	expected, err := expectedQuantile(q, observations)
+	if err != nil {
+		return err
+	}
+
+	if _, err := client.WriteSeries(ctx, generateHistogramSeries(name, ts, bounds, observations)); err != nil {
+		return errors.Wrap(err, "failed to write histogram series")
+	}
+
+	query := fmt.Sprintf("histogram_quantile(%s, %s_bucket)", strconv.FormatFloat(q, 'f', -1, 64), name)
+	matrix, err := client.QueryRange(ctx, query, ts, ts, writeInterval)
+	if err != nil {
+		return errors.Wrapf(err, "failed to run query %s", query)
+	}
+
+	if len(matrix) != 1 {
+		return fmt.Errorf("expected 1 series in the result of %s but got %d", query, len(matrix))
+	}
+	if len(matrix[0].Values) == 0 {
+		return ErrNoData
+	}
+
+	for _, sample := range matrix[0].Values {
+		if actual := float64(sample.Value); math.IsNaN(actual) || math.Abs(actual-expected) > tolerance {
+			return fmt.Errorf("%s at timestamp %d has value %f while was expecting %f (tolerance %f)", query, sample.Timestamp, actual, expected, tolerance)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,162 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/util/teststorage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateHistogramSeries(t *testing.T) {
+	ts := time.Unix(1000, 0)
+	sample := func(v float64) []prompb.Sample {
+		return []prompb.Sample{{Value: v, Timestamp: ts.UnixMilli()}}
+	}
+
+	series := generateHistogramSeries("test", ts, []float64{10, 1}, []float64{0.5, 2, 5, 20})
+	assert.Equal(t, []prompb.TimeSeries{
+		{Labels: []prompb.Label{{Name: "__name__", Value: "test_bucket"}, {Name: "le", Value: "1"}}, Samples: sample(1)},
+		{Labels: []prompb.Label{{Name: "__name__", Value: "test_bucket"}, {Name: "le", Value: "10"}}, Samples: sample(3)},
+		{Labels: []prompb.Label{{Name: "__name__", Value: "test_bucket"}, {Name: "le", Value: "+Inf"}}, Samples: sample(4)},
+		{Labels: []prompb.Label{{Name: "__name__", Value: "test_count"}}, Samples: sample(4)},
+		{Labels: []prompb.Label{{Name: "__name__", Value: "test_sum"}}, Samples: sample(27.5)},
+	}, series)
+}
+
+func TestExpectedQuantile(t *testing.T) {
+	values := []float64{5, 1, 4, 2, 3}
+
+	for q, expected := range map[float64]float64{0: 1, 0.2: 1, 0.5: 3, 0.9: 5, 1: 5} {
+		actual, err := expectedQuantile(q, values)
+		require.NoError(t, err)
+		assert.Equal(t, expected, actual, "quantile: %f", q)
+	}
+
+	_, err := expectedQuantile(0.5, nil)
+	assert.Error(t, err)
+
+	_, err = expectedQuantile(1.5, values)
+	assert.Error(t, err)
+}
+
+func TestCheckHistogramQuantile(t *testing.T) {
+	var (
+		ts     = time.Unix(10000, 0)
+		bounds = []float64{10, 20, 30, 40, 50, 60, 70, 80, 90, 100}
+	)
+
+	// A controlled uniform distribution, whose 0.9 quantile is 90.
+	observations := make([]float64, 0, 100)
+	for v := 1; v <= 100; v++ {
+		observations = append(observations, float64(v))
+	}
+
+	t.Run("should succeed if the quantile is within tolerance", func(t *testing.T) {
+		client := newStorageClient(t)
+		require.NoError(t, checkHistogramQuantile(context.Background(), client, "test", ts, bounds, observations, 0.9, 1))
+		assert.Equal(t, []string{"histogram_quantile(0.9, test_bucket)"}, client.queries)
+	})
+
+	t.Run("should fail if the quantile is out of tolerance", func(t *testing.T) {
+		// Skewed observations, concentrated at the lower end of the 90-100 bucket: the quantile estimated
+		// by linear interpolation within the bucket is far from the exact one.
+		skewed := append([]float64{}, observations[:80]...)
+		for i := 0; i < 20; i++ {
+			skewed = append(skewed, 90.5)
+		}
+
+		client := newStorageClient(t)
+		err := checkHistogramQuantile(context.Background(), client, "test", ts, bounds, skewed, 0.9, 1)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "while was expecting 90.500000")
+	})
+
+	t.Run("should fail if the query returns no series", func(t *testing.T) {
+		client := &ClientMock{}
+		client.On("WriteSeries", mock.Anything, mock.Anything).Return(200, nil)
+		client.On("QueryRange", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Matrix{}, nil)
+
+		err := checkHistogramQuantile(context.Background(), client, "test", ts, bounds, observations, 0.9, math.Inf(+1))
+		assert.EqualError(t, err, "expected 1 series in the result of histogram_quantile(0.9, test_bucket) but got 0")
+	})
+}
+
+// storageClient is a MimirClient writing series to a local storage and running queries on it
+// with the PromQL engine.
+type storageClient struct {
+	ClientMock
+
+	storage *teststorage.TestStorage
+	engine  *promql.Engine
+	queries []string
+}
+
+func newStorageClient(t *testing.T) *storageClient {
+	storage := teststorage.New(t)
+	t.Cleanup(func() { _ = storage.Close() })
+
+	return &storageClient{
+		storage: storage,
+		engine:  promql.NewEngine(promql.EngineOpts{MaxSamples: 1e6, Timeout: time.Minute}),
+	}
+}
+
+func (c *storageClient) WriteSeries(ctx context.Context, series []prompb.TimeSeries) (int, error) {
+	app := c.storage.Appender(ctx)
+	for _, s := range series {
+		lbls := make(labels.Labels, 0, len(s.Labels))
+		for _, l := range s.Labels {
+			lbls = append(lbls, labels.Label{Name: l.Name, Value: l.Value})
+		}
+		for _, sample := range s.Samples {
+			if _, err := app.Append(0, lbls, sample.Timestamp, sample.Value); err != nil {
+				return 400, err
+			}
+		}
+	}
+	return 200, app.Commit()
+}
+
+func (c *storageClient) QueryRange(ctx context.Context, query string, start, end time.Time, step time.Duration) (model.Matrix, error) {
+	c.queries = append(c.queries, query)
+
+	q, err := c.engine.NewRangeQuery(c.storage, query, start, end, step)
+	if err != nil {
+		return nil, err
+	}
+
+	res := q.Exec(ctx)
+	if res.Err != nil {
+		return nil, res.Err
+	}
+
+	matrix, err := res.Matrix()
+	if err != nil {
+		return nil, err
+	}
+
+	// Convert the PromQL result to the one returned by the Prometheus API client.
+	out := make(model.Matrix, 0, len(matrix))
+	for _, series := range matrix {
+		stream := &model.SampleStream{Metric: model.Metric{}}
+		for _, l := range series.Metric {
+			stream.Metric[model.LabelName(l.Name)] = model.LabelValue(l.Value)
+		}
+		for _, p := range series.Points {
+			stream.Values = append(stream.Values, model.SamplePair{Timestamp: model.Time(p.T), Value: model.SampleValue(p.V)})
+		}
+		out = append(out, stream)
+	}
+	return out, nil
+}
@@ -0,0 +1,101 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"context"
+	"flag"
+	"math"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+)
+
+var (
+	// ErrNotYetCompacted is returned by CheckHistoricalData when the historical range has missing samples,
+	// which is expected until the blocks have been compacted and are queryable from the store-gateway.
+	ErrNotYetCompacted = errors.New("the historical data is not queryable yet")
+
+	// ErrCompactedValuesMismatch is returned by CheckHistoricalData when the historical range returns
+	// samples with values different than the expected ones.
+	ErrCompactedValuesMismatch = errors.New("the historical data has unexpected values")
+)
+
+// HistoricalCheckConfig configures the historical time range checked by CheckHistoricalData.
+type HistoricalCheckConfig struct {
+	MinAge    time.Duration
+	Duration  time.Duration
+	Step      time.Duration
+	Tolerance float64
+}
+
+// RegisterFlagsWithPrefix registers flags with the given prefix.
+func (cfg *HistoricalCheckConfig) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
+	f.DurationVar(&cfg.MinAge, prefix+".min-age", 14*time.Hour, "The age of the end of the historical time range. It should be greater than -querier.query-ingesters-within configured in Mimir, so that the range is only queried from the store-gateway.")
+	f.DurationVar(&cfg.Duration, prefix+".duration", time.Hour, "The duration of the historical time range.")
+	f.DurationVar(&cfg.Step, prefix+".step", time.Minute, "The step of the range query run on the historical time range.")
+	f.Float64Var(&cfg.Tolerance, prefix+".tolerance", 1e-6, "The max relative difference between the expected and actual values of the historical samples.")
+}
+
+// timeRange returns the historical time range, given the current time.
+func (cfg *HistoricalCheckConfig) timeRange(now time.Time) (start, end time.Time) {
+	end = now.Add(-cfg.MinAge).Truncate(cfg.Step)
+	start = end.Add(-cfg.Duration)
+	return start, end
+}
+
+// CheckHistoricalData runs the input range query on the configured historical time range, older than the data
+// queried from ingesters, and checks the query returns a single series with a sample at each step, whose value
+// is the one returned by the expected function within the configured tolerance. It fails with ErrNotYetCompacted
+// if samples are missing, or with ErrCompactedValuesMismatch if any sample has an unexpected value.
+func (c *Client) CheckHistoricalData(ctx context.Context, cfg HistoricalCheckConfig, query string, expected func(ts time.Time) float64, now time.Time) error {
+	start, end := cfg.timeRange(now)
+
+	matrix, err := c.QueryRange(ctx, query, start, end, cfg.Step)
+	if err != nil {
+		return errors.Wrapf(err, "failed to query the historical range %d-%d", start.UnixMilli(), end.UnixMilli())
+	}
+
+	return verifyHistoricalMatrix(matrix, start, end, cfg.Step, cfg.Tolerance, expected)
+}
+
+// verifyHistoricalMatrix checks the input matrix has a single series with the expected value at each step in
+// the input time range. Missing samples are checked first, so that a partially queryable range is reported as
+// not yet compacted even if the returned values are unexpected.
+func verifyHistoricalMatrix(matrix model.Matrix, start, end time.Time, step time.Duration, tolerance float64, expected func(ts time.Time) float64) error {
+	if len(matrix) > 1 {
+		return errors.Wrapf(ErrCompactedValuesMismatch, "expected 1 series in the result but got %d", len(matrix))
+	}
+
+	expectedSamples := int(end.Sub(start)/step) + 1
+	actualSamples := 0
+	if len(matrix) == 1 {
+		actualSamples = len(matrix[0].Values)
+	}
+
+	if actualSamples < expectedSamples {
+		return errors.Wrapf(ErrNotYetCompacted, "the range %d-%d returned %d out of %d samples", start.UnixMilli(), end.UnixMilli(), actualSamples, expectedSamples)
+	}
+
+	for _, sample := range matrix[0].Values {
+		ts := sample.Timestamp.Time().UTC()
+		expectedValue := expected(ts)
+
+		if !compareSampleValuesWithMixedTolerance(float64(sample.Value), expectedValue, tolerance) {
+			return errors.Wrapf(ErrCompactedValuesMismatch, "sample at timestamp %d (%s) has value %f while was expecting %f", sample.Timestamp, ts.String(), sample.Value, expectedValue)
+		}
+	}
+
+	return nil
+}
+
+// compareSampleValuesWithMixedTolerance returns whether the actual value matches the expected one, within the
+// input tolerance. Unlike compareSampleValuesWithTolerance, the tolerance is absolute for expected values between
+// -1 and 1, so that values close to zero (eg. sine wave samples) don't fail the comparison due to rounding errors.
+func compareSampleValuesWithMixedTolerance(actual, expected, tolerance float64) bool {
+	if actual == expected {
+		return true
+	}
+	return math.Abs(actual-expected) <= tolerance*math.Max(math.Abs(expected), 1)
+}
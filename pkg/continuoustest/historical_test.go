@@ -0,0 +1,116 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/flagext"
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_CheckHistoricalData(t *testing.T) {
+	const numSeries = 3
+
+	cfg := HistoricalCheckConfig{
+		MinAge:    14 * time.Hour,
+		Duration:  time.Hour,
+		Step:      time.Minute,
+		Tolerance: 1e-6,
+	}
+
+	now := time.Now()
+	start, end := cfg.timeRange(now)
+	expected := func(ts time.Time) float64 { return numSeries * generateSineWaveValue(ts) }
+
+	// newMatrix returns a matrix with a sample at each step in the historical time range, with the value
+	// returned by the input function. Samples are skipped if the function returns NaN.
+	newMatrix := func(value func(ts time.Time) float64) model.Matrix {
+		stream := &model.SampleStream{Metric: model.Metric{}}
+		for ts := start; !ts.After(end); ts = ts.Add(cfg.Step) {
+			if v := value(ts); !math.IsNaN(v) {
+				stream.Values = append(stream.Values, model.SamplePair{Timestamp: model.TimeFromUnixNano(ts.UnixNano()), Value: model.SampleValue(v)})
+			}
+		}
+		return model.Matrix{stream}
+	}
+
+	tests := map[string]struct {
+		matrix      model.Matrix
+		expectedErr error
+	}{
+		"should succeed if compacted data has the expected values": {
+			matrix: newMatrix(expected),
+		},
+		"should fail as not yet compacted if the historical range has no data": {
+			matrix:      model.Matrix{},
+			expectedErr: ErrNotYetCompacted,
+		},
+		"should fail as not yet compacted if the historical range is partially queryable": {
+			matrix: newMatrix(func(ts time.Time) float64 {
+				// Only the most recent half of the range has been compacted.
+				if ts.Before(start.Add(cfg.Duration / 2)) {
+					return math.NaN()
+				}
+				return expected(ts)
+			}),
+			expectedErr: ErrNotYetCompacted,
+		},
+		"should fail as mismatch if compacted data has unexpected values": {
+			matrix: newMatrix(func(ts time.Time) float64 {
+				return expected(ts) + 0.5
+			}),
+			expectedErr: ErrCompactedValuesMismatch,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			var receivedQuery string
+
+			server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+				require.NoError(t, request.ParseForm())
+				receivedQuery = request.Form.Get("query")
+
+				body, err := json.Marshal(map[string]interface{}{
+					"status": "success",
+					"data":   map[string]interface{}{"resultType": "matrix", "result": testData.matrix},
+				})
+				require.NoError(t, err)
+
+				writer.Header().Set("Content-Type", "application/json")
+				_, _ = writer.Write(body)
+			}))
+			t.Cleanup(server.Close)
+
+			clientCfg := ClientConfig{}
+			flagext.DefaultValues(&clientCfg)
+			require.NoError(t, clientCfg.WriteBaseEndpoint.Set(server.URL))
+			require.NoError(t, clientCfg.ReadBaseEndpoint.Set(server.URL))
+
+			c, err := NewClient(clientCfg, log.NewNopLogger(), nil)
+			require.NoError(t, err)
+
+			err = c.CheckHistoricalData(context.Background(), cfg, "sum(test)", expected, now)
+			assert.Equal(t, "sum(test)", receivedQuery)
+
+			if testData.expectedErr == nil {
+				require.NoError(t, err)
+				return
+			}
+
+			require.Error(t, err)
+			assert.True(t, errors.Is(err, testData.expectedErr), err.Error())
+		})
+	}
+}
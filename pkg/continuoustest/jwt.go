@@ -0,0 +1,61 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// loadJWT returns the JWT configured either as literal value or as path to a file containing it.
+func loadJWT(jwt, jwtFile string) (string, error) {
+	if jwt != "" && jwtFile != "" {
+		return "", errors.New("the JWT and the JWT file can't be both set")
+	}
+	if jwtFile == "" {
+		return jwt, nil
+	}
+
+	content, err := os.ReadFile(jwtFile)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to read the JWT file")
+	}
+
+	return strings.TrimSpace(string(content)), nil
+}
+
+// getJWTClaim returns the value of the input claim from the JWT payload. The JWT signature
+// is not verified, because it's expected to be verified by the authentication proxy in
+// front of Mimir.
+func getJWTClaim(jwt, claim string) (string, error) {
+	parts := strings.Split(jwt, ".")
+	if len(parts) != 3 {
+		return "", errors.New("malformed JWT")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return "", errors.Wrap(err, "failed to decode the JWT payload")
+	}
+
+	claims := map[string]interface{}{}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return "", errors.Wrap(err, "failed to unmarshal the JWT payload")
+	}
+
+	value, ok := claims[claim]
+	if !ok {
+		return "", errors.Errorf("the claim %q is missing in the JWT", claim)
+	}
+
+	str, ok := value.(string)
+	if !ok || str == "" {
+		return "", errors.Errorf("the claim %q in the JWT is not a non-empty string", claim)
+	}
+
+	return str, nil
+}
@@ -0,0 +1,116 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/grafana/dskit/flagext"
+	"github.com/pkg/errors"
+)
+
+const (
+	writeTransportHTTP  = "http"
+	writeTransportKafka = "kafka"
+)
+
+// KafkaProducer produces messages to a Kafka topic. The tool doesn't embed a Kafka client, so a producer
+// must be provided to write to Kafka, connecting to the configured brokers.
+type KafkaProducer interface {
+	// Produce synchronously produces a message with the input key and value to the input topic.
+	Produce(ctx context.Context, topic string, key, value []byte) error
+}
+
+// KafkaConfig configures the Kafka topic written to when the write transport is Kafka. The Kafka transport
+// requires a producer, which can't be built from CLI flags, so it can only be configured programmatically.
+type KafkaConfig struct {
+	Brokers flagext.StringSliceCSV
+	Topic   string
+
+	// Producer is the producer used to write to Kafka.
+	Producer KafkaProducer
+}
+
+func (cfg *KafkaConfig) validate() error {
+	if len(cfg.Brokers) == 0 {
+		return errors.New("the Kafka brokers must be set when the write transport is kafka")
+	}
+	if cfg.Topic == "" {
+		return errors.New("the Kafka topic must be set when the write transport is kafka")
+	}
+	if cfg.Producer == nil {
+		return errors.New("a Kafka producer must be provided when the write transport is kafka")
+	}
+	return nil
+}
+
+// validateWriteTransport returns an error if the input write transport is not supported. An empty
+// write transport means http.
+func validateWriteTransport(transport string) error {
+	switch transport {
+	case "", writeTransportHTTP, writeTransportKafka:
+		return nil
+	default:
+		return fmt.Errorf("unsupported write transport %q", transport)
+	}
+}
+
+// kafkaRoundTripper produces remote write requests to a Kafka topic instead of sending them over HTTP.
+// Each request is produced as a message whose value is the serialized prompb.WriteRequest and whose key
+// is the tenant ID. Being a round tripper, writes to Kafka go through the same timeout, in-flight limit
+// and metrics as HTTP writes. The other requests are sent with the next round tripper.
+type kafkaRoundTripper struct {
+	topic    string
+	producer KafkaProducer
+	next     http.RoundTripper
+}
+
+func newKafkaRoundTripper(cfg KafkaConfig, next http.RoundTripper) *kafkaRoundTripper {
+	return &kafkaRoundTripper{topic: cfg.Topic, producer: cfg.Producer, next: next}
+}
+
+// RoundTrip implements http.RoundTripper. On success, it returns an empty response with the status code
+// of a successful HTTP write, so that callers can handle the writes regardless of the transport.
+func (rt *kafkaRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if getRequestOperation(req.URL.Path) != operationWrite {
+		return rt.next.RoundTrip(req)
+	}
+
+	compressed, err := io.ReadAll(req.Body)
+	_ = req.Body.Close()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read write request")
+	}
+
+	data, err := snappyDecode(compressed)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decompress write request")
+	}
+
+	// The tenant ID header is set by the client round tripper.
+	if err := rt.producer.Produce(req.Context(), rt.topic, []byte(req.Header.Get("X-Scope-OrgID")), data); err != nil {
+		return nil, errors.Wrapf(err, "failed to produce write request to Kafka topic %s", rt.topic)
+	}
+
+	return &http.Response{
+		Status:     http.StatusText(http.StatusOK),
+		StatusCode: http.StatusOK,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{},
+		Body:       io.NopCloser(bytes.NewReader(nil)),
+		Request:    req,
+	}, nil
+}
+
+// CloseIdleConnections closes the idle connections of the next round tripper, if supported.
+func (rt *kafkaRoundTripper) CloseIdleConnections() {
+	if closer, ok := rt.next.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
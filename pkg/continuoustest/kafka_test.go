@@ -0,0 +1,204 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/gogo/protobuf/proto"
+	"github.com/grafana/dskit/flagext"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+	"go.uber.org/atomic"
+)
+
+func TestClient_WriteSeries_KafkaTransport(t *testing.T) {
+	receivedHTTPRequests := atomic.NewInt32(0)
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		receivedHTTPRequests.Inc()
+	}))
+	t.Cleanup(server.Close)
+
+	newClient := func(t *testing.T, producer KafkaProducer, reg prometheus.Registerer, opts ...func(cfg *ClientConfig)) (*Client, error) {
+		cfg := ClientConfig{}
+		flagext.DefaultValues(&cfg)
+		cfg.TenantID = "tenant-1"
+		cfg.WriteBatchSize = 2
+		cfg.WriteTransport = writeTransportKafka
+		cfg.WriteKafka.Brokers = []string{"localhost:9092"}
+		cfg.WriteKafka.Topic = "ingest"
+		cfg.WriteKafka.Producer = producer
+		require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+		require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+		for _, opt := range opts {
+			opt(&cfg)
+		}
+
+		return NewClient(cfg, log.NewNopLogger(), reg)
+	}
+
+	t.Run("should produce the serialized write requests to the configured topic", func(t *testing.T) {
+		producer := &inMemoryKafkaProducer{}
+		c, err := newClient(t, producer, nil)
+		require.NoError(t, err)
+
+		series := generateSineWaveSeries("test", time.Now(), 3)
+		statusCode, err := c.WriteSeries(context.Background(), series)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, statusCode)
+
+		_, err = c.WriteSeries(user.InjectOrgID(context.Background(), "tenant-2"), series[:1])
+		require.NoError(t, err)
+
+		// The batch size is honored, and the key is the tenant ID.
+		messages := producer.getMessages()
+		require.Len(t, messages, 3)
+
+		expected := []struct {
+			key    string
+			series []prompb.TimeSeries
+		}{
+			{key: "tenant-1", series: series[0:2]},
+			{key: "tenant-1", series: series[2:3]},
+			{key: "tenant-2", series: series[0:1]},
+		}
+
+		for i, message := range messages {
+			assert.Equal(t, "ingest", message.topic)
+			assert.Equal(t, expected[i].key, string(message.key))
+
+			req := prompb.WriteRequest{}
+			require.NoError(t, proto.Unmarshal(message.value, &req))
+			assert.Equal(t, expected[i].series, req.Timeseries)
+		}
+
+		// No write request should be sent over HTTP.
+		assert.Equal(t, int32(0), receivedHTTPRequests.Load())
+	})
+
+	t.Run("should return the producer error", func(t *testing.T) {
+		producer := &inMemoryKafkaProducer{err: errors.New("broker not available")}
+		c, err := newClient(t, producer, nil)
+		require.NoError(t, err)
+
+		_, err = c.WriteSeries(context.Background(), generateSineWaveSeries("test", time.Now(), 1))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "broker not available")
+	})
+
+	t.Run("should track the write requests produced to Kafka like the HTTP ones", func(t *testing.T) {
+		reg := prometheus.NewPedanticRegistry()
+		c, err := newClient(t, &inMemoryKafkaProducer{}, reg)
+		require.NoError(t, err)
+
+		_, err = c.WriteSeries(context.Background(), generateSineWaveSeries("test", time.Now(), 3))
+		require.NoError(t, err)
+
+		families, err := reg.Gather()
+		require.NoError(t, err)
+
+		var count uint64
+		for _, family := range families {
+			if family.GetName() == "mimir_continuous_test_client_request_duration_seconds" {
+				for _, metric := range family.GetMetric() {
+					count += metric.GetHistogram().GetSampleCount()
+				}
+			}
+		}
+		assert.Equal(t, uint64(2), count)
+	})
+
+	t.Run("should honor the write timeout", func(t *testing.T) {
+		producer := &inMemoryKafkaProducer{block: true}
+		c, err := newClient(t, producer, nil, func(cfg *ClientConfig) {
+			cfg.WriteTimeout = 100 * time.Millisecond
+		})
+		require.NoError(t, err)
+
+		_, err = c.WriteSeries(context.Background(), generateSineWaveSeries("test", time.Now(), 1))
+		require.Error(t, err)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("should honor the max in-flight write requests", func(t *testing.T) {
+		producer := &inMemoryKafkaProducer{}
+		c, err := newClient(t, producer, nil, func(cfg *ClientConfig) {
+			cfg.WriteMaxInflight = 1
+		})
+		require.NoError(t, err)
+
+		// Hold the only in-flight slot, so that writes wait until the context is canceled.
+		require.NoError(t, c.writeInflight.Acquire(context.Background(), 1))
+		defer c.writeInflight.Release(1)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		_, err = c.WriteSeries(ctx, generateSineWaveSeries("test", time.Now(), 1))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to wait for in-flight write requests to complete")
+		assert.Empty(t, producer.getMessages())
+	})
+
+	t.Run("should fail if the producer has not been provided", func(t *testing.T) {
+		_, err := newClient(t, nil, nil)
+		require.EqualError(t, err, "a Kafka producer must be provided when the write transport is kafka")
+	})
+}
+
+func TestNewClient_ShouldFailOnUnsupportedWriteTransport(t *testing.T) {
+	cfg := ClientConfig{}
+	flagext.DefaultValues(&cfg)
+	cfg.WriteTransport = "grpc"
+	require.NoError(t, cfg.WriteBaseEndpoint.Set("http://localhost"))
+	require.NoError(t, cfg.ReadBaseEndpoint.Set("http://localhost"))
+
+	_, err := NewClient(cfg, log.NewNopLogger(), nil)
+	require.EqualError(t, err, `unsupported write transport "grpc"`)
+}
+
+type kafkaMessage struct {
+	topic string
+	key   []byte
+	value []byte
+}
+
+// inMemoryKafkaProducer is a KafkaProducer keeping the produced messages in memory.
+type inMemoryKafkaProducer struct {
+	err   error
+	block bool
+
+	mx       sync.Mutex
+	messages []kafkaMessage
+}
+
+func (p *inMemoryKafkaProducer) Produce(ctx context.Context, topic string, key, value []byte) error {
+	if p.err != nil {
+		return p.err
+	}
+	if p.block {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	p.messages = append(p.messages, kafkaMessage{topic: topic, key: key, value: value})
+	return nil
+}
+
+func (p *inMemoryKafkaProducer) getMessages() []kafkaMessage {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	return append([]kafkaMessage{}, p.messages...)
+}
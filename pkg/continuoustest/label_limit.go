@@ -0,0 +1,57 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+const (
+	// labelValueTooLongReason is the reason samples of series with a label value exceeding
+	// the max length are rejected for.
+	labelValueTooLongReason = "label_value_too_long"
+
+	// labelValueTooLongLabel is the name of the label whose value exceeds the max length.
+	labelValueTooLongLabel = "long_label"
+)
+
+// ErrLabelValueLengthLimitNotEnforced is returned by CheckLabelValueLengthLimit when the series with a too
+// long label value is not rejected as expected.
+var ErrLabelValueLengthLimitNotEnforced = errors.New("the max label value length limit is not enforced as expected")
+
+// CheckLabelValueLengthLimit checks the max label value length limit configured in Mimir for the tenant is
+// enforced. It writes a series for the input metric with a label value of the input length, which is expected
+// to exceed the limit, and checks the write is rejected with a 4xx error reporting the label value as too long.
+// The series bypasses the client-side batch validation, so that it's always sent to the server. Fails with
+// ErrLabelValueLengthLimitNotEnforced if the series has been accepted or rejected for another reason.
+func (c *Client) CheckLabelValueLengthLimit(ctx context.Context, metricName string, length int) error {
+	if length <= 0 {
+		return errors.New("the label value length must be greater than 0")
+	}
+
+	now := time.Now()
+	req := &prompb.WriteRequest{Timeseries: []prompb.TimeSeries{{
+		Labels: []prompb.Label{
+			{Name: "__name__", Value: metricName},
+			{Name: labelValueTooLongLabel, Value: strings.Repeat("x", length)},
+		},
+		Samples: []prompb.Sample{{Value: generateSineWaveValue(now), Timestamp: now.UnixMilli()}},
+	}}}
+
+	statusCode, err := c.sendWriteRequest(ctx, req)
+	if err == nil {
+		return errors.Wrapf(ErrLabelValueLengthLimitNotEnforced, "the series with a label value of %d characters has been accepted with status code %d", length, statusCode)
+	}
+
+	var partialErr *PartialWriteError
+	if !errors.As(err, &partialErr) || partialErr.Reasons[labelValueTooLongReason] == 0 {
+		return errors.Wrapf(ErrLabelValueLengthLimitNotEnforced, "the series with a label value of %d characters has been rejected with an unexpected error: %s", length, err.Error())
+	}
+
+	return nil
+}
@@ -0,0 +1,123 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/grafana/dskit/flagext"
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_CheckLabelValueLengthLimit(t *testing.T) {
+	const serverLimit = 2048
+
+	tests := map[string]struct {
+		length         int
+		rejectStatus   int
+		rejectMessage  string
+		validateBatch  bool
+		expectedErr    error
+		expectedErrMsg string
+	}{
+		"should succeed if the too long label value is rejected": {
+			length:        serverLimit + 1,
+			rejectStatus:  http.StatusBadRequest,
+			rejectMessage: `label value too long for metric: "test{long_label=\"xxx\"}" label value: "xxx"`,
+		},
+		"should succeed if the too long label value is rejected even if the client validates batches": {
+			length:        serverLimit + 1,
+			rejectStatus:  http.StatusBadRequest,
+			rejectMessage: `label value too long for metric: "test{long_label=\"xxx\"}" label value: "xxx"`,
+			validateBatch: true,
+		},
+		"should fail if the label value within the limit is accepted": {
+			length:         serverLimit,
+			rejectStatus:   http.StatusBadRequest,
+			rejectMessage:  "label value too long",
+			expectedErr:    ErrLabelValueLengthLimitNotEnforced,
+			expectedErrMsg: "label value of 2048 characters has been accepted with status code 200",
+		},
+		"should fail if the series is rejected for another reason": {
+			length:         serverLimit + 1,
+			rejectStatus:   http.StatusBadRequest,
+			rejectMessage:  "per-user series limit",
+			expectedErr:    ErrLabelValueLengthLimitNotEnforced,
+			expectedErrMsg: "rejected with an unexpected error",
+		},
+		"should fail if the series is rejected with a 5xx error": {
+			length:         serverLimit + 1,
+			rejectStatus:   http.StatusInternalServerError,
+			rejectMessage:  "label value too long",
+			expectedErr:    ErrLabelValueLengthLimitNotEnforced,
+			expectedErrMsg: "rejected with an unexpected error",
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			// The server rejects series with label values longer than its limit.
+			server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+				body, err := ioutil.ReadAll(request.Body)
+				require.NoError(t, err)
+
+				body, err = snappy.Decode(nil, body)
+				require.NoError(t, err)
+
+				req := prompb.WriteRequest{}
+				require.NoError(t, proto.Unmarshal(body, &req))
+				require.Len(t, req.Timeseries, 1)
+
+				for _, l := range req.Timeseries[0].Labels {
+					if len(l.Value) > serverLimit {
+						writer.WriteHeader(testData.rejectStatus)
+						_, _ = writer.Write([]byte(testData.rejectMessage))
+						return
+					}
+				}
+			}))
+			t.Cleanup(server.Close)
+
+			cfg := ClientConfig{}
+			flagext.DefaultValues(&cfg)
+			cfg.ValidateBatch = testData.validateBatch
+			require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+			require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+			c, err := NewClient(cfg, log.NewNopLogger(), nil)
+			require.NoError(t, err)
+
+			err = c.CheckLabelValueLengthLimit(context.Background(), "test", testData.length)
+			if testData.expectedErr == nil {
+				require.NoError(t, err)
+				return
+			}
+
+			require.Error(t, err)
+			assert.True(t, errors.Is(err, testData.expectedErr))
+			assert.Contains(t, err.Error(), testData.expectedErrMsg)
+		})
+	}
+}
+
+func TestClient_CheckLabelValueLengthLimit_ShouldFailOnInvalidLength(t *testing.T) {
+	c := &Client{}
+
+	for _, length := range []int{0, -1} {
+		t.Run(fmt.Sprintf("length: %d", length), func(t *testing.T) {
+			err := c.CheckLabelValueLengthLimit(context.Background(), "test", length)
+			require.EqualError(t, err, "the label value length must be greater than 0")
+		})
+	}
+}
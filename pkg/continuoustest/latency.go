@@ -0,0 +1,124 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// latencyReservoirSize is the max number of request latencies sampled for each operation. Once the
+// reservoir is full, latencies are randomly sampled so that the reservoir is a uniform sample of all of them.
+const latencyReservoirSize = 10000
+
+// ErrLatencyThresholdExceeded is returned when the latency percentile of an operation exceeds the threshold.
+var ErrLatencyThresholdExceeded = errors.New("latency threshold exceeded")
+
+// LatencyPercentiles is the latency distribution of the requests of an operation.
+type LatencyPercentiles struct {
+	// Count is the number of requests observed, including the ones not sampled in the reservoir.
+	Count int64
+
+	P50 time.Duration
+	P90 time.Duration
+	P99 time.Duration
+}
+
+// latencyTracker tracks the latency of requests by operation, in fixed-size reservoirs.
+type latencyTracker struct {
+	mx         sync.Mutex
+	rnd        *rand.Rand
+	reservoirs map[string]*latencyReservoir
+}
+
+type latencyReservoir struct {
+	count   int64
+	samples []time.Duration
+}
+
+func newLatencyTracker() *latencyTracker {
+	return &latencyTracker{
+		rnd:        rand.New(rand.NewSource(time.Now().UnixNano())),
+		reservoirs: map[string]*latencyReservoir{},
+	}
+}
+
+func (t *latencyTracker) observe(operation string, latency time.Duration) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+
+	r, ok := t.reservoirs[operation]
+	if !ok {
+		r = &latencyReservoir{}
+		t.reservoirs[operation] = r
+	}
+
+	r.count++
+	if len(r.samples) < latencyReservoirSize {
+		r.samples = append(r.samples, latency)
+		return
+	}
+
+	// Replace a random sample with decreasing probability, so that each latency
+	// observed so far has the same probability to be in the reservoir.
+	if idx := t.rnd.Int63n(r.count); idx < latencyReservoirSize {
+		r.samples[idx] = latency
+	}
+}
+
+// percentiles returns the latency percentiles of each operation observed so far.
+func (t *latencyTracker) percentiles() map[string]LatencyPercentiles {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+
+	out := make(map[string]LatencyPercentiles, len(t.reservoirs))
+	for operation, r := range t.reservoirs {
+		sorted := append([]time.Duration(nil), r.samples...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+		out[operation] = LatencyPercentiles{
+			Count: r.count,
+			P50:   latencyPercentile(sorted, 0.5),
+			P90:   latencyPercentile(sorted, 0.9),
+			P99:   latencyPercentile(sorted, 0.99),
+		}
+	}
+
+	return out
+}
+
+// latencyPercentile returns the q-percentile (0 < q <= 1) of the input sorted latencies, using the
+// nearest-rank method. Returns 0 if there are no latencies.
+func latencyPercentile(sorted []time.Duration, q float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	rank := int(math.Ceil(q * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// checkMaxP99Latency returns an error if the p99 latency of any of the input operations exceeds max.
+func checkMaxP99Latency(percentiles map[string]LatencyPercentiles, max time.Duration) error {
+	operations := make([]string, 0, len(percentiles))
+	for operation := range percentiles {
+		operations = append(operations, operation)
+	}
+	sort.Strings(operations)
+
+	for _, operation := range operations {
+		if p99 := percentiles[operation].P99; p99 > max {
+			return errors.Wrapf(ErrLatencyThresholdExceeded, "the p99 latency of %s requests is %s while the max allowed is %s", operation, p99, max)
+		}
+	}
+
+	return nil
+}
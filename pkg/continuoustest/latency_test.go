@@ -0,0 +1,148 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/flagext"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLatencyTracker_Percentiles(t *testing.T) {
+	tracker := newLatencyTracker()
+
+	// Feed 1ms, 2ms, ..., 100ms in reverse order, to check latencies are sorted.
+	for i := 100; i >= 1; i-- {
+		tracker.observe(operationWrite, time.Duration(i)*time.Millisecond)
+	}
+	tracker.observe(operationRead, 5*time.Millisecond)
+
+	assert.Equal(t, map[string]LatencyPercentiles{
+		operationWrite: {Count: 100, P50: 50 * time.Millisecond, P90: 90 * time.Millisecond, P99: 99 * time.Millisecond},
+		operationRead:  {Count: 1, P50: 5 * time.Millisecond, P90: 5 * time.Millisecond, P99: 5 * time.Millisecond},
+	}, tracker.percentiles())
+}
+
+func TestLatencyTracker_ShouldBoundTheReservoirSize(t *testing.T) {
+	tracker := newLatencyTracker()
+
+	// Most requests are fast, while 5% of them are slow.
+	for i := 0; i < 5*latencyReservoirSize; i++ {
+		latency := 10 * time.Millisecond
+		if i%20 == 0 {
+			latency = time.Second
+		}
+		tracker.observe(operationWrite, latency)
+	}
+
+	assert.Len(t, tracker.reservoirs[operationWrite].samples, latencyReservoirSize)
+
+	percentiles := tracker.percentiles()[operationWrite]
+	assert.Equal(t, int64(5*latencyReservoirSize), percentiles.Count)
+	assert.Equal(t, 10*time.Millisecond, percentiles.P50)
+	assert.Equal(t, 10*time.Millisecond, percentiles.P90)
+	assert.Equal(t, time.Second, percentiles.P99)
+}
+
+func TestLatencyPercentile(t *testing.T) {
+	tests := map[string]struct {
+		sorted   []time.Duration
+		q        float64
+		expected time.Duration
+	}{
+		"no latencies": {
+			q:        0.99,
+			expected: 0,
+		},
+		"single latency": {
+			sorted:   []time.Duration{time.Second},
+			q:        0.5,
+			expected: time.Second,
+		},
+		"p50 of even number of latencies": {
+			sorted:   []time.Duration{1, 2, 3, 4},
+			q:        0.5,
+			expected: 2,
+		},
+		"p99 of few latencies is the max": {
+			sorted:   []time.Duration{1, 2, 3, 4},
+			q:        0.99,
+			expected: 4,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			assert.Equal(t, testData.expected, latencyPercentile(testData.sorted, testData.q))
+		})
+	}
+}
+
+func TestCheckMaxP99Latency(t *testing.T) {
+	percentiles := map[string]LatencyPercentiles{
+		operationRead:  {Count: 10, P50: time.Millisecond, P90: 2 * time.Millisecond, P99: 200 * time.Millisecond},
+		operationWrite: {Count: 10, P50: time.Millisecond, P90: 2 * time.Millisecond, P99: 50 * time.Millisecond},
+	}
+
+	assert.NoError(t, checkMaxP99Latency(percentiles, 200*time.Millisecond))
+
+	err := checkMaxP99Latency(percentiles, 100*time.Millisecond)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrLatencyThresholdExceeded))
+	assert.EqualError(t, err, "the p99 latency of read requests is 200ms while the max allowed is 100ms: latency threshold exceeded")
+}
+
+func TestClient_CheckLatency(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.URL.Path == "/api/v1/query_range" {
+			time.Sleep(50 * time.Millisecond)
+		}
+
+		writer.Header().Set("Content-Type", "application/json")
+		_, _ = writer.Write([]byte(`{"status":"success","data":{"resultType":"matrix","result":[]}}`))
+	}))
+	t.Cleanup(server.Close)
+
+	for _, testData := range []struct {
+		maxP99Latency time.Duration
+		expectedErr   bool
+	}{
+		{maxP99Latency: 0, expectedErr: false},
+		{maxP99Latency: time.Minute, expectedErr: false},
+		{maxP99Latency: 10 * time.Millisecond, expectedErr: true},
+	} {
+		cfg := ClientConfig{}
+		flagext.DefaultValues(&cfg)
+		cfg.MaxP99Latency = testData.maxP99Latency
+		require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+		require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+		c, err := NewClient(cfg, log.NewNopLogger(), nil)
+		require.NoError(t, err)
+
+		_, err = c.WriteSeries(context.Background(), generateSineWaveSeries("test", time.Now(), 1))
+		require.NoError(t, err)
+		_, err = c.QueryRange(context.Background(), "test", time.Unix(1000, 0), time.Unix(2000, 0), 20*time.Second)
+		require.NoError(t, err)
+
+		percentiles := c.LatencyPercentiles()
+		assert.Equal(t, int64(1), percentiles[operationWrite].Count)
+		assert.Equal(t, int64(1), percentiles[operationRead].Count)
+		assert.GreaterOrEqual(t, percentiles[operationRead].P99, 50*time.Millisecond)
+
+		err = c.CheckLatency()
+		if testData.expectedErr {
+			assert.ErrorIs(t, err, ErrLatencyThresholdExceeded)
+		} else {
+			assert.NoError(t, err)
+		}
+	}
+}
@@ -4,8 +4,18 @@ package continuoustest
 
 import (
 	"context"
+	"flag"
+	"net/http"
+	"strconv"
 	"sync"
 	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+	"github.com/weaveworks/common/user"
 )
 
 type Test interface {
@@ -16,39 +26,132 @@ type Test interface {
 	Init() error
 
 	// Run runs a single test cycle. This function is called multiple times, at periodic intervals.
-	Run(ctx context.Context, now time.Time)
+	// Returns an error if the test cycle failed.
+	Run(ctx context.Context, now time.Time) error
+}
+
+type ManagerConfig struct {
+	LivenessWindow time.Duration
+	Pushgateway    PushgatewayConfig
+	Scenarios      ScenariosConfig
+}
+
+func (cfg *ManagerConfig) RegisterFlags(f *flag.FlagSet) {
+	f.DurationVar(&cfg.LivenessWindow, "tests.liveness-window", 10*time.Minute, "The liveness endpoint reports the tool as unhealthy if no test cycle succeeded within this period.")
+	cfg.Pushgateway.RegisterFlags(f)
+	cfg.Scenarios.RegisterFlags(f)
+}
+
+// managedTest is a test registered to the manager.
+type managedTest struct {
+	test Test
+
+	// tenantID is the tenant the test runs for. If empty, the tenant configured in the client is used.
+	tenantID string
+
+	// client is the client dedicated to the test, if any, whose lifecycle is managed by the manager.
+	client *Client
 }
 
 type Manager struct {
-	tests []Test
+	cfg   ManagerConfig
+	tests []managedTest
+
+	lastSuccessMx sync.Mutex
+	lastSuccess   time.Time
+
+	// The sinks receiving the result of each test cycle.
+	sinks []ResultSink
+
+	// The pusher is nil if pushing metrics to Pushgateway is disabled.
+	pusherMx     sync.Mutex
+	pusher       *push.Pusher
+	pusherLogger log.Logger
 }
 
-func NewManager() *Manager {
-	return &Manager{}
+func NewManager(cfg ManagerConfig) *Manager {
+	return &Manager{
+		cfg:         cfg,
+		lastSuccess: time.Now(),
+	}
 }
 
 func (m *Manager) AddTest(t Test) {
-	m.tests = append(m.tests, t)
+	m.AddTestForTenant(t, "")
+}
+
+// AddTestForTenant adds a test running for the input tenant. The tenant is injected in the context
+// passed to each test cycle, so that the requests issued through the client are isolated from other
+// tests sharing the same client.
+func (m *Manager) AddTestForTenant(t Test, tenantID string) {
+	m.tests = append(m.tests, managedTest{test: t, tenantID: tenantID})
+}
+
+// AddTestWithClient adds a test using a dedicated client, created with the input config, instead of the client
+// shared with other tests, so that the client settings (eg. batch size, tenant or compression) can be tuned for
+// each test. The test is built by the input function, given the dedicated client. The client metrics have a
+// "client" label set to the index of the dedicated client, in the order they're added, so that dedicated clients
+// can share the same registry. Since the label names differ, they can't share it with a client created with
+// NewClient, unless configured with a different metrics prefix. The manager closes the idle connections of the
+// client once the tests have stopped, which is the only cleanup required, because the client doesn't run any
+// background goroutine.
+func (m *Manager) AddTestWithClient(cfg ClientConfig, logger log.Logger, reg prometheus.Registerer, newTest func(client MimirClient) Test) error {
+	if reg != nil {
+		reg = prometheus.WrapRegistererWith(prometheus.Labels{"client": strconv.Itoa(m.numDedicatedClients())}, reg)
+	}
+
+	client, err := NewClient(cfg, logger, reg)
+	if err != nil {
+		return errors.Wrap(err, "failed to create the test client")
+	}
+
+	m.tests = append(m.tests, managedTest{test: newTest(client), client: client})
+	return nil
+}
+
+// AddResultSinks adds sinks receiving the result of each test cycle. It must be called before Run().
+func (m *Manager) AddResultSinks(sinks ...ResultSink) {
+	m.sinks = append(m.sinks, sinks...)
+}
+
+// EnablePushgateway enables pushing the metrics gathered from the input gatherer to the configured
+// Pushgateway after each test cycle, using the input round tripper (eg. the one used by the client to
+// set the tenant ID). It's a no-op if the Pushgateway URL is not configured.
+func (m *Manager) EnablePushgateway(gatherer prometheus.Gatherer, rt http.RoundTripper, logger log.Logger) {
+	if m.cfg.Pushgateway.URL == "" {
+		return
+	}
+
+	m.pusher = newPushgatewayPusher(m.cfg.Pushgateway, gatherer, rt)
+	m.pusherLogger = logger
 }
 
 func (m *Manager) Run(ctx context.Context) error {
+	defer m.closeClients()
+
+	// Only run the enabled scenarios.
+	tests, err := m.cfg.Scenarios.filter(m.tests)
+	if err != nil {
+		return err
+	}
+
 	// Initialize all tests.
-	for _, t := range m.tests {
-		if err := t.Init(); err != nil {
+	for _, t := range tests {
+		if err := t.test.Init(); err != nil {
 			return err
 		}
 	}
 
 	// Continuously run all tests. Each test is executed in a dedicated goroutine.
 	wg := sync.WaitGroup{}
-	wg.Add(len(m.tests))
+	wg.Add(len(tests))
 
-	for _, test := range m.tests {
-		go func(t Test) {
+	for _, test := range tests {
+		go func(t managedTest) {
 			defer wg.Done()
 
 			// Run it immediately, and then every configured period.
-			t.Run(ctx, time.Now())
+			m.runTest(ctx, t)
 
 			// TODO We may consider to allow to configure the test interval.
 			ticker := time.NewTicker(time.Minute)
@@ -56,7 +159,7 @@ func (m *Manager) Run(ctx context.Context) error {
 			for {
 				select {
 				case <-ticker.C:
-					t.Run(ctx, time.Now())
+					m.runTest(ctx, t)
 				case <-ctx.Done():
 					return
 				}
@@ -67,3 +170,92 @@ func (m *Manager) Run(ctx context.Context) error {
 	wg.Wait()
 	return nil
 }
+
+// numDedicatedClients returns the number of tests added with a dedicated client.
+func (m *Manager) numDedicatedClients() int {
+	count := 0
+	for _, t := range m.tests {
+		if t.client != nil {
+			count++
+		}
+	}
+	return count
+}
+
+// closeClients closes the idle connections of the clients dedicated to tests.
+func (m *Manager) closeClients() {
+	for _, t := range m.tests {
+		if t.client != nil {
+			t.client.CloseIdleConnections()
+		}
+	}
+}
+
+func (m *Manager) runTest(ctx context.Context, t managedTest) {
+	defer m.pushMetrics()
+
+	if t.tenantID != "" {
+		ctx = user.InjectOrgID(ctx, t.tenantID)
+	}
+
+	start := time.Now()
+	err := t.test.Run(ctx, start)
+	m.recordResult(t, start, err)
+	if err != nil {
+		return
+	}
+
+	m.lastSuccessMx.Lock()
+	m.lastSuccess = time.Now()
+	m.lastSuccessMx.Unlock()
+}
+
+func (m *Manager) recordResult(t managedTest, start time.Time, err error) {
+	if len(m.sinks) == 0 {
+		return
+	}
+
+	result := CheckResult{
+		TestName: t.test.Name(),
+		TenantID: t.tenantID,
+		Start:    start,
+		Duration: time.Since(start),
+		Err:      err,
+	}
+
+	for _, sink := range m.sinks {
+		sink.Record(result)
+	}
+}
+
+func (m *Manager) pushMetrics() {
+	if m.pusher == nil {
+		return
+	}
+
+	// Tests run concurrently, so we serialize the pushes.
+	m.pusherMx.Lock()
+	defer m.pusherMx.Unlock()
+
+	if err := m.pusher.Push(); err != nil {
+		level.Warn(m.pusherLogger).Log("msg", "Failed to push metrics to Pushgateway", "err", err)
+	}
+}
+
+// LivenessHandler returns an HTTP handler responding with 200 if a test cycle succeeded within
+// the configured liveness window, or 503 otherwise. The time the manager has been created at
+// is used as last success until the first test cycle succeeds.
+func (m *Manager) LivenessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		m.lastSuccessMx.Lock()
+		elapsed := time.Since(m.lastSuccess)
+		m.lastSuccessMx.Unlock()
+
+		if elapsed > m.cfg.LivenessWindow {
+			http.Error(w, "no successful test cycle since "+elapsed.Round(time.Second).String(), http.StatusServiceUnavailable)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	})
+}
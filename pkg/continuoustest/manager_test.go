@@ -0,0 +1,442 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/grafana/dskit/flagext"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+)
+
+func TestManager_LivenessHandler(t *testing.T) {
+	const livenessWindow = 200 * time.Millisecond
+
+	getStatusCode := func(m *Manager) int {
+		rec := httptest.NewRecorder()
+		m.LivenessHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/live", nil))
+		return rec.Code
+	}
+
+	t.Run("should report live until the window elapses with no successful test cycle", func(t *testing.T) {
+		succeeded := atomic.NewBool(false)
+
+		test := &TestMock{}
+		test.On("Name").Return("test")
+		test.On("Init").Return(nil)
+		test.On("Run", mock.Anything, mock.Anything).Return(nil).Run(func(mock.Arguments) {
+			succeeded.Store(true)
+		}).Once()
+
+		m := NewManager(ManagerConfig{LivenessWindow: livenessWindow})
+		m.AddTest(test)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		t.Cleanup(cancel)
+		go func() { _ = m.Run(ctx) }()
+
+		require.Eventually(t, succeeded.Load, time.Second, 10*time.Millisecond)
+		assert.Equal(t, http.StatusOK, getStatusCode(m))
+
+		// No test cycle is expected to run within the window, because the test interval is longer.
+		time.Sleep(2 * livenessWindow)
+		assert.Equal(t, http.StatusServiceUnavailable, getStatusCode(m))
+	})
+
+	t.Run("should report not live if test cycles keep failing", func(t *testing.T) {
+		failed := atomic.NewBool(false)
+
+		test := &TestMock{}
+		test.On("Init").Return(nil)
+		test.On("Run", mock.Anything, mock.Anything).Return(errors.New("failed")).Run(func(mock.Arguments) {
+			failed.Store(true)
+		})
+
+		m := NewManager(ManagerConfig{LivenessWindow: livenessWindow})
+		m.AddTest(test)
+		m.lastSuccess = time.Now().Add(-2 * livenessWindow)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		t.Cleanup(cancel)
+		go func() { _ = m.Run(ctx) }()
+
+		require.Eventually(t, failed.Load, time.Second, 10*time.Millisecond)
+		assert.Equal(t, http.StatusServiceUnavailable, getStatusCode(m))
+	})
+}
+
+func TestManager_AddTestForTenant(t *testing.T) {
+	var (
+		receivedMx      sync.Mutex
+		receivedTenants = map[string][]string{}
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		body, err := ioutil.ReadAll(request.Body)
+		require.NoError(t, err)
+
+		body, err = snappy.Decode(nil, body)
+		require.NoError(t, err)
+
+		req := prompb.WriteRequest{}
+		require.NoError(t, proto.Unmarshal(body, &req))
+
+		receivedMx.Lock()
+		defer receivedMx.Unlock()
+
+		for _, series := range req.Timeseries {
+			for _, l := range series.Labels {
+				if l.Name == "__name__" {
+					receivedTenants[l.Value] = append(receivedTenants[l.Value], request.Header.Get("X-Scope-OrgID"))
+				}
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := ClientConfig{}
+	flagext.DefaultValues(&cfg)
+	cfg.TenantID = "default"
+	require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+	require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+	c, err := NewClient(cfg, log.NewNopLogger(), nil)
+	require.NoError(t, err)
+
+	m := NewManager(ManagerConfig{LivenessWindow: time.Minute})
+	completed := atomic.NewInt32(0)
+
+	for name, tenantID := range map[string]string{"scenario_1": "tenant-1", "scenario_2": "tenant-2", "scenario_3": ""} {
+		name := name
+
+		test := &TestMock{}
+		test.On("Init").Return(nil)
+		test.On("Run", mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+			_, err := c.WriteSeries(args.Get(0).(context.Context), generateSineWaveSeries(name, time.Now(), 1))
+			assert.NoError(t, err)
+			completed.Inc()
+		}).Once()
+
+		m.AddTestForTenant(test, tenantID)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go func() { _ = m.Run(ctx) }()
+
+	require.Eventually(t, func() bool { return completed.Load() == 3 }, time.Second, 10*time.Millisecond)
+
+	receivedMx.Lock()
+	defer receivedMx.Unlock()
+
+	assert.Equal(t, map[string][]string{
+		"scenario_1": {"tenant-1"},
+		"scenario_2": {"tenant-2"},
+		"scenario_3": {"default"},
+	}, receivedTenants)
+}
+
+func TestManager_AddTestWithClient(t *testing.T) {
+	var (
+		receivedMx       sync.Mutex
+		receivedRequests = map[string]int{}
+		receivedTenants  = map[string]string{}
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		body, err := ioutil.ReadAll(request.Body)
+		require.NoError(t, err)
+
+		body, err = snappy.Decode(nil, body)
+		require.NoError(t, err)
+
+		req := prompb.WriteRequest{}
+		require.NoError(t, proto.Unmarshal(body, &req))
+		require.NotEmpty(t, req.Timeseries)
+
+		receivedMx.Lock()
+		defer receivedMx.Unlock()
+
+		for _, l := range req.Timeseries[0].Labels {
+			if l.Name == "__name__" {
+				receivedRequests[l.Value]++
+				receivedTenants[l.Value] = request.Header.Get("X-Scope-OrgID")
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	reg := prometheus.NewPedanticRegistry()
+	m := NewManager(ManagerConfig{LivenessWindow: time.Minute})
+	completed := atomic.NewInt32(0)
+
+	for name, batchSize := range map[string]int{"small_batches": 1, "large_batches": 2} {
+		name := name
+
+		cfg := ClientConfig{}
+		flagext.DefaultValues(&cfg)
+		cfg.TenantID = "tenant-" + name
+		cfg.WriteBatchSize = batchSize
+		cfg.MetricsPrefix = name + "_"
+		require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+		require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+		require.NoError(t, m.AddTestWithClient(cfg, log.NewNopLogger(), reg, func(client MimirClient) Test {
+			test := &TestMock{}
+			test.On("Init").Return(nil)
+			test.On("Run", mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+				_, err := client.WriteSeries(args.Get(0).(context.Context), generateSineWaveSeries(name, time.Now(), 4))
+				assert.NoError(t, err)
+				completed.Inc()
+			}).Once()
+			return test
+		}))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go func() { _ = m.Run(ctx) }()
+
+	require.Eventually(t, func() bool { return completed.Load() == 2 }, time.Second, 10*time.Millisecond)
+
+	receivedMx.Lock()
+	defer receivedMx.Unlock()
+
+	// Each test writes with its own client, honoring its own batch size and tenant.
+	assert.Equal(t, map[string]int{"small_batches": 4, "large_batches": 2}, receivedRequests)
+	assert.Equal(t, map[string]string{"small_batches": "tenant-small_batches", "large_batches": "tenant-large_batches"}, receivedTenants)
+}
+
+func TestManager_AddTestWithClient_ShouldAllowClientsSharingTheSameRegistry(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {}))
+	t.Cleanup(server.Close)
+
+	reg := prometheus.NewPedanticRegistry()
+	m := NewManager(ManagerConfig{LivenessWindow: time.Minute})
+
+	var clients []MimirClient
+	for i := 0; i < 2; i++ {
+		cfg := ClientConfig{}
+		flagext.DefaultValues(&cfg)
+		require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+		require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+		require.NoError(t, m.AddTestWithClient(cfg, log.NewNopLogger(), reg, func(client MimirClient) Test {
+			clients = append(clients, client)
+			return &TestMock{}
+		}))
+	}
+
+	// Write once with the first client, and twice with the second one.
+	for i, client := range clients {
+		for j := 0; j <= i; j++ {
+			_, err := client.WriteSeries(context.Background(), generateSineWaveSeries("test", time.Now(), 1))
+			require.NoError(t, err)
+		}
+	}
+
+	// The metrics of each client are tracked separately.
+	families, err := reg.Gather()
+	require.NoError(t, err)
+
+	actual := map[string]uint64{}
+	for _, family := range families {
+		if family.GetName() != "mimir_continuous_test_client_request_duration_seconds" {
+			continue
+		}
+
+		for _, metric := range family.GetMetric() {
+			for _, pair := range metric.GetLabel() {
+				if pair.GetName() == "client" {
+					actual[pair.GetValue()] += metric.GetHistogram().GetSampleCount()
+				}
+			}
+		}
+	}
+
+	assert.Equal(t, map[string]uint64{"0": 1, "1": 2}, actual)
+}
+
+func TestManager_AddTestWithClient_ShouldFailOnInvalidClientConfig(t *testing.T) {
+	m := NewManager(ManagerConfig{LivenessWindow: time.Minute})
+
+	err := m.AddTestWithClient(ClientConfig{}, log.NewNopLogger(), nil, func(MimirClient) Test {
+		require.Fail(t, "the test should not be built")
+		return nil
+	})
+	assert.EqualError(t, err, "failed to create the test client: the write endpoint has not been set")
+	assert.Empty(t, m.tests)
+}
+
+func TestManager_EnablePushgateway(t *testing.T) {
+	var (
+		receivedMx       sync.Mutex
+		receivedRequests []*http.Request
+		receivedBodies   []string
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		body, err := ioutil.ReadAll(request.Body)
+		require.NoError(t, err)
+
+		receivedMx.Lock()
+		receivedRequests = append(receivedRequests, request)
+		receivedBodies = append(receivedBodies, string(body))
+		receivedMx.Unlock()
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := ClientConfig{}
+	flagext.DefaultValues(&cfg)
+	cfg.TenantID = "tenant-1"
+	require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+	require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+	c, err := NewClient(cfg, log.NewNopLogger(), nil)
+	require.NoError(t, err)
+
+	reg := prometheus.NewPedanticRegistry()
+	promauto.With(reg).NewCounter(prometheus.CounterOpts{Name: "test_cycles_total", Help: "Test."}).Inc()
+
+	m := NewManager(ManagerConfig{
+		LivenessWindow: time.Minute,
+		Pushgateway:    PushgatewayConfig{URL: server.URL, Job: "continuous-test"},
+	})
+	m.EnablePushgateway(reg, c.RoundTripper(), log.NewNopLogger())
+
+	ran := atomic.NewBool(false)
+	test := &TestMock{}
+	test.On("Init").Return(nil)
+	test.On("Run", mock.Anything, mock.Anything).Return(errors.New("failed")).Run(func(mock.Arguments) {
+		ran.Store(true)
+	}).Once()
+	m.AddTest(test)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go func() { _ = m.Run(ctx) }()
+
+	// Metrics should be pushed even if the test cycle failed.
+	require.Eventually(t, func() bool {
+		receivedMx.Lock()
+		defer receivedMx.Unlock()
+		return len(receivedRequests) > 0
+	}, time.Second, 10*time.Millisecond)
+	assert.True(t, ran.Load())
+
+	receivedMx.Lock()
+	defer receivedMx.Unlock()
+
+	hostname, err := os.Hostname()
+	require.NoError(t, err)
+
+	require.Len(t, receivedRequests, 1)
+	assert.Equal(t, http.MethodPut, receivedRequests[0].Method)
+	assert.Equal(t, "/metrics/job/continuous-test/instance/"+hostname, receivedRequests[0].URL.Path)
+	assert.Equal(t, "tenant-1", receivedRequests[0].Header.Get("X-Scope-OrgID"))
+	assert.Contains(t, receivedBodies[0], "test_cycles_total")
+}
+
+func TestManager_EnablePushgateway_ShouldBeNoopIfNotConfigured(t *testing.T) {
+	m := NewManager(ManagerConfig{LivenessWindow: time.Minute})
+	m.EnablePushgateway(prometheus.NewPedanticRegistry(), http.DefaultTransport, log.NewNopLogger())
+	assert.Nil(t, m.pusher)
+}
+
+func TestManager_AddResultSinks(t *testing.T) {
+	m := NewManager(ManagerConfig{LivenessWindow: time.Minute})
+
+	completed := atomic.NewInt32(0)
+	for name, err := range map[string]error{"succeeding": nil, "failing": errors.New("failed")} {
+		test := &TestMock{}
+		test.On("Name").Return(name)
+		test.On("Init").Return(nil)
+		test.On("Run", mock.Anything, mock.Anything).Return(err).Run(func(mock.Arguments) {
+			completed.Inc()
+		}).Once()
+
+		m.AddTestForTenant(test, "tenant-"+name)
+	}
+
+	first, second := &recordingResultSink{}, &recordingResultSink{}
+	m.AddResultSinks(first, second)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go func() { _ = m.Run(ctx) }()
+
+	// Each sink should receive the result of each test cycle.
+	for _, sink := range []*recordingResultSink{first, second} {
+		require.Eventually(t, func() bool { return len(sink.getResults()) == 2 }, time.Second, 10*time.Millisecond)
+
+		results := map[string]CheckResult{}
+		for _, result := range sink.getResults() {
+			results[result.TestName] = result
+		}
+
+		require.Contains(t, results, "succeeding")
+		assert.True(t, results["succeeding"].Success())
+		assert.Equal(t, "tenant-succeeding", results["succeeding"].TenantID)
+		assert.False(t, results["succeeding"].Start.IsZero())
+
+		require.Contains(t, results, "failing")
+		assert.False(t, results["failing"].Success())
+		assert.EqualError(t, results["failing"].Err, "failed")
+		assert.Equal(t, "tenant-failing", results["failing"].TenantID)
+	}
+}
+
+// recordingResultSink is a ResultSink keeping all received results in memory.
+type recordingResultSink struct {
+	mx      sync.Mutex
+	results []CheckResult
+}
+
+func (s *recordingResultSink) Record(result CheckResult) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.results = append(s.results, result)
+}
+
+func (s *recordingResultSink) getResults() []CheckResult {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return append([]CheckResult{}, s.results...)
+}
+
+// TestMock mocks Test.
+type TestMock struct {
+	mock.Mock
+}
+
+func (m *TestMock) Name() string {
+	args := m.Called()
+	return args.String(0)
+}
+
+func (m *TestMock) Init() error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+func (m *TestMock) Run(ctx context.Context, now time.Time) error {
+	args := m.Called(ctx, now)
+	return args.Error(0)
+}
@@ -0,0 +1,79 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+)
+
+// metricFamilyNames returns the sorted names of the metrics matching the input regex in the given time range.
+// The names are enumerated through the label values API on the metric name label.
+func metricFamilyNames(ctx context.Context, client MimirClient, nameRegex string, start, end time.Time) ([]string, error) {
+	// Like PromQL, the regex is fully anchored.
+	re, err := regexp.Compile("^(?:" + nameRegex + ")$")
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid metric name regex %q", nameRegex)
+	}
+
+	values, _, err := client.LabelValues(ctx, model.MetricNameLabel, []string{fmt.Sprintf("{%s=~%q}", model.MetricNameLabel, nameRegex)}, start, end, 0)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to enumerate metric names")
+	}
+
+	// Filter the names on the client side too, in case the label values API ignored the selector.
+	names := make([]string, 0, len(values))
+	for _, value := range values {
+		if re.MatchString(string(value)) {
+			names = append(names, string(value))
+		}
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// queryRangeMetricFamily runs a range query for each metric whose name matches the input regex, and returns
+// the results by metric name. The query for each metric is built by the input function, given the metric name.
+// Returns ErrNoData if no metric matches the regex.
+func queryRangeMetricFamily(ctx context.Context, client MimirClient, nameRegex string, buildQuery func(metricName string) string, start, end time.Time, step time.Duration) (map[string]model.Matrix, error) {
+	names, err := metricFamilyNames(ctx, client, nameRegex, start, end)
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		return nil, ErrNoData
+	}
+
+	results := make(map[string]model.Matrix, len(names))
+	for _, name := range names {
+		matrix, err := client.QueryRange(ctx, buildQuery(name), start, end, step)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to query metric %s", name)
+		}
+		results[name] = matrix
+	}
+
+	return results, nil
+}
+
+// mergeMetricFamilyResults returns the series of all the input results, sorted by metric name.
+func mergeMetricFamilyResults(results map[string]model.Matrix) model.Matrix {
+	names := make([]string, 0, len(results))
+	for name := range results {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var out model.Matrix
+	for _, name := range names {
+		out = append(out, results[name]...)
+	}
+	return out
+}
@@ -0,0 +1,87 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryRangeMetricFamily(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		end   = time.Unix(1000, 0)
+		start = end.Add(-time.Hour)
+		step  = time.Minute
+	)
+
+	buildQuery := func(metricName string) string {
+		return "sum(" + metricName + ")"
+	}
+
+	matrixFor := func(metricName string, value model.SampleValue) model.Matrix {
+		return model.Matrix{{
+			Metric: model.Metric{model.MetricNameLabel: model.LabelValue(metricName)},
+			Values: []model.SamplePair{{Timestamp: model.TimeFromUnix(end.Unix()), Value: value}},
+		}}
+	}
+
+	t.Run("should run a query for each metric name matching the regex", func(t *testing.T) {
+		client := &ClientMock{}
+		client.On("LabelValues", mock.Anything, "__name__", []string{`{__name__=~"test_.*_total"}`}, start, end, 0).
+			Return(model.LabelValues{"test_writes_total", "test_reads_total", "other_total", "test_reads_total_bucket"}, false, nil)
+		client.On("QueryRange", mock.Anything, "sum(test_reads_total)", start, end, step).Return(matrixFor("test_reads_total", 1), nil)
+		client.On("QueryRange", mock.Anything, "sum(test_writes_total)", start, end, step).Return(matrixFor("test_writes_total", 2), nil)
+
+		results, err := queryRangeMetricFamily(ctx, client, "test_.*_total", buildQuery, start, end, step)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]model.Matrix{
+			"test_reads_total":  matrixFor("test_reads_total", 1),
+			"test_writes_total": matrixFor("test_writes_total", 2),
+		}, results)
+
+		// Metrics not matching the regex, if returned by the label values API, should not be queried.
+		client.AssertNumberOfCalls(t, "QueryRange", 2)
+
+		assert.Equal(t, append(matrixFor("test_reads_total", 1), matrixFor("test_writes_total", 2)...), mergeMetricFamilyResults(results))
+	})
+
+	t.Run("should return ErrNoData if no metric name matches the regex", func(t *testing.T) {
+		client := &ClientMock{}
+		client.On("LabelValues", mock.Anything, "__name__", mock.Anything, start, end, 0).Return(model.LabelValues{"other_total"}, false, nil)
+
+		_, err := queryRangeMetricFamily(ctx, client, "test_.*", buildQuery, start, end, step)
+		assert.ErrorIs(t, err, ErrNoData)
+		client.AssertNotCalled(t, "QueryRange", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("should fail if a query fails", func(t *testing.T) {
+		client := &ClientMock{}
+		client.On("LabelValues", mock.Anything, "__name__", mock.Anything, start, end, 0).Return(model.LabelValues{"test_a", "test_b"}, false, nil)
+		client.On("QueryRange", mock.Anything, "sum(test_a)", start, end, step).Return(matrixFor("test_a", 1), nil)
+		client.On("QueryRange", mock.Anything, "sum(test_b)", start, end, step).Return(model.Matrix(nil), errors.New("failed"))
+
+		_, err := queryRangeMetricFamily(ctx, client, "test_.*", buildQuery, start, end, step)
+		assert.EqualError(t, err, "failed to query metric test_b: failed")
+	})
+
+	t.Run("should fail if the label values request fails", func(t *testing.T) {
+		client := &ClientMock{}
+		client.On("LabelValues", mock.Anything, "__name__", mock.Anything, start, end, 0).Return(model.LabelValues(nil), false, errors.New("failed"))
+
+		_, err := queryRangeMetricFamily(ctx, client, "test_.*", buildQuery, start, end, step)
+		assert.EqualError(t, err, "failed to enumerate metric names: failed")
+	})
+
+	t.Run("should fail on invalid regex", func(t *testing.T) {
+		_, err := queryRangeMetricFamily(ctx, &ClientMock{}, "test_(", buildQuery, start, end, step)
+		assert.Error(t, err)
+	})
+}
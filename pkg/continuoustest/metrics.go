@@ -52,3 +52,68 @@ func NewTestMetrics(testName string, reg prometheus.Registerer) *TestMetrics {
 		}),
 	}
 }
+
+// clientMetrics holds the metrics tracked by the client.
+type clientMetrics struct {
+	requestDuration    *prometheus.HistogramVec
+	writeCircuitState  prometheus.Gauge
+	successRatio       *prometheus.GaugeVec
+	rejectedBatches    *prometheus.CounterVec
+	streamWrites       prometheus.Counter
+	streamWritesFailed prometheus.Counter
+	inflightRequests   *prometheus.GaugeVec
+	waitingRequests    prometheus.Gauge
+	readServedBy       *prometheus.CounterVec
+	writeQuotaLeft     prometheus.Gauge
+	writeWarnings      prometheus.Counter
+}
+
+func newClientMetrics(reg prometheus.Registerer) *clientMetrics {
+	return &clientMetrics{
+		requestDuration: promauto.With(reg).NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "mimir_continuous_test_client_request_duration_seconds",
+			Help:    "Time spent executing requests to Mimir.",
+			Buckets: prometheus.ExponentialBuckets(0.001, 4, 8),
+		}, []string{"path", "status_code", "tenant"}),
+		writeCircuitState: promauto.With(reg).NewGauge(prometheus.GaugeOpts{
+			Name: "mimir_continuous_test_client_write_circuit_state",
+			Help: "State of the write circuit breaker (0: closed, 1: open, 2: half-open).",
+		}),
+		successRatio: promauto.With(reg).NewGaugeVec(prometheus.GaugeOpts{
+			Name: "mimir_continuous_test_client_success_ratio",
+			Help: "Ratio of successful requests over the most recent requests, by operation.",
+		}, []string{"operation"}),
+		rejectedBatches: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "mimir_continuous_test_client_rejected_batches_total",
+			Help: "Total number of batches of series rejected by the client-side validation before being sent, by reason.",
+		}, []string{"reason"}),
+		streamWrites: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "mimir_continuous_test_client_stream_writes_total",
+			Help: "Total number of writes attempted by stream writers.",
+		}),
+		streamWritesFailed: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "mimir_continuous_test_client_stream_writes_failed_total",
+			Help: "Total number of failed writes attempted by stream writers.",
+		}),
+		inflightRequests: promauto.With(reg).NewGaugeVec(prometheus.GaugeOpts{
+			Name: "mimir_continuous_test_client_inflight_requests",
+			Help: "Current number of in-flight requests to Mimir, by operation.",
+		}, []string{"operation"}),
+		waitingRequests: promauto.With(reg).NewGauge(prometheus.GaugeOpts{
+			Name: "mimir_continuous_test_client_waiting_requests",
+			Help: "Current number of write requests waiting to be sent because the max number of in-flight write requests has been reached.",
+		}),
+		readServedBy: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "mimir_continuous_test_client_read_requests_served_total",
+			Help: "Total number of read requests served by each read endpoint, when failing over across multiple read endpoints.",
+		}, []string{"endpoint"}),
+		writeQuotaLeft: promauto.With(reg).NewGauge(prometheus.GaugeOpts{
+			Name: "mimir_continuous_test_client_write_quota_remaining",
+			Help: "Remaining write quota, as reported by the rate limit headers of the most recent write response returning them.",
+		}),
+		writeWarnings: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "mimir_continuous_test_client_write_warnings_total",
+			Help: "Total number of warnings returned via the Warning header by successful write requests.",
+		}),
+	}
+}
@@ -0,0 +1,52 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+)
+
+// assertNoDataPollInterval is how frequently AssertNoData runs the query.
+const assertNoDataPollInterval = 250 * time.Millisecond
+
+// ErrDataStillPresent is returned by AssertNoData when the query still returns data after the deadline.
+var ErrDataStillPresent = errors.New("the query still returns data")
+
+// AssertNoData polls the input range query until it returns an empty matrix, for example to confirm deleted
+// series are no longer queryable. It fails with ErrDataStillPresent if the query keeps returning data until
+// the deadline, or with the query error if the last query run before the deadline failed.
+func (c *Client) AssertNoData(ctx context.Context, query string, r v1.Range, deadline time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, deadline)
+	defer cancel()
+
+	var lastErr error
+
+	for {
+		matrix, err := c.QueryRange(ctx, query, r.Start, r.End, r.Step)
+		if err == nil && len(matrix) == 0 {
+			return nil
+		}
+
+		// Do not override the outcome of the last query with the error caused by the deadline expiring.
+		if ctx.Err() == nil {
+			if err != nil {
+				lastErr = errors.Wrapf(err, "failed to run query %s", query)
+			} else {
+				lastErr = errors.Wrapf(ErrDataStillPresent, "query %s returned %d series", query, len(matrix))
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			if lastErr == nil {
+				lastErr = ctx.Err()
+			}
+			return errors.Wrapf(lastErr, "data not gone within %s", deadline)
+		case <-time.After(assertNoDataPollInterval):
+		}
+	}
+}
@@ -0,0 +1,101 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/flagext"
+	"github.com/pkg/errors"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+)
+
+func TestClient_AssertNoData(t *testing.T) {
+	const (
+		emptyResponse    = `{"status":"success","data":{"resultType":"matrix","result":[]}}`
+		nonEmptyResponse = `{"status":"success","data":{"resultType":"matrix","result":[{"metric":{"__name__":"test"},"values":[[1000,"1"]]}]}}`
+	)
+
+	tests := map[string]struct {
+		handler        func(writer http.ResponseWriter, attempt int32)
+		expectedErr    error
+		expectedErrMsg string
+		minAttempts    int32
+		maxAttempts    int32
+	}{
+		"should succeed once data disappears after one poll": {
+			handler: func(writer http.ResponseWriter, attempt int32) {
+				if attempt == 1 {
+					_, _ = writer.Write([]byte(nonEmptyResponse))
+					return
+				}
+				_, _ = writer.Write([]byte(emptyResponse))
+			},
+			minAttempts: 2,
+			maxAttempts: 2,
+		},
+		"should fail if data persists until the deadline": {
+			handler: func(writer http.ResponseWriter, _ int32) {
+				_, _ = writer.Write([]byte(nonEmptyResponse))
+			},
+			expectedErr: ErrDataStillPresent,
+			minAttempts: 2,
+		},
+		"should fail with the query error if the query keeps failing until the deadline": {
+			handler: func(writer http.ResponseWriter, _ int32) {
+				writer.WriteHeader(http.StatusBadRequest)
+				_, _ = writer.Write([]byte(`{"status":"error","errorType":"bad_data","error":"invalid query"}`))
+			},
+			expectedErrMsg: "invalid query",
+			minAttempts:    2,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			attempts := atomic.NewInt32(0)
+
+			server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+				writer.Header().Set("Content-Type", "application/json")
+				testData.handler(writer, attempts.Inc())
+			}))
+			t.Cleanup(server.Close)
+
+			cfg := ClientConfig{}
+			flagext.DefaultValues(&cfg)
+			require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+			require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+			c, err := NewClient(cfg, log.NewNopLogger(), nil)
+			require.NoError(t, err)
+
+			now := time.Now()
+			err = c.AssertNoData(context.Background(), "test", v1.Range{Start: now.Add(-time.Minute), End: now, Step: time.Minute}, 3*assertNoDataPollInterval)
+
+			switch {
+			case testData.expectedErr != nil:
+				require.Error(t, err)
+				assert.True(t, errors.Is(err, testData.expectedErr))
+			case testData.expectedErrMsg != "":
+				require.Error(t, err)
+				assert.False(t, errors.Is(err, ErrDataStillPresent))
+				assert.Contains(t, err.Error(), testData.expectedErrMsg)
+			default:
+				require.NoError(t, err)
+			}
+
+			assert.GreaterOrEqual(t, attempts.Load(), testData.minAttempts)
+			if testData.maxAttempts > 0 {
+				assert.LessOrEqual(t, attempts.Load(), testData.maxAttempts)
+			}
+		})
+	}
+}
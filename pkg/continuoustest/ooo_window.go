@@ -0,0 +1,71 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// ErrOutOfOrderWindowViolation is returned by CheckOutOfOrderWindow when the out-of-order window boundary
+// is not enforced as expected.
+var ErrOutOfOrderWindowViolation = errors.New("the out-of-order window boundary is not enforced as expected")
+
+// outOfOrderRejectReasons are the reasons samples older than the out-of-order window are rejected for.
+var outOfOrderRejectReasons = []string{"sample-out-of-order", "sample-out-of-bounds"}
+
+// CheckOutOfOrderWindow checks the out-of-order window configured in Mimir for the tenant is enforced at
+// its boundary. It writes a sample at the current time for the input metric, then an out-of-order sample
+// the input margin inside the window, which is expected to be accepted, and one the input margin outside
+// the window, which is expected to be rejected with a 4xx error reporting the sample as out-of-order or
+// out-of-bounds. Fails with ErrOutOfOrderWindowViolation if any of the two outcomes is not the expected one.
+func (c *Client) CheckOutOfOrderWindow(ctx context.Context, metricName string, window, margin time.Duration) error {
+	if margin <= 0 || margin >= window {
+		return errors.New("the margin must be greater than 0 and less than the out-of-order window")
+	}
+
+	now := time.Now().Truncate(time.Millisecond)
+	write := func(ts time.Time) error {
+		// The sample value is the timestamp, so that each sample is distinguishable.
+		_, err := c.WriteSeries(ctx, []prompb.TimeSeries{{
+			Labels:  []prompb.Label{{Name: "__name__", Value: metricName}},
+			Samples: []prompb.Sample{{Value: float64(ts.UnixMilli()), Timestamp: ts.UnixMilli()}},
+		}})
+		return err
+	}
+
+	if err := write(now); err != nil {
+		return errors.Wrap(err, "failed to write the in-order sample")
+	}
+
+	insideTs := now.Add(-window + margin)
+	if err := write(insideTs); err != nil {
+		return errors.Wrapf(ErrOutOfOrderWindowViolation, "the sample at timestamp %d, %s inside the window, has been rejected: %s", insideTs.UnixMilli(), margin, err.Error())
+	}
+
+	outsideTs := now.Add(-window - margin)
+	err := write(outsideTs)
+	if err == nil {
+		return errors.Wrapf(ErrOutOfOrderWindowViolation, "the sample at timestamp %d, %s outside the window, has been accepted", outsideTs.UnixMilli(), margin)
+	}
+
+	var partialErr *PartialWriteError
+	if !errors.As(err, &partialErr) || !hasAnyReason(partialErr, outOfOrderRejectReasons) {
+		return errors.Wrapf(ErrOutOfOrderWindowViolation, "the sample at timestamp %d, %s outside the window, has been rejected with an unexpected error: %s", outsideTs.UnixMilli(), margin, err.Error())
+	}
+
+	return nil
+}
+
+// hasAnyReason returns whether the input error reports samples rejected for any of the input reasons.
+func hasAnyReason(err *PartialWriteError, reasons []string) bool {
+	for _, reason := range reasons {
+		if err.Reasons[reason] > 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,127 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/grafana/dskit/flagext"
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_CheckOutOfOrderWindow(t *testing.T) {
+	const (
+		window = 10 * time.Minute
+		margin = time.Minute
+	)
+
+	tests := map[string]struct {
+		serverWindow   time.Duration
+		rejectMessage  string
+		expectedErr    error
+		expectedErrMsg string
+	}{
+		"should succeed if the window is enforced at the expected boundary": {
+			serverWindow:  window,
+			rejectMessage: "out of order sample",
+		},
+		"should succeed if samples outside the window are rejected as out of bounds": {
+			serverWindow:  window,
+			rejectMessage: "out of bounds",
+		},
+		"should fail if the sample inside the window is rejected": {
+			serverWindow:   window - 2*margin,
+			rejectMessage:  "out of order sample",
+			expectedErr:    ErrOutOfOrderWindowViolation,
+			expectedErrMsg: "inside the window, has been rejected",
+		},
+		"should fail if the sample outside the window is accepted": {
+			serverWindow:   window + 2*margin,
+			rejectMessage:  "out of order sample",
+			expectedErr:    ErrOutOfOrderWindowViolation,
+			expectedErrMsg: "outside the window, has been accepted",
+		},
+		"should fail if the sample outside the window is rejected for another reason": {
+			serverWindow:   window,
+			rejectMessage:  "per-user series limit",
+			expectedErr:    ErrOutOfOrderWindowViolation,
+			expectedErrMsg: "rejected with an unexpected error",
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			var (
+				mx     sync.Mutex
+				headTs int64
+			)
+
+			// The server accepts out-of-order samples within its window from the most recent sample.
+			server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+				body, err := ioutil.ReadAll(request.Body)
+				require.NoError(t, err)
+
+				body, err = snappy.Decode(nil, body)
+				require.NoError(t, err)
+
+				req := prompb.WriteRequest{}
+				require.NoError(t, proto.Unmarshal(body, &req))
+				require.Len(t, req.Timeseries, 1)
+				require.Len(t, req.Timeseries[0].Samples, 1)
+
+				mx.Lock()
+				defer mx.Unlock()
+
+				ts := req.Timeseries[0].Samples[0].Timestamp
+				if ts < headTs-testData.serverWindow.Milliseconds() {
+					writer.WriteHeader(http.StatusBadRequest)
+					_, _ = writer.Write([]byte(testData.rejectMessage))
+					return
+				}
+				if ts > headTs {
+					headTs = ts
+				}
+			}))
+			t.Cleanup(server.Close)
+
+			cfg := ClientConfig{}
+			flagext.DefaultValues(&cfg)
+			require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+			require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+			c, err := NewClient(cfg, log.NewNopLogger(), nil)
+			require.NoError(t, err)
+
+			err = c.CheckOutOfOrderWindow(context.Background(), "test", window, margin)
+			if testData.expectedErr == nil {
+				require.NoError(t, err)
+				return
+			}
+
+			require.Error(t, err)
+			assert.True(t, errors.Is(err, testData.expectedErr))
+			assert.Contains(t, err.Error(), testData.expectedErrMsg)
+		})
+	}
+}
+
+func TestClient_CheckOutOfOrderWindow_ShouldFailOnInvalidMargin(t *testing.T) {
+	c := &Client{}
+
+	for _, margin := range []time.Duration{0, time.Minute} {
+		err := c.CheckOutOfOrderWindow(context.Background(), "test", time.Minute, margin)
+		require.EqualError(t, err, "the margin must be greater than 0 and less than the out-of-order window")
+	}
+}
@@ -0,0 +1,111 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+const (
+	// maxPartialWriteErrBodyLen is the max length of the response body parsed to extract the rejection reason.
+	maxPartialWriteErrBodyLen = 64 * 1024
+
+	reasonUnknown = "unknown"
+)
+
+// partialWriteReasons maps the error messages returned by Mimir when rejecting samples to the reason
+// the samples have been rejected for. The reasons match the ones tracked by Mimir discarded samples metric.
+var partialWriteReasons = []struct {
+	message string
+	reason  string
+}{
+	{message: "out of order sample", reason: "sample-out-of-order"},
+	{message: "out of bounds", reason: "sample-out-of-bounds"},
+	{message: "duplicate sample for timestamp", reason: "new-value-for-timestamp"},
+	{message: "per-user series limit", reason: "per_user_series_limit"},
+	{message: "per-metric series limit", reason: "per_metric_series_limit"},
+	{message: "timestamp too new", reason: "too_far_in_future"},
+	{message: "series has too many labels", reason: "max_label_names_per_series"},
+	{message: "label value too long", reason: "label_value_too_long"},
+	{message: "sample invalid label", reason: "label_invalid"},
+	{message: "duplicate label name", reason: "duplicate_label_names"},
+	{message: "labels not sorted", reason: "labels_not_sorted"},
+	{message: "sample missing metric name", reason: "missing_metric_name"},
+	{message: "sample invalid metric name", reason: "metric_name_invalid"},
+}
+
+// PartialWriteError is returned when Mimir rejects a write request with a 4xx error because of invalid
+// samples. Mimir ingests the valid samples of the request anyway, so the request may have been partially
+// ingested: the error tracks a lower bound of the number of rejected samples, by reason, out of the samples
+// in the request.
+type PartialWriteError struct {
+	StatusCode int
+
+	// Total is the number of samples in the write request.
+	Total int
+
+	// Rejected is a lower bound of the number of rejected samples. Mimir responds with a single error
+	// message, reporting only the first rejected sample of the request, so it's always 1 and the actual
+	// number of rejected samples may be higher.
+	Rejected int
+
+	// Reasons is the number of samples reported as rejected, by reason. It only tracks the reason of
+	// the rejected sample reported by the error message.
+	Reasons map[string]int
+
+	// Message is the (truncated) response body.
+	Message string
+}
+
+func (e *PartialWriteError) Error() string {
+	reasons := make([]string, 0, len(e.Reasons))
+	for reason, count := range e.Reasons {
+		reasons = append(reasons, fmt.Sprintf("%s=%d", reason, count))
+	}
+	sort.Strings(reasons)
+
+	return fmt.Sprintf("server returned HTTP status %d %s and rejected at least %d out of %d samples (reasons: %s) with body %q (truncated to %d bytes)",
+		e.StatusCode, http.StatusText(e.StatusCode), e.Rejected, e.Total, strings.Join(reasons, ", "), e.Message, maxErrMsgLen)
+}
+
+// IsPartial returns whether some samples of the write request may have been ingested.
+func (e *PartialWriteError) IsPartial() bool {
+	return e.Rejected < e.Total
+}
+
+// isPartialWriteStatusCode returns whether a write request failed with the input status code may have
+// been partially ingested. Rate limited requests are rejected as a whole.
+func isPartialWriteStatusCode(statusCode int) bool {
+	return statusCode/100 == 4 && statusCode != http.StatusTooManyRequests
+}
+
+// parsePartialWriteError parses the response body of a write request, containing total samples, rejected
+// with the input status code. The body is expected to be the single error message returned by Mimir, so
+// one rejected sample is counted for each response, as a lower bound of the actual rejected samples.
+func parsePartialWriteError(statusCode int, body string, total int) *PartialWriteError {
+	out := &PartialWriteError{
+		StatusCode: statusCode,
+		Total:      total,
+		Rejected:   1,
+		Reasons:    map[string]int{getPartialWriteReason(body): 1},
+		Message:    body,
+	}
+
+	if len(out.Message) > maxErrMsgLen {
+		out.Message = out.Message[:maxErrMsgLen]
+	}
+
+	return out
+}
+
+func getPartialWriteReason(message string) string {
+	for _, r := range partialWriteReasons {
+		if strings.Contains(message, r.message) {
+			return r.reason
+		}
+	}
+	return reasonUnknown
+}
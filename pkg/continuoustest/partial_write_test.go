@@ -0,0 +1,137 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/flagext"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePartialWriteError(t *testing.T) {
+	tests := map[string]struct {
+		statusCode  int
+		body        string
+		total       int
+		expected    *PartialWriteError
+		expectedAll bool
+	}{
+		"single rejected sample": {
+			statusCode: http.StatusBadRequest,
+			body:       `user=anonymous: err: out of order sample. timestamp=2022-01-01T00:00:00Z, series={__name__="test"}` + "\n",
+			total:      10,
+			expected: &PartialWriteError{
+				StatusCode: http.StatusBadRequest,
+				Total:      10,
+				Rejected:   1,
+				Reasons:    map[string]int{"sample-out-of-order": 1},
+				Message:    `user=anonymous: err: out of order sample. timestamp=2022-01-01T00:00:00Z, series={__name__="test"}` + "\n",
+			},
+		},
+		"single error message spanning multiple lines": {
+			statusCode: http.StatusBadRequest,
+			body: strings.Join([]string{
+				`user=anonymous: err: out of bounds. timestamp=2022-01-01T00:00:00Z, series={__name__="test"}`,
+				`(the sample has been rejected because its timestamp is too old)`,
+			}, "\n"),
+			total: 4,
+			expected: &PartialWriteError{
+				StatusCode: http.StatusBadRequest,
+				Total:      4,
+				Rejected:   1,
+				Reasons:    map[string]int{"sample-out-of-bounds": 1},
+			},
+		},
+		"unknown error message": {
+			statusCode: http.StatusBadRequest,
+			body:       "something unexpected",
+			total:      1,
+			expected: &PartialWriteError{
+				StatusCode: http.StatusBadRequest,
+				Total:      1,
+				Rejected:   1,
+				Reasons:    map[string]int{reasonUnknown: 1},
+			},
+			expectedAll: true,
+		},
+		"empty body": {
+			statusCode: http.StatusBadRequest,
+			body:       "",
+			total:      1,
+			expected: &PartialWriteError{
+				StatusCode: http.StatusBadRequest,
+				Total:      1,
+				Rejected:   1,
+				Reasons:    map[string]int{reasonUnknown: 1},
+			},
+			expectedAll: true,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			actual := parsePartialWriteError(testData.statusCode, testData.body, testData.total)
+
+			// Only compare the message if expected.
+			if testData.expected.Message == "" {
+				testData.expected.Message = actual.Message
+			}
+
+			assert.Equal(t, testData.expected, actual)
+			assert.Equal(t, !testData.expectedAll, actual.IsPartial())
+		})
+	}
+}
+
+func TestClient_WriteSeries_ShouldReturnPartialWriteError(t *testing.T) {
+	statusCode := http.StatusBadRequest
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		http.Error(writer, `user=anonymous: err: duplicate sample for timestamp. timestamp=2022-01-01T00:00:00Z, series={__name__="test"}`, statusCode)
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := ClientConfig{}
+	flagext.DefaultValues(&cfg)
+	require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+	require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+	c, err := NewClient(cfg, log.NewNopLogger(), nil)
+	require.NoError(t, err)
+
+	series := generateSineWaveSeries("test", time.Now(), 5)
+
+	t.Run("should return a partial write error on 4xx", func(t *testing.T) {
+		statusCode = http.StatusBadRequest
+
+		actualStatusCode, err := c.WriteSeries(context.Background(), series)
+		assert.Equal(t, http.StatusBadRequest, actualStatusCode)
+
+		var partialErr *PartialWriteError
+		require.True(t, errors.As(err, &partialErr))
+		assert.Equal(t, 5, partialErr.Total)
+		assert.Equal(t, 1, partialErr.Rejected)
+		assert.Equal(t, map[string]int{"new-value-for-timestamp": 1}, partialErr.Reasons)
+		assert.True(t, partialErr.IsPartial())
+	})
+
+	t.Run("should not return a partial write error on 429", func(t *testing.T) {
+		statusCode = http.StatusTooManyRequests
+
+		actualStatusCode, err := c.WriteSeries(context.Background(), series)
+		assert.Equal(t, http.StatusTooManyRequests, actualStatusCode)
+		require.Error(t, err)
+
+		var partialErr *PartialWriteError
+		assert.False(t, errors.As(err, &partialErr))
+	})
+}
@@ -0,0 +1,74 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// redactedSecret replaces the secrets in the printed config, like Mimir does in the /config endpoint.
+const redactedSecret = "********"
+
+// PrintClientConfig writes the input config to w as YAML, with the secrets redacted: the JWT, the values
+// of the header templates (which may contain credentials) and the passwords of the endpoints.
+func PrintClientConfig(w io.Writer, cfg ClientConfig) error {
+	out, err := yaml.Marshal(redactClientConfig(cfg))
+	if err != nil {
+		return errors.Wrap(err, "failed to encode the client config")
+	}
+
+	_, err = w.Write(out)
+	return err
+}
+
+// redactClientConfig returns a copy of the input config with the secrets redacted. The passwords of the
+// write and read base endpoints are redacted when they're marshalled.
+func redactClientConfig(cfg ClientConfig) ClientConfig {
+	if cfg.JWT != "" {
+		cfg.JWT = redactedSecret
+	}
+
+	if len(cfg.HeaderTemplates) > 0 {
+		headerTemplates := make([]string, 0, len(cfg.HeaderTemplates))
+		for _, template := range cfg.HeaderTemplates {
+			name := strings.SplitN(template, "=", 2)[0]
+			headerTemplates = append(headerTemplates, name+"="+redactedSecret)
+		}
+		cfg.HeaderTemplates = headerTemplates
+	}
+
+	cfg.WriteShardedEndpoints = redactEndpoints(cfg.WriteShardedEndpoints)
+	cfg.ReadFailoverEndpoints = redactEndpoints(cfg.ReadFailoverEndpoints)
+	return cfg
+}
+
+// redactEndpoints returns a copy of the input endpoints with the passwords redacted. Endpoints which
+// can't be parsed are redacted as a whole, because it can't be told whether they contain a password.
+func redactEndpoints(endpoints []string) []string {
+	if endpoints == nil {
+		return nil
+	}
+
+	out := make([]string, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		u, err := url.Parse(endpoint)
+		if err != nil {
+			out = append(out, redactedSecret)
+			continue
+		}
+
+		if u.User != nil {
+			if _, ok := u.User.Password(); ok {
+				u.User = url.UserPassword(u.User.Username(), redactedSecret)
+			}
+		}
+		out = append(out, u.String())
+	}
+
+	return out
+}
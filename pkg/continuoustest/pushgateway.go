@@ -0,0 +1,37 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"flag"
+	"net/http"
+	"os"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+type PushgatewayConfig struct {
+	URL string
+	Job string
+}
+
+func (cfg *PushgatewayConfig) RegisterFlags(f *flag.FlagSet) {
+	f.StringVar(&cfg.URL, "tests.pushgateway-url", "", "If set, the metrics are pushed to the Pushgateway at this URL after each test cycle.")
+	f.StringVar(&cfg.Job, "tests.pushgateway-job", "mimir-continuous-test", "The job label of the metrics pushed to the Pushgateway.")
+}
+
+// newPushgatewayPusher returns a pusher pushing the metrics gathered from the input gatherer to the
+// configured Pushgateway, using the input round tripper. The metrics are grouped by job and hostname,
+// so that multiple replicas of the tool don't override each other metrics.
+func newPushgatewayPusher(cfg PushgatewayConfig, gatherer prometheus.Gatherer, rt http.RoundTripper) *push.Pusher {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+
+	return push.New(cfg.URL, cfg.Job).
+		Gatherer(gatherer).
+		Grouping("instance", hostname).
+		Client(&http.Client{Transport: rt})
+}
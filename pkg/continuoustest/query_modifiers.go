@@ -0,0 +1,121 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// rampLookbackDelta is the PromQL lookback delta used to compute the value expected at a given time.
+const rampLookbackDelta = 5 * time.Minute
+
+// ramp describes a series whose samples are written at a fixed interval, starting from start, and whose
+// value is the sample timestamp in seconds, so that the value expected at any time is easy to compute.
+type ramp struct {
+	name       string
+	start      time.Time
+	interval   time.Duration
+	numSamples int
+}
+
+// series returns the series to write.
+func (r ramp) series() []prompb.TimeSeries {
+	samples := make([]prompb.Sample, 0, r.numSamples)
+	for i := 0; i < r.numSamples; i++ {
+		ts := r.start.Add(time.Duration(i) * r.interval)
+		samples = append(samples, prompb.Sample{Value: float64(ts.Unix()), Timestamp: ts.UnixMilli()})
+	}
+
+	return []prompb.TimeSeries{{
+		Labels:  []prompb.Label{{Name: "__name__", Value: r.name}},
+		Samples: samples,
+	}}
+}
+
+// valueAt returns the value expected to be returned by an instant query of the ramp at the input time,
+// which is the value of the most recent sample within the lookback delta. Returns false if no sample is
+// expected to be returned.
+func (r ramp) valueAt(t time.Time) (float64, bool) {
+	if r.numSamples <= 0 || t.Before(r.start) {
+		return 0, false
+	}
+
+	i := int(t.Sub(r.start) / r.interval)
+	if i >= r.numSamples {
+		i = r.numSamples - 1
+	}
+
+	ts := r.start.Add(time.Duration(i) * r.interval)
+	if t.Sub(ts) > rampLookbackDelta {
+		return 0, false
+	}
+	return float64(ts.Unix()), true
+}
+
+// atModifierQuery returns the query selecting the input metric at the input time with the @ modifier.
+func atModifierQuery(metric string, at time.Time) string {
+	return fmt.Sprintf("%s @ %s", metric, strconv.FormatFloat(float64(at.UnixMilli())/1000, 'f', -1, 64))
+}
+
+// offsetQuery returns the query selecting the input metric with the offset modifier.
+func offsetQuery(metric string, offset time.Duration) string {
+	return fmt.Sprintf("%s offset %s", metric, model.Duration(offset).String())
+}
+
+// checkAtModifier writes the input ramp and then runs an instant query at the input evaluation time,
+// selecting the ramp at the input time with the @ modifier. The result is expected to be the ramp value
+// at the @ time, regardless of the evaluation time.
+func checkAtModifier(ctx context.Context, client MimirClient, r ramp, at, evalTime time.Time) error {
+	return checkRampQuery(ctx, client, r, atModifierQuery(r.name, at), evalTime, at)
+}
+
+// checkOffset writes the input ramp and then runs an instant query at the input evaluation time, selecting
+// the ramp with the offset modifier. The result is expected to be the ramp value at the evaluation time
+// minus the offset.
+func checkOffset(ctx context.Context, client MimirClient, r ramp, offset time.Duration, evalTime time.Time) error {
+	return checkRampQuery(ctx, client, r, offsetQuery(r.name, offset), evalTime, evalTime.Add(-offset))
+}
+
+func checkRampQuery(ctx context.Context, client MimirClient, r ramp, query string, evalTime, expectedAt time.Time) error {
+	expected, ok := r.valueAt(expectedAt)
+	if !ok {
+		return fmt.Errorf("the ramp has no sample at %s", expectedAt.String())
+	}
+
+	if _, err := client.WriteSeries(ctx, r.series()); err != nil {
+		return errors.Wrap(err, "failed to write the ramp series")
+	}
+
+	result, err := client.Query(ctx, query, evalTime)
+	if err != nil {
+		return errors.Wrapf(err, "failed to run query %s", query)
+	}
+
+	return verifyRampQueryResult(query, result, expected)
+}
+
+// verifyRampQueryResult checks the input instant query result has a single sample with the expected value.
+func verifyRampQueryResult(query string, result model.Value, expected float64) error {
+	vector, ok := result.(model.Vector)
+	if !ok {
+		return fmt.Errorf("was expecting to get a vector from %s but got %s", query, result.Type().String())
+	}
+	if len(vector) == 0 {
+		return errors.Wrapf(ErrNoData, "no data returned by %s", query)
+	}
+	if len(vector) != 1 {
+		return fmt.Errorf("expected 1 sample in the result of %s but got %d", query, len(vector))
+	}
+
+	if actual := float64(vector[0].Value); !compareSampleValues(actual, expected) {
+		return fmt.Errorf("%s at timestamp %d has value %f while was expecting %f", query, vector[0].Timestamp, actual, expected)
+	}
+	return nil
+}
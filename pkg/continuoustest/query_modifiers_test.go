@@ -0,0 +1,150 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRamp(t *testing.T) {
+	r := ramp{name: "test", start: time.Unix(1000, 0), interval: 20 * time.Second, numSamples: 3}
+
+	assert.Equal(t, []prompb.TimeSeries{{
+		Labels: []prompb.Label{{Name: "__name__", Value: "test"}},
+		Samples: []prompb.Sample{
+			{Value: 1000, Timestamp: 1000000},
+			{Value: 1020, Timestamp: 1020000},
+			{Value: 1040, Timestamp: 1040000},
+		},
+	}}, r.series())
+
+	tests := map[string]struct {
+		at            time.Time
+		expectedValue float64
+		expectedOK    bool
+	}{
+		"before the first sample": {
+			at: time.Unix(999, 0),
+		},
+		"at the first sample": {
+			at:            time.Unix(1000, 0),
+			expectedValue: 1000,
+			expectedOK:    true,
+		},
+		"between two samples": {
+			at:            time.Unix(1039, 0),
+			expectedValue: 1020,
+			expectedOK:    true,
+		},
+		"after the last sample, within the lookback delta": {
+			at:            time.Unix(1040, 0).Add(rampLookbackDelta),
+			expectedValue: 1040,
+			expectedOK:    true,
+		},
+		"after the last sample, outside the lookback delta": {
+			at: time.Unix(1041, 0).Add(rampLookbackDelta),
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			value, ok := r.valueAt(testData.at)
+			assert.Equal(t, testData.expectedOK, ok)
+			assert.Equal(t, testData.expectedValue, value)
+		})
+	}
+}
+
+func TestAtModifierQuery(t *testing.T) {
+	assert.Equal(t, "test @ 1000", atModifierQuery("test", time.Unix(1000, 0)))
+	assert.Equal(t, "test @ 1000.5", atModifierQuery("test", time.UnixMilli(1000500)))
+}
+
+func TestOffsetQuery(t *testing.T) {
+	assert.Equal(t, "test offset 5m", offsetQuery("test", 5*time.Minute))
+	assert.Equal(t, "test offset 1h30s", offsetQuery("test", time.Hour+30*time.Second))
+}
+
+func TestCheckAtModifier(t *testing.T) {
+	var (
+		r        = ramp{name: "test", start: time.Unix(1000, 0), interval: 20 * time.Second, numSamples: 10}
+		at       = time.Unix(1050, 0)
+		evalTime = time.Unix(5000, 0)
+	)
+
+	tests := map[string]struct {
+		result      model.Value
+		expectedErr string
+	}{
+		"should succeed if the value at the @ time is returned": {
+			result: model.Vector{{Timestamp: model.TimeFromUnix(evalTime.Unix()), Value: 1040}},
+		},
+		"should fail if the value at the evaluation time is returned": {
+			result:      model.Vector{{Timestamp: model.TimeFromUnix(evalTime.Unix()), Value: 1180}},
+			expectedErr: "test @ 1050 at timestamp 5000000 has value 1180.000000 while was expecting 1040.000000",
+		},
+		"should fail if no data is returned": {
+			result:      model.Vector{},
+			expectedErr: "no data returned by test @ 1050: " + ErrNoData.Error(),
+		},
+		"should fail if the result is not a vector": {
+			result:      model.Matrix{},
+			expectedErr: "was expecting to get a vector from test @ 1050 but got matrix",
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			client := &ClientMock{}
+			client.On("WriteSeries", mock.Anything, r.series()).Return(200, nil)
+			client.On("Query", mock.Anything, "test @ 1050", evalTime).Return(testData.result, nil)
+
+			err := checkAtModifier(context.Background(), client, r, at, evalTime)
+			if testData.expectedErr == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, testData.expectedErr)
+			}
+
+			client.AssertExpectations(t)
+		})
+	}
+}
+
+func TestCheckOffset(t *testing.T) {
+	var (
+		r        = ramp{name: "test", start: time.Unix(1000, 0), interval: 20 * time.Second, numSamples: 10}
+		evalTime = time.Unix(1170, 0)
+	)
+
+	t.Run("should succeed if the value at the evaluation time minus the offset is returned", func(t *testing.T) {
+		client := &ClientMock{}
+		client.On("WriteSeries", mock.Anything, r.series()).Return(200, nil)
+		client.On("Query", mock.Anything, "test offset 1m", evalTime).Return(model.Vector{{Timestamp: model.TimeFromUnix(evalTime.Unix()), Value: 1100}}, nil)
+
+		require.NoError(t, checkOffset(context.Background(), client, r, time.Minute, evalTime))
+	})
+
+	t.Run("should fail if the offset is ignored", func(t *testing.T) {
+		client := &ClientMock{}
+		client.On("WriteSeries", mock.Anything, r.series()).Return(200, nil)
+		client.On("Query", mock.Anything, "test offset 1m", evalTime).Return(model.Vector{{Timestamp: model.TimeFromUnix(evalTime.Unix()), Value: 1160}}, nil)
+
+		require.Error(t, checkOffset(context.Background(), client, r, time.Minute, evalTime))
+	})
+
+	t.Run("should fail without writing if the ramp has no sample at the expected time", func(t *testing.T) {
+		client := &ClientMock{}
+
+		require.Error(t, checkOffset(context.Background(), client, r, time.Hour, evalTime))
+		client.AssertNotCalled(t, "WriteSeries", mock.Anything, mock.Anything)
+	})
+}
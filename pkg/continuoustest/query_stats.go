@@ -0,0 +1,116 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/pkg/errors"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// QueryStats holds the statistics returned by the Prometheus API when a query is run with stats=all.
+type QueryStats struct {
+	Samples QuerySamplesStats `json:"samples"`
+}
+
+// QuerySamplesStats holds the number of samples processed by a query.
+type QuerySamplesStats struct {
+	// TotalQueryableSamples is the total number of samples scanned while evaluating the query.
+	TotalQueryableSamples int64 `json:"totalQueryableSamples"`
+
+	// PeakSamples is the max number of samples held in memory at once while evaluating the query.
+	PeakSamples int64 `json:"peakSamples"`
+}
+
+// QueryRangeWithStats performs a range query requesting the query statistics, and returns them along
+// with the query result. Returns an error if the server doesn't return the statistics.
+func (c *Client) QueryRangeWithStats(ctx context.Context, query string, r v1.Range) (model.Matrix, QueryStats, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.getRequestTimeout(ctx, c.cfg.ReadTimeout))
+	defer cancel()
+
+	params := url.Values{}
+	params.Set("query", query)
+	params.Set("start", formatQueryTime(r.Start))
+	params.Set("end", formatQueryTime(r.End))
+	params.Set("step", strconv.FormatFloat(r.Step.Seconds(), 'f', -1, 64))
+	params.Set("stats", "all")
+
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", c.cfg.ReadBaseEndpoint.String()+"/api/v1/query_range?"+params.Encode(), nil)
+	if err != nil {
+		return nil, QueryStats{}, err
+	}
+
+	httpResp, err := c.readRawClient.Do(httpReq)
+	if err != nil {
+		return nil, QueryStats{}, err
+	}
+	defer httpResp.Body.Close()
+
+	if httpResp.StatusCode/100 != 2 {
+		truncatedBody, err := io.ReadAll(io.LimitReader(httpResp.Body, maxErrMsgLen))
+		if err != nil {
+			return nil, QueryStats{}, errors.Wrapf(err, "server returned HTTP status %s and client failed to read response body", httpResp.Status)
+		}
+
+		return nil, QueryStats{}, fmt.Errorf("server returned HTTP status %s and body %q (truncated to %d bytes)", httpResp.Status, string(truncatedBody), maxErrMsgLen)
+	}
+
+	resp := struct {
+		Status    string `json:"status"`
+		ErrorType string `json:"errorType"`
+		Error     string `json:"error"`
+		Data      struct {
+			ResultType model.ValueType `json:"resultType"`
+			Result     model.Matrix    `json:"result"`
+			Stats      *QueryStats     `json:"stats"`
+		} `json:"data"`
+	}{}
+	if err := json.NewDecoder(httpResp.Body).Decode(&resp); err != nil {
+		return nil, QueryStats{}, errors.Wrap(err, "failed to decode query response")
+	}
+	if resp.Status != "success" {
+		return nil, QueryStats{}, errors.Errorf("query failed with status %q, error type %q and error %q", resp.Status, resp.ErrorType, resp.Error)
+	}
+	if resp.Data.ResultType != model.ValMatrix {
+		return nil, QueryStats{}, fmt.Errorf("was expecting to get a %s but got %s", model.ValMatrix, resp.Data.ResultType)
+	}
+	if resp.Data.Stats == nil {
+		return nil, QueryStats{}, errors.New("the query response has no stats")
+	}
+
+	return resp.Data.Result, *resp.Data.Stats, nil
+}
+
+// CheckWriteAmplification writes the input series and then runs the input range query, which is expected to
+// select the written samples, checking that the number of samples scanned by the query is at least the
+// number of written samples and at most maxAmplification times it.
+func (c *Client) CheckWriteAmplification(ctx context.Context, series []prompb.TimeSeries, query string, r v1.Range, maxAmplification float64) error {
+	if _, err := c.WriteSeries(ctx, series); err != nil {
+		return errors.Wrap(err, "failed to write series")
+	}
+
+	_, stats, err := c.QueryRangeWithStats(ctx, query, r)
+	if err != nil {
+		return errors.Wrap(err, "failed to query series")
+	}
+
+	written := countSamples(&prompb.WriteRequest{Timeseries: series})
+	return verifyScannedSamples(stats, int64(written), int64(float64(written)*maxAmplification))
+}
+
+// verifyScannedSamples checks whether the number of samples scanned by a query is within the input range.
+func verifyScannedSamples(stats QueryStats, min, max int64) error {
+	if scanned := stats.Samples.TotalQueryableSamples; scanned < min || scanned > max {
+		return fmt.Errorf("the query scanned %d samples while was expecting between %d and %d samples", scanned, min, max)
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/grafana/dskit/flagext"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_QueryRangeWithStats(t *testing.T) {
+	tests := map[string]struct {
+		response      string
+		expectedStats QueryStats
+		expectedErr   string
+	}{
+		"should return the query stats": {
+			response: `{"status":"success","data":{"resultType":"matrix","result":[{"metric":{"__name__":"test"},"values":[[1000,"1"]]}],` +
+				`"stats":{"timings":{"evalTotalTime":0.001},"samples":{"totalQueryableSamples":20,"peakSamples":4}}}}`,
+			expectedStats: QueryStats{Samples: QuerySamplesStats{TotalQueryableSamples: 20, PeakSamples: 4}},
+		},
+		"should fail if the response has no stats": {
+			response:    `{"status":"success","data":{"resultType":"matrix","result":[]}}`,
+			expectedErr: "the query response has no stats",
+		},
+		"should fail if the query failed": {
+			response:    `{"status":"error","errorType":"bad_data","error":"invalid query"}`,
+			expectedErr: `query failed with status "error", error type "bad_data" and error "invalid query"`,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			var receivedRequest *http.Request
+
+			server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+				receivedRequest = request
+				writer.Header().Set("Content-Type", "application/json")
+				_, _ = writer.Write([]byte(testData.response))
+			}))
+			t.Cleanup(server.Close)
+
+			cfg := ClientConfig{}
+			flagext.DefaultValues(&cfg)
+			require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+			require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+			c, err := NewClient(cfg, log.NewNopLogger(), nil)
+			require.NoError(t, err)
+
+			matrix, stats, err := c.QueryRangeWithStats(context.Background(), "test", v1.Range{Start: time.Unix(1000, 0), End: time.Unix(1000, 0), Step: time.Minute})
+
+			require.NotNil(t, receivedRequest)
+			assert.Equal(t, "/api/v1/query_range", receivedRequest.URL.Path)
+			assert.Equal(t, "all", receivedRequest.URL.Query().Get("stats"))
+
+			if testData.expectedErr != "" {
+				require.EqualError(t, err, testData.expectedErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, testData.expectedStats, stats)
+			require.Len(t, matrix, 1)
+			assert.Equal(t, []model.SamplePair{{Timestamp: 1000000, Value: 1}}, matrix[0].Values)
+		})
+	}
+}
+
+func TestClient_CheckWriteAmplification(t *testing.T) {
+	const numSeries = 10
+
+	tests := map[string]struct {
+		// Number of samples scanned per written sample, as reported by the mocked query stats.
+		scannedPerWritten int
+		maxAmplification  float64
+		expectedErr       string
+	}{
+		"should succeed if the query scanned exactly the written samples": {
+			scannedPerWritten: 1,
+			maxAmplification:  1,
+		},
+		"should succeed if the scanned samples are within the max amplification": {
+			scannedPerWritten: 2,
+			maxAmplification:  3,
+		},
+		"should fail if the scanned samples exceed the max amplification": {
+			scannedPerWritten: 4,
+			maxAmplification:  3,
+			expectedErr:       "the query scanned 40 samples while was expecting between 10 and 30 samples",
+		},
+		"should fail if the query scanned less samples than written": {
+			scannedPerWritten: 0,
+			maxAmplification:  3,
+			expectedErr:       "the query scanned 0 samples while was expecting between 10 and 30 samples",
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			writtenSamples := 0
+
+			server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+				if request.URL.Path == "/api/v1/push" {
+					body, err := ioutil.ReadAll(request.Body)
+					require.NoError(t, err)
+					body, err = snappy.Decode(nil, body)
+					require.NoError(t, err)
+
+					var req prompb.WriteRequest
+					require.NoError(t, proto.Unmarshal(body, &req))
+					writtenSamples += countSamples(&req)
+					return
+				}
+
+				writer.Header().Set("Content-Type", "application/json")
+				_, _ = writer.Write([]byte(fmt.Sprintf(`{"status":"success","data":{"resultType":"matrix","result":[],"stats":{"samples":{"totalQueryableSamples":%d}}}}`,
+					writtenSamples*testData.scannedPerWritten)))
+			}))
+			t.Cleanup(server.Close)
+
+			cfg := ClientConfig{}
+			flagext.DefaultValues(&cfg)
+			require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+			require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+			c, err := NewClient(cfg, log.NewNopLogger(), nil)
+			require.NoError(t, err)
+
+			now := time.Now()
+			err = c.CheckWriteAmplification(context.Background(), generateSineWaveSeries("test", now, numSeries), "test", v1.Range{Start: now, End: now, Step: time.Minute}, testData.maxAmplification)
+			assert.Equal(t, numSeries, writtenSamples)
+
+			if testData.expectedErr != "" {
+				require.EqualError(t, err, testData.expectedErr)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
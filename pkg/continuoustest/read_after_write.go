@@ -0,0 +1,81 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// ErrStaleCache is returned by CheckReadAfterWrite when the query results cache serves a result
+// not including a sample which is returned when bypassing the cache.
+var ErrStaleCache = errors.New("the query results cache served a stale result")
+
+// CheckReadAfterWrite writes a fresh sample for the input metric and then repeatedly queries the exact
+// time range of the sample, the given number of times at the given interval, both through the query
+// results cache and bypassing it. The check fails with ErrStaleCache as soon as the cached result misses
+// the sample while the non-cached one includes it, or with an error if the sample never shows up.
+func (c *Client) CheckReadAfterWrite(ctx context.Context, metricName string, attempts int, interval time.Duration) error {
+	// The sample value is the timestamp, so that each run writes a value distinguishable from previous ones.
+	ts := time.Now().Truncate(time.Second)
+	value := float64(ts.Unix())
+
+	series := []prompb.TimeSeries{{
+		Labels:  []prompb.Label{{Name: "__name__", Value: metricName}},
+		Samples: []prompb.Sample{{Value: value, Timestamp: ts.UnixMilli()}},
+	}}
+	if _, err := c.WriteSeries(ctx, series); err != nil {
+		return errors.Wrap(err, "failed to write the read-after-write sample")
+	}
+
+	r := v1.Range{Start: ts, End: ts, Step: time.Second}
+	visible := false
+
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if attempt > 1 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(interval):
+			}
+		}
+
+		cached, uncached, err := c.QueryRangeCacheProbe(ctx, metricName, r)
+		if err != nil {
+			return err
+		}
+
+		cachedHasSample := matrixHasSample(cached, ts, value)
+		uncachedHasSample := matrixHasSample(uncached, ts, value)
+
+		if uncachedHasSample && !cachedHasSample {
+			return errors.Wrapf(ErrStaleCache, "sample at timestamp %d with value %f missing from the cached result at attempt %d", ts.UnixMilli(), value, attempt)
+		}
+
+		visible = visible || cachedHasSample || uncachedHasSample
+	}
+
+	if !visible {
+		return fmt.Errorf("sample at timestamp %d with value %f not returned by any of the %d queries", ts.UnixMilli(), value, attempts)
+	}
+
+	return nil
+}
+
+// matrixHasSample returns whether any series of the input matrix has a sample with the input timestamp and value.
+func matrixHasSample(matrix model.Matrix, ts time.Time, value float64) bool {
+	for _, stream := range matrix {
+		for _, sample := range stream.Values {
+			if sample.Timestamp.Time().Equal(ts) && compareSampleValues(float64(sample.Value), value) {
+				return true
+			}
+		}
+	}
+	return false
+}
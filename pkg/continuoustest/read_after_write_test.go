@@ -0,0 +1,110 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/grafana/dskit/flagext"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_CheckReadAfterWrite(t *testing.T) {
+	tests := map[string]struct {
+		// Number of queries bypassing the cache before the written sample shows up.
+		ingestionDelay int
+		staleCache     bool
+		expectedErr    error
+		expectedErrMsg string
+	}{
+		"should succeed if the written sample is returned by both cached and non-cached queries": {},
+		"should succeed if the written sample shows up after some attempts": {
+			ingestionDelay: 2,
+		},
+		"should fail if the cached result misses the written sample": {
+			staleCache:  true,
+			expectedErr: ErrStaleCache,
+		},
+		"should fail if the written sample never shows up": {
+			ingestionDelay: 10,
+			expectedErrMsg: "not returned by any of the 3 queries",
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			var (
+				mx              sync.Mutex
+				written         *prompb.Sample
+				uncachedQueries int
+			)
+
+			server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+				mx.Lock()
+				defer mx.Unlock()
+
+				if request.URL.Path == "/api/v1/push" {
+					compressed, err := ioutil.ReadAll(request.Body)
+					require.NoError(t, err)
+					uncompressed, err := snappy.Decode(nil, compressed)
+					require.NoError(t, err)
+					req := prompb.WriteRequest{}
+					require.NoError(t, proto.Unmarshal(uncompressed, &req))
+					written = &req.Timeseries[0].Samples[0]
+					return
+				}
+
+				// The sample shows up after the ingestion delay. A stale cache keeps serving an empty result.
+				visible := uncachedQueries >= testData.ingestionDelay
+				if request.Header.Get("Cache-Control") == "no-store" {
+					uncachedQueries++
+				} else if testData.staleCache {
+					visible = false
+				}
+
+				result := ""
+				if visible && written != nil {
+					result = fmt.Sprintf(`{"metric":{"__name__":"test"},"values":[[%d,"%v"]]}`, written.Timestamp/1000, written.Value)
+				}
+
+				writer.Header().Set("Content-Type", "application/json")
+				_, _ = writer.Write([]byte(`{"status":"success","data":{"resultType":"matrix","result":[` + result + `]}}`))
+			}))
+			t.Cleanup(server.Close)
+
+			cfg := ClientConfig{}
+			flagext.DefaultValues(&cfg)
+			require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+			require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+			c, err := NewClient(cfg, log.NewNopLogger(), nil)
+			require.NoError(t, err)
+
+			err = c.CheckReadAfterWrite(context.Background(), "test", 3, time.Millisecond)
+
+			switch {
+			case testData.expectedErr != nil:
+				require.Error(t, err)
+				assert.True(t, errors.Is(err, testData.expectedErr))
+			case testData.expectedErrMsg != "":
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), testData.expectedErrMsg)
+			default:
+				require.NoError(t, err)
+			}
+		})
+	}
+}
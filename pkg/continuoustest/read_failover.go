@@ -0,0 +1,97 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// readFailoverRoundTripper sends read requests to the primary read endpoint and, if the request fails with
+// a network or 5xx error, fails over to the next endpoint, in order. Requests failed because of the query
+// itself (4xx) are not failed over, because they're expected to fail on any endpoint.
+type readFailoverRoundTripper struct {
+	rt        http.RoundTripper
+	endpoints []string
+	served    *prometheus.CounterVec
+	logger    log.Logger
+}
+
+// newReadFailoverRoundTripper returns a round tripper failing over from the primary endpoint, which
+// read requests are built for, to the secondary ones.
+func newReadFailoverRoundTripper(rt http.RoundTripper, primary string, secondaries []string, served *prometheus.CounterVec, logger log.Logger) *readFailoverRoundTripper {
+	endpoints := make([]string, 0, len(secondaries)+1)
+	for _, endpoint := range append([]string{primary}, secondaries...) {
+		endpoints = append(endpoints, strings.TrimSuffix(endpoint, "/"))
+	}
+
+	return &readFailoverRoundTripper{
+		rt:        rt,
+		endpoints: endpoints,
+		served:    served,
+		logger:    logger,
+	}
+}
+
+func (rt *readFailoverRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Requests not sent to the primary endpoint, or whose body can't be read again, can't be failed over.
+	path, ok := rt.trimPrimaryEndpoint(req.URL.String())
+	if !ok || !canResendRequest(req) {
+		return rt.rt.RoundTrip(req)
+	}
+
+	for attempt := 0; ; attempt++ {
+		endpoint := rt.endpoints[attempt]
+
+		attemptReq, err := cloneRequestForAttempt(req, attempt)
+		if err != nil {
+			return nil, err
+		}
+
+		if attempt > 0 {
+			attemptReq.URL, err = url.Parse(endpoint + path)
+			if err != nil {
+				return nil, err
+			}
+			attemptReq.Host = ""
+		}
+
+		resp, err := rt.rt.RoundTrip(attemptReq)
+		if !isRetryableReadError(req, resp, err) || attempt == len(rt.endpoints)-1 {
+			if err == nil {
+				rt.served.WithLabelValues(endpoint).Inc()
+			}
+			return resp, err
+		}
+
+		if err == nil {
+			level.Warn(rt.logger).Log("msg", "Read request failed, failing over to the next read endpoint", "endpoint", endpoint, "next_endpoint", rt.endpoints[attempt+1], "status_code", resp.StatusCode)
+			drainAndCloseResponse(resp)
+		} else {
+			level.Warn(rt.logger).Log("msg", "Read request failed, failing over to the next read endpoint", "endpoint", endpoint, "next_endpoint", rt.endpoints[attempt+1], "err", err)
+		}
+	}
+}
+
+// trimPrimaryEndpoint returns the input request URL without the primary endpoint, and whether
+// the URL has been built for the primary endpoint.
+func (rt *readFailoverRoundTripper) trimPrimaryEndpoint(reqURL string) (string, bool) {
+	if !strings.HasPrefix(reqURL, rt.endpoints[0]) {
+		return "", false
+	}
+
+	path := strings.TrimPrefix(reqURL, rt.endpoints[0])
+	return path, path == "" || path[0] == '/' || path[0] == '?'
+}
+
+// CloseIdleConnections closes the idle connections of the wrapped round tripper, if supported.
+func (rt *readFailoverRoundTripper) CloseIdleConnections() {
+	if closer, ok := rt.rt.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
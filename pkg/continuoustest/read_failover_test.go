@@ -0,0 +1,182 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/flagext"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+)
+
+func TestClient_ShouldFailOverReadsToTheNextEndpoint(t *testing.T) {
+	const (
+		matrixResponse = `{"status":"success","data":{"resultType":"matrix","result":[{"metric":{"__name__":"test"},"values":[[1000,"1"]]}]}}`
+		vectorResponse = `{"status":"success","data":{"resultType":"vector","result":[{"metric":{"__name__":"test"},"value":[1000,"1"]}]}}`
+	)
+
+	newServer := func(requests *atomic.Int32, statusCode int) *httptest.Server {
+		server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			requests.Inc()
+
+			writer.Header().Set("Content-Type", "application/json")
+			if statusCode != http.StatusOK {
+				writer.WriteHeader(statusCode)
+				_, _ = writer.Write([]byte(`{"status":"error","errorType":"execution","error":"failed"}`))
+				return
+			}
+			if strings.HasSuffix(request.URL.Path, "/api/v1/query") {
+				_, _ = writer.Write([]byte(vectorResponse))
+				return
+			}
+			_, _ = writer.Write([]byte(matrixResponse))
+		}))
+		t.Cleanup(server.Close)
+		return server
+	}
+
+	// The primary endpoint has been closed, so requests to it fail with a connection error.
+	closedServer := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	closedServer.Close()
+
+	tests := map[string]struct {
+		primaryStatusCode   int
+		primaryClosed       bool
+		secondaryStatusCode int
+		expectedErr         bool
+		expectedPrimary     int32
+		expectedSecondary   int32
+		expectedServedBy    string
+	}{
+		"should fail over on 5xx errors": {
+			primaryStatusCode:   http.StatusServiceUnavailable,
+			secondaryStatusCode: http.StatusOK,
+			expectedPrimary:     2,
+			expectedSecondary:   2,
+			expectedServedBy:    "secondary",
+		},
+		"should fail over on connection errors": {
+			primaryClosed:       true,
+			secondaryStatusCode: http.StatusOK,
+			expectedSecondary:   2,
+			expectedServedBy:    "secondary",
+		},
+		"should not fail over on query errors": {
+			primaryStatusCode:   http.StatusUnprocessableEntity,
+			secondaryStatusCode: http.StatusOK,
+			expectedErr:         true,
+			expectedPrimary:     2,
+			expectedServedBy:    "primary",
+		},
+		"should not fail over if the primary succeeds": {
+			primaryStatusCode:   http.StatusOK,
+			secondaryStatusCode: http.StatusOK,
+			expectedPrimary:     2,
+			expectedServedBy:    "primary",
+		},
+		"should fail if all endpoints fail": {
+			primaryStatusCode:   http.StatusInternalServerError,
+			secondaryStatusCode: http.StatusInternalServerError,
+			expectedErr:         true,
+			expectedPrimary:     2,
+			expectedSecondary:   2,
+			expectedServedBy:    "secondary",
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			primaryRequests, secondaryRequests := atomic.NewInt32(0), atomic.NewInt32(0)
+
+			primaryURL := closedServer.URL
+			if !testData.primaryClosed {
+				primaryURL = newServer(primaryRequests, testData.primaryStatusCode).URL
+			}
+			secondaryURL := newServer(secondaryRequests, testData.secondaryStatusCode).URL
+
+			reg := prometheus.NewPedanticRegistry()
+			cfg := ClientConfig{}
+			flagext.DefaultValues(&cfg)
+			require.NoError(t, cfg.WriteBaseEndpoint.Set(primaryURL))
+			require.NoError(t, cfg.ReadBaseEndpoint.Set(primaryURL))
+			require.NoError(t, cfg.ReadFailoverEndpoints.Set(secondaryURL))
+
+			c, err := NewClient(cfg, log.NewNopLogger(), reg)
+			require.NoError(t, err)
+
+			matrix, err := c.QueryRange(context.Background(), "test", time.Unix(1000, 0), time.Unix(2000, 0), 20*time.Second)
+			if testData.expectedErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				require.Len(t, matrix, 1)
+			}
+
+			vector, err := c.QueryVector(context.Background(), "test", time.Unix(1000, 0))
+			if testData.expectedErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				require.Len(t, vector, 1)
+			}
+
+			assert.Equal(t, testData.expectedPrimary, primaryRequests.Load())
+			assert.Equal(t, testData.expectedSecondary, secondaryRequests.Load())
+
+			servedBy := map[string]string{"primary": primaryURL, "secondary": secondaryURL}[testData.expectedServedBy]
+			assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(fmt.Sprintf(`
+				# HELP mimir_continuous_test_client_read_requests_served_total Total number of read requests served by each read endpoint, when failing over across multiple read endpoints.
+				# TYPE mimir_continuous_test_client_read_requests_served_total counter
+				mimir_continuous_test_client_read_requests_served_total{endpoint="%s"} 2
+			`, servedBy)), "mimir_continuous_test_client_read_requests_served_total"))
+		})
+	}
+}
+
+func TestReadFailoverRoundTripper_TrimPrimaryEndpoint(t *testing.T) {
+	rt := newReadFailoverRoundTripper(nil, "http://primary:9090/prometheus/", []string{"http://secondary"}, nil, log.NewNopLogger())
+
+	tests := map[string]struct {
+		url          string
+		expectedPath string
+		expectedOK   bool
+	}{
+		"API path": {
+			url:          "http://primary:9090/prometheus/api/v1/query_range",
+			expectedPath: "/api/v1/query_range",
+			expectedOK:   true,
+		},
+		"query string": {
+			url:          "http://primary:9090/prometheus?query=up",
+			expectedPath: "?query=up",
+			expectedOK:   true,
+		},
+		"other endpoint": {
+			url: "http://other:9090/prometheus/api/v1/query_range",
+		},
+		"other endpoint sharing the prefix": {
+			url: "http://primary:9090/prometheus-other/api/v1/query_range",
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			path, ok := rt.trimPrimaryEndpoint(testData.url)
+			assert.Equal(t, testData.expectedOK, ok)
+			if testData.expectedOK {
+				assert.Equal(t, testData.expectedPath, path)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// checkRecordingRule writes the input series, which a recording rule configured in the ruler is expected
+// to evaluate, waits for the rule evaluation interval and then queries the series recorded by the rule,
+// checking each returned sample is within the input tolerance of the expected value. The recorded query
+// is typically the name of the metric recorded by the rule, optionally with label matchers.
+func checkRecordingRule(ctx context.Context, client MimirClient, series []prompb.TimeSeries, recordedQuery string, expected, tolerance float64, evalInterval time.Duration) error {
+	if _, err := client.WriteSeries(ctx, series); err != nil {
+		return errors.Wrap(err, "failed to write the recording rule input series")
+	}
+
+	// Wait until the rule has been evaluated at least once after the series have been written.
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(evalInterval):
+	}
+
+	result, err := client.Query(ctx, recordedQuery, time.Now())
+	if err != nil {
+		return errors.Wrapf(err, "failed to run query %s", recordedQuery)
+	}
+
+	vector, ok := result.(model.Vector)
+	if !ok {
+		return fmt.Errorf("was expecting to get a vector from %s but got %s", recordedQuery, result.Type().String())
+	}
+	if len(vector) == 0 {
+		return errors.Wrapf(ErrNoData, "no series recorded by the rule returned by %s", recordedQuery)
+	}
+
+	for _, sample := range vector {
+		if actual := float64(sample.Value); math.IsNaN(actual) || math.Abs(actual-expected) > tolerance {
+			return fmt.Errorf("series %s recorded by the rule at timestamp %d has value %f while was expecting %f (tolerance %f)", sample.Metric, sample.Timestamp, actual, expected, tolerance)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,118 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/grafana/dskit/flagext"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckRecordingRule(t *testing.T) {
+	const (
+		evalInterval = 50 * time.Millisecond
+		recordedName = "job:test:sum"
+	)
+
+	// The input series, whose sum is recorded by the rule.
+	ts := time.Now()
+	series := generateSineWaveSeries("test", ts, 3)
+	expected := 3 * generateSineWaveValue(ts)
+
+	// newServer returns a mock server simulating the ruler: once series are written, the rule output
+	// series is returned with the sum of the written values, plus the input offset.
+	newServer := func(t *testing.T, offset float64) *httptest.Server {
+		var (
+			mx      sync.Mutex
+			written bool
+			sum     float64
+		)
+
+		server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			mx.Lock()
+			defer mx.Unlock()
+
+			switch request.URL.Path {
+			case "/api/v1/push":
+				body, err := ioutil.ReadAll(request.Body)
+				require.NoError(t, err)
+				body, err = snappy.Decode(nil, body)
+				require.NoError(t, err)
+
+				req := prompb.WriteRequest{}
+				require.NoError(t, proto.Unmarshal(body, &req))
+
+				for _, s := range req.Timeseries {
+					for _, sample := range s.Samples {
+						sum += sample.Value
+					}
+				}
+				written = true
+
+			case "/api/v1/query":
+				assert.Equal(t, recordedName, request.URL.Query().Get("query"))
+
+				result := "[]"
+				if written {
+					result = fmt.Sprintf(`[{"metric":{"__name__":%q},"value":[%d,"%f"]}]`, recordedName, time.Now().Unix(), sum+offset)
+				}
+
+				writer.Header().Set("Content-Type", "application/json")
+				_, _ = writer.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":` + result + `}}`))
+			}
+		}))
+		t.Cleanup(server.Close)
+
+		return server
+	}
+
+	newClient := func(t *testing.T, server *httptest.Server) *Client {
+		cfg := ClientConfig{}
+		flagext.DefaultValues(&cfg)
+		require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+		require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+		c, err := NewClient(cfg, log.NewNopLogger(), nil)
+		require.NoError(t, err)
+		return c
+	}
+
+	t.Run("should succeed if the recorded series has the expected value", func(t *testing.T) {
+		c := newClient(t, newServer(t, 0))
+
+		start := time.Now()
+		require.NoError(t, checkRecordingRule(context.Background(), c, series, recordedName, expected, 0.001, evalInterval))
+		assert.GreaterOrEqual(t, time.Since(start), evalInterval)
+	})
+
+	t.Run("should fail if the recorded series value is out of tolerance", func(t *testing.T) {
+		c := newClient(t, newServer(t, 1))
+
+		err := checkRecordingRule(context.Background(), c, series, recordedName, expected, 0.001, evalInterval)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "recorded by the rule")
+	})
+
+	t.Run("should fail if the context is canceled while waiting for the rule evaluation", func(t *testing.T) {
+		c := newClient(t, newServer(t, 0))
+
+		ctx, cancel := context.WithTimeout(context.Background(), evalInterval/2)
+		defer cancel()
+
+		err := checkRecordingRule(ctx, c, series, recordedName, expected, 0.001, time.Minute)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
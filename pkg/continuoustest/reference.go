@@ -0,0 +1,42 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+)
+
+// ErrReferenceMismatch is returned by CompareWithReference when the query result returned by Mimir
+// differs from the one returned by the reference data source.
+var ErrReferenceMismatch = errors.New("the query result differs from the reference one")
+
+// RangeQuerier runs range queries. It's implemented by MimirClient, so that the same client can be used
+// to query both Mimir and a reference Prometheus.
+type RangeQuerier interface {
+	QueryRange(ctx context.Context, query string, start, end time.Time, step time.Duration) (model.Matrix, error)
+}
+
+// CompareWithReference runs the same range query against Mimir and an independent reference data source
+// (eg. a Prometheus server scraping the same targets), and returns ErrReferenceMismatch reporting the first
+// divergence if the results differ. Sample values are compared with the input relative tolerance.
+func CompareWithReference(ctx context.Context, mimir, reference RangeQuerier, query string, r v1.Range, tolerance float64) error {
+	expected, err := reference.QueryRange(ctx, query, r.Start, r.End, r.Step)
+	if err != nil {
+		return errors.Wrap(err, "failed to run the query against the reference")
+	}
+
+	actual, err := mimir.QueryRange(ctx, query, r.Start, r.End, r.Step)
+	if err != nil {
+		return errors.Wrap(err, "failed to run the query against Mimir")
+	}
+
+	if err := compareMatrices(expected, actual, tolerance); err != nil {
+		return errors.Wrapf(ErrReferenceMismatch, "%s: %s", query, err.Error())
+	}
+	return nil
+}
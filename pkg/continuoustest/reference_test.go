@@ -0,0 +1,94 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompareWithReference(t *testing.T) {
+	r := v1.Range{Start: time.Unix(1000, 0), End: time.Unix(1060, 0), Step: 30 * time.Second}
+
+	reference := model.Matrix{
+		{Metric: model.Metric{"job": "a"}, Values: []model.SamplePair{{Timestamp: 1000000, Value: 1}, {Timestamp: 1030000, Value: 2}, {Timestamp: 1060000, Value: 3}}},
+		{Metric: model.Metric{"job": "b"}, Values: []model.SamplePair{{Timestamp: 1000000, Value: 10}, {Timestamp: 1030000, Value: 20}, {Timestamp: 1060000, Value: 30}}},
+	}
+
+	tests := map[string]struct {
+		mimir          model.Matrix
+		mimirErr       error
+		referenceErr   error
+		expectedErr    error
+		expectedErrMsg string
+	}{
+		"should succeed if the results match, regardless of the series order": {
+			mimir: model.Matrix{reference[1], reference[0]},
+		},
+		"should succeed if the values are within the tolerance": {
+			mimir: model.Matrix{
+				reference[0],
+				{Metric: model.Metric{"job": "b"}, Values: []model.SamplePair{{Timestamp: 1000000, Value: 10.0000001}, {Timestamp: 1030000, Value: 20}, {Timestamp: 1060000, Value: 30}}},
+			},
+		},
+		"should fail if a sample value diverges": {
+			mimir: model.Matrix{
+				reference[0],
+				{Metric: model.Metric{"job": "b"}, Values: []model.SamplePair{{Timestamp: 1000000, Value: 10}, {Timestamp: 1030000, Value: 21}, {Timestamp: 1060000, Value: 30}}},
+			},
+			expectedErr:    ErrReferenceMismatch,
+			expectedErrMsg: `series {job="b"} at timestamp 1030000 has value 21.000000 while was expecting 20.000000`,
+		},
+		"should fail if a series is missing in Mimir": {
+			mimir:          model.Matrix{reference[0]},
+			expectedErr:    ErrReferenceMismatch,
+			expectedErrMsg: "expected 2 series but got 1",
+		},
+		"should fail if a sample is missing in Mimir": {
+			mimir: model.Matrix{
+				reference[0],
+				{Metric: model.Metric{"job": "b"}, Values: []model.SamplePair{{Timestamp: 1000000, Value: 10}, {Timestamp: 1060000, Value: 30}}},
+			},
+			expectedErr:    ErrReferenceMismatch,
+			expectedErrMsg: `series {job="b"} has 2 samples while was expecting 3`,
+		},
+		"should fail if the query against Mimir fails": {
+			mimirErr:       errors.New("mimir unavailable"),
+			expectedErrMsg: "failed to run the query against Mimir: mimir unavailable",
+		},
+		"should fail if the query against the reference fails": {
+			referenceErr:   errors.New("reference unavailable"),
+			expectedErrMsg: "failed to run the query against the reference: reference unavailable",
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			mimirClient := &ClientMock{}
+			mimirClient.On("QueryRange", mock.Anything, "sum by (job) (rate(test[1m]))", r.Start, r.End, r.Step).Return(testData.mimir, testData.mimirErr)
+
+			referenceClient := &ClientMock{}
+			referenceClient.On("QueryRange", mock.Anything, "sum by (job) (rate(test[1m]))", r.Start, r.End, r.Step).Return(reference, testData.referenceErr)
+
+			err := CompareWithReference(context.Background(), mimirClient, referenceClient, "sum by (job) (rate(test[1m]))", r, 1e-6)
+			if testData.expectedErrMsg == "" {
+				require.NoError(t, err)
+				return
+			}
+
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), testData.expectedErrMsg)
+			if testData.expectedErr != nil {
+				assert.True(t, errors.Is(err, testData.expectedErr))
+			}
+		})
+	}
+}
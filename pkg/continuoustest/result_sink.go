@@ -0,0 +1,96 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// CheckResult is the outcome of a single test cycle.
+type CheckResult struct {
+	// TestName is the name of the test.
+	TestName string
+
+	// TenantID is the tenant the test ran for, or empty if the test ran for the tenant configured in the client.
+	TenantID string
+
+	// Start is the time the test cycle started at.
+	Start time.Time
+
+	// Duration is the time spent running the test cycle.
+	Duration time.Duration
+
+	// Err is the error the test cycle failed with, including any difference between expected and actual
+	// results, or nil if the test cycle succeeded.
+	Err error
+}
+
+// Success returns whether the test cycle succeeded.
+func (r CheckResult) Success() bool {
+	return r.Err == nil
+}
+
+// ResultSink receives the outcome of each test cycle run by the Manager.
+type ResultSink interface {
+	// Record records the result of a test cycle. It's called concurrently by tests running in parallel.
+	Record(result CheckResult)
+}
+
+// MetricsResultSink tracks the results of test cycles as metrics.
+type MetricsResultSink struct {
+	checksTotal   *prometheus.CounterVec
+	checkDuration *prometheus.HistogramVec
+}
+
+// NewMetricsResultSink returns a ResultSink tracking the results of test cycles as metrics registered to the input registerer.
+func NewMetricsResultSink(reg prometheus.Registerer) *MetricsResultSink {
+	return &MetricsResultSink{
+		checksTotal: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "mimir_continuous_test_check_results_total",
+			Help: "Total number of test cycles, by result.",
+		}, []string{"test", "result"}),
+		checkDuration: promauto.With(reg).NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "mimir_continuous_test_check_duration_seconds",
+			Help:    "Time spent running test cycles.",
+			Buckets: prometheus.ExponentialBuckets(0.01, 4, 8),
+		}, []string{"test"}),
+	}
+}
+
+// Record implements ResultSink.
+func (s *MetricsResultSink) Record(result CheckResult) {
+	outcome := "success"
+	if !result.Success() {
+		outcome = "failure"
+	}
+
+	s.checksTotal.WithLabelValues(result.TestName, outcome).Inc()
+	s.checkDuration.WithLabelValues(result.TestName).Observe(result.Duration.Seconds())
+}
+
+// LoggingResultSink logs the results of test cycles.
+type LoggingResultSink struct {
+	logger log.Logger
+}
+
+// NewLoggingResultSink returns a ResultSink logging the results of test cycles with the input logger.
+func NewLoggingResultSink(logger log.Logger) *LoggingResultSink {
+	return &LoggingResultSink{logger: logger}
+}
+
+// Record implements ResultSink.
+func (s *LoggingResultSink) Record(result CheckResult) {
+	keyvals := []interface{}{"test", result.TestName, "tenant", result.TenantID, "duration", result.Duration}
+
+	if result.Success() {
+		level.Info(s.logger).Log(append([]interface{}{"msg", "Test cycle succeeded"}, keyvals...)...)
+		return
+	}
+
+	level.Warn(s.logger).Log(append([]interface{}{"msg", "Test cycle failed"}, append(keyvals, "err", result.Err)...)...)
+}
@@ -0,0 +1,49 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricsResultSink(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+	sink := NewMetricsResultSink(reg)
+
+	sink.Record(CheckResult{TestName: "test-1", Duration: time.Second})
+	sink.Record(CheckResult{TestName: "test-1", Duration: time.Second, Err: errors.New("failed")})
+	sink.Record(CheckResult{TestName: "test-2", Duration: time.Second})
+
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP mimir_continuous_test_check_results_total Total number of test cycles, by result.
+		# TYPE mimir_continuous_test_check_results_total counter
+		mimir_continuous_test_check_results_total{result="failure",test="test-1"} 1
+		mimir_continuous_test_check_results_total{result="success",test="test-1"} 1
+		mimir_continuous_test_check_results_total{result="success",test="test-2"} 1
+	`), "mimir_continuous_test_check_results_total"))
+
+	assert.Equal(t, 2, testutil.CollectAndCount(sink.checkDuration))
+}
+
+func TestLoggingResultSink(t *testing.T) {
+	buf := bytes.Buffer{}
+	sink := NewLoggingResultSink(log.NewLogfmtLogger(&buf))
+
+	sink.Record(CheckResult{TestName: "test-1", TenantID: "tenant-1", Duration: time.Second})
+	sink.Record(CheckResult{TestName: "test-2", Duration: time.Second, Err: errors.New("sample mismatch")})
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Equal(t, []string{
+		`level=info msg="Test cycle succeeded" test=test-1 tenant=tenant-1 duration=1s`,
+		`level=warn msg="Test cycle failed" test=test-2 tenant= duration=1s err="sample mismatch"`,
+	}, lines)
+}
@@ -0,0 +1,207 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+)
+
+const (
+	retryBackoffExponential = "exponential"
+	retryBackoffConstant    = "constant"
+)
+
+// Backoff computes the delay before retrying a failed request. The client may share the same Backoff
+// across concurrent requests, so implementations must be safe for concurrent use.
+type Backoff interface {
+	// NextDelay returns the delay before the input retry attempt, starting from 1.
+	NextDelay(attempt int) time.Duration
+
+	// Reset is called once a request succeeded after retries, so that stateful implementations
+	// can start from scratch on the next failure.
+	Reset()
+}
+
+// RetryBackoffConfig configures the backoff between retries of failed requests.
+type RetryBackoffConfig struct {
+	Strategy string        `yaml:"strategy"`
+	MinDelay time.Duration `yaml:"min_delay"`
+	MaxDelay time.Duration `yaml:"max_delay"`
+}
+
+// RegisterFlagsWithPrefix registers flags with the given prefix.
+func (cfg *RetryBackoffConfig) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
+	f.StringVar(&cfg.Strategy, prefix+".strategy", retryBackoffExponential, fmt.Sprintf("The backoff strategy between retries of failed requests. Supported values: %s (doubles the delay at each retry, from the min to the max delay), %s (always waits the min delay).", retryBackoffExponential, retryBackoffConstant))
+	f.DurationVar(&cfg.MinDelay, prefix+".min-delay", 100*time.Millisecond, "The delay before the first retry.")
+	f.DurationVar(&cfg.MaxDelay, prefix+".max-delay", 5*time.Second, "The max delay between retries.")
+}
+
+func (cfg *RetryBackoffConfig) validate() error {
+	if cfg.Strategy != retryBackoffExponential && cfg.Strategy != retryBackoffConstant {
+		return fmt.Errorf("unsupported retry backoff strategy %q", cfg.Strategy)
+	}
+	if cfg.MinDelay < 0 {
+		return errors.New("the retry backoff min delay must not be negative")
+	}
+	if cfg.MaxDelay < cfg.MinDelay {
+		return errors.New("the retry backoff max delay must be greater than or equal to the min delay")
+	}
+	return nil
+}
+
+func (cfg *RetryBackoffConfig) newBackoff() Backoff {
+	if cfg.Strategy == retryBackoffConstant {
+		return NewConstantBackoff(cfg.MinDelay)
+	}
+	return NewExponentialBackoff(cfg.MinDelay, cfg.MaxDelay)
+}
+
+// ExponentialBackoff doubles the delay at each retry attempt, starting from the min delay and
+// capped to the max delay.
+type ExponentialBackoff struct {
+	minDelay time.Duration
+	maxDelay time.Duration
+}
+
+func NewExponentialBackoff(minDelay, maxDelay time.Duration) *ExponentialBackoff {
+	return &ExponentialBackoff{minDelay: minDelay, maxDelay: maxDelay}
+}
+
+func (b *ExponentialBackoff) NextDelay(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+
+	// Guard against overflows when shifting.
+	if attempt > 62 {
+		return b.maxDelay
+	}
+
+	delay := b.minDelay << (attempt - 1)
+	if delay > b.maxDelay || delay < b.minDelay {
+		return b.maxDelay
+	}
+	return delay
+}
+
+// Reset is a no-op, because the delay only depends on the attempt.
+func (b *ExponentialBackoff) Reset() {}
+
+// ConstantBackoff waits the same delay before each retry attempt.
+type ConstantBackoff struct {
+	delay time.Duration
+}
+
+func NewConstantBackoff(delay time.Duration) *ConstantBackoff {
+	return &ConstantBackoff{delay: delay}
+}
+
+func (b *ConstantBackoff) NextDelay(int) time.Duration {
+	return b.delay
+}
+
+// Reset is a no-op, because the delay is constant.
+func (b *ConstantBackoff) Reset() {}
+
+// readRetryRoundTripper retries read requests failed with a network or 5xx error, up to the configured
+// number of retries, waiting the delay computed by the backoff before each retry.
+type readRetryRoundTripper struct {
+	rt         http.RoundTripper
+	backoff    Backoff
+	maxRetries int
+	logger     log.Logger
+}
+
+func newReadRetryRoundTripper(rt http.RoundTripper, backoff Backoff, maxRetries int, logger log.Logger) *readRetryRoundTripper {
+	return &readRetryRoundTripper{
+		rt:         rt,
+		backoff:    backoff,
+		maxRetries: maxRetries,
+		logger:     logger,
+	}
+}
+
+func (rt *readRetryRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Requests whose body can't be read again can't be retried.
+	if !canResendRequest(req) {
+		return rt.rt.RoundTrip(req)
+	}
+
+	for attempt := 0; ; attempt++ {
+		attemptReq, err := cloneRequestForAttempt(req, attempt)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := rt.rt.RoundTrip(attemptReq)
+		if !isRetryableReadError(req, resp, err) || attempt >= rt.maxRetries {
+			if err == nil && attempt > 0 && resp.StatusCode/100 != 5 {
+				rt.backoff.Reset()
+			}
+			return resp, err
+		}
+
+		delay := rt.backoff.NextDelay(attempt + 1)
+		if !waitWithContext(req.Context(), delay) {
+			return resp, err
+		}
+
+		if err == nil {
+			level.Warn(rt.logger).Log("msg", "Read request failed, retrying", "status_code", resp.StatusCode, "backoff", delay)
+			drainAndCloseResponse(resp)
+		} else {
+			level.Warn(rt.logger).Log("msg", "Read request failed, retrying", "backoff", delay, "err", err)
+		}
+	}
+}
+
+// CloseIdleConnections closes the idle connections of the wrapped round tripper, if supported.
+func (rt *readRetryRoundTripper) CloseIdleConnections() {
+	if closer, ok := rt.rt.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
+
+// canResendRequest returns whether the input request can be sent multiple times, because it has no body
+// or its body can be read again.
+func canResendRequest(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// cloneRequestForAttempt returns a copy of the input request to send at the input attempt, starting from 0.
+// Each attempt is sent with a copy of the request, because the wrapped round tripper may modify it (eg.
+// compressing the body), and the body is read again for attempts after the first one.
+func cloneRequestForAttempt(req *http.Request, attempt int) (*http.Request, error) {
+	attemptReq := req.Clone(req.Context())
+	if attempt > 0 && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read the request body again to resend it")
+		}
+		attemptReq.Body = body
+	}
+	return attemptReq, nil
+}
+
+// isRetryableReadError returns whether the input read request failed with a network or 5xx error, which
+// is expected to be transient. Errors caused by the query itself (4xx) are not retryable.
+func isRetryableReadError(req *http.Request, resp *http.Response, err error) bool {
+	if err != nil {
+		return req.Context().Err() == nil
+	}
+	return resp.StatusCode/100 == 5
+}
+
+// drainAndCloseResponse releases the connection of a response which is not going to be returned.
+func drainAndCloseResponse(resp *http.Response) {
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+}
@@ -0,0 +1,273 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/flagext"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExponentialBackoff_NextDelay(t *testing.T) {
+	b := NewExponentialBackoff(100*time.Millisecond, time.Second)
+
+	tests := map[int]time.Duration{
+		0:    100 * time.Millisecond,
+		1:    100 * time.Millisecond,
+		2:    200 * time.Millisecond,
+		3:    400 * time.Millisecond,
+		4:    800 * time.Millisecond,
+		5:    time.Second,
+		100:  time.Second,
+		1000: time.Second,
+	}
+
+	for attempt, expected := range tests {
+		assert.Equal(t, expected, b.NextDelay(attempt), "attempt: %d", attempt)
+	}
+}
+
+func TestConstantBackoff_NextDelay(t *testing.T) {
+	b := NewConstantBackoff(time.Second)
+
+	for _, attempt := range []int{1, 2, 10} {
+		assert.Equal(t, time.Second, b.NextDelay(attempt))
+	}
+}
+
+func TestRetryBackoffConfig_Validate(t *testing.T) {
+	tests := map[string]struct {
+		cfg         RetryBackoffConfig
+		expectedErr string
+	}{
+		"exponential": {
+			cfg: RetryBackoffConfig{Strategy: retryBackoffExponential, MinDelay: time.Second, MaxDelay: time.Minute},
+		},
+		"constant": {
+			cfg: RetryBackoffConfig{Strategy: retryBackoffConstant, MinDelay: time.Second, MaxDelay: time.Second},
+		},
+		"unsupported strategy": {
+			cfg:         RetryBackoffConfig{Strategy: "linear", MinDelay: time.Second, MaxDelay: time.Minute},
+			expectedErr: `unsupported retry backoff strategy "linear"`,
+		},
+		"negative min delay": {
+			cfg:         RetryBackoffConfig{Strategy: retryBackoffExponential, MinDelay: -time.Second, MaxDelay: time.Minute},
+			expectedErr: "the retry backoff min delay must not be negative",
+		},
+		"max delay lower than min delay": {
+			cfg:         RetryBackoffConfig{Strategy: retryBackoffExponential, MinDelay: time.Minute, MaxDelay: time.Second},
+			expectedErr: "the retry backoff max delay must be greater than or equal to the min delay",
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			err := testData.cfg.validate()
+			if testData.expectedErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, testData.expectedErr)
+			}
+		})
+	}
+}
+
+func TestClient_QueryRange_ShouldRetryWithInjectedBackoff(t *testing.T) {
+	tests := map[string]struct {
+		failures          int
+		failureStatusCode int
+		maxRetries        int
+		expectedAttempts  int
+		expectedBackoff   []int
+		expectedResets    int
+		expectedErr       bool
+	}{
+		"should retry on 5xx errors until success": {
+			failures:          2,
+			failureStatusCode: http.StatusServiceUnavailable,
+			maxRetries:        3,
+			expectedAttempts:  3,
+			expectedBackoff:   []int{1, 2},
+			expectedResets:    1,
+		},
+		"should give up after the max retries": {
+			failures:          5,
+			failureStatusCode: http.StatusInternalServerError,
+			maxRetries:        2,
+			expectedAttempts:  3,
+			expectedBackoff:   []int{1, 2},
+			expectedErr:       true,
+		},
+		"should not retry on 4xx errors": {
+			failures:          1,
+			failureStatusCode: http.StatusBadRequest,
+			maxRetries:        3,
+			expectedAttempts:  1,
+			expectedErr:       true,
+		},
+		"should not retry if retries are disabled": {
+			failures:          1,
+			failureStatusCode: http.StatusInternalServerError,
+			maxRetries:        0,
+			expectedAttempts:  1,
+			expectedErr:       true,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			var (
+				attemptsMx sync.Mutex
+				attempts   int
+				queries    []string
+			)
+
+			server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+				// The body is expected to be compressed on each attempt, exactly once.
+				gz, err := gzip.NewReader(request.Body)
+				require.NoError(t, err)
+				decoded, err := ioutil.ReadAll(gz)
+				require.NoError(t, err)
+				values, err := url.ParseQuery(string(decoded))
+				require.NoError(t, err)
+
+				attemptsMx.Lock()
+				attempts++
+				queries = append(queries, values.Get("query"))
+				failed := attempts <= testData.failures
+				attemptsMx.Unlock()
+
+				writer.Header().Set("Content-Type", "application/json")
+				if failed {
+					writer.WriteHeader(testData.failureStatusCode)
+					_, _ = writer.Write([]byte(`{"status":"error","errorType":"internal","error":"failed"}`))
+					return
+				}
+				_, _ = writer.Write([]byte(`{"status":"success","data":{"resultType":"matrix","result":[]}}`))
+			}))
+			t.Cleanup(server.Close)
+
+			b := &recordingBackoff{}
+
+			cfg := ClientConfig{}
+			flagext.DefaultValues(&cfg)
+			cfg.Backoff = b
+			cfg.ReadMaxRetries = testData.maxRetries
+			cfg.ReadCompressRequest = true
+			require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+			require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+			c, err := NewClient(cfg, log.NewNopLogger(), nil)
+			require.NoError(t, err)
+
+			_, err = c.QueryRange(context.Background(), "sum(test)", time.Unix(1000, 0), time.Unix(2000, 0), 20*time.Second)
+			if testData.expectedErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+
+			attemptsMx.Lock()
+			defer attemptsMx.Unlock()
+
+			assert.Equal(t, testData.expectedAttempts, attempts)
+			for _, query := range queries {
+				assert.Equal(t, "sum(test)", query)
+			}
+
+			backoffAttempts, resets := b.get()
+			assert.Equal(t, testData.expectedBackoff, backoffAttempts)
+			assert.Equal(t, testData.expectedResets, resets)
+		})
+	}
+}
+
+func TestClient_WriteSeries_ShouldQueryInjectedBackoffOnConnectionClosedErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {}))
+	t.Cleanup(server.Close)
+
+	attempts := 0
+	b := &recordingBackoff{}
+
+	cfg := ClientConfig{}
+	flagext.DefaultValues(&cfg)
+	cfg.Backoff = b
+	cfg.HTTPClient = &http.Client{
+		Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			attempts++
+			if attempts == 1 {
+				_, _ = io.Copy(io.Discard, req.Body)
+				return nil, errors.New("http2: server sent GOAWAY and closed the connection")
+			}
+			return http.DefaultTransport.RoundTrip(req)
+		}),
+	}
+	require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+	require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+	c, err := NewClient(cfg, log.NewNopLogger(), nil)
+	require.NoError(t, err)
+
+	_, err = c.WriteSeries(context.Background(), generateSineWaveSeries("test", time.Now(), 1))
+	require.NoError(t, err)
+	assert.Equal(t, 2, attempts)
+
+	backoffAttempts, resets := b.get()
+	assert.Equal(t, []int{1}, backoffAttempts)
+	assert.Equal(t, 1, resets)
+}
+
+func TestNewClient_ShouldValidateRetryBackoffConfig(t *testing.T) {
+	cfg := ClientConfig{}
+	flagext.DefaultValues(&cfg)
+	cfg.RetryBackoff.Strategy = "unknown"
+	require.NoError(t, cfg.WriteBaseEndpoint.Set("http://localhost"))
+	require.NoError(t, cfg.ReadBaseEndpoint.Set("http://localhost"))
+
+	_, err := NewClient(cfg, log.NewNopLogger(), nil)
+	assert.EqualError(t, err, `unsupported retry backoff strategy "unknown"`)
+
+	// The config is ignored if the backoff is injected.
+	cfg.Backoff = NewConstantBackoff(0)
+	_, err = NewClient(cfg, log.NewNopLogger(), nil)
+	assert.NoError(t, err)
+}
+
+// recordingBackoff is a Backoff without delay, recording the attempts it has been queried for.
+type recordingBackoff struct {
+	mx       sync.Mutex
+	attempts []int
+	resets   int
+}
+
+func (b *recordingBackoff) NextDelay(attempt int) time.Duration {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	b.attempts = append(b.attempts, attempt)
+	return 0
+}
+
+func (b *recordingBackoff) Reset() {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	b.resets++
+}
+
+func (b *recordingBackoff) get() ([]int, int) {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	return append([]int(nil), b.attempts...), b.resets
+}
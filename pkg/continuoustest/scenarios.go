@@ -0,0 +1,93 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"flag"
+	"sort"
+	"strings"
+
+	"github.com/grafana/dskit/flagext"
+	"github.com/pkg/errors"
+)
+
+// ErrUnknownScenario is returned when a scenario enabled or disabled via config doesn't match any registered test.
+var ErrUnknownScenario = errors.New("unknown scenario")
+
+// ScenariosConfig selects the scenarios to run among the tests registered to the manager. Each scenario
+// is identified by the test name.
+type ScenariosConfig struct {
+	Enabled  flagext.StringSliceCSV
+	Disabled flagext.StringSliceCSV
+}
+
+func (cfg *ScenariosConfig) RegisterFlags(f *flag.FlagSet) {
+	f.Var(&cfg.Enabled, "tests.enabled-scenarios", "Comma separated list of scenarios to run. If empty, all scenarios are run.")
+	f.Var(&cfg.Disabled, "tests.disabled-scenarios", "Comma separated list of scenarios to not run. Takes precedence over the enabled scenarios.")
+}
+
+// filter returns the input tests whose scenario is enabled. Returns an error if the config references a
+// scenario not registered.
+func (cfg ScenariosConfig) filter(tests []managedTest) ([]managedTest, error) {
+	if len(cfg.Enabled) == 0 && len(cfg.Disabled) == 0 {
+		return tests, nil
+	}
+
+	registered := map[string]struct{}{}
+	for _, t := range tests {
+		registered[t.test.Name()] = struct{}{}
+	}
+
+	enabled, err := scenariosSet(cfg.Enabled, registered)
+	if err != nil {
+		return nil, err
+	}
+	disabled, err := scenariosSet(cfg.Disabled, registered)
+	if err != nil {
+		return nil, err
+	}
+
+	filtered := make([]managedTest, 0, len(tests))
+	for _, t := range tests {
+		name := t.test.Name()
+
+		if _, ok := disabled[name]; ok {
+			continue
+		}
+		if _, ok := enabled[name]; len(enabled) > 0 && !ok {
+			continue
+		}
+
+		filtered = append(filtered, t)
+	}
+
+	return filtered, nil
+}
+
+// scenariosSet returns the set of input scenario names, failing if any of them is not registered.
+func scenariosSet(names []string, registered map[string]struct{}) (map[string]struct{}, error) {
+	out := make(map[string]struct{}, len(names))
+
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		if _, ok := registered[name]; !ok {
+			return nil, errors.Wrapf(ErrUnknownScenario, "%q (registered scenarios: %s)", name, strings.Join(sortedScenarios(registered), ", "))
+		}
+		out[name] = struct{}{}
+	}
+
+	return out, nil
+}
+
+func sortedScenarios(registered map[string]struct{}) []string {
+	names := make([]string, 0, len(registered))
+	for name := range registered {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,116 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/grafana/dskit/flagext"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+)
+
+func TestManager_Run_ShouldOnlyRunEnabledScenarios(t *testing.T) {
+	m := NewManager(ManagerConfig{
+		LivenessWindow: time.Minute,
+		Scenarios: ScenariosConfig{
+			Enabled:  flagext.StringSliceCSV{"scenario_1", "scenario_2"},
+			Disabled: flagext.StringSliceCSV{"scenario_2"},
+		},
+	})
+
+	runs := map[string]*atomic.Int32{}
+	for _, name := range []string{"scenario_1", "scenario_2", "scenario_3"} {
+		counter := atomic.NewInt32(0)
+		runs[name] = counter
+
+		test := &TestMock{}
+		test.On("Name").Return(name)
+		test.On("Init").Return(nil)
+		test.On("Run", mock.Anything, mock.Anything).Return(nil).Run(func(mock.Arguments) {
+			counter.Inc()
+		})
+
+		m.AddTest(test)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go func() { _ = m.Run(ctx) }()
+
+	require.Eventually(t, func() bool { return runs["scenario_1"].Load() == 1 }, time.Second, 10*time.Millisecond)
+
+	// Give some time to the other scenarios to run, if they were wrongly enabled.
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, int32(0), runs["scenario_2"].Load())
+	assert.Equal(t, int32(0), runs["scenario_3"].Load())
+}
+
+func TestManager_Run_ShouldFailOnUnknownScenario(t *testing.T) {
+	for _, cfg := range []ScenariosConfig{
+		{Enabled: flagext.StringSliceCSV{"scenario_1", "unknown"}},
+		{Disabled: flagext.StringSliceCSV{"unknown"}},
+	} {
+		test := &TestMock{}
+		test.On("Name").Return("scenario_1")
+
+		m := NewManager(ManagerConfig{LivenessWindow: time.Minute, Scenarios: cfg})
+		m.AddTest(test)
+
+		err := m.Run(context.Background())
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, ErrUnknownScenario))
+		assert.Contains(t, err.Error(), `"unknown" (registered scenarios: scenario_1)`)
+
+		// The test has not been initialized.
+		test.AssertNotCalled(t, "Init")
+	}
+}
+
+func TestScenariosConfig_filter(t *testing.T) {
+	tests := []managedTest{
+		{test: newNamedTestMock("a")},
+		{test: newNamedTestMock("b"), tenantID: "tenant-1"},
+		{test: newNamedTestMock("b"), tenantID: "tenant-2"},
+		{test: newNamedTestMock("c")},
+	}
+
+	for testName, testData := range map[string]struct {
+		cfg      ScenariosConfig
+		expected []managedTest
+	}{
+		"no scenarios configured": {
+			cfg:      ScenariosConfig{},
+			expected: tests,
+		},
+		"enabled scenarios": {
+			cfg:      ScenariosConfig{Enabled: flagext.StringSliceCSV{"b", "c"}},
+			expected: tests[1:],
+		},
+		"disabled scenarios": {
+			cfg:      ScenariosConfig{Disabled: flagext.StringSliceCSV{"b"}},
+			expected: []managedTest{tests[0], tests[3]},
+		},
+		"disabled scenarios take precedence over enabled ones": {
+			cfg:      ScenariosConfig{Enabled: flagext.StringSliceCSV{"a", "b"}, Disabled: flagext.StringSliceCSV{"a"}},
+			expected: tests[1:3],
+		},
+	} {
+		t.Run(testName, func(t *testing.T) {
+			actual, err := testData.cfg.filter(tests)
+			require.NoError(t, err)
+			assert.Equal(t, testData.expected, actual)
+		})
+	}
+}
+
+func newNamedTestMock(name string) *TestMock {
+	test := &TestMock{}
+	test.On("Name").Return(name)
+	return test
+}
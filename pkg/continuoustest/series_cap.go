@@ -0,0 +1,177 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// TenantLimits holds the subset of the tenant limits relevant to the tests.
+type TenantLimits struct {
+	MaxGlobalSeriesPerUser int `json:"max_global_series_per_user"`
+}
+
+// TenantLimits returns the limits of the tenant of the request, as exposed by the Mimir tenant limits endpoint.
+// The endpoint is not served under the Prometheus API prefix, so it's resolved against the root of the read endpoint.
+func (c *Client) TenantLimits(ctx context.Context) (TenantLimits, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.getRequestTimeout(ctx, c.cfg.ReadTimeout))
+	defer cancel()
+
+	params := url.Values{}
+	params.Set("tenant", c.getTenantID(ctx))
+
+	endpoint := *c.cfg.ReadBaseEndpoint.URL
+	endpoint.Path = "/api/v1/tenant_limits"
+	endpoint.RawPath = ""
+	endpoint.RawQuery = params.Encode()
+
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", endpoint.String(), nil)
+	if err != nil {
+		return TenantLimits{}, err
+	}
+
+	httpResp, err := c.readRawClient.Do(httpReq)
+	if err != nil {
+		return TenantLimits{}, err
+	}
+	defer httpResp.Body.Close()
+
+	if httpResp.StatusCode/100 != 2 {
+		truncatedBody, err := io.ReadAll(io.LimitReader(httpResp.Body, maxErrMsgLen))
+		if err != nil {
+			return TenantLimits{}, errors.Wrapf(err, "server returned HTTP status %s and client failed to read response body", httpResp.Status)
+		}
+
+		return TenantLimits{}, fmt.Errorf("server returned HTTP status %s and body %q (truncated to %d bytes)", httpResp.Status, string(truncatedBody), maxErrMsgLen)
+	}
+
+	limits := TenantLimits{}
+	if err := json.NewDecoder(httpResp.Body).Decode(&limits); err != nil {
+		return TenantLimits{}, errors.Wrap(err, "failed to decode tenant limits response")
+	}
+
+	return limits, nil
+}
+
+// seriesCapper caps the number of distinct series written for each tenant, so that tests don't exceed
+// the tenant series limit, which would just cause writes to fail. Series already written for a tenant
+// are always allowed, while new series are dropped once the cap has been reached.
+type seriesCapper struct {
+	logger        log.Logger
+	droppedSeries *prometheus.CounterVec
+
+	mx     sync.Mutex
+	seen   map[string]map[string]struct{}
+	limits map[string]int
+}
+
+func newSeriesCapper(logger log.Logger, reg prometheus.Registerer) *seriesCapper {
+	return &seriesCapper{
+		logger: logger,
+		droppedSeries: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "mimir_continuous_test_capped_series_dropped_total",
+			Help: "Total number of series not written because the max number of series for the tenant has been reached.",
+		}, []string{"tenant"}),
+		seen:   map[string]map[string]struct{}{},
+		limits: map[string]int{},
+	}
+}
+
+// getMaxSeries returns the max number of series for the input tenant. If the configured max series is
+// negative, the limit is read from the tenant limits via getLimits, and cached for the next calls.
+func (c *seriesCapper) getMaxSeries(ctx context.Context, tenantID string, configured int, getLimits func(ctx context.Context) (TenantLimits, error)) (int, error) {
+	if configured >= 0 {
+		return configured, nil
+	}
+
+	c.mx.Lock()
+	maxSeries, ok := c.limits[tenantID]
+	c.mx.Unlock()
+
+	if ok {
+		return maxSeries, nil
+	}
+
+	limits, err := getLimits(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	c.mx.Lock()
+	c.limits[tenantID] = limits.MaxGlobalSeriesPerUser
+	c.mx.Unlock()
+
+	return limits.MaxGlobalSeriesPerUser, nil
+}
+
+// apply returns the input series, without the series which would exceed maxSeries distinct series written
+// for the input tenant, along with the index of each returned series in the input ones. The cap is disabled
+// if maxSeries is 0.
+func (c *seriesCapper) apply(tenantID string, maxSeries int, series []prompb.TimeSeries) ([]prompb.TimeSeries, []int) {
+	if maxSeries <= 0 {
+		indexes := make([]int, len(series))
+		for i := range series {
+			indexes[i] = i
+		}
+		return series, indexes
+	}
+
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	seen, ok := c.seen[tenantID]
+	if !ok {
+		seen = map[string]struct{}{}
+		c.seen[tenantID] = seen
+	}
+
+	out := make([]prompb.TimeSeries, 0, len(series))
+	indexes := make([]int, 0, len(series))
+	dropped := 0
+
+	for i, s := range series {
+		key := seriesKey(s)
+		if _, ok := seen[key]; !ok {
+			if len(seen) >= maxSeries {
+				dropped++
+				continue
+			}
+			seen[key] = struct{}{}
+		}
+
+		out = append(out, s)
+		indexes = append(indexes, i)
+	}
+
+	if dropped > 0 {
+		c.droppedSeries.WithLabelValues(tenantID).Add(float64(dropped))
+		level.Warn(c.logger).Log("msg", "Not writing some series because the max number of series for the tenant has been reached", "tenant", tenantID, "max_series", maxSeries, "dropped", dropped)
+	}
+
+	return out, indexes
+}
+
+// seriesKey returns a key identifying the input series, regardless of the labels order.
+func seriesKey(series prompb.TimeSeries) string {
+	lbls := make(labels.Labels, 0, len(series.Labels))
+	for _, l := range series.Labels {
+		lbls = append(lbls, labels.Label{Name: l.Name, Value: l.Value})
+	}
+	sort.Sort(lbls)
+	return lbls.String()
+}
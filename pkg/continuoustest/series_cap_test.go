@@ -0,0 +1,233 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/grafana/dskit/flagext"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+)
+
+func TestSeriesCapper(t *testing.T) {
+	now := time.Now()
+
+	t.Run("should cap the number of distinct series per tenant", func(t *testing.T) {
+		capper := newSeriesCapper(log.NewNopLogger(), prometheus.NewPedanticRegistry())
+
+		// The first 3 series are allowed, the others are dropped.
+		actual, _ := capper.apply("tenant-1", 3, generateSineWaveSeries("test", now, 5))
+		assert.Equal(t, generateSineWaveSeries("test", now, 3), actual)
+
+		// The series already written are allowed at the next timestamp, regardless of the labels order.
+		next := generateSineWaveSeries("test", now.Add(time.Minute), 5)
+		next[0].Labels = []prompb.Label{next[0].Labels[1], next[0].Labels[0]}
+		next[1].Labels = []prompb.Label{{Name: "__name__", Value: "new_series"}}
+		actual, indexes := capper.apply("tenant-1", 3, next)
+		assert.Equal(t, []prompb.TimeSeries{next[0], next[2]}, actual)
+		assert.Equal(t, []int{0, 2}, indexes)
+
+		// The cap is tracked per tenant.
+		actual, _ = capper.apply("tenant-2", 2, generateSineWaveSeries("test", now, 5))
+		assert.Equal(t, generateSineWaveSeries("test", now, 2), actual)
+
+		assert.Equal(t, 5.0, testutil.ToFloat64(capper.droppedSeries.WithLabelValues("tenant-1")))
+		assert.Equal(t, 3.0, testutil.ToFloat64(capper.droppedSeries.WithLabelValues("tenant-2")))
+	})
+
+	t.Run("should not cap series if disabled", func(t *testing.T) {
+		capper := newSeriesCapper(log.NewNopLogger(), prometheus.NewPedanticRegistry())
+
+		series := generateSineWaveSeries("test", now, 5)
+		actual, indexes := capper.apply("tenant-1", 0, series)
+		assert.Equal(t, series, actual)
+		assert.Equal(t, []int{0, 1, 2, 3, 4}, indexes)
+		assert.Equal(t, 0.0, testutil.ToFloat64(capper.droppedSeries.WithLabelValues("tenant-1")))
+	})
+}
+
+func TestSeriesCapper_getMaxSeries(t *testing.T) {
+	capper := newSeriesCapper(log.NewNopLogger(), prometheus.NewPedanticRegistry())
+
+	calls := 0
+	getLimits := func(context.Context) (TenantLimits, error) {
+		calls++
+		return TenantLimits{MaxGlobalSeriesPerUser: 100}, nil
+	}
+
+	// The configured value is used if not negative.
+	maxSeries, err := capper.getMaxSeries(context.Background(), "tenant-1", 10, getLimits)
+	require.NoError(t, err)
+	assert.Equal(t, 10, maxSeries)
+	assert.Equal(t, 0, calls)
+
+	// The tenant limits are read once and then cached.
+	for i := 0; i < 2; i++ {
+		maxSeries, err = capper.getMaxSeries(context.Background(), "tenant-1", -1, getLimits)
+		require.NoError(t, err)
+		assert.Equal(t, 100, maxSeries)
+		assert.Equal(t, 1, calls)
+	}
+
+	// Errors are not cached.
+	_, err = capper.getMaxSeries(context.Background(), "tenant-2", -1, func(context.Context) (TenantLimits, error) {
+		return TenantLimits{}, errors.New("failed")
+	})
+	require.EqualError(t, err, "failed")
+	assert.NotContains(t, capper.limits, "tenant-2")
+}
+
+func TestClient_WriteSeries_ShouldCapSeriesPerTenant(t *testing.T) {
+	var (
+		receivedMx     sync.Mutex
+		receivedSeries int
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.URL.Path == "/api/v1/tenant_limits" {
+			_, _ = writer.Write([]byte(`{"max_global_series_per_user":3}`))
+			return
+		}
+
+		body, err := ioutil.ReadAll(request.Body)
+		require.NoError(t, err)
+
+		body, err = snappy.Decode(nil, body)
+		require.NoError(t, err)
+
+		req := prompb.WriteRequest{}
+		require.NoError(t, proto.Unmarshal(body, &req))
+
+		receivedMx.Lock()
+		receivedSeries += len(req.Timeseries)
+		receivedMx.Unlock()
+	}))
+	t.Cleanup(server.Close)
+
+	for name, maxSeries := range map[string]int{"static limit": 3, "limit read from the tenant limits": -1} {
+		t.Run(name, func(t *testing.T) {
+			receivedMx.Lock()
+			receivedSeries = 0
+			receivedMx.Unlock()
+
+			cfg := ClientConfig{}
+			flagext.DefaultValues(&cfg)
+			cfg.TenantID = "tenant-1"
+			cfg.MaxSeriesPerTenant = maxSeries
+			require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+			require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+			c, err := NewClient(cfg, log.NewNopLogger(), nil)
+			require.NoError(t, err)
+
+			_, err = c.WriteSeries(context.Background(), generateSineWaveSeries("test", time.Now(), 5))
+			require.NoError(t, err)
+
+			receivedMx.Lock()
+			defer receivedMx.Unlock()
+			assert.Equal(t, 3, receivedSeries)
+		})
+	}
+}
+
+func TestClient_TenantLimits(t *testing.T) {
+	var receivedTenant string
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.URL.Path != "/api/v1/tenant_limits" {
+			writer.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		receivedTenant = request.URL.Query().Get("tenant")
+		writer.Header().Set("Content-Type", "application/json")
+		_, _ = writer.Write([]byte(`{"ingestion_rate":10000,"max_global_series_per_user":150000}`))
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := ClientConfig{}
+	flagext.DefaultValues(&cfg)
+	cfg.TenantID = "tenant-1"
+	require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+	// The read endpoint includes the Prometheus API prefix, while the tenant limits endpoint is served at the root.
+	require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL+"/prometheus"))
+
+	c, err := NewClient(cfg, log.NewNopLogger(), nil)
+	require.NoError(t, err)
+
+	limits, err := c.TenantLimits(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, TenantLimits{MaxGlobalSeriesPerUser: 150000}, limits)
+	assert.Equal(t, "tenant-1", receivedTenant)
+
+	// The tenant injected in the context should take precedence.
+	_, err = c.TenantLimits(user.InjectOrgID(context.Background(), "tenant-2"))
+	require.NoError(t, err)
+	assert.Equal(t, "tenant-2", receivedTenant)
+}
+
+func TestClient_WriteSeriesWithTimestamps_ShouldCapSeriesPerTenant(t *testing.T) {
+	var receivedSeries []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		body, err := ioutil.ReadAll(request.Body)
+		require.NoError(t, err)
+
+		body, err = snappy.Decode(nil, body)
+		require.NoError(t, err)
+
+		req := prompb.WriteRequest{}
+		require.NoError(t, proto.Unmarshal(body, &req))
+
+		for _, s := range req.Timeseries {
+			receivedSeries = append(receivedSeries, seriesKey(s))
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := ClientConfig{}
+	flagext.DefaultValues(&cfg)
+	cfg.TenantID = "tenant-1"
+	cfg.MaxSeriesPerTenant = 3
+	require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+	require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+	c, err := NewClient(cfg, log.NewNopLogger(), nil)
+	require.NoError(t, err)
+
+	now := time.Now()
+	series := generateSineWaveSeries("test", now, 5)
+
+	_, timestamps, err := c.WriteSeriesWithTimestamps(context.Background(), series)
+	require.NoError(t, err)
+	require.Len(t, timestamps, 5)
+	assert.Equal(t, []int64{now.UnixMilli()}, timestamps[0])
+	assert.Equal(t, []int64{now.UnixMilli()}, timestamps[2])
+	assert.Nil(t, timestamps[3])
+	assert.Nil(t, timestamps[4])
+	assert.Equal(t, []string{seriesKey(series[0]), seriesKey(series[1]), seriesKey(series[2])}, receivedSeries)
+
+	// The timestamps of the written series should be returned at the index of the input series.
+	receivedSeries = nil
+	next := generateSineWaveSeries("test", now.Add(time.Minute), 5)
+
+	_, timestamps, err = c.WriteSeriesWithTimestamps(context.Background(), []prompb.TimeSeries{next[4], next[1]})
+	require.NoError(t, err)
+	assert.Equal(t, [][]int64{nil, {now.Add(time.Minute).UnixMilli()}}, timestamps)
+	assert.Equal(t, []string{seriesKey(next[1])}, receivedSeries)
+}
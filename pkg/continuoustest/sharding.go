@@ -0,0 +1,93 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+
+	"github.com/pkg/errors"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+)
+
+// shardingControlHeader is the header honored by the query-frontend to override the number of shards
+// a query is split into. Query sharding is disabled if the number of shards is lower than 1.
+const shardingControlHeader = "Sharding-Control"
+
+// ErrShardingMismatch is returned by CheckQuerySharding when the result of the sharded query differs
+// from the non-sharded one.
+var ErrShardingMismatch = errors.New("the sharded query result differs from the non-sharded one")
+
+// CheckQuerySharding runs the same range query twice: first with query sharding disabled, through the
+// Sharding-Control header honored by the query-frontend, and then with the default sharding. Returns
+// ErrShardingMismatch if the results differ. Sample values are compared with the input relative tolerance,
+// because sharding may change the order in which floating point values are aggregated.
+func (c *Client) CheckQuerySharding(ctx context.Context, query string, r v1.Range, tolerance float64) error {
+	unsharded, err := c.queryRangeMatrix(ctx, query, r, http.Header{shardingControlHeader: []string{"0"}})
+	if err != nil {
+		return errors.Wrap(err, "failed to run non-sharded query")
+	}
+
+	sharded, err := c.queryRangeMatrix(ctx, query, r, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to run sharded query")
+	}
+
+	if err := compareMatrices(unsharded, sharded, tolerance); err != nil {
+		return errors.Wrapf(ErrShardingMismatch, "%s: %s", query, err.Error())
+	}
+	return nil
+}
+
+// compareMatrices returns an error describing the first difference found between the expected and actual
+// matrices, regardless of the series order. Sample values are compared with the input relative tolerance.
+func compareMatrices(expected, actual model.Matrix, tolerance float64) error {
+	actualByMetric := make(map[string]*model.SampleStream, len(actual))
+	for _, stream := range actual {
+		actualByMetric[stream.Metric.String()] = stream
+	}
+
+	if len(expected) != len(actual) {
+		return fmt.Errorf("expected %d series but got %d", len(expected), len(actual))
+	}
+
+	for _, expectedStream := range expected {
+		metric := expectedStream.Metric.String()
+
+		actualStream, ok := actualByMetric[metric]
+		if !ok {
+			return fmt.Errorf("series %s is missing", metric)
+		}
+		if len(expectedStream.Values) != len(actualStream.Values) {
+			return fmt.Errorf("series %s has %d samples while was expecting %d", metric, len(actualStream.Values), len(expectedStream.Values))
+		}
+
+		for i, expectedSample := range expectedStream.Values {
+			actualSample := actualStream.Values[i]
+
+			if actualSample.Timestamp != expectedSample.Timestamp {
+				return fmt.Errorf("series %s has a sample at timestamp %d while was expecting %d", metric, actualSample.Timestamp, expectedSample.Timestamp)
+			}
+			if !compareSampleValuesWithTolerance(float64(actualSample.Value), float64(expectedSample.Value), tolerance) {
+				return fmt.Errorf("series %s at timestamp %d has value %f while was expecting %f", metric, actualSample.Timestamp, actualSample.Value, expectedSample.Value)
+			}
+		}
+	}
+
+	return nil
+}
+
+// compareSampleValuesWithTolerance returns whether the actual value is within the input relative tolerance
+// of the expected one. NaN values are equal to each other, while infinite values must match exactly.
+func compareSampleValuesWithTolerance(actual, expected, tolerance float64) bool {
+	if math.IsNaN(actual) || math.IsNaN(expected) {
+		return math.IsNaN(actual) && math.IsNaN(expected)
+	}
+	if math.IsInf(actual, 0) || math.IsInf(expected, 0) {
+		return actual == expected
+	}
+	return actual == expected || math.Abs(actual-expected) <= tolerance*math.Abs(expected)
+}
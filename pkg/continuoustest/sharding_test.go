@@ -0,0 +1,127 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/flagext"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_CheckQuerySharding(t *testing.T) {
+	const unshardedResponse = `{"status":"success","data":{"resultType":"matrix","result":[
+		{"metric":{"job":"a"},"values":[[1000,"1"],[1020,"2"]]},
+		{"metric":{"job":"b"},"values":[[1000,"100"],[1020,"200"]]}
+	]}}`
+
+	tests := map[string]struct {
+		shardedResponse string
+		tolerance       float64
+		expectedErr     string
+	}{
+		"should succeed if the results are equal, regardless of the series order": {
+			shardedResponse: `{"status":"success","data":{"resultType":"matrix","result":[
+				{"metric":{"job":"b"},"values":[[1000,"100"],[1020,"200"]]},
+				{"metric":{"job":"a"},"values":[[1000,"1"],[1020,"2"]]}
+			]}}`,
+		},
+		"should succeed if the values differ within tolerance": {
+			shardedResponse: `{"status":"success","data":{"resultType":"matrix","result":[
+				{"metric":{"job":"a"},"values":[[1000,"1.0000001"],[1020,"2"]]},
+				{"metric":{"job":"b"},"values":[[1000,"100"],[1020,"200.00001"]]}
+			]}}`,
+			tolerance: 1e-6,
+		},
+		"should fail if the values differ": {
+			shardedResponse: `{"status":"success","data":{"resultType":"matrix","result":[
+				{"metric":{"job":"a"},"values":[[1000,"1"],[1020,"2"]]},
+				{"metric":{"job":"b"},"values":[[1000,"100"],[1020,"210"]]}
+			]}}`,
+			tolerance:   1e-6,
+			expectedErr: `sum by(job) (test): series {job="b"} at timestamp 1020000 has value 210.000000 while was expecting 200.000000: ` + ErrShardingMismatch.Error(),
+		},
+		"should fail if a series is missing": {
+			shardedResponse: `{"status":"success","data":{"resultType":"matrix","result":[
+				{"metric":{"job":"a"},"values":[[1000,"1"],[1020,"2"]]},
+				{"metric":{"job":"c"},"values":[[1000,"100"],[1020,"200"]]}
+			]}}`,
+			expectedErr: `sum by(job) (test): series {job="b"} is missing: ` + ErrShardingMismatch.Error(),
+		},
+		"should fail if a sample is missing": {
+			shardedResponse: `{"status":"success","data":{"resultType":"matrix","result":[
+				{"metric":{"job":"a"},"values":[[1000,"1"]]},
+				{"metric":{"job":"b"},"values":[[1000,"100"],[1020,"200"]]}
+			]}}`,
+			expectedErr: `sum by(job) (test): series {job="a"} has 1 samples while was expecting 2: ` + ErrShardingMismatch.Error(),
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			var receivedShardingControl []string
+
+			server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+				shardingControl := request.Header.Get("Sharding-Control")
+				receivedShardingControl = append(receivedShardingControl, shardingControl)
+
+				writer.Header().Set("Content-Type", "application/json")
+				if shardingControl == "0" {
+					_, _ = writer.Write([]byte(unshardedResponse))
+				} else {
+					_, _ = writer.Write([]byte(testData.shardedResponse))
+				}
+			}))
+			t.Cleanup(server.Close)
+
+			cfg := ClientConfig{}
+			flagext.DefaultValues(&cfg)
+			require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+			require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+			c, err := NewClient(cfg, log.NewNopLogger(), nil)
+			require.NoError(t, err)
+
+			err = c.CheckQuerySharding(context.Background(), "sum by(job) (test)", v1.Range{Start: time.Unix(1000, 0), End: time.Unix(1020, 0), Step: 20 * time.Second}, testData.tolerance)
+			if testData.expectedErr != "" {
+				require.EqualError(t, err, testData.expectedErr)
+				assert.ErrorIs(t, err, ErrShardingMismatch)
+			} else {
+				require.NoError(t, err)
+			}
+
+			// The first query should disable sharding, while the second one should use the default sharding.
+			assert.Equal(t, []string{"0", ""}, receivedShardingControl)
+		})
+	}
+}
+
+func TestCompareSampleValuesWithTolerance(t *testing.T) {
+	assert.True(t, compareSampleValuesWithTolerance(1, 1, 0))
+	assert.True(t, compareSampleValuesWithTolerance(0, 0, 0))
+	assert.False(t, compareSampleValuesWithTolerance(1.0001, 1, 0))
+	assert.True(t, compareSampleValuesWithTolerance(1.0001, 1, 0.001))
+	assert.False(t, compareSampleValuesWithTolerance(1.01, 1, 0.001))
+	assert.True(t, compareSampleValuesWithTolerance(math.NaN(), math.NaN(), 0))
+	assert.False(t, compareSampleValuesWithTolerance(math.NaN(), 1, 0.001))
+	assert.True(t, compareSampleValuesWithTolerance(math.Inf(1), math.Inf(1), 0))
+	assert.False(t, compareSampleValuesWithTolerance(math.Inf(1), math.Inf(-1), 0.001))
+}
+
+func TestCompareMatrices(t *testing.T) {
+	expected := model.Matrix{{Metric: model.Metric{"job": "a"}, Values: []model.SamplePair{{Timestamp: 1000, Value: 1}}}}
+
+	assert.NoError(t, compareMatrices(expected, expected, 0))
+	assert.EqualError(t, compareMatrices(expected, model.Matrix{}, 0), "expected 1 series but got 0")
+	assert.EqualError(t, compareMatrices(expected, model.Matrix{{Metric: model.Metric{"job": "a"}, Values: []model.SamplePair{{Timestamp: 2000, Value: 1}}}}, 0),
+		`series {job="a"} has a sample at timestamp 2000 while was expecting 1000`)
+}
@@ -0,0 +1,45 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"sync"
+)
+
+// slidingWindowRatio tracks the ratio of successful operations over the last size operations.
+type slidingWindowRatio struct {
+	mx        sync.Mutex
+	outcomes  []bool
+	next      int
+	count     int
+	successes int
+}
+
+func newSlidingWindowRatio(size int) *slidingWindowRatio {
+	return &slidingWindowRatio{
+		outcomes: make([]bool, size),
+	}
+}
+
+// observe records the outcome of an operation, evicting the oldest one if the window is full,
+// and returns the updated success ratio.
+func (w *slidingWindowRatio) observe(success bool) float64 {
+	w.mx.Lock()
+	defer w.mx.Unlock()
+
+	if w.count == len(w.outcomes) {
+		if w.outcomes[w.next] {
+			w.successes--
+		}
+	} else {
+		w.count++
+	}
+
+	w.outcomes[w.next] = success
+	if success {
+		w.successes++
+	}
+	w.next = (w.next + 1) % len(w.outcomes)
+
+	return float64(w.successes) / float64(w.count)
+}
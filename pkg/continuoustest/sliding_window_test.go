@@ -0,0 +1,26 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlidingWindowRatio(t *testing.T) {
+	w := newSlidingWindowRatio(4)
+
+	// The ratio is computed over the observed operations until the window is full.
+	assert.Equal(t, 1.0, w.observe(true))
+	assert.Equal(t, 0.5, w.observe(false))
+	assert.Equal(t, 2.0/3, w.observe(true))
+	assert.Equal(t, 0.75, w.observe(true))
+
+	// Once the window is full, the oldest outcome is evicted on each observation.
+	assert.Equal(t, 0.5, w.observe(false))  // Evicts true.
+	assert.Equal(t, 0.5, w.observe(false))  // Evicts false.
+	assert.Equal(t, 0.25, w.observe(false)) // Evicts true.
+	assert.Equal(t, 0.0, w.observe(false))  // Evicts true.
+	assert.Equal(t, 0.25, w.observe(true))  // Evicts false.
+}
@@ -0,0 +1,106 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/value"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+const specialValueLabel = "value_kind"
+
+// specialValues are the special float values written by generateSpecialValueSeries, by kind. NaN is the
+// Prometheus normal NaN, because the stale NaN would be interpreted as a staleness marker.
+var specialValues = []struct {
+	kind  string
+	value float64
+}{
+	{kind: "nan", value: math.Float64frombits(value.NormalNaN)},
+	{kind: "pos_inf", value: math.Inf(1)},
+	{kind: "neg_inf", value: math.Inf(-1)},
+	{kind: "min_subnormal", value: math.SmallestNonzeroFloat64},
+	{kind: "max_subnormal", value: math.Float64frombits(0x000fffffffffffff)},
+}
+
+// generateSpecialValueSeries returns a series for each special float value (NaN, +Inf, -Inf and subnormal
+// values), identified by the value_kind label, with a sample at the input timestamp.
+func generateSpecialValueSeries(name string, t time.Time) []prompb.TimeSeries {
+	out := make([]prompb.TimeSeries, 0, len(specialValues))
+
+	for _, v := range specialValues {
+		out = append(out, prompb.TimeSeries{
+			Labels: []prompb.Label{{
+				Name:  "__name__",
+				Value: name,
+			}, {
+				Name:  specialValueLabel,
+				Value: v.kind,
+			}},
+			Samples: []prompb.Sample{{
+				Value:     v.value,
+				Timestamp: t.UnixMilli(),
+			}},
+		})
+	}
+
+	return out
+}
+
+// verifySpecialValueSamples checks that the input matrix contains a series for each special value written
+// by generateSpecialValueSeries, and that all samples of each series match the written value bit-accurately.
+func verifySpecialValueSamples(m model.Matrix) error {
+	found := map[string]bool{}
+
+	for _, series := range m {
+		kind := string(series.Metric[specialValueLabel])
+		expected, ok := getSpecialValue(kind)
+		if !ok {
+			return fmt.Errorf("unexpected series %s with unknown special value kind", series.Metric.String())
+		}
+
+		if len(series.Values) == 0 {
+			return fmt.Errorf("no samples returned for series %s", series.Metric.String())
+		}
+
+		for _, sample := range series.Values {
+			if !sameSampleValue(float64(sample.Value), expected) {
+				return fmt.Errorf("sample at timestamp %d (%s) for series %s has value %v (bits: %#016x) while %v (bits: %#016x) was expected",
+					sample.Timestamp, sample.Timestamp.Time().UTC().String(), series.Metric.String(),
+					float64(sample.Value), math.Float64bits(float64(sample.Value)), expected, math.Float64bits(expected))
+			}
+		}
+
+		found[kind] = true
+	}
+
+	for _, v := range specialValues {
+		if !found[v.kind] {
+			return fmt.Errorf("no series returned for special value kind %s", v.kind)
+		}
+	}
+
+	return nil
+}
+
+func getSpecialValue(kind string) (float64, bool) {
+	for _, v := range specialValues {
+		if v.kind == kind {
+			return v.value, true
+		}
+	}
+	return 0, false
+}
+
+// sameSampleValue returns whether the two input values are bit-accurately equal, except that any NaN
+// compares equal to any other NaN, because NaN payloads are not guaranteed to be preserved end-to-end.
+func sameSampleValue(actual, expected float64) bool {
+	if math.IsNaN(actual) || math.IsNaN(expected) {
+		return math.IsNaN(actual) && math.IsNaN(expected)
+	}
+	return math.Float64bits(actual) == math.Float64bits(expected)
+}
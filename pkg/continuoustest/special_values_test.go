@@ -0,0 +1,152 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/value"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateSpecialValueSeries(t *testing.T) {
+	now := time.Unix(1000, 0)
+	series := generateSpecialValueSeries("test", now)
+	require.Len(t, series, len(specialValues))
+
+	for i, s := range series {
+		assert.Equal(t, []prompb.Label{{Name: "__name__", Value: "test"}, {Name: specialValueLabel, Value: specialValues[i].kind}}, s.Labels)
+		require.Len(t, s.Samples, 1)
+		assert.Equal(t, now.UnixMilli(), s.Samples[0].Timestamp)
+		assert.Equal(t, math.Float64bits(specialValues[i].value), math.Float64bits(s.Samples[0].Value))
+	}
+
+	// The generated NaN must not be a staleness marker.
+	assert.False(t, value.IsStaleNaN(series[0].Samples[0].Value))
+}
+
+func TestSpecialValues_ShouldSurviveProtobufRoundTrip(t *testing.T) {
+	for _, v := range specialValues {
+		t.Run(v.kind, func(t *testing.T) {
+			req := prompb.WriteRequest{Timeseries: []prompb.TimeSeries{{
+				Labels:  []prompb.Label{{Name: "__name__", Value: "test"}},
+				Samples: []prompb.Sample{{Value: v.value, Timestamp: 1000}},
+			}}}
+
+			data, err := proto.Marshal(&req)
+			require.NoError(t, err)
+
+			decoded := prompb.WriteRequest{}
+			require.NoError(t, proto.Unmarshal(data, &decoded))
+
+			require.Len(t, decoded.Timeseries, 1)
+			require.Len(t, decoded.Timeseries[0].Samples, 1)
+			assert.Equal(t, math.Float64bits(v.value), math.Float64bits(decoded.Timeseries[0].Samples[0].Value))
+		})
+	}
+}
+
+func TestSpecialValues_ShouldSurviveJSONRoundTrip(t *testing.T) {
+	for _, v := range specialValues {
+		t.Run(v.kind, func(t *testing.T) {
+			input := model.Matrix{{
+				Metric: model.Metric{"__name__": "test", specialValueLabel: model.LabelValue(v.kind)},
+				Values: []model.SamplePair{{Timestamp: 1000, Value: model.SampleValue(v.value)}},
+			}}
+
+			data, err := json.Marshal(input)
+			require.NoError(t, err)
+
+			decoded := model.Matrix{}
+			require.NoError(t, json.Unmarshal(data, &decoded))
+
+			require.Len(t, decoded, 1)
+			require.Len(t, decoded[0].Values, 1)
+			assert.Equal(t, math.Float64bits(v.value), math.Float64bits(float64(decoded[0].Values[0].Value)))
+			assert.True(t, sameSampleValue(float64(decoded[0].Values[0].Value), v.value))
+		})
+	}
+}
+
+func TestVerifySpecialValueSamples(t *testing.T) {
+	toMatrix := func(series []prompb.TimeSeries) model.Matrix {
+		out := make(model.Matrix, 0, len(series))
+		for _, s := range series {
+			metric := model.Metric{}
+			for _, l := range s.Labels {
+				metric[model.LabelName(l.Name)] = model.LabelValue(l.Value)
+			}
+
+			stream := &model.SampleStream{Metric: metric}
+			for _, sample := range s.Samples {
+				stream.Values = append(stream.Values, model.SamplePair{Timestamp: model.Time(sample.Timestamp), Value: model.SampleValue(sample.Value)})
+			}
+			out = append(out, stream)
+		}
+		return out
+	}
+
+	t.Run("should succeed if all special values are returned", func(t *testing.T) {
+		m := toMatrix(generateSpecialValueSeries("test", time.Unix(1000, 0)))
+		assert.NoError(t, verifySpecialValueSamples(m))
+	})
+
+	t.Run("should succeed if NaN is returned with a different payload", func(t *testing.T) {
+		m := toMatrix(generateSpecialValueSeries("test", time.Unix(1000, 0)))
+		m[0].Values[0].Value = model.SampleValue(math.Float64frombits(0x7ff8000000000002))
+		assert.NoError(t, verifySpecialValueSamples(m))
+	})
+
+	t.Run("should fail if a special value is missing", func(t *testing.T) {
+		m := toMatrix(generateSpecialValueSeries("test", time.Unix(1000, 0)))
+		assert.EqualError(t, verifySpecialValueSamples(m[1:]), "no series returned for special value kind nan")
+	})
+
+	t.Run("should fail if a subnormal value is flushed to zero", func(t *testing.T) {
+		m := toMatrix(generateSpecialValueSeries("test", time.Unix(1000, 0)))
+		m[3].Values[0].Value = 0
+		assert.Error(t, verifySpecialValueSamples(m))
+	})
+
+	t.Run("should fail if the infinity sign is flipped", func(t *testing.T) {
+		m := toMatrix(generateSpecialValueSeries("test", time.Unix(1000, 0)))
+		m[1].Values[0].Value = model.SampleValue(math.Inf(-1))
+		assert.Error(t, verifySpecialValueSamples(m))
+	})
+
+	t.Run("should fail on unknown special value kind", func(t *testing.T) {
+		m := toMatrix(generateSpecialValueSeries("test", time.Unix(1000, 0)))
+		m[0].Metric[specialValueLabel] = "unknown"
+		assert.Error(t, verifySpecialValueSamples(m))
+	})
+}
+
+func TestSameSampleValue(t *testing.T) {
+	tests := map[string]struct {
+		actual, expected float64
+		same             bool
+	}{
+		"NaN vs NaN":                  {actual: math.NaN(), expected: math.NaN(), same: true},
+		"NaN vs number":               {actual: math.NaN(), expected: 1, same: false},
+		"number vs NaN":               {actual: 1, expected: math.NaN(), same: false},
+		"+Inf vs +Inf":                {actual: math.Inf(1), expected: math.Inf(1), same: true},
+		"+Inf vs -Inf":                {actual: math.Inf(1), expected: math.Inf(-1), same: false},
+		"subnormal vs subnormal":      {actual: math.SmallestNonzeroFloat64, expected: math.SmallestNonzeroFloat64, same: true},
+		"subnormal vs zero":           {actual: 0, expected: math.SmallestNonzeroFloat64, same: false},
+		"positive vs negative zero":   {actual: 0, expected: math.Copysign(0, -1), same: false},
+		"close but not equal numbers": {actual: 1, expected: math.Nextafter(1, 2), same: false},
+	}
+
+	for name, testData := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, testData.same, sameSampleValue(testData.actual, testData.expected))
+		})
+	}
+}
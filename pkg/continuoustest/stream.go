@@ -0,0 +1,138 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+)
+
+// decodeQueryRangeResponseStream incrementally decodes a Prometheus range query API response
+// from the input reader, calling fn for each series in the matrix result as soon as it's decoded.
+func decodeQueryRangeResponseStream(r io.Reader, fn func(model.SampleStream) error) error {
+	var (
+		dec       = json.NewDecoder(r)
+		status    string
+		errorType string
+		errorMsg  string
+	)
+
+	if err := expectJSONDelim(dec, '{'); err != nil {
+		return err
+	}
+
+	for dec.More() {
+		key, err := readJSONKey(dec)
+		if err != nil {
+			return err
+		}
+
+		switch key {
+		case "status":
+			err = dec.Decode(&status)
+		case "errorType":
+			err = dec.Decode(&errorType)
+		case "error":
+			err = dec.Decode(&errorMsg)
+		case "data":
+			err = decodeQueryRangeDataStream(dec, fn)
+		default:
+			err = dec.Decode(&json.RawMessage{})
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if status != "success" {
+		return errors.Errorf("query failed with status %q, error type %q and error %q", status, errorType, errorMsg)
+	}
+
+	return expectJSONDelim(dec, '}')
+}
+
+func decodeQueryRangeDataStream(dec *json.Decoder, fn func(model.SampleStream) error) error {
+	token, err := dec.Token()
+	if err != nil {
+		return errors.Wrap(err, "failed to decode response")
+	}
+
+	// The data may be null in case of errors.
+	if token == nil {
+		return nil
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '{' {
+		return errors.Errorf("unexpected token %v in response data", token)
+	}
+
+	for dec.More() {
+		key, err := readJSONKey(dec)
+		if err != nil {
+			return err
+		}
+
+		switch key {
+		case "resultType":
+			var resultType string
+			if err := dec.Decode(&resultType); err != nil {
+				return errors.Wrap(err, "failed to decode result type")
+			}
+			if resultType != model.ValMatrix.String() {
+				return errors.Errorf("was expecting to get a Matrix but got %s", resultType)
+			}
+		case "result":
+			if err := expectJSONDelim(dec, '['); err != nil {
+				return err
+			}
+
+			for dec.More() {
+				var stream model.SampleStream
+				if err := dec.Decode(&stream); err != nil {
+					return errors.Wrap(err, "failed to decode series")
+				}
+				if err := fn(stream); err != nil {
+					return err
+				}
+			}
+
+			if err := expectJSONDelim(dec, ']'); err != nil {
+				return err
+			}
+		default:
+			if err := dec.Decode(&json.RawMessage{}); err != nil {
+				return errors.Wrap(err, "failed to decode response")
+			}
+		}
+	}
+
+	return expectJSONDelim(dec, '}')
+}
+
+func readJSONKey(dec *json.Decoder) (string, error) {
+	token, err := dec.Token()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to decode response")
+	}
+
+	key, ok := token.(string)
+	if !ok {
+		return "", errors.Errorf("unexpected token %v in response", token)
+	}
+	return key, nil
+}
+
+func expectJSONDelim(dec *json.Decoder, expected json.Delim) error {
+	token, err := dec.Token()
+	if err != nil {
+		return errors.Wrap(err, "failed to decode response")
+	}
+
+	if delim, ok := token.(json.Delim); !ok || delim != expected {
+		return errors.Errorf("unexpected token %v in response while was expecting %v", token, expected)
+	}
+	return nil
+}
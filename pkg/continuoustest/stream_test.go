@@ -0,0 +1,129 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/flagext"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeQueryRangeResponseStream(t *testing.T) {
+	tests := map[string]struct {
+		body           string
+		expectedSeries []model.SampleStream
+		expectedErr    string
+	}{
+		"should call the function for each series": {
+			body: `{"status":"success","data":{"resultType":"matrix","result":[
+				{"metric":{"series_id":"0"},"values":[[1000,"1"],[1020,"2"]]},
+				{"metric":{"series_id":"1"},"values":[[1000,"3"]]}
+			]}}`,
+			expectedSeries: []model.SampleStream{
+				{Metric: model.Metric{"series_id": "0"}, Values: []model.SamplePair{{Timestamp: 1000000, Value: 1}, {Timestamp: 1020000, Value: 2}}},
+				{Metric: model.Metric{"series_id": "1"}, Values: []model.SamplePair{{Timestamp: 1000000, Value: 3}}},
+			},
+		},
+		"should skip unknown fields": {
+			body: `{"status":"success","warnings":["some warning"],"data":{"resultType":"matrix","stats":{"samples":1},"result":[
+				{"metric":{"series_id":"0"},"values":[[1000,"1"]]}
+			]}}`,
+			expectedSeries: []model.SampleStream{
+				{Metric: model.Metric{"series_id": "0"}, Values: []model.SamplePair{{Timestamp: 1000000, Value: 1}}},
+			},
+		},
+		"should not call the function on empty result": {
+			body:           `{"status":"success","data":{"resultType":"matrix","result":[]}}`,
+			expectedSeries: nil,
+		},
+		"should return error if the result is not a matrix": {
+			body:        `{"status":"success","data":{"resultType":"vector","result":[]}}`,
+			expectedErr: "was expecting to get a Matrix but got vector",
+		},
+		"should return error if the query failed": {
+			body:        `{"status":"error","errorType":"bad_data","error":"invalid query"}`,
+			expectedErr: `query failed with status "error", error type "bad_data" and error "invalid query"`,
+		},
+		"should return error if the response is not JSON": {
+			body:        `<html>Bad Gateway</html>`,
+			expectedErr: "failed to decode response: invalid character '<' looking for beginning of value",
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			var actual []model.SampleStream
+
+			err := decodeQueryRangeResponseStream(strings.NewReader(testData.body), func(stream model.SampleStream) error {
+				actual = append(actual, stream)
+				return nil
+			})
+
+			if testData.expectedErr != "" {
+				require.EqualError(t, err, testData.expectedErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, testData.expectedSeries, actual)
+		})
+	}
+
+	t.Run("should stop on the first error returned by the function", func(t *testing.T) {
+		body := `{"status":"success","data":{"resultType":"matrix","result":[
+			{"metric":{"series_id":"0"},"values":[[1000,"1"]]},
+			{"metric":{"series_id":"1"},"values":[[1000,"1"]]}
+		]}}`
+
+		calls := 0
+		err := decodeQueryRangeResponseStream(strings.NewReader(body), func(stream model.SampleStream) error {
+			calls++
+			return errors.New("stop")
+		})
+
+		require.EqualError(t, err, "stop")
+		assert.Equal(t, 1, calls)
+	})
+}
+
+func TestClient_QueryRangeStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		assert.Equal(t, "/api/v1/query_range", request.URL.Path)
+		assert.Equal(t, "test", request.URL.Query().Get("query"))
+
+		writer.Header().Set("Content-Type", "application/json")
+		_, _ = writer.Write([]byte(`{"status":"success","data":{"resultType":"matrix","result":[
+			{"metric":{"series_id":"0"},"values":[[1000,"1"]]},
+			{"metric":{"series_id":"1"},"values":[[1000,"2"]]},
+			{"metric":{"series_id":"2"},"values":[[1000,"3"]]}
+		]}}`))
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := ClientConfig{}
+	flagext.DefaultValues(&cfg)
+	require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+	require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+	c, err := NewClient(cfg, log.NewNopLogger(), nil)
+	require.NoError(t, err)
+
+	var actual []string
+	err = c.QueryRangeStream(context.Background(), "test", v1.Range{Start: time.Unix(1000, 0), End: time.Unix(1000, 0), Step: time.Second}, func(stream model.SampleStream) error {
+		actual = append(actual, string(stream.Metric["series_id"]))
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"0", "1", "2"}, actual)
+}
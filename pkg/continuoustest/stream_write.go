@@ -0,0 +1,51 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// StreamWrite writes the input series once immediately and then at each interval, with the timestamp of the
+// samples set to the current time, until the context is canceled. Each series is expected to have a single
+// sample, whose value is written at each tick. Returns nil once the context is canceled, or the first write error.
+func (c *Client) StreamWrite(ctx context.Context, series []prompb.TimeSeries, interval time.Duration) error {
+	return c.streamWrite(ctx, series, interval, false)
+}
+
+// StreamWriteContinueOnError is like StreamWrite, but keeps writing when a write fails. Failed writes are
+// logged and tracked in the client metrics.
+func (c *Client) StreamWriteContinueOnError(ctx context.Context, series []prompb.TimeSeries, interval time.Duration) {
+	_ = c.streamWrite(ctx, series, interval, true)
+}
+
+func (c *Client) streamWrite(ctx context.Context, series []prompb.TimeSeries, interval time.Duration, continueOnError bool) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		now := time.Now().UnixMilli()
+		_, err := c.WriteSeries(ctx, withSampleTimestamps(series, func(int, int) int64 { return now }))
+
+		c.metrics.streamWrites.Inc()
+		if err != nil && ctx.Err() == nil {
+			c.metrics.streamWritesFailed.Inc()
+
+			if !continueOnError {
+				return errors.Wrapf(err, "failed to write series at timestamp %d", now)
+			}
+			level.Warn(c.logger).Log("msg", "Failed to stream write series", "timestamp", now, "err", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
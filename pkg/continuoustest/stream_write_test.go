@@ -0,0 +1,133 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/grafana/dskit/flagext"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_StreamWrite(t *testing.T) {
+	const interval = 20 * time.Millisecond
+
+	var (
+		receivedMx       sync.Mutex
+		receivedRequests []prompb.WriteRequest
+		statusCode       = http.StatusOK
+	)
+
+	getReceivedRequests := func() []prompb.WriteRequest {
+		receivedMx.Lock()
+		defer receivedMx.Unlock()
+		return append([]prompb.WriteRequest{}, receivedRequests...)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		body, err := ioutil.ReadAll(request.Body)
+		require.NoError(t, err)
+
+		body, err = snappy.Decode(nil, body)
+		require.NoError(t, err)
+
+		req := prompb.WriteRequest{}
+		require.NoError(t, proto.Unmarshal(body, &req))
+
+		receivedMx.Lock()
+		receivedRequests = append(receivedRequests, req)
+		writer.WriteHeader(statusCode)
+		receivedMx.Unlock()
+	}))
+	t.Cleanup(server.Close)
+
+	newClient := func(t *testing.T, reg prometheus.Registerer) *Client {
+		receivedMx.Lock()
+		receivedRequests = nil
+		receivedMx.Unlock()
+
+		cfg := ClientConfig{}
+		flagext.DefaultValues(&cfg)
+		require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+		require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+		c, err := NewClient(cfg, log.NewNopLogger(), reg)
+		require.NoError(t, err)
+		return c
+	}
+
+	series := generateSineWaveSeries("test", time.Unix(0, 0), 2)
+
+	t.Run("should write series at each tick until the context is canceled", func(t *testing.T) {
+		statusCode = http.StatusOK
+		c := newClient(t, nil)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error, 1)
+		go func() { done <- c.StreamWrite(ctx, series, interval) }()
+
+		require.Eventually(t, func() bool { return len(getReceivedRequests()) >= 3 }, time.Second, interval)
+		cancel()
+		require.NoError(t, <-done)
+
+		// Each write is expected to have all series, stamped with an increasing timestamp.
+		var lastTimestamp int64
+		for _, req := range getReceivedRequests() {
+			require.Len(t, req.Timeseries, len(series))
+			for i, s := range req.Timeseries {
+				assert.Equal(t, series[i].Labels, s.Labels)
+				require.Len(t, s.Samples, 1)
+				assert.Equal(t, series[i].Samples[0].Value, s.Samples[0].Value)
+				assert.Greater(t, s.Samples[0].Timestamp, lastTimestamp)
+			}
+			lastTimestamp = req.Timeseries[0].Samples[0].Timestamp
+		}
+
+		// The input series should not be modified.
+		assert.Equal(t, int64(0), series[0].Samples[0].Timestamp)
+	})
+
+	t.Run("should return the first write error", func(t *testing.T) {
+		statusCode = http.StatusInternalServerError
+		c := newClient(t, nil)
+
+		err := c.StreamWrite(context.Background(), series, interval)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "500")
+		assert.NotEmpty(t, getReceivedRequests())
+	})
+
+	t.Run("should keep writing on error if configured", func(t *testing.T) {
+		statusCode = http.StatusInternalServerError
+		reg := prometheus.NewPedanticRegistry()
+		c := newClient(t, reg)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			c.StreamWriteContinueOnError(ctx, series, interval)
+			close(done)
+		}()
+
+		require.Eventually(t, func() bool { return len(getReceivedRequests()) >= 3 }, time.Second, interval)
+		cancel()
+		<-done
+
+		writes := testutil.ToFloat64(c.metrics.streamWrites)
+		assert.GreaterOrEqual(t, writes, 3.0)
+		assert.GreaterOrEqual(t, testutil.ToFloat64(c.metrics.streamWritesFailed), 3.0)
+	})
+}
@@ -0,0 +1,65 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// defaultTenantID is the default value of the -tests.tenant-id flag.
+const defaultTenantID = "anonymous"
+
+// tenantIDFileReader reads the tenant ID from a file which may be rotated, for example when provisioned
+// as a mounted secret. The tenant ID is cached and the file is read again once the refresh interval has
+// elapsed since the last read.
+type tenantIDFileReader struct {
+	path            string
+	refreshInterval time.Duration
+
+	mx       sync.Mutex
+	tenantID string
+	lastRead time.Time
+}
+
+func newTenantIDFileReader(path string, refreshInterval time.Duration) (*tenantIDFileReader, error) {
+	r := &tenantIDFileReader{path: path, refreshInterval: refreshInterval}
+	if err := r.read(time.Now()); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// get returns the tenant ID read from the file. If reading the file fails (eg. the file is being rotated),
+// the previously read tenant ID is returned.
+func (r *tenantIDFileReader) get() string {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	if now := time.Now(); now.Sub(r.lastRead) >= r.refreshInterval {
+		_ = r.read(now)
+	}
+	return r.tenantID
+}
+
+// read reads the tenant ID from the file. Must be called with the lock held, unless the reader is
+// not in use yet.
+func (r *tenantIDFileReader) read(now time.Time) error {
+	content, err := os.ReadFile(r.path)
+	if err != nil {
+		return errors.Wrap(err, "failed to read the tenant ID file")
+	}
+
+	tenantID := strings.TrimSpace(string(content))
+	if tenantID == "" {
+		return errors.Errorf("the tenant ID file %s is empty", r.path)
+	}
+
+	r.tenantID = tenantID
+	r.lastRead = now
+	return nil
+}
@@ -0,0 +1,115 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/flagext"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTenantIDFileReader(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tenant")
+
+	t.Run("should fail if the file doesn't exist", func(t *testing.T) {
+		_, err := newTenantIDFileReader(path, time.Minute)
+		require.Error(t, err)
+	})
+
+	t.Run("should fail if the file is empty", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(path, []byte("\n"), 0600))
+
+		_, err := newTenantIDFileReader(path, time.Minute)
+		require.Error(t, err)
+	})
+
+	t.Run("should cache the tenant ID until the refresh interval elapses", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(path, []byte("tenant-1\n"), 0600))
+
+		r, err := newTenantIDFileReader(path, time.Minute)
+		require.NoError(t, err)
+		assert.Equal(t, "tenant-1", r.get())
+
+		require.NoError(t, os.WriteFile(path, []byte("tenant-2"), 0600))
+		assert.Equal(t, "tenant-1", r.get())
+
+		// Simulate the refresh interval elapsed.
+		r.lastRead = time.Now().Add(-time.Minute)
+		assert.Equal(t, "tenant-2", r.get())
+	})
+
+	t.Run("should keep the previous tenant ID if the file can't be read", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(path, []byte("tenant-1"), 0600))
+
+		r, err := newTenantIDFileReader(path, 0)
+		require.NoError(t, err)
+
+		require.NoError(t, os.Remove(path))
+		assert.Equal(t, "tenant-1", r.get())
+	})
+}
+
+func TestClient_ShouldReadTenantIDFromFile(t *testing.T) {
+	var (
+		receivedMx      sync.Mutex
+		receivedTenants []string
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		receivedMx.Lock()
+		receivedTenants = append(receivedTenants, request.Header.Get("X-Scope-OrgID"))
+		receivedMx.Unlock()
+	}))
+	t.Cleanup(server.Close)
+
+	path := filepath.Join(t.TempDir(), "tenant")
+	require.NoError(t, os.WriteFile(path, []byte("tenant-1"), 0600))
+
+	cfg := ClientConfig{}
+	flagext.DefaultValues(&cfg)
+	cfg.TenantIDFile = path
+	cfg.TenantIDFileRefresh = 0
+	require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+	require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+	c, err := NewClient(cfg, log.NewNopLogger(), nil)
+	require.NoError(t, err)
+
+	_, err = c.WriteSeries(context.Background(), generateSineWaveSeries("test", time.Now(), 1))
+	require.NoError(t, err)
+
+	// Rotate the tenant ID.
+	require.NoError(t, os.WriteFile(path, []byte("tenant-2"), 0600))
+
+	_, err = c.WriteSeries(context.Background(), generateSineWaveSeries("test", time.Now(), 1))
+	require.NoError(t, err)
+
+	receivedMx.Lock()
+	defer receivedMx.Unlock()
+	assert.Equal(t, []string{"tenant-1", "tenant-2"}, receivedTenants)
+}
+
+func TestNewClient_ShouldFailIfTenantIDAndTenantIDFileAreBothSet(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tenant")
+	require.NoError(t, os.WriteFile(path, []byte("tenant-1"), 0600))
+
+	cfg := ClientConfig{}
+	flagext.DefaultValues(&cfg)
+	cfg.TenantID = "tenant-2"
+	cfg.TenantIDFile = path
+	require.NoError(t, cfg.WriteBaseEndpoint.Set("http://localhost"))
+	require.NoError(t, cfg.ReadBaseEndpoint.Set("http://localhost"))
+
+	_, err := NewClient(cfg, log.NewNopLogger(), nil)
+	require.EqualError(t, err, "the tenant ID and the tenant ID file can't be both set")
+}
@@ -0,0 +1,101 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"io/ioutil"
+	"sync"
+
+	dstls "github.com/grafana/dskit/crypto/tls"
+	"github.com/pkg/errors"
+)
+
+// getTLSConfig returns the TLS config built from the input client config, or nil if TLS is not configured.
+// The client certificate, if any, is reloaded from disk when the certificate or key files change.
+func getTLSConfig(cfg dstls.ClientConfig) (*tls.Config, error) {
+	if cfg == (dstls.ClientConfig{}) {
+		return nil, nil
+	}
+
+	tlsCfg, err := cfg.GetTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tlsCfg.Certificates) > 0 {
+		reloader, err := newClientCertReloader(cfg.CertPath, cfg.KeyPath)
+		if err != nil {
+			return nil, err
+		}
+
+		tlsCfg.Certificates = nil
+		tlsCfg.GetClientCertificate = reloader.getClientCertificate
+	}
+
+	return tlsCfg, nil
+}
+
+// clientCertReloader loads the client certificate from disk, reloading it when the certificate or key
+// files change. The files are checked on each TLS handshake, so a rotated certificate is presented on
+// the next connection, while the in-flight requests keep using their connection.
+type clientCertReloader struct {
+	certPath string
+	keyPath  string
+
+	mx      sync.Mutex
+	cert    *tls.Certificate
+	version string
+}
+
+func newClientCertReloader(certPath, keyPath string) (*clientCertReloader, error) {
+	r := &clientCertReloader{certPath: certPath, keyPath: keyPath}
+	if _, err := r.getClientCertificate(nil); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// getClientCertificate implements tls.Config.GetClientCertificate. If reloading a changed certificate
+// fails (eg. the files are being rotated and only one of them has been updated yet), the previously
+// loaded certificate is returned.
+func (r *clientCertReloader) getClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	version, err := r.filesVersion()
+	if err == nil && version == r.version {
+		return r.cert, nil
+	}
+
+	if err == nil {
+		var cert tls.Certificate
+		if cert, err = tls.LoadX509KeyPair(r.certPath, r.keyPath); err == nil {
+			r.cert = &cert
+			r.version = version
+			return r.cert, nil
+		}
+	}
+
+	if r.cert != nil {
+		return r.cert, nil
+	}
+	return nil, errors.Wrapf(err, "failed to load TLS certificate %s,%s", r.certPath, r.keyPath)
+}
+
+// filesVersion returns a string which changes whenever the certificate or key files change. The version
+// is the hash of the files content, because the modification time may not change when the files are
+// rotated in a quick succession.
+func (r *clientCertReloader) filesVersion() (string, error) {
+	hash := sha256.New()
+	for _, path := range []string{r.certPath, r.keyPath} {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		_, _ = hash.Write(data)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,177 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/flagext"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_ShouldReloadTLSClientCertificate(t *testing.T) {
+	ca := newTestCA(t)
+
+	var (
+		receivedMx  sync.Mutex
+		receivedCNs []string
+	)
+
+	// Mock a server requiring a client certificate signed by the CA.
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		receivedMx.Lock()
+		receivedCNs = append(receivedCNs, request.TLS.PeerCertificates[0].Subject.CommonName)
+		receivedMx.Unlock()
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: ca.pool()}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	dir := t.TempDir()
+	certPath, keyPath := filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key")
+	ca.writeClientCertificate(t, "client-1", certPath, keyPath)
+
+	cfg := ClientConfig{}
+	flagext.DefaultValues(&cfg)
+	cfg.DisableKeepAlives = true
+	cfg.TLS.CertPath = certPath
+	cfg.TLS.KeyPath = keyPath
+	cfg.TLS.InsecureSkipVerify = true
+	require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+	require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+	c, err := NewClient(cfg, log.NewNopLogger(), nil)
+	require.NoError(t, err)
+
+	write := func() {
+		_, err := c.WriteSeries(context.Background(), generateSineWaveSeries("test", time.Now(), 1))
+		require.NoError(t, err)
+	}
+
+	write()
+
+	// Rotate the certificate, atomically replacing the files like a secrets manager would do.
+	ca.writeClientCertificate(t, "client-2", certPath+".new", keyPath+".new")
+	require.NoError(t, os.Rename(keyPath+".new", keyPath))
+	require.NoError(t, os.Rename(certPath+".new", certPath))
+
+	write()
+
+	receivedMx.Lock()
+	defer receivedMx.Unlock()
+	assert.Equal(t, []string{"client-1", "client-2"}, receivedCNs)
+}
+
+func TestClientCertReloader_ShouldKeepPreviousCertificateOnReloadFailure(t *testing.T) {
+	ca := newTestCA(t)
+
+	dir := t.TempDir()
+	certPath, keyPath := filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key")
+	ca.writeClientCertificate(t, "client-1", certPath, keyPath)
+
+	r, err := newClientCertReloader(certPath, keyPath)
+	require.NoError(t, err)
+
+	// Simulate a partial rotation, where only the certificate has been replaced yet.
+	other := t.TempDir()
+	ca.writeClientCertificate(t, "client-2", filepath.Join(other, "client.crt"), filepath.Join(other, "client.key"))
+	require.NoError(t, os.Rename(filepath.Join(other, "client.crt"), certPath))
+
+	cert, err := r.getClientCertificate(nil)
+	require.NoError(t, err)
+
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	require.NoError(t, err)
+	assert.Equal(t, "client-1", leaf.Subject.CommonName)
+
+	// Complete the rotation.
+	require.NoError(t, os.Rename(filepath.Join(other, "client.key"), keyPath))
+
+	cert, err = r.getClientCertificate(nil)
+	require.NoError(t, err)
+
+	leaf, err = x509.ParseCertificate(cert.Certificate[0])
+	require.NoError(t, err)
+	assert.Equal(t, "client-2", leaf.Subject.CommonName)
+}
+
+func TestNewClientCertReloader_ShouldFailOnMissingFiles(t *testing.T) {
+	dir := t.TempDir()
+	_, err := newClientCertReloader(filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key"))
+	require.Error(t, err)
+}
+
+// testCA is a certificate authority issuing client certificates in tests.
+type testCA struct {
+	key  *ecdsa.PrivateKey
+	cert *x509.Certificate
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return &testCA{key: key, cert: cert}
+}
+
+func (ca *testCA) pool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	return pool
+}
+
+func (ca *testCA) writeClientCertificate(t *testing.T, commonName, certPath, keyPath string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, key.Public(), ca.key)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+}
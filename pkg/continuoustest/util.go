@@ -4,11 +4,14 @@ package continuoustest
 
 import (
 	"fmt"
+	"hash/fnv"
 	"math"
 	"math/rand"
+	"sort"
 	"strconv"
 	"time"
 
+	"github.com/pkg/errors"
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/prompb"
 )
@@ -17,6 +20,21 @@ const (
 	maxComparisonDelta = 0.001
 )
 
+// ErrNoData is returned by verifiers when the query result has no data but data was expected.
+var ErrNoData = errors.New("the query result has no data")
+
+// emptyResultPolicy defines how verifiers treat a query result with no data.
+type emptyResultPolicy int
+
+const (
+	// emptyResultFails makes the verification fail with ErrNoData if the result has no data.
+	emptyResultFails emptyResultPolicy = iota
+
+	// emptyResultExpected makes the verification succeed only if the result has no data
+	// (eg. after the series have been deleted).
+	emptyResultExpected
+)
+
 func alignTimestampToInterval(ts time.Time, interval time.Duration) time.Time {
 	return ts.Truncate(interval)
 }
@@ -63,6 +81,146 @@ func generateSineWaveSeries(name string, t time.Time, numSeries int) []prompb.Ti
 	return out
 }
 
+// newTenantSeriesGenerator returns a function generating numSeries series with random values. The generated
+// series labels and values only depend on the tenant ID and the timestamp, so the same tenant always gets
+// the same series and values, making multi-tenant runs reproducible.
+func newTenantSeriesGenerator(name, tenantID string, numSeries int) func(t time.Time) []prompb.TimeSeries {
+	hash := fnv.New64a()
+	_, _ = hash.Write([]byte(tenantID))
+	seed := int64(hash.Sum64())
+
+	// Generate the series labels once, so that they're stable over time.
+	rnd := rand.New(rand.NewSource(seed))
+	labels := make([][]prompb.Label, 0, numSeries)
+	for i := 0; i < numSeries; i++ {
+		labels = append(labels, []prompb.Label{
+			{Name: "__name__", Value: name},
+			{Name: "series_id", Value: strconv.Itoa(i)},
+			{Name: "tenant_seed", Value: strconv.FormatUint(rnd.Uint64(), 16)},
+		})
+	}
+
+	return func(t time.Time) []prompb.TimeSeries {
+		rnd := rand.New(rand.NewSource(seed ^ t.UnixMilli()))
+		out := make([]prompb.TimeSeries, 0, numSeries)
+
+		for i := 0; i < numSeries; i++ {
+			out = append(out, prompb.TimeSeries{
+				Labels: labels[i],
+				Samples: []prompb.Sample{{
+					Value:     rnd.Float64(),
+					Timestamp: t.UnixMilli(),
+				}},
+			})
+		}
+
+		return out
+	}
+}
+
+// withTimestampAlignment wraps the input series generator so that the timestamp of the generated samples is
+// snapped to the nearest multiple of interval, the same way Prometheus aligns scrape timestamps. The values
+// are generated for the aligned timestamp, so the samples read back from Mimir are expected to have exactly
+// the aligned timestamps and the values generated for them. Alignment is disabled if interval is <= 0.
+func withTimestampAlignment(generate func(t time.Time) []prompb.TimeSeries, interval time.Duration) func(t time.Time) []prompb.TimeSeries {
+	return func(t time.Time) []prompb.TimeSeries {
+		return generate(snapTimestampToInterval(t, interval))
+	}
+}
+
+// snapTimestampToInterval returns the input timestamp rounded to the nearest multiple of interval, or the
+// timestamp itself if interval is <= 0. Halfway values are rounded up.
+func snapTimestampToInterval(ts time.Time, interval time.Duration) time.Time {
+	if interval <= 0 {
+		return ts
+	}
+	return ts.Round(interval)
+}
+
+// sortSeriesLabels returns the input series with labels sorted by name. The input series are not
+// modified: series whose labels are not sorted are copied.
+func sortSeriesLabels(series []prompb.TimeSeries) []prompb.TimeSeries {
+	var out []prompb.TimeSeries
+
+	for i, s := range series {
+		if sort.SliceIsSorted(s.Labels, func(a, b int) bool { return s.Labels[a].Name < s.Labels[b].Name }) {
+			continue
+		}
+
+		// Copy the input series on the first unsorted one.
+		if out == nil {
+			out = make([]prompb.TimeSeries, len(series))
+			copy(out, series)
+		}
+
+		lbls := make([]prompb.Label, len(s.Labels))
+		copy(lbls, s.Labels)
+		sort.Slice(lbls, func(a, b int) bool { return lbls[a].Name < lbls[b].Name })
+		out[i].Labels = lbls
+	}
+
+	if out == nil {
+		return series
+	}
+	return out
+}
+
+// withValuePrecision wraps the input series generator so that the generated sample values are rounded to
+// the input number of decimal places, making the values exactly representable in the JSON-encoded query
+// results and the read-back comparison reliable. The verifiers compare values with a tolerance of
+// maxComparisonDelta, which is way smaller than the rounding error with few decimal places: the values
+// expected by verifiers must be rounded the same way (see quantizeValue). Rounding is disabled if
+// decimals is < 0.
+func withValuePrecision(generate func(t time.Time) []prompb.TimeSeries, decimals int) func(t time.Time) []prompb.TimeSeries {
+	return func(t time.Time) []prompb.TimeSeries {
+		series := generate(t)
+		for _, s := range series {
+			for i := range s.Samples {
+				s.Samples[i].Value = quantizeValue(s.Samples[i].Value, decimals)
+			}
+		}
+		return series
+	}
+}
+
+// quantizeValue returns the input value rounded to the input number of decimal places, or the value
+// itself if decimals is < 0.
+func quantizeValue(value float64, decimals int) float64 {
+	if decimals < 0 {
+		return value
+	}
+
+	factor := math.Pow10(decimals)
+	return math.Round(value*factor) / factor
+}
+
+// withSampleTimestamps returns a copy of the input series with the timestamp of each sample set to the one
+// returned by the input function, given the index of the series and of the sample within the series.
+func withSampleTimestamps(series []prompb.TimeSeries, timestampFunc func(seriesIndex, sampleIndex int) int64) []prompb.TimeSeries {
+	out := make([]prompb.TimeSeries, len(series))
+
+	for i, s := range series {
+		samples := make([]prompb.Sample, len(s.Samples))
+		for j, sample := range s.Samples {
+			samples[j] = prompb.Sample{Value: sample.Value, Timestamp: timestampFunc(i, j)}
+		}
+
+		out[i] = s
+		out[i].Samples = samples
+	}
+
+	return out
+}
+
+// shuffleSeriesLabels shuffles the order of labels of each input series in place.
+func shuffleSeriesLabels(series []prompb.TimeSeries, rnd *rand.Rand) {
+	for _, s := range series {
+		rnd.Shuffle(len(s.Labels), func(i, j int) {
+			s.Labels[i], s.Labels[j] = s.Labels[j], s.Labels[i]
+		})
+	}
+}
+
 func generateSineWaveValue(t time.Time) float64 {
 	period := 10 * time.Minute
 	radians := 2 * math.Pi * float64(t.UnixNano()) / float64(period.Nanoseconds())
@@ -71,21 +229,47 @@ func generateSineWaveValue(t time.Time) float64 {
 
 // verifySineWaveSamplesSum assumes the input matrix is the result of a range query summing the values
 // of expectedSeries sine wave series and checks whether the actual values match the expected ones.
-// Returns error if values don't match.
-func verifySineWaveSamplesSum(matrix model.Matrix, expectedSeries int, expectedStep time.Duration) error {
+// Returns error if values don't match. A result with no data is verified according to the input policy.
+func verifySineWaveSamplesSum(matrix model.Matrix, expectedSeries int, expectedStep time.Duration, emptyResult emptyResultPolicy) error {
+	return verifySamplesSum(matrix, expectedStep, emptyResult, 0, func(ts time.Time) float64 {
+		return generateSineWaveValue(ts) * float64(expectedSeries)
+	})
+}
+
+// verifySamplesSum assumes the input matrix is the result of a range query summing the values of the
+// written series and checks whether the actual values match the ones returned by expectedSum for each
+// timestamp, with no gaps. If maxPoints is > 0, the values are only checked for up to maxPoints samples picked
+// by downsampleMatrix, to limit the cost of computing the expected values on long ranges, while gaps are
+// still checked for all samples. A result with no data is verified according to the input policy.
+func verifySamplesSum(matrix model.Matrix, expectedStep time.Duration, emptyResult emptyResultPolicy, maxPoints int, expectedSum func(ts time.Time) float64) error {
+	if err := verifyEmptyResult(matrix, emptyResult); err != nil || emptyResult == emptyResultExpected {
+		return err
+	}
+
 	if len(matrix) != 1 {
 		return fmt.Errorf("expected 1 series in the result but got %d", len(matrix))
 	}
 
 	samples := matrix[0].Values
 
+	// The timestamps of the samples whose value is checked. If nil, all samples are checked.
+	var checked map[model.Time]struct{}
+	if maxPoints > 0 {
+		checked = map[model.Time]struct{}{}
+		for _, sample := range downsampleMatrix(matrix, maxPoints)[0].Values {
+			checked[sample.Timestamp] = struct{}{}
+		}
+	}
+
 	for idx, sample := range samples {
 		ts := time.UnixMilli(int64(sample.Timestamp)).UTC()
 
 		// Assert on value.
-		expectedValue := generateSineWaveValue(ts)
-		if !compareSampleValues(float64(sample.Value), expectedValue*float64(expectedSeries)) {
-			return fmt.Errorf("sample at timestamp %d (%s) has value %f while was expecting %f", sample.Timestamp, ts.String(), sample.Value, expectedValue)
+		if _, ok := checked[sample.Timestamp]; ok || checked == nil {
+			expectedValue := expectedSum(ts)
+			if !compareSampleValues(float64(sample.Value), expectedValue) {
+				return fmt.Errorf("sample at timestamp %d (%s) has value %f while was expecting %f", sample.Timestamp, ts.String(), sample.Value, expectedValue)
+			}
 		}
 
 		// Assert on sample timestamp. We expect no gaps.
@@ -103,6 +287,79 @@ func verifySineWaveSamplesSum(matrix model.Matrix, expectedSeries int, expectedS
 	return nil
 }
 
+// verifyEmptyResult checks whether the input matrix has data according to the input policy. Returns
+// ErrNoData if the matrix has no data but data was expected.
+func verifyEmptyResult(matrix model.Matrix, policy emptyResultPolicy) error {
+	numSamples := 0
+	for _, stream := range matrix {
+		numSamples += len(stream.Values)
+	}
+
+	switch {
+	case policy == emptyResultFails && numSamples == 0:
+		return ErrNoData
+	case policy == emptyResultExpected && numSamples > 0:
+		return fmt.Errorf("expected no data in the result but got %d series with %d samples", len(matrix), numSamples)
+	default:
+		return nil
+	}
+}
+
+// downsampleMatrix returns a copy of the input matrix where each series has at most the input number of
+// points, picking the samples closest to evenly spaced timestamps. The first and last sample of each
+// series are always preserved, so the number of points is at least 2.
+func downsampleMatrix(matrix model.Matrix, points int) model.Matrix {
+	out := make(model.Matrix, 0, len(matrix))
+	for _, stream := range matrix {
+		out = append(out, &model.SampleStream{
+			Metric: stream.Metric,
+			Values: downsampleSamples(stream.Values, points),
+		})
+	}
+	return out
+}
+
+func downsampleSamples(samples []model.SamplePair, points int) []model.SamplePair {
+	if points < 2 {
+		points = 2
+	}
+	if len(samples) <= points {
+		return samples
+	}
+
+	var (
+		first = samples[0].Timestamp
+		last  = samples[len(samples)-1].Timestamp
+		out   = make([]model.SamplePair, 0, points)
+		idx   = 0
+	)
+
+	for i := 0; i < points; i++ {
+		target := first + model.Time(int64(last-first)*int64(i)/int64(points-1))
+
+		// Move forward as far as the next sample is closer to the target timestamp.
+		for idx+1 < len(samples) && timeDistance(samples[idx+1].Timestamp, target) <= timeDistance(samples[idx].Timestamp, target) {
+			idx++
+		}
+
+		// Do not pick the same sample twice.
+		if len(out) > 0 && out[len(out)-1].Timestamp == samples[idx].Timestamp {
+			continue
+		}
+
+		out = append(out, samples[idx])
+	}
+
+	return out
+}
+
+func timeDistance(first, second model.Time) model.Time {
+	if first > second {
+		return first - second
+	}
+	return second - first
+}
+
 func compareSampleValues(actual, expected float64) bool {
 	delta := math.Abs((actual - expected) / maxComparisonDelta)
 	return delta < maxComparisonDelta
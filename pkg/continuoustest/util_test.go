@@ -3,10 +3,13 @@
 package continuoustest
 
 import (
+	"math/rand"
+	"strconv"
 	"testing"
 	"time"
 
 	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/prompb"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -53,6 +56,7 @@ func TestVerifySineWaveSamplesSums(t *testing.T) {
 
 	tests := map[string]struct {
 		samples        []model.SamplePair
+		emptyResult    emptyResultPolicy
 		expectedSeries int
 		expectedStep   time.Duration
 		expectedErr    string
@@ -96,12 +100,35 @@ func TestVerifySineWaveSamplesSums(t *testing.T) {
 			expectedStep:   10 * time.Second,
 			expectedErr:    "sample at timestamp .* was expected to have timestamp .*",
 		},
+		"should return error if there's no data and data is expected": {
+			samples:        nil,
+			emptyResult:    emptyResultFails,
+			expectedSeries: 5,
+			expectedStep:   10 * time.Second,
+			expectedErr:    ErrNoData.Error(),
+		},
+		"should return no error if there's no data and no data is expected": {
+			samples:        nil,
+			emptyResult:    emptyResultExpected,
+			expectedSeries: 5,
+			expectedStep:   10 * time.Second,
+			expectedErr:    "",
+		},
+		"should return error if there's data and no data is expected": {
+			samples: []model.SamplePair{
+				newSamplePair(now.Add(10*time.Second), 5*generateSineWaveValue(now.Add(10*time.Second))),
+			},
+			emptyResult:    emptyResultExpected,
+			expectedSeries: 5,
+			expectedStep:   10 * time.Second,
+			expectedErr:    "expected no data in the result but got 1 series with 1 samples",
+		},
 	}
 
 	for testName, testData := range tests {
 		t.Run(testName, func(t *testing.T) {
 			matrix := model.Matrix{{Values: testData.samples}}
-			actual := verifySineWaveSamplesSum(matrix, testData.expectedSeries, testData.expectedStep)
+			actual := verifySineWaveSamplesSum(matrix, testData.expectedSeries, testData.expectedStep, testData.emptyResult)
 			if testData.expectedErr == "" {
 				assert.NoError(t, actual)
 			} else {
@@ -112,6 +139,145 @@ func TestVerifySineWaveSamplesSums(t *testing.T) {
 	}
 }
 
+func TestVerifyEmptyResult(t *testing.T) {
+	nonEmpty := model.Matrix{{Values: []model.SamplePair{newSamplePair(time.Unix(10, 0), 1)}}}
+
+	t.Run("expect data", func(t *testing.T) {
+		assert.ErrorIs(t, verifyEmptyResult(nil, emptyResultFails), ErrNoData)
+		assert.ErrorIs(t, verifyEmptyResult(model.Matrix{}, emptyResultFails), ErrNoData)
+		assert.ErrorIs(t, verifyEmptyResult(model.Matrix{{Metric: model.Metric{"__name__": "test"}}}, emptyResultFails), ErrNoData)
+		assert.NoError(t, verifyEmptyResult(nonEmpty, emptyResultFails))
+	})
+
+	t.Run("expect empty", func(t *testing.T) {
+		assert.NoError(t, verifyEmptyResult(nil, emptyResultExpected))
+		assert.NoError(t, verifyEmptyResult(model.Matrix{}, emptyResultExpected))
+		assert.NoError(t, verifyEmptyResult(model.Matrix{{Metric: model.Metric{"__name__": "test"}}}, emptyResultExpected))
+
+		err := verifyEmptyResult(nonEmpty, emptyResultExpected)
+		require.Error(t, err)
+		assert.NotErrorIs(t, err, ErrNoData)
+	})
+}
+
+func TestVerifySamplesSum_ShouldOnlyCheckValuesOfDownsampledPoints(t *testing.T) {
+	const step = 10 * time.Second
+	now := time.Unix(1000, 0)
+
+	newMatrix := func(numSamples int, update func(idx int, sample *model.SamplePair)) model.Matrix {
+		samples := make([]model.SamplePair, 0, numSamples)
+		for i := 0; i < numSamples; i++ {
+			ts := now.Add(time.Duration(i) * step)
+			sample := newSamplePair(ts, generateSineWaveValue(ts))
+			update(i, &sample)
+			samples = append(samples, sample)
+		}
+		return model.Matrix{{Values: samples}}
+	}
+
+	checked := 0
+	expectedSum := func(ts time.Time) float64 {
+		checked++
+		return generateSineWaveValue(ts)
+	}
+
+	// A mismatching value of a point not picked by downsampling is not detected.
+	matrix := newMatrix(100, func(idx int, sample *model.SamplePair) {
+		if idx == 1 {
+			sample.Value = 12345
+		}
+	})
+	require.NoError(t, verifySamplesSum(matrix, step, emptyResultFails, 5, expectedSum))
+	assert.Equal(t, 5, checked)
+
+	// A mismatching value of the first or last point is always detected.
+	for _, mismatchIdx := range []int{0, 99} {
+		mismatchIdx := mismatchIdx
+		matrix = newMatrix(100, func(idx int, sample *model.SamplePair) {
+			if idx == mismatchIdx {
+				sample.Value = 12345
+			}
+		})
+		assert.Error(t, verifySamplesSum(matrix, step, emptyResultFails, 5, expectedSum))
+	}
+
+	// Gaps are detected even between points not picked by downsampling.
+	matrix = newMatrix(100, func(idx int, sample *model.SamplePair) {
+		if idx >= 2 {
+			ts := now.Add(time.Duration(idx+1) * step)
+			*sample = newSamplePair(ts, generateSineWaveValue(ts))
+		}
+	})
+	err := verifySamplesSum(matrix, step, emptyResultFails, 5, expectedSum)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "was expected to have timestamp")
+}
+
+func TestDownsampleMatrix(t *testing.T) {
+	// Generate a dense series with 1 sample every 10s over 1h.
+	dense := make([]model.SamplePair, 0, 361)
+	for ts := 0; ts <= 3600; ts += 10 {
+		dense = append(dense, newSamplePair(time.Unix(int64(ts), 0), float64(ts)))
+	}
+
+	tests := map[string]struct {
+		input    []model.SamplePair
+		points   int
+		expected []model.SamplePair
+	}{
+		"should downsample a dense series to the requested number of points": {
+			input:  dense,
+			points: 5,
+			expected: []model.SamplePair{
+				newSamplePair(time.Unix(0, 0), 0),
+				newSamplePair(time.Unix(900, 0), 900),
+				newSamplePair(time.Unix(1800, 0), 1800),
+				newSamplePair(time.Unix(2700, 0), 2700),
+				newSamplePair(time.Unix(3600, 0), 3600),
+			},
+		},
+		"should pick the nearest samples to evenly spaced timestamps": {
+			input: []model.SamplePair{
+				newSamplePair(time.Unix(0, 0), 0),
+				newSamplePair(time.Unix(10, 0), 10),
+				newSamplePair(time.Unix(48, 0), 48),
+				newSamplePair(time.Unix(90, 0), 90),
+				newSamplePair(time.Unix(100, 0), 100),
+			},
+			points: 3,
+			expected: []model.SamplePair{
+				newSamplePair(time.Unix(0, 0), 0),
+				newSamplePair(time.Unix(48, 0), 48),
+				newSamplePair(time.Unix(100, 0), 100),
+			},
+		},
+		"should always preserve the first and last points": {
+			input:  dense,
+			points: 1,
+			expected: []model.SamplePair{
+				newSamplePair(time.Unix(0, 0), 0),
+				newSamplePair(time.Unix(3600, 0), 3600),
+			},
+		},
+		"should return the input series if it has no more than the requested number of points": {
+			input:    dense[:3],
+			points:   5,
+			expected: dense[:3],
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			input := model.Matrix{{Metric: model.Metric{"__name__": "test"}, Values: testData.input}}
+
+			actual := downsampleMatrix(input, testData.points)
+			require.Len(t, actual, 1)
+			assert.Equal(t, model.Metric{"__name__": "test"}, actual[0].Metric)
+			assert.Equal(t, testData.expected, actual[0].Values)
+		})
+	}
+}
+
 func TestMinTime(t *testing.T) {
 	first := time.Now()
 	second := first.Add(time.Second)
@@ -139,6 +305,217 @@ func TestRandTime(t *testing.T) {
 	}
 }
 
+func TestNewTenantSeriesGenerator(t *testing.T) {
+	now := time.Now()
+
+	first := newTenantSeriesGenerator("test", "tenant-1", 10)
+	second := newTenantSeriesGenerator("test", "tenant-1", 10)
+	other := newTenantSeriesGenerator("test", "tenant-2", 10)
+
+	// Generators for the same tenant should produce the same series and values.
+	assert.Equal(t, first(now), second(now))
+	assert.Equal(t, first(now.Add(time.Minute)), second(now.Add(time.Minute)))
+
+	// The output should not depend on the previous calls.
+	assert.Equal(t, first(now), second(now))
+
+	// Generators for different tenants should produce different series and values.
+	assert.NotEqual(t, first(now)[0].Labels, other(now)[0].Labels)
+	assert.NotEqual(t, first(now)[0].Samples, other(now)[0].Samples)
+
+	// The series should be stable over time, while values change.
+	assert.Equal(t, first(now)[0].Labels, first(now.Add(time.Minute))[0].Labels)
+	assert.NotEqual(t, first(now)[0].Samples[0].Value, first(now.Add(time.Minute))[0].Samples[0].Value)
+}
+
+func TestSnapTimestampToInterval(t *testing.T) {
+	tests := map[string]struct {
+		ts       time.Time
+		interval time.Duration
+		expected time.Time
+	}{
+		"should keep a timestamp already aligned": {
+			ts:       time.Unix(30, 0),
+			interval: 15 * time.Second,
+			expected: time.Unix(30, 0),
+		},
+		"should round down a timestamp closer to the previous boundary": {
+			ts:       time.Unix(37, 499*int64(time.Millisecond)),
+			interval: 15 * time.Second,
+			expected: time.Unix(30, 0),
+		},
+		"should round up a timestamp closer to the next boundary": {
+			ts:       time.Unix(38, 0),
+			interval: 15 * time.Second,
+			expected: time.Unix(45, 0),
+		},
+		"should round up a timestamp halfway between two boundaries": {
+			ts:       time.Unix(37, 500*int64(time.Millisecond)),
+			interval: 15 * time.Second,
+			expected: time.Unix(45, 0),
+		},
+		"should keep the timestamp if the interval is 0": {
+			ts:       time.Unix(37, 123*int64(time.Millisecond)),
+			interval: 0,
+			expected: time.Unix(37, 123*int64(time.Millisecond)),
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			assert.Equal(t, testData.expected.UnixMilli(), snapTimestampToInterval(testData.ts, testData.interval).UnixMilli())
+		})
+	}
+}
+
+func TestWithTimestampAlignment(t *testing.T) {
+	generate := withTimestampAlignment(func(t time.Time) []prompb.TimeSeries {
+		return generateSineWaveSeries("test", t, 2)
+	}, 15*time.Second)
+
+	for _, ts := range []time.Time{time.Unix(1000, 0), time.Unix(1003, 0), time.Unix(1007, 999*int64(time.Millisecond)), time.Unix(1014, 0)} {
+		aligned := snapTimestampToInterval(ts, 15*time.Second)
+
+		// The generated samples are expected to match the ones generated for the aligned timestamp.
+		actual := generate(ts)
+		assert.Equal(t, generateSineWaveSeries("test", aligned, 2), actual)
+
+		for _, series := range actual {
+			for _, sample := range series.Samples {
+				assert.Zero(t, sample.Timestamp%(15*time.Second).Milliseconds())
+			}
+		}
+	}
+}
+
+func TestQuantizeValue(t *testing.T) {
+	tests := map[string]struct {
+		value    float64
+		decimals int
+		expected float64
+	}{
+		"disabled": {
+			value:    0.123456789,
+			decimals: -1,
+			expected: 0.123456789,
+		},
+		"zero decimals": {
+			value:    2.5,
+			decimals: 0,
+			expected: 3,
+		},
+		"round down": {
+			value:    0.123456789,
+			decimals: 3,
+			expected: 0.123,
+		},
+		"round up": {
+			value:    0.98765,
+			decimals: 2,
+			expected: 0.99,
+		},
+		"negative value": {
+			value:    -0.98765,
+			decimals: 2,
+			expected: -0.99,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			assert.Equal(t, testData.expected, quantizeValue(testData.value, testData.decimals))
+		})
+	}
+}
+
+func TestWithValuePrecision(t *testing.T) {
+	const decimals = 3
+
+	generate := withValuePrecision(func(t time.Time) []prompb.TimeSeries {
+		return generateSineWaveSeries("test", t, 2)
+	}, decimals)
+
+	for _, ts := range []time.Time{time.Unix(1000, 0), time.Unix(1003, 0), time.Unix(1007, 999*int64(time.Millisecond))} {
+		actual := generate(ts)
+		require.Len(t, actual, 2)
+
+		for _, series := range actual {
+			for _, sample := range series.Samples {
+				assert.Equal(t, quantizeValue(generateSineWaveValue(ts), decimals), sample.Value)
+
+				// The value formatted with the configured precision must round-trip exactly.
+				parsed, err := strconv.ParseFloat(strconv.FormatFloat(sample.Value, 'f', decimals, 64), 64)
+				require.NoError(t, err)
+				assert.Equal(t, sample.Value, parsed)
+			}
+		}
+	}
+}
+
+func TestShuffleSeriesLabels(t *testing.T) {
+	series := generateSineWaveSeries("test", time.Now(), 10)
+	for i := range series {
+		series[i].Labels = append(series[i].Labels,
+			prompb.Label{Name: "cluster", Value: "test"},
+			prompb.Label{Name: "namespace", Value: "test"},
+			prompb.Label{Name: "pod", Value: "test"})
+	}
+
+	original := make([][]prompb.Label, 0, len(series))
+	for _, s := range series {
+		original = append(original, append([]prompb.Label{}, s.Labels...))
+	}
+
+	shuffleSeriesLabels(series, rand.New(rand.NewSource(1)))
+
+	shuffled := 0
+	for i, s := range series {
+		if !assert.ObjectsAreEqual(original[i], s.Labels) {
+			shuffled++
+		}
+
+		// The set of labels must not change.
+		assert.ElementsMatch(t, original[i], s.Labels)
+		for _, l := range s.Labels {
+			assert.True(t, model.LabelName(l.Name).IsValid())
+		}
+	}
+
+	assert.Greater(t, shuffled, 0)
+
+	// The shuffling must be deterministic given the seed.
+	other := generateSineWaveSeries("test", time.Now(), 10)
+	for i := range other {
+		other[i].Labels = append([]prompb.Label{}, original[i]...)
+	}
+	shuffleSeriesLabels(other, rand.New(rand.NewSource(1)))
+
+	for i := range series {
+		assert.Equal(t, series[i].Labels, other[i].Labels)
+	}
+}
+
+func TestSortSeriesLabels(t *testing.T) {
+	sorted := []prompb.Label{{Name: "__name__", Value: "test"}, {Name: "a", Value: "1"}, {Name: "b", Value: "2"}}
+	unsorted := []prompb.Label{{Name: "b", Value: "2"}, {Name: "__name__", Value: "test"}, {Name: "a", Value: "1"}}
+
+	t.Run("should return the input series if labels are already sorted", func(t *testing.T) {
+		series := []prompb.TimeSeries{{Labels: sorted}}
+		actual := sortSeriesLabels(series)
+		assert.Equal(t, series, actual)
+		assert.Same(t, &series[0], &actual[0])
+	})
+
+	t.Run("should sort labels without modifying the input series", func(t *testing.T) {
+		input := append([]prompb.Label{}, unsorted...)
+		series := []prompb.TimeSeries{{Labels: sorted}, {Labels: input}}
+
+		actual := sortSeriesLabels(series)
+		assert.Equal(t, []prompb.TimeSeries{{Labels: sorted}, {Labels: sorted}}, actual)
+		assert.Equal(t, unsorted, series[1].Labels)
+	})
+}
+
 func newSamplePair(ts time.Time, value float64) model.SamplePair {
 	return model.SamplePair{
 		Timestamp: model.Time(ts.UnixMilli()),
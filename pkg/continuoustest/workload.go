@@ -0,0 +1,123 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// workloadMetric is a metric of a workload profile. A series is generated for each label set.
+type workloadMetric struct {
+	name      string
+	counter   bool
+	labelSets [][]prompb.Label
+}
+
+// nodeExporterProfile is a subset of the metrics exposed by node_exporter on a Linux host, with
+// the same label sets and cardinality of a host with 4 CPUs, 2 filesystems and 2 network devices.
+var nodeExporterProfile = func() []workloadMetric {
+	var cpuLabelSets [][]prompb.Label
+	for cpu := 0; cpu < 4; cpu++ {
+		for _, mode := range []string{"idle", "iowait", "irq", "nice", "softirq", "steal", "system", "user"} {
+			cpuLabelSets = append(cpuLabelSets, []prompb.Label{{Name: "cpu", Value: strconv.Itoa(cpu)}, {Name: "mode", Value: mode}})
+		}
+	}
+
+	filesystemLabelSets := [][]prompb.Label{
+		{{Name: "device", Value: "/dev/sda1"}, {Name: "fstype", Value: "ext4"}, {Name: "mountpoint", Value: "/"}},
+		{{Name: "device", Value: "tmpfs"}, {Name: "fstype", Value: "tmpfs"}, {Name: "mountpoint", Value: "/run"}},
+	}
+
+	networkLabelSets := [][]prompb.Label{
+		{{Name: "device", Value: "eth0"}},
+		{{Name: "device", Value: "lo"}},
+	}
+
+	noLabels := [][]prompb.Label{nil}
+
+	return []workloadMetric{
+		{name: "node_cpu_seconds_total", counter: true, labelSets: cpuLabelSets},
+		{name: "node_memory_MemTotal_bytes", labelSets: noLabels},
+		{name: "node_memory_MemFree_bytes", labelSets: noLabels},
+		{name: "node_memory_MemAvailable_bytes", labelSets: noLabels},
+		{name: "node_filesystem_size_bytes", labelSets: filesystemLabelSets},
+		{name: "node_filesystem_avail_bytes", labelSets: filesystemLabelSets},
+		{name: "node_network_receive_bytes_total", counter: true, labelSets: networkLabelSets},
+		{name: "node_network_transmit_bytes_total", counter: true, labelSets: networkLabelSets},
+		{name: "node_load1", labelSets: noLabels},
+		{name: "node_load5", labelSets: noLabels},
+		{name: "node_load15", labelSets: noLabels},
+	}
+}()
+
+// generateNodeExporterSeries returns the series exposed by numInstances node_exporter instances, with
+// a sample at the input timestamp. Counters monotonically increase over time, while gauges oscillate.
+// The values only depend on the timestamp and the series, so that they can be verified when read back.
+func generateNodeExporterSeries(t time.Time, numInstances int) []prompb.TimeSeries {
+	return generateWorkloadSeries(nodeExporterProfile, "node_exporter", t, numInstances)
+}
+
+func generateWorkloadSeries(profile []workloadMetric, job string, t time.Time, numInstances int) []prompb.TimeSeries {
+	var out []prompb.TimeSeries
+
+	for i := 0; i < numInstances; i++ {
+		instance := fmt.Sprintf("instance-%d:9100", i)
+
+		for _, metric := range profile {
+			for j, labelSet := range metric.labelSets {
+				lbls := make([]prompb.Label, 0, len(labelSet)+3)
+				lbls = append(lbls, prompb.Label{Name: "__name__", Value: metric.name})
+				lbls = append(lbls, prompb.Label{Name: "instance", Value: instance})
+				lbls = append(lbls, prompb.Label{Name: "job", Value: job})
+				lbls = append(lbls, labelSet...)
+				sort.Slice(lbls, func(a, b int) bool { return lbls[a].Name < lbls[b].Name })
+
+				// Each series grows at a different rate, or oscillates with a different offset.
+				factor := float64(i + j + 1)
+				value := factor * (1 + math.Sin(float64(t.Unix())/600))
+				if metric.counter {
+					value = factor * float64(t.Unix())
+				}
+
+				out = append(out, prompb.TimeSeries{
+					Labels:  lbls,
+					Samples: []prompb.Sample{{Value: value, Timestamp: t.UnixMilli()}},
+				})
+			}
+		}
+	}
+
+	return out
+}
+
+// generateMultiMetricSeries returns numSeries series for each of the input metric names, all sharing the
+// labels of the input template (e.g. instance labels), so that a single batch spans many metric names as
+// in real workloads. Each series has a sample at the input timestamp, with the same value of the series
+// generated by generateSineWaveSeries.
+func generateMultiMetricSeries(names []string, labelTemplate []prompb.Label, t time.Time, numSeries int) []prompb.TimeSeries {
+	out := make([]prompb.TimeSeries, 0, len(names)*numSeries)
+	value := generateSineWaveValue(t)
+
+	for _, name := range names {
+		for i := 0; i < numSeries; i++ {
+			lbls := make([]prompb.Label, 0, len(labelTemplate)+2)
+			lbls = append(lbls, prompb.Label{Name: "__name__", Value: name})
+			lbls = append(lbls, labelTemplate...)
+			lbls = append(lbls, prompb.Label{Name: "series_id", Value: strconv.Itoa(i)})
+			sort.Slice(lbls, func(a, b int) bool { return lbls[a].Name < lbls[b].Name })
+
+			out = append(out, prompb.TimeSeries{
+				Labels:  lbls,
+				Samples: []prompb.Sample{{Value: value, Timestamp: t.UnixMilli()}},
+			})
+		}
+	}
+
+	return out
+}
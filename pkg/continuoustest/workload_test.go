@@ -0,0 +1,119 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateNodeExporterSeries(t *testing.T) {
+	const seriesPerInstance = 32 + 3 + 4 + 4 + 3
+
+	now := time.Now()
+
+	for _, numInstances := range []int{0, 1, 3} {
+		series := generateNodeExporterSeries(now, numInstances)
+		require.Len(t, series, numInstances*seriesPerInstance)
+
+		metricNames := map[string]struct{}{}
+		instances := map[string]struct{}{}
+		uniqueSeries := map[string]struct{}{}
+
+		for _, s := range series {
+			require.Len(t, s.Samples, 1)
+			assert.Equal(t, now.UnixMilli(), s.Samples[0].Timestamp)
+
+			// Labels are expected to be sorted by name.
+			assert.True(t, sort.SliceIsSorted(s.Labels, func(i, j int) bool { return s.Labels[i].Name < s.Labels[j].Name }))
+
+			key := ""
+			for _, l := range s.Labels {
+				key += l.Name + "=" + l.Value + ","
+
+				switch l.Name {
+				case "__name__":
+					metricNames[l.Value] = struct{}{}
+				case "instance":
+					instances[l.Value] = struct{}{}
+				case "job":
+					assert.Equal(t, "node_exporter", l.Value)
+				}
+			}
+			uniqueSeries[key] = struct{}{}
+		}
+
+		assert.Len(t, uniqueSeries, len(series))
+		assert.Len(t, instances, numInstances)
+
+		if numInstances > 0 {
+			assert.Equal(t, map[string]struct{}{
+				"node_cpu_seconds_total":            {},
+				"node_memory_MemTotal_bytes":        {},
+				"node_memory_MemFree_bytes":         {},
+				"node_memory_MemAvailable_bytes":    {},
+				"node_filesystem_size_bytes":        {},
+				"node_filesystem_avail_bytes":       {},
+				"node_network_receive_bytes_total":  {},
+				"node_network_transmit_bytes_total": {},
+				"node_load1":                        {},
+				"node_load5":                        {},
+				"node_load15":                       {},
+			}, metricNames)
+		}
+	}
+}
+
+func TestGenerateNodeExporterSeries_ShouldGenerateMonotonicCounters(t *testing.T) {
+	now := time.Now()
+	first := generateNodeExporterSeries(now, 2)
+	second := generateNodeExporterSeries(now.Add(time.Minute), 2)
+	require.Equal(t, len(first), len(second))
+
+	for i := range first {
+		assert.Equal(t, first[i].Labels, second[i].Labels)
+
+		for _, l := range first[i].Labels {
+			if l.Name == "__name__" && (l.Value == "node_cpu_seconds_total" || l.Value == "node_network_receive_bytes_total") {
+				assert.Greater(t, second[i].Samples[0].Value, first[i].Samples[0].Value)
+			}
+		}
+	}
+}
+
+func TestGenerateMultiMetricSeries(t *testing.T) {
+	now := time.Now()
+	names := []string{"metric_1", "metric_2", "metric_3"}
+	template := []prompb.Label{{Name: "job", Value: "test"}, {Name: "instance", Value: "instance-1"}}
+
+	series := generateMultiMetricSeries(names, template, now, 2)
+	require.Len(t, series, len(names)*2)
+
+	seriesPerMetric := map[string]int{}
+
+	for _, s := range series {
+		require.Len(t, s.Samples, 1)
+		assert.Equal(t, now.UnixMilli(), s.Samples[0].Timestamp)
+		assert.Equal(t, generateSineWaveValue(now), s.Samples[0].Value)
+
+		// Labels are expected to be sorted by name, and to include the template labels.
+		assert.True(t, sort.SliceIsSorted(s.Labels, func(i, j int) bool { return s.Labels[i].Name < s.Labels[j].Name }))
+		assert.Subset(t, s.Labels, template)
+
+		for _, l := range s.Labels {
+			if l.Name == "__name__" {
+				seriesPerMetric[l.Value]++
+			}
+		}
+	}
+
+	assert.Equal(t, map[string]int{"metric_1": 2, "metric_2": 2, "metric_3": 2}, seriesPerMetric)
+
+	// The input template should not be modified.
+	assert.Equal(t, []prompb.Label{{Name: "job", Value: "test"}, {Name: "instance", Value: "instance-1"}}, template)
+}
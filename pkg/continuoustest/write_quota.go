@@ -0,0 +1,36 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// writeQuotaRemainingHeaders are the response headers reporting the remaining write quota, in order
+// of precedence. Mimir doesn't return them, but they may be set by a gateway or proxy in front of it.
+var writeQuotaRemainingHeaders = []string{
+	"X-RateLimit-Remaining",
+	"X-Mimir-RateLimit-Remaining",
+	"RateLimit-Remaining",
+}
+
+// parseWriteQuotaRemaining returns the remaining write quota reported by the input response headers.
+// Returns false if none of the supported headers is set to a valid value.
+func parseWriteQuotaRemaining(header http.Header) (float64, bool) {
+	for _, name := range writeQuotaRemainingHeaders {
+		value := strings.TrimSpace(header.Get(name))
+		if value == "" {
+			continue
+		}
+
+		remaining, err := strconv.ParseFloat(value, 64)
+		if err != nil || remaining < 0 {
+			continue
+		}
+		return remaining, true
+	}
+
+	return 0, false
+}
@@ -0,0 +1,111 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/flagext"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+)
+
+func TestClient_WriteSeries_ShouldTrackWriteQuotaRemaining(t *testing.T) {
+	remaining := atomic.NewString("")
+	statusCode := atomic.NewInt64(http.StatusOK)
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if value := remaining.Load(); value != "" {
+			writer.Header().Set("X-RateLimit-Remaining", value)
+		}
+		writer.WriteHeader(int(statusCode.Load()))
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := ClientConfig{}
+	flagext.DefaultValues(&cfg)
+	require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+	require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+	c, err := NewClient(cfg, log.NewNopLogger(), nil)
+	require.NoError(t, err)
+
+	series := generateSineWaveSeries("test", time.Now(), 1)
+
+	// The gauge is updated from the response headers.
+	remaining.Store("1500")
+	_, err = c.WriteSeries(context.Background(), series)
+	require.NoError(t, err)
+	assert.Equal(t, 1500.0, testutil.ToFloat64(c.metrics.writeQuotaLeft))
+
+	// The gauge is updated from rate limited responses too.
+	remaining.Store("0")
+	statusCode.Store(http.StatusTooManyRequests)
+	_, err = c.WriteSeries(context.Background(), series)
+	require.Error(t, err)
+	assert.Equal(t, 0.0, testutil.ToFloat64(c.metrics.writeQuotaLeft))
+
+	// The gauge is left unchanged when the response has no quota headers.
+	remaining.Store("")
+	statusCode.Store(http.StatusOK)
+	_, err = c.WriteSeries(context.Background(), series)
+	require.NoError(t, err)
+	assert.Equal(t, 0.0, testutil.ToFloat64(c.metrics.writeQuotaLeft))
+}
+
+func TestParseWriteQuotaRemaining(t *testing.T) {
+	tests := map[string]struct {
+		header        http.Header
+		expectedValue float64
+		expectedOK    bool
+	}{
+		"no headers": {
+			header:     http.Header{},
+			expectedOK: false,
+		},
+		"X-RateLimit-Remaining": {
+			header:        http.Header{"X-Ratelimit-Remaining": []string{"100"}},
+			expectedValue: 100,
+			expectedOK:    true,
+		},
+		"Mimir-specific header": {
+			header:        http.Header{"X-Mimir-Ratelimit-Remaining": []string{" 12.5 "}},
+			expectedValue: 12.5,
+			expectedOK:    true,
+		},
+		"RateLimit-Remaining": {
+			header:        http.Header{"Ratelimit-Remaining": []string{"7"}},
+			expectedValue: 7,
+			expectedOK:    true,
+		},
+		"X-RateLimit-Remaining takes precedence": {
+			header:        http.Header{"X-Ratelimit-Remaining": []string{"1"}, "Ratelimit-Remaining": []string{"2"}},
+			expectedValue: 1,
+			expectedOK:    true,
+		},
+		"invalid values are skipped": {
+			header:        http.Header{"X-Ratelimit-Remaining": []string{"many"}, "Ratelimit-Remaining": []string{"2"}},
+			expectedValue: 2,
+			expectedOK:    true,
+		},
+		"negative value": {
+			header:     http.Header{"X-Ratelimit-Remaining": []string{"-1"}},
+			expectedOK: false,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			value, ok := parseWriteQuotaRemaining(testData.header)
+			assert.Equal(t, testData.expectedOK, ok)
+			assert.Equal(t, testData.expectedValue, value)
+		})
+	}
+}
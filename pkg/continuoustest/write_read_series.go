@@ -6,12 +6,15 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"math/rand"
 	"strconv"
 	"time"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/prompb"
 )
 
 const (
@@ -20,13 +23,25 @@ const (
 )
 
 type WriteReadSeriesTestConfig struct {
-	NumSeries   int
-	MaxQueryAge time.Duration
+	NumSeries          int
+	MaxQueryAge        time.Duration
+	ShuffleLabels      bool
+	ShuffleLabelsSeed  int64
+	SeedTenantID       string
+	MaxVerifiedPoints  int
+	TimestampAlignment time.Duration
+	ValuePrecision     int
 }
 
 func (cfg *WriteReadSeriesTestConfig) RegisterFlags(f *flag.FlagSet) {
 	f.IntVar(&cfg.NumSeries, "tests.write-read-series-test.num-series", 10000, "Number of series used for the test.")
 	f.DurationVar(&cfg.MaxQueryAge, "tests.write-read-series-test.max-query-age", 7*24*time.Hour, "How back in the past metrics can be queried at most.")
+	f.BoolVar(&cfg.ShuffleLabels, "tests.write-read-series-test.shuffle-labels", false, "True to shuffle the order of labels of written series, to check Mimir treats series the same regardless of the labels order on the wire. The labels of the shuffled series are not sorted by the client, regardless of -tests.write-sort-labels.")
+	f.Int64Var(&cfg.ShuffleLabelsSeed, "tests.write-read-series-test.shuffle-labels-seed", 1, "The seed used to shuffle the order of labels of written series.")
+	f.IntVar(&cfg.MaxVerifiedPoints, "tests.write-read-series-test.max-verified-points", 0, "The max number of points, evenly spread over the query time range, whose value is checked for each range query. The first and last points are always checked, and gaps are checked for all points. 0 to check the value of all points.")
+	f.DurationVar(&cfg.TimestampAlignment, "tests.write-read-series-test.timestamp-alignment", 0, "If set, the timestamp of written samples is snapped to the nearest multiple of this interval, the same way Prometheus aligns scrape timestamps, and the values are generated for the aligned timestamp. The samples read back are expected to have the values generated for the aligned timestamps. Must be less than or equal to the write interval (20s). 0 to disable.")
+	f.IntVar(&cfg.ValuePrecision, "tests.write-read-series-test.value-precision", -1, "If >= 0, the values of written samples are rounded to this number of decimal places, so that they're exactly representable in the query results. The expected values are rounded the same way, and still compared with a small tolerance. -1 to disable.")
+	f.StringVar(&cfg.SeedTenantID, "tests.write-read-series-test.seed-tenant-id", "", "If set, the series are generated with random values seeded from this tenant ID instead of a sine wave, so that the same tenant ID always produces the same series and values. Usually set to the tenant the test writes to, to correlate the data expected to exist for each tenant.")
 }
 
 type WriteReadSeriesTest struct {
@@ -35,6 +50,10 @@ type WriteReadSeriesTest struct {
 	client  MimirClient
 	logger  log.Logger
 	metrics *TestMetrics
+	rnd     *rand.Rand
+
+	// generate returns the series to write at the input timestamp.
+	generate func(t time.Time) []prompb.TimeSeries
 
 	lastWrittenTimestamp time.Time
 	queryMinTime         time.Time
@@ -44,12 +63,27 @@ type WriteReadSeriesTest struct {
 func NewWriteReadSeriesTest(cfg WriteReadSeriesTestConfig, client MimirClient, logger log.Logger, reg prometheus.Registerer) *WriteReadSeriesTest {
 	const name = "write-read-series"
 
+	generate := func(t time.Time) []prompb.TimeSeries {
+		return generateSineWaveSeries(metricName, t, cfg.NumSeries)
+	}
+	if cfg.SeedTenantID != "" {
+		generate = newTenantSeriesGenerator(metricName, cfg.SeedTenantID, cfg.NumSeries)
+	}
+	if cfg.ValuePrecision >= 0 {
+		generate = withValuePrecision(generate, cfg.ValuePrecision)
+	}
+	if cfg.TimestampAlignment > 0 {
+		generate = withTimestampAlignment(generate, cfg.TimestampAlignment)
+	}
+
 	return &WriteReadSeriesTest{
-		name:    name,
-		cfg:     cfg,
-		client:  client,
-		logger:  log.With(logger, "test", name),
-		metrics: NewTestMetrics(name, reg),
+		name:     name,
+		cfg:      cfg,
+		client:   client,
+		logger:   log.With(logger, "test", name),
+		metrics:  NewTestMetrics(name, reg),
+		rnd:      rand.New(rand.NewSource(cfg.ShuffleLabelsSeed)),
+		generate: generate,
 	}
 }
 
@@ -60,15 +94,28 @@ func (t *WriteReadSeriesTest) Name() string {
 
 // Init implements Test.
 func (t *WriteReadSeriesTest) Init() error {
+	if t.cfg.TimestampAlignment < 0 || t.cfg.TimestampAlignment > writeInterval {
+		return errors.Errorf("the timestamp alignment must be between 0 and the write interval (%s)", writeInterval)
+	}
+
 	// TODO Here we should populate lastWrittenTimestamp, queryMinTime, queryMaxTime after querying Mimir to get data previously written.
 	return nil
 }
 
 // Run implements Test.
-func (t *WriteReadSeriesTest) Run(ctx context.Context, now time.Time) {
+func (t *WriteReadSeriesTest) Run(ctx context.Context, now time.Time) error {
+	var firstErr error
+
 	// Write series for each expected timestamp until now.
 	for timestamp := t.nextWriteTimestamp(now); !timestamp.After(now); timestamp = t.nextWriteTimestamp(now) {
-		statusCode, err := t.client.WriteSeries(ctx, generateSineWaveSeries(metricName, timestamp, t.cfg.NumSeries))
+		series := t.generate(timestamp)
+		writeCtx := ctx
+		if t.cfg.ShuffleLabels {
+			shuffleSeriesLabels(series, t.rnd)
+			writeCtx = withPreservedLabelsOrder(ctx)
+		}
+
+		statusCode, err := t.client.WriteSeries(writeCtx, series)
 
 		t.metrics.writesTotal.Inc()
 		if statusCode/100 != 2 {
@@ -83,6 +130,10 @@ func (t *WriteReadSeriesTest) Run(ctx context.Context, now time.Time) {
 		// We keep writing the next interval, but we reset the query timestamp because we can't reliably
 		// assert on query results due to possible gaps.
 		if statusCode/100 == 4 {
+			if firstErr == nil {
+				firstErr = newWriteSeriesError(statusCode, err)
+			}
+
 			t.lastWrittenTimestamp = timestamp
 			t.queryMinTime = time.Time{}
 			t.queryMaxTime = time.Time{}
@@ -92,6 +143,9 @@ func (t *WriteReadSeriesTest) Run(ctx context.Context, now time.Time) {
 		// If the write request failed because of a network or 5xx error, we'll retry to write series
 		// in the next test run.
 		if statusCode/100 != 2 || err != nil {
+			if firstErr == nil {
+				firstErr = newWriteSeriesError(statusCode, err)
+			}
 			break
 		}
 
@@ -104,8 +158,12 @@ func (t *WriteReadSeriesTest) Run(ctx context.Context, now time.Time) {
 	}
 
 	for _, timeRange := range t.getRangeQueryTimeRanges(now) {
-		t.runRangeQueryAndVerifyResult(ctx, timeRange[0], timeRange[1])
+		if err := t.runRangeQueryAndVerifyResult(ctx, timeRange[0], timeRange[1]); err != nil && firstErr == nil {
+			firstErr = err
+		}
 	}
+
+	return firstErr
 }
 
 // getRangeQueryTimeRanges returns the start/end time ranges to use to run test range queries.
@@ -154,13 +212,13 @@ func (t *WriteReadSeriesTest) getRangeQueryTimeRanges(now time.Time) (ranges [][
 	return ranges
 }
 
-func (t *WriteReadSeriesTest) runRangeQueryAndVerifyResult(ctx context.Context, start, end time.Time) {
+func (t *WriteReadSeriesTest) runRangeQueryAndVerifyResult(ctx context.Context, start, end time.Time) error {
 	// We align start, end and step to write interval in order to avoid any false positives
 	// when checking results correctness. The min/max query time is always aligned.
 	start = maxTime(t.queryMinTime, alignTimestampToInterval(start, writeInterval))
 	end = minTime(t.queryMaxTime, alignTimestampToInterval(end, writeInterval))
 	if end.Before(start) {
-		return
+		return nil
 	}
 
 	step := getQueryStep(start, end, writeInterval)
@@ -174,16 +232,42 @@ func (t *WriteReadSeriesTest) runRangeQueryAndVerifyResult(ctx context.Context,
 	if err != nil {
 		t.metrics.queriesFailedTotal.Inc()
 		level.Warn(logger).Log("msg", "Failed to execute range query", "err", err)
-		return
+		return errors.Wrap(err, "failed to execute range query")
 	}
 
 	t.metrics.queryResultChecksTotal.Inc()
-	err = verifySineWaveSamplesSum(matrix, t.cfg.NumSeries, step)
+	err = verifySamplesSum(matrix, step, emptyResultFails, t.cfg.MaxVerifiedPoints, t.expectedSum)
 	if err != nil {
 		t.metrics.queryResultChecksFailedTotal.Inc()
 		level.Warn(logger).Log("msg", "Range query result check failed", "err", err)
-		return
+		return errors.Wrap(err, "range query result check failed")
 	}
+
+	return nil
+}
+
+// expectedSum returns the expected sum of the values of the series read at the input timestamp, which
+// is expected to be aligned to the write interval.
+func (t *WriteReadSeriesTest) expectedSum(ts time.Time) float64 {
+	// The samples read at the input timestamp are the ones written at the same timestamp, unless the
+	// timestamp alignment moved them after it: in that case the samples written at the previous write
+	// interval are read.
+	if snapTimestampToInterval(ts, t.cfg.TimestampAlignment).After(ts) {
+		ts = ts.Add(-writeInterval)
+	}
+
+	if t.cfg.SeedTenantID == "" {
+		value := generateSineWaveValue(snapTimestampToInterval(ts, t.cfg.TimestampAlignment))
+		return quantizeValue(value, t.cfg.ValuePrecision) * float64(t.cfg.NumSeries)
+	}
+
+	sum := 0.0
+	for _, s := range t.generate(ts) {
+		for _, sample := range s.Samples {
+			sum += sample.Value
+		}
+	}
+	return sum
 }
 
 func (t *WriteReadSeriesTest) nextWriteTimestamp(now time.Time) time.Time {
@@ -193,3 +277,10 @@ func (t *WriteReadSeriesTest) nextWriteTimestamp(now time.Time) time.Time {
 
 	return t.lastWrittenTimestamp.Add(writeInterval)
 }
+
+func newWriteSeriesError(statusCode int, err error) error {
+	if err == nil {
+		return errors.Errorf("failed to remote write series (status code: %d)", statusCode)
+	}
+	return errors.Wrapf(err, "failed to remote write series (status code: %d)", statusCode)
+}
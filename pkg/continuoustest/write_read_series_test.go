@@ -60,6 +60,21 @@ func TestWriteReadSeriesTest_Run(t *testing.T) {
 			"mimir_continuous_test_queries_total", "mimir_continuous_test_queries_failed_total"))
 	})
 
+	t.Run("should preserve the labels order of series written with shuffled labels", func(t *testing.T) {
+		shuffleCfg := cfg
+		shuffleCfg.ShuffleLabels = true
+
+		client := &ClientMock{}
+		client.On("WriteSeries", mock.Anything, mock.Anything).Return(200, nil)
+		client.On("QueryRange", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Matrix{}, nil)
+
+		test := NewWriteReadSeriesTest(shuffleCfg, client, logger, prometheus.NewPedanticRegistry())
+		test.Run(context.Background(), time.Unix(1000, 0))
+
+		client.AssertNumberOfCalls(t, "WriteSeries", 1)
+		assert.True(t, isLabelsOrderPreserved(client.Calls[0].Arguments.Get(0).(context.Context)))
+	})
+
 	t.Run("should write series with timestamp aligned to write interval", func(t *testing.T) {
 		client := &ClientMock{}
 		client.On("WriteSeries", mock.Anything, mock.Anything).Return(200, nil)
@@ -272,6 +287,99 @@ func TestWriteReadSeriesTest_Run(t *testing.T) {
 			"mimir_continuous_test_query_result_checks_total", "mimir_continuous_test_query_result_checks_failed_total"))
 	})
 
+	t.Run("should write and verify series seeded from the tenant ID, if configured", func(t *testing.T) {
+		now := time.Unix(1000, 0)
+
+		seededCfg := cfg
+		seededCfg.SeedTenantID = "tenant-1"
+
+		expected := newTenantSeriesGenerator(metricName, "tenant-1", seededCfg.NumSeries)(now)
+		expectedSum := 0.0
+		for _, s := range expected {
+			expectedSum += s.Samples[0].Value
+		}
+
+		client := &ClientMock{}
+		client.On("WriteSeries", mock.Anything, mock.Anything).Return(200, nil)
+		client.On("QueryRange", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Matrix{
+			{Values: []model.SamplePair{newSamplePair(now, expectedSum)}},
+		}, nil)
+
+		test := NewWriteReadSeriesTest(seededCfg, client, logger, prometheus.NewPedanticRegistry())
+		require.NoError(t, test.Run(context.Background(), now))
+
+		client.AssertNumberOfCalls(t, "WriteSeries", 1)
+		client.AssertCalled(t, "WriteSeries", mock.Anything, expected)
+		assert.Equal(t, 0.0, testutil.ToFloat64(test.metrics.queryResultChecksFailedTotal))
+	})
+
+	t.Run("should write and verify series with timestamps aligned to the configured interval", func(t *testing.T) {
+		now := time.Unix(1000, 0)
+
+		alignedCfg := cfg
+		alignedCfg.TimestampAlignment = 15 * time.Second
+
+		// The sample written at 1000s is moved to 1005s, so the one written at 980s (moved to 975s)
+		// is expected to be read at 1000s.
+		client := &ClientMock{}
+		client.On("WriteSeries", mock.Anything, mock.Anything).Return(200, nil)
+		client.On("QueryRange", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Matrix{
+			{Values: []model.SamplePair{newSamplePair(now, generateSineWaveValue(time.Unix(975, 0))*float64(cfg.NumSeries))}},
+		}, nil)
+
+		test := NewWriteReadSeriesTest(alignedCfg, client, logger, prometheus.NewPedanticRegistry())
+		require.NoError(t, test.Init())
+		require.NoError(t, test.Run(context.Background(), now))
+
+		client.AssertNumberOfCalls(t, "WriteSeries", 1)
+		client.AssertCalled(t, "WriteSeries", mock.Anything, generateSineWaveSeries(metricName, time.Unix(1005, 0), 2))
+		assert.Equal(t, 0.0, testutil.ToFloat64(test.metrics.queryResultChecksFailedTotal))
+	})
+
+	t.Run("should write and verify series with values rounded to the configured precision", func(t *testing.T) {
+		now := time.Unix(1000, 0)
+
+		precisionCfg := cfg
+		precisionCfg.ValuePrecision = 1
+
+		expected := generateSineWaveSeries(metricName, now, 2)
+		for _, s := range expected {
+			s.Samples[0].Value = quantizeValue(s.Samples[0].Value, 1)
+		}
+
+		// The sum of the unrounded values doesn't match.
+		client := &ClientMock{}
+		client.On("WriteSeries", mock.Anything, mock.Anything).Return(200, nil)
+		client.On("QueryRange", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Matrix{
+			{Values: []model.SamplePair{newSamplePair(now, generateSineWaveValue(now)*float64(cfg.NumSeries))}},
+		}, nil)
+
+		test := NewWriteReadSeriesTest(precisionCfg, client, logger, prometheus.NewPedanticRegistry())
+		require.Error(t, test.Run(context.Background(), now))
+
+		client.AssertCalled(t, "WriteSeries", mock.Anything, expected)
+		assert.Equal(t, 2.0, testutil.ToFloat64(test.metrics.queryResultChecksFailedTotal))
+
+		// The sum of the rounded values matches.
+		client = &ClientMock{}
+		client.On("WriteSeries", mock.Anything, mock.Anything).Return(200, nil)
+		client.On("QueryRange", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(model.Matrix{
+			{Values: []model.SamplePair{newSamplePair(now, quantizeValue(generateSineWaveValue(now), 1)*float64(cfg.NumSeries))}},
+		}, nil)
+
+		test = NewWriteReadSeriesTest(precisionCfg, client, logger, prometheus.NewPedanticRegistry())
+		require.NoError(t, test.Run(context.Background(), now))
+		assert.Equal(t, 0.0, testutil.ToFloat64(test.metrics.queryResultChecksFailedTotal))
+	})
+
+	t.Run("should fail to initialize if the timestamp alignment is greater than the write interval", func(t *testing.T) {
+		alignedCfg := cfg
+		alignedCfg.TimestampAlignment = 2 * writeInterval
+
+		test := NewWriteReadSeriesTest(alignedCfg, &ClientMock{}, logger, prometheus.NewPedanticRegistry())
+		assert.Error(t, test.Init())
+	})
+
 	t.Run("should query written series, compare results and track failure if results don't match", func(t *testing.T) {
 		now := time.Unix(1000, 0)
 
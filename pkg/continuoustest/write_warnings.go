@@ -0,0 +1,92 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// writeWarning is a warning attached by the server to a write response via the Warning header.
+type writeWarning struct {
+	// Code is the 3 digits warning code, or 0 if the header value is malformed.
+	Code int
+
+	// Text is the warning text, or the whole header value if it's malformed.
+	Text string
+}
+
+// parseWarningHeaders parses the Warning headers in the input response headers. Each header value may carry
+// multiple comma separated warnings, in the format defined by RFC 7234: code agent "text" ["date"]. Values
+// which can't be parsed are returned as is, so that no warning gets lost.
+func parseWarningHeaders(header http.Header) []writeWarning {
+	var out []writeWarning
+
+	for _, value := range header.Values("Warning") {
+		for rest := strings.TrimSpace(value); rest != ""; {
+			warning, remaining, ok := parseWarningValue(rest)
+			if !ok {
+				out = append(out, writeWarning{Text: rest})
+				break
+			}
+
+			out = append(out, warning)
+			rest = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(remaining), ","))
+		}
+	}
+
+	return out
+}
+
+// parseWarningValue parses the first warning in the input header value, and returns the remaining value.
+func parseWarningValue(value string) (writeWarning, string, bool) {
+	fields := strings.SplitN(value, " ", 3)
+	if len(fields) != 3 || len(fields[0]) != 3 {
+		return writeWarning{}, "", false
+	}
+
+	code, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return writeWarning{}, "", false
+	}
+
+	text, rest, ok := parseQuotedString(strings.TrimSpace(fields[2]))
+	if !ok {
+		return writeWarning{}, "", false
+	}
+
+	// Skip the optional warning date.
+	if trimmed := strings.TrimSpace(rest); strings.HasPrefix(trimmed, `"`) {
+		if _, afterDate, ok := parseQuotedString(trimmed); ok {
+			rest = afterDate
+		}
+	}
+
+	return writeWarning{Code: code, Text: text}, rest, true
+}
+
+// parseQuotedString parses the quoted string at the beginning of the input value, unescaping
+// backslash-escaped characters, and returns the remaining value.
+func parseQuotedString(value string) (string, string, bool) {
+	if !strings.HasPrefix(value, `"`) {
+		return "", "", false
+	}
+
+	var text strings.Builder
+	for i := 1; i < len(value); i++ {
+		switch c := value[i]; c {
+		case '\\':
+			if i+1 < len(value) {
+				i++
+				text.WriteByte(value[i])
+			}
+		case '"':
+			return text.String(), value[i+1:], true
+		default:
+			text.WriteByte(c)
+		}
+	}
+
+	return "", "", false
+}
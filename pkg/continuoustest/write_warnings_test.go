@@ -0,0 +1,93 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package continuoustest
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/flagext"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_WriteSeries_ShouldTrackWarnings(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.Header().Add("Warning", `299 mimir "approaching the per-user series limit"`)
+		writer.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := ClientConfig{}
+	flagext.DefaultValues(&cfg)
+	require.NoError(t, cfg.WriteBaseEndpoint.Set(server.URL))
+	require.NoError(t, cfg.ReadBaseEndpoint.Set(server.URL))
+
+	var logs bytes.Buffer
+	c, err := NewClient(cfg, log.NewLogfmtLogger(log.NewSyncWriter(&logs)), nil)
+	require.NoError(t, err)
+
+	statusCode, err := c.WriteSeries(context.Background(), generateSineWaveSeries("test", time.Now(), 1))
+	require.NoError(t, err)
+	assert.Equal(t, 200, statusCode)
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(c.metrics.writeWarnings))
+	assert.Contains(t, logs.String(), `msg="Write request succeeded with a warning from the server" status_code=200 warning_code=299 warning="approaching the per-user series limit"`)
+}
+
+func TestParseWarningHeaders(t *testing.T) {
+	tests := map[string]struct {
+		values   []string
+		expected []writeWarning
+	}{
+		"no warnings": {
+			values:   nil,
+			expected: nil,
+		},
+		"single warning": {
+			values:   []string{`299 mimir "deprecated API"`},
+			expected: []writeWarning{{Code: 299, Text: "deprecated API"}},
+		},
+		"warning with date": {
+			values:   []string{`299 - "deprecated API" "Sat, 25 Aug 2012 23:34:45 GMT"`},
+			expected: []writeWarning{{Code: 299, Text: "deprecated API"}},
+		},
+		"warning with escaped quotes": {
+			values:   []string{`299 mimir "the \"foo\" label is deprecated"`},
+			expected: []writeWarning{{Code: 299, Text: `the "foo" label is deprecated`}},
+		},
+		"multiple warnings in the same header": {
+			values:   []string{`299 mimir "first", 199 - "second" "Sat, 25 Aug 2012 23:34:45 GMT"`},
+			expected: []writeWarning{{Code: 299, Text: "first"}, {Code: 199, Text: "second"}},
+		},
+		"multiple headers": {
+			values:   []string{`299 mimir "first"`, `299 mimir "second"`},
+			expected: []writeWarning{{Code: 299, Text: "first"}, {Code: 299, Text: "second"}},
+		},
+		"malformed warning": {
+			values:   []string{"approaching the limit"},
+			expected: []writeWarning{{Text: "approaching the limit"}},
+		},
+		"unquoted warning text": {
+			values:   []string{"299 mimir approaching the limit"},
+			expected: []writeWarning{{Text: "299 mimir approaching the limit"}},
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			header := http.Header{}
+			for _, value := range testData.values {
+				header.Add("Warning", value)
+			}
+
+			assert.Equal(t, testData.expected, parseWarningHeaders(header))
+		})
+	}
+}
@@ -0,0 +1,198 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package mimir
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/flagext"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+
+	"github.com/grafana/mimir/pkg/ingester/activeseries"
+	"github.com/grafana/mimir/pkg/util"
+	util_log "github.com/grafana/mimir/pkg/util/log"
+	"github.com/grafana/mimir/pkg/util/version"
+)
+
+// ConfigSchemaVersion identifies the schema of the YAML config understood by this binary.
+// It must be bumped whenever a change to the config breaks compatibility with configs built
+// for a previous schema (e.g. a config option is removed or its meaning changes).
+const ConfigSchemaVersion = "1"
+
+// ConfigVersionResponse is the response of the /config/version endpoint.
+type ConfigVersionResponse struct {
+	Version      string `json:"version"`
+	ConfigSchema string `json:"config_schema"`
+}
+
+// configVersionHandler returns an HTTP handler exposing the Mimir version and the config
+// schema version, so that tooling can check the compatibility of a config before applying it.
+func configVersionHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		util.WriteJSONResponse(w, ConfigVersionResponse{
+			Version:      version.Version,
+			ConfigSchema: ConfigSchemaVersion,
+		})
+	})
+}
+
+// LoadConfig returns a Config initialized with the default values and then overridden by
+// each input YAML file, in order, so that later files override earlier ones. The deprecated
+// config options are migrated to their new location the same way the Mimir binary does.
+func LoadConfig(paths ...string) (Config, error) {
+	cfg := Config{}
+	flagext.DefaultValues(&cfg)
+
+	for _, path := range paths {
+		buf, err := os.ReadFile(path)
+		if err != nil {
+			return Config{}, errors.Wrapf(err, "failed to read config file %s", path)
+		}
+
+		if err := yaml.UnmarshalStrict(buf, &cfg); err != nil {
+			return Config{}, errors.Wrapf(err, "failed to parse config file %s", path)
+		}
+	}
+
+	if err := cfg.migrateDeprecatedActiveSeriesCustomTrackers(); err != nil {
+		return Config{}, err
+	}
+
+	// Reset the deprecated config once migrated, so that it's not migrated again when initializing modules.
+	cfg.Ingester.ActiveSeriesCustomTrackers = activeseries.CustomTrackersConfig{}
+
+	return cfg, nil
+}
+
+// ApplyFlagOverrides applies the input overrides, keyed by CLI flag name (without the leading dash),
+// to the input config, as if they were passed on the command line. The config settings not overridden
+// are left unchanged.
+func ApplyFlagOverrides(cfg *Config, overrides map[string]string) error {
+	fs := flag.NewFlagSet("overrides", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	// Registering the flags resets the config to the default values, so we restore the current values
+	// once registered. The flags are bound to the config fields, so they're then set by parsing the overrides.
+	current := *cfg
+	cfg.RegisterFlags(fs, util_log.Logger)
+	*cfg = current
+
+	// Sort the overrides, so that errors are deterministic.
+	names := make([]string, 0, len(overrides))
+	for name := range overrides {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	args := make([]string, 0, len(names))
+	for _, name := range names {
+		args = append(args, fmt.Sprintf("-%s=%s", name, overrides[name]))
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return errors.Wrap(err, "failed to apply flag overrides")
+	}
+
+	return nil
+}
+
+// Equal returns whether the config is equal to the other one. The configs are compared by their YAML
+// representation, as exposed by the /config endpoint, so that fields not serialized to YAML (eg. providers
+// injected at runtime) are ignored.
+func (c *Config) Equal(other Config) bool {
+	return c.Diff(other) == ""
+}
+
+// Diff returns a human-readable description of the differences between the config and the other one, or
+// an empty string if they're equal. Each line reports a YAML path removed (-), added (+) or changed (~)
+// in the other config. The configs are compared like Equal does.
+func (c *Config) Diff(other Config) string {
+	actual, err := util.YAMLMarshalUnmarshal(c)
+	if err != nil {
+		return fmt.Sprintf("failed to marshal the config: %s", err)
+	}
+
+	expected, err := util.YAMLMarshalUnmarshal(other)
+	if err != nil {
+		return fmt.Sprintf("failed to marshal the other config: %s", err)
+	}
+
+	actualValues := map[string]string{}
+	expectedValues := map[string]string{}
+	flattenYAMLConfig("", actual, actualValues)
+	flattenYAMLConfig("", expected, expectedValues)
+
+	paths := make([]string, 0, len(actualValues))
+	for path := range actualValues {
+		paths = append(paths, path)
+	}
+	for path := range expectedValues {
+		if _, ok := actualValues[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+
+	var lines []string
+	for _, path := range paths {
+		actualValue, inActual := actualValues[path]
+		expectedValue, inExpected := expectedValues[path]
+
+		switch {
+		case !inExpected:
+			lines = append(lines, fmt.Sprintf("- %s: %s", path, actualValue))
+		case !inActual:
+			lines = append(lines, fmt.Sprintf("+ %s: %s", path, expectedValue))
+		case actualValue != expectedValue:
+			lines = append(lines, fmt.Sprintf("~ %s: %s -> %s", path, actualValue, expectedValue))
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// flattenYAMLConfig adds the leaf values of the input YAML object to out, keyed by their dotted path.
+func flattenYAMLConfig(prefix string, obj map[interface{}]interface{}, out map[string]string) {
+	for key, value := range obj {
+		path := fmt.Sprint(key)
+		if prefix != "" {
+			path = prefix + "." + path
+		}
+
+		if nested, ok := value.(map[interface{}]interface{}); ok && len(nested) > 0 {
+			flattenYAMLConfig(path, nested, out)
+			continue
+		}
+		out[path] = fmt.Sprint(value)
+	}
+}
+
+// migrateDeprecatedActiveSeriesCustomTrackers copies the active series custom trackers from
+// the deprecated ingester config to the limits config, if set. Previously ActiveSeriesCustomTrackers
+// was an ingester config, now it's in LimitsConfig. We provide backwards compatibility for it by
+// parsing the old YAML location and copying it to LimitsConfig, unless it's also defined in the
+// limits, which is invalid.
+//
+// TODO Remove in Mimir 2.3.
+func (c *Config) migrateDeprecatedActiveSeriesCustomTrackers() error {
+	if c.Ingester.ActiveSeriesCustomTrackers.Empty() {
+		return nil
+	}
+
+	if !c.LimitsConfig.ActiveSeriesCustomTrackersConfig.Empty() {
+		return fmt.Errorf("can't define active series custom trackers in both ingester and limits config, please define them only in the limits")
+	}
+
+	level.Warn(util_log.Logger).Log("msg", "active_series_custom_trackers is defined as an ingester config, this location is deprecated, please move it to the limits config")
+	flagext.DeprecatedFlagsUsed.Inc()
+	c.LimitsConfig.ActiveSeriesCustomTrackersConfig = c.Ingester.ActiveSeriesCustomTrackers
+	return nil
+}
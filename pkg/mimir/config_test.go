@@ -0,0 +1,158 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package mimir
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfig(t *testing.T) {
+	writeFile := func(t *testing.T, content string) string {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		require.NoError(t, os.WriteFile(path, []byte(content), 0666))
+		return path
+	}
+
+	t.Run("should merge multiple config files, with later files overriding earlier ones", func(t *testing.T) {
+		first := writeFile(t, `
+target: ruler
+server:
+  http_listen_port: 8080
+ingester:
+  active_series_custom_trackers:
+    dev: '{namespace=~"dev-.*"}'
+`)
+		second := writeFile(t, `
+target: querier
+server:
+  grpc_listen_port: 9095
+`)
+
+		cfg, err := LoadConfig(first, second)
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{Querier}, []string(cfg.Target))
+		assert.Equal(t, 8080, cfg.Server.HTTPListenPort)
+		assert.Equal(t, 9095, cfg.Server.GRPCListenPort)
+
+		// Defaults should be preserved for settings not overridden by any file.
+		assert.Equal(t, "anonymous", cfg.NoAuthTenant)
+
+		// The deprecated active series custom trackers should be migrated to the limits.
+		assert.True(t, cfg.Ingester.ActiveSeriesCustomTrackers.Empty())
+		assert.Equal(t, `dev:{namespace=~"dev-.*"}`, cfg.LimitsConfig.ActiveSeriesCustomTrackersConfig.String())
+
+		// The migrated config should be honored by the overrides.
+		prepareGlobalMetricsRegistry(t)
+		cfg.Server.HTTPListenPort = 0
+		cfg.Server.GRPCListenPort = 0
+
+		c, err := New(cfg)
+		require.NoError(t, err)
+		_, err = c.ModuleManager.InitModuleServices(Overrides)
+		require.NoError(t, err)
+		defer c.Server.Stop()
+
+		assert.Equal(t, `dev:{namespace=~"dev-.*"}`, c.Overrides.ActiveSeriesCustomTrackersConfig("nonexistent").String())
+	})
+
+	t.Run("should fail if active series custom trackers are defined in both the ingester and limits config", func(t *testing.T) {
+		first := writeFile(t, `
+ingester:
+  active_series_custom_trackers:
+    dev: '{namespace=~"dev-.*"}'
+`)
+		second := writeFile(t, `
+limits:
+  active_series_custom_trackers_config:
+    prod: '{namespace=~"prod-.*"}'
+`)
+
+		_, err := LoadConfig(first, second)
+		require.EqualError(t, err, "can't define active series custom trackers in both ingester and limits config, please define them only in the limits")
+	})
+
+	t.Run("should fail on unknown config fields", func(t *testing.T) {
+		_, err := LoadConfig(writeFile(t, "unknown: true\n"))
+		require.Error(t, err)
+	})
+}
+
+func TestApplyFlagOverrides(t *testing.T) {
+	newConfig := func(t *testing.T) Config {
+		cfg, err := LoadConfig()
+		require.NoError(t, err)
+
+		// Customize a setting not overridden, to check it's preserved.
+		cfg.Server.HTTPListenPort = 9000
+		return cfg
+	}
+
+	t.Run("should apply the overrides and preserve the other settings", func(t *testing.T) {
+		cfg := newConfig(t)
+
+		require.NoError(t, ApplyFlagOverrides(&cfg, map[string]string{
+			"target":          "querier,ruler",
+			"querier.timeout": "30s",
+		}))
+
+		assert.Equal(t, []string{Querier, Ruler}, []string(cfg.Target))
+		assert.Equal(t, 30*time.Second, cfg.Querier.EngineConfig.Timeout)
+		assert.Equal(t, 9000, cfg.Server.HTTPListenPort)
+		assert.Equal(t, "anonymous", cfg.NoAuthTenant)
+	})
+
+	t.Run("should fail on unknown flag", func(t *testing.T) {
+		cfg := newConfig(t)
+
+		err := ApplyFlagOverrides(&cfg, map[string]string{"unknown": "true"})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "-unknown")
+	})
+
+	t.Run("should fail on invalid value", func(t *testing.T) {
+		cfg := newConfig(t)
+
+		err := ApplyFlagOverrides(&cfg, map[string]string{"querier.timeout": "invalid"})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "querier.timeout")
+	})
+}
+
+func TestConfig_Equal(t *testing.T) {
+	newConfig := func(t *testing.T) Config {
+		cfg, err := LoadConfig()
+		require.NoError(t, err)
+		return cfg
+	}
+
+	t.Run("should be equal for identical configs", func(t *testing.T) {
+		first, second := newConfig(t), newConfig(t)
+
+		assert.True(t, first.Equal(second))
+		assert.Empty(t, first.Diff(second))
+	})
+
+	t.Run("should not be equal if the target changed", func(t *testing.T) {
+		first, second := newConfig(t), newConfig(t)
+		second.Target = []string{Querier}
+
+		assert.False(t, first.Equal(second))
+		assert.Equal(t, "~ target: all -> querier", first.Diff(second))
+	})
+
+	t.Run("should report nested changes", func(t *testing.T) {
+		first, second := newConfig(t), newConfig(t)
+		second.Querier.EngineConfig.Timeout = 30 * time.Second
+		second.Server.HTTPListenPort = 9000
+
+		assert.False(t, first.Equal(second))
+		assert.Equal(t, "~ querier.timeout: 2m0s -> 30s\n~ server.http_listen_port: 8080 -> 9000", first.Diff(second))
+	})
+}
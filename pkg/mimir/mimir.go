@@ -128,7 +128,8 @@ func (c *Config) RegisterFlags(f *flag.FlagSet, logger log.Logger) {
 
 	f.Var(&c.Target, "target", "Comma-separated list of components to include in the instantiated process. "+
 		"The default value 'all' includes all components that are required to form a functional Grafana Mimir instance in single-binary mode. "+
-		"Use the '-modules' command line flag to get a list of available components, and to see which components are included with 'all'.")
+		"Use the '-modules' command line flag to get a list of available components, and to see which components are included with 'all'. "+
+		"A component can't be combined with another one which already includes it, for example 'all' with 'ruler'.")
 
 	f.BoolVar(&c.MultitenancyEnabled, "auth.multitenancy-enabled", true, "When set to true, incoming HTTP requests must specify tenant ID in HTTP X-Scope-OrgId header. When set to false, tenant ID from -auth.no-auth-tenant is used instead.")
 	f.StringVar(&c.NoAuthTenant, "auth.no-auth-tenant", "anonymous", "Tenant ID to use when multitenancy is disabled.")
@@ -357,8 +358,37 @@ type Mimir struct {
 	StoreQueryables []querier.QueryableWithFilter
 }
 
+// ConfigHook mutates the config of Mimir programmatically.
+type ConfigHook func(cfg *Config)
+
+// Option customizes how Mimir is built by New.
+type Option func(opts *options)
+
+type options struct {
+	configHooks []ConfigHook
+}
+
+// WithConfigHooks returns an Option registering hooks invoked, in order, by New before the config is used
+// to build Mimir, so that embedders can mutate the config after it has been loaded. The mutated config is
+// not validated again, so hooks are responsible for keeping it valid.
+func WithConfigHooks(hooks ...ConfigHook) Option {
+	return func(opts *options) {
+		opts.configHooks = append(opts.configHooks, hooks...)
+	}
+}
+
 // New makes a new Mimir.
-func New(cfg Config) (*Mimir, error) {
+func New(cfg Config, opts ...Option) (*Mimir, error) {
+	o := options{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	// Run the config hooks before the config is used, so that their mutations apply to all modules.
+	for _, hook := range o.configHooks {
+		hook(&cfg)
+	}
+
 	if cfg.PrintConfig {
 		if err := yaml.NewEncoder(os.Stdout).Encode(&cfg); err != nil {
 			fmt.Println("Error encoding config:", err)
@@ -396,6 +426,10 @@ func New(cfg Config) (*Mimir, error) {
 		return nil, err
 	}
 
+	if err := mimir.validateTargets(cfg.Target); err != nil {
+		return nil, err
+	}
+
 	return mimir, nil
 }
 
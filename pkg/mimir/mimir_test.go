@@ -513,6 +513,44 @@ overrides:
 	}
 }
 
+func TestNew_ShouldRunConfigHooks(t *testing.T) {
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	prepareGlobalMetricsRegistry(t)
+
+	cfg := Config{}
+	flagext.RegisterFlagsWithLogger(log.NewNopLogger(), &cfg)
+
+	cfg.Target = []string{All}
+
+	serverCfg := getServerConfig(t)
+	var invoked []string
+
+	c, err := New(cfg, WithConfigHooks(
+		func(cfg *Config) {
+			invoked = append(invoked, "first")
+			cfg.Target = []string{Server}
+			cfg.Server = serverCfg
+		},
+		func(cfg *Config) {
+			// The mutations of previous hooks are visible to the next ones.
+			invoked = append(invoked, "second:"+strings.Join(cfg.Target, ","))
+		},
+	))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"first", "second:" + Server}, invoked)
+	assert.Equal(t, flagext.StringSliceCSV{Server}, c.Cfg.Target)
+
+	// The mutations take effect in the modules initialized by Mimir.
+	_, err = c.ModuleManager.InitModuleServices(Server)
+	require.NoError(t, err)
+	t.Cleanup(c.Server.Shutdown)
+
+	// The server listens on the port set by the hook.
+	conn, err := net.Dial("tcp", net.JoinHostPort(serverCfg.HTTPListenAddress, strconv.Itoa(serverCfg.HTTPListenPort)))
+	require.NoError(t, err)
+	require.NoError(t, conn.Close())
+}
+
 // Generates server config, with gRPC listening on random port.
 func getServerConfig(t *testing.T) server.Config {
 	grpcHost, grpcPortNum := getHostnameAndRandomPort(t)
@@ -10,11 +10,12 @@ import (
 	"flag"
 	"fmt"
 	"net/http"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-kit/log/level"
-	"github.com/grafana/dskit/flagext"
 	"github.com/grafana/dskit/kv/codec"
 	"github.com/grafana/dskit/kv/memberlist"
 	"github.com/grafana/dskit/modules"
@@ -115,6 +116,7 @@ func (t *Mimir) initAPI() (services.Service, error) {
 
 	t.API = a
 	t.API.RegisterAPI(t.Cfg.Server.PathPrefix, t.Cfg, newDefaultConfig(), t.BuildInfoHandler)
+	t.API.RegisterConfigVersion(configVersionHandler())
 
 	return nil, nil
 }
@@ -211,17 +213,9 @@ func (t *Mimir) initRing() (serv services.Service, err error) {
 
 func (t *Mimir) initRuntimeConfig() (services.Service, error) {
 	// TODO Remove in Mimir 2.3.
-	//      Previously ActiveSeriesCustomTrackers was an ingester config, now it's in LimitsConfig.
-	//      We provide backwards compatibility for it by parsing the old YAML location and copying it to LimitsConfig here,
-	//      unless it's also defined in the limits, which is invalid.
 	//      This needs to be set before setting default limits for unmarshalling.
-	if !t.Cfg.Ingester.ActiveSeriesCustomTrackers.Empty() {
-		if !t.Cfg.LimitsConfig.ActiveSeriesCustomTrackersConfig.Empty() {
-			return nil, fmt.Errorf("can't define active series custom trackers in both ingester and limits config, please define them only in the limits")
-		}
-		level.Warn(util_log.Logger).Log("msg", "active_series_custom_trackers is defined as an ingester config, this location is deprecated, please move it to the limits config")
-		flagext.DeprecatedFlagsUsed.Inc()
-		t.Cfg.LimitsConfig.ActiveSeriesCustomTrackersConfig = t.Cfg.Ingester.ActiveSeriesCustomTrackers
+	if err := t.Cfg.migrateDeprecatedActiveSeriesCustomTrackers(); err != nil {
+		return nil, err
 	}
 
 	if t.Cfg.RuntimeConfig.LoadPath == "" {
@@ -248,9 +242,15 @@ func (t *Mimir) initRuntimeConfig() (services.Service, error) {
 
 func (t *Mimir) initOverrides() (serv services.Service, err error) {
 	t.Overrides, err = validation.NewOverrides(t.Cfg.LimitsConfig, t.TenantLimits)
+	if err != nil {
+		return nil, err
+	}
+
+	t.API.RegisterTenantLimits(tenantLimitsHandler(t.Overrides))
+
 	// overrides don't have operational state, nor do they need to do anything more in starting/stopping phase,
 	// so there is no need to return any service.
-	return nil, err
+	return nil, nil
 }
 
 func (t *Mimir) initOverridesExporter() (services.Service, error) {
@@ -784,3 +784,53 @@ func (t *Mimir) setupModuleManager() error {
 
 	return nil
 }
+
+// validateTargets returns an error if the input target combines incompatible modules, which is the case when
+// a module is also a dependency of another module of the target (eg. all combined with ingester).
+func (t *Mimir) validateTargets(target []string) error {
+	for _, module := range target {
+		if !t.ModuleManager.IsModuleRegistered(module) {
+			// Unknown modules are reported when initialising the modules.
+			continue
+		}
+
+		for _, dep := range t.ModuleManager.DependenciesForModule(module) {
+			for _, other := range target {
+				if other == dep {
+					return fmt.Errorf("invalid target %s: the %s module can't be combined with %s, which already includes it", strings.Join(target, ","), other, module)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// Modules returns the sorted list of modules which run for the input target, including the
+// transitive dependencies of the target modules.
+func Modules(target []string) ([]string, error) {
+	t := &Mimir{}
+	if err := t.setupModuleManager(); err != nil {
+		return nil, err
+	}
+
+	uniq := map[string]struct{}{}
+	for _, module := range target {
+		if !t.ModuleManager.IsModuleRegistered(module) {
+			return nil, fmt.Errorf("unrecognised module name: %s", module)
+		}
+
+		uniq[module] = struct{}{}
+		for _, dep := range t.ModuleManager.DependenciesForModule(module) {
+			uniq[dep] = struct{}{}
+		}
+	}
+
+	result := make([]string, 0, len(uniq))
+	for module := range uniq {
+		result = append(result, module)
+	}
+	sort.Strings(result)
+
+	return result, nil
+}
@@ -6,9 +6,11 @@
 package mimir
 
 import (
+	"encoding/json"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"sort"
 	"testing"
 
 	"github.com/gorilla/mux"
@@ -21,7 +23,7 @@ import (
 )
 
 func changeTargetConfig(c *Config) {
-	c.Target = []string{"all", "ruler"}
+	c.Target = []string{"all", "alertmanager"}
 }
 
 func TestAPIConfig(t *testing.T) {
@@ -62,7 +64,7 @@ func TestAPIConfig(t *testing.T) {
 			actualCfg:          changeTargetConfig,
 			expectedStatusCode: 200,
 			expectedBody: func(t *testing.T, body string) {
-				assert.Contains(t, body, "target: all,ruler\n")
+				assert.Contains(t, body, "target: all,alertmanager\n")
 			},
 		},
 		{
@@ -80,7 +82,26 @@ func TestAPIConfig(t *testing.T) {
 			actualCfg:          changeTargetConfig,
 			expectedStatusCode: 200,
 			expectedBody: func(t *testing.T, body string) {
-				assert.Equal(t, "target: all,ruler\n", body)
+				assert.Equal(t, "target: all,alertmanager\n", body)
+			},
+		},
+		{
+			name:               "config version",
+			path:               "/config/version",
+			expectedStatusCode: 200,
+			expectedBody: func(t *testing.T, body string) {
+				var resp ConfigVersionResponse
+				require.NoError(t, json.Unmarshal([]byte(body), &resp))
+				assert.NotEmpty(t, resp.Version)
+				assert.Equal(t, ConfigSchemaVersion, resp.ConfigSchema)
+			},
+		},
+		{
+			name:               "index page links the config version",
+			path:               "/",
+			expectedStatusCode: 200,
+			expectedBody: func(t *testing.T, body string) {
+				assert.Contains(t, body, `href="/config/version"`)
 			},
 		},
 	} {
@@ -163,6 +184,125 @@ func TestMimir_InitRulerStorage(t *testing.T) {
 	}
 }
 
+func TestInitModuleForTest(t *testing.T) {
+	t.Run("should init the ruler storage and its dependencies", func(t *testing.T) {
+		cfg := newDefaultConfig()
+		cfg.Target = []string{Ruler}
+		cfg.RulerStorage.Backend = "local"
+		cfg.RulerStorage.Local.Directory = t.TempDir()
+
+		mimir, serv, err := InitModuleForTest(*cfg, RulerStorage)
+		require.NoError(t, err)
+
+		// The ruler storage has no service.
+		assert.Nil(t, serv)
+		assert.NotNil(t, mimir.RulerStorage)
+
+		// Dependencies should have been initialized too.
+		assert.NotNil(t, mimir.Overrides)
+		assert.NotNil(t, mimir.API)
+	})
+
+	t.Run("should return error on unknown module", func(t *testing.T) {
+		_, _, err := InitModuleForTest(*newDefaultConfig(), "unknown")
+		require.Error(t, err)
+	})
+}
+
+func TestModules(t *testing.T) {
+	sorted := func(modules ...string) []string {
+		sort.Strings(modules)
+		return modules
+	}
+
+	rulerModules := []string{Ruler, DistributorService, StoreQueryable, RulerStorage, Ring, Overrides, RuntimeConfig, MemberlistKV, API, Server, ActivityTracker, SanityCheck}
+
+	tests := map[string]struct {
+		target   []string
+		expected []string
+		err      string
+	}{
+		"all": {
+			target: []string{All},
+			expected: sorted(All, QueryFrontend, QueryFrontendTripperware, Querier, TenantFederation, Queryable, Ingester, IngesterService,
+				Distributor, DistributorService, Purger, TenantDeletion, StoreGateway, StoreQueryable, Ruler, RulerStorage, Compactor,
+				Ring, Overrides, RuntimeConfig, MemberlistKV, API, Server, ActivityTracker, SanityCheck),
+		},
+		"ruler": {
+			target:   []string{Ruler},
+			expected: sorted(rulerModules...),
+		},
+		"multiple targets": {
+			target:   []string{Ruler, AlertManager, OverridesExporter},
+			expected: sorted(append([]string{AlertManager, OverridesExporter}, rulerModules...)...),
+		},
+		"unknown module": {
+			target: []string{Ruler, "unknown"},
+			err:    "unrecognised module name: unknown",
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			actual, err := Modules(testData.target)
+			if testData.err != "" {
+				require.EqualError(t, err, testData.err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, testData.expected, actual)
+		})
+	}
+}
+
+func TestNew_ShouldValidateTargets(t *testing.T) {
+	tests := map[string]struct {
+		target []string
+		err    string
+	}{
+		"all": {
+			target: []string{All},
+		},
+		"single component": {
+			target: []string{Ingester},
+		},
+		"multiple independent components": {
+			target: []string{Distributor, Ingester, Querier},
+		},
+		"all combined with a component not included in all": {
+			target: []string{All, AlertManager},
+		},
+		"all combined with a component included in all": {
+			target: []string{All, Ingester},
+			err:    "invalid target all,ingester: the ingester module can't be combined with all, which already includes it",
+		},
+		"all combined with the ruler": {
+			target: []string{All, Ruler},
+			err:    "invalid target all,ruler: the ruler module can't be combined with all, which already includes it",
+		},
+		"component combined with one of its dependencies": {
+			target: []string{Ruler, DistributorService},
+			err:    "invalid target ruler,distributor-service: the distributor-service module can't be combined with ruler, which already includes it",
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			cfg := newDefaultConfig()
+			cfg.Target = testData.target
+
+			_, err := New(*cfg)
+			if testData.err != "" {
+				require.EqualError(t, err, testData.err)
+				return
+			}
+
+			require.NoError(t, err)
+		})
+	}
+}
+
 func TestMultiKVSetup(t *testing.T) {
 	dir := t.TempDir()
 
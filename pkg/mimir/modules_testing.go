@@ -0,0 +1,42 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package mimir
+
+import (
+	"github.com/gorilla/mux"
+	"github.com/grafana/dskit/modules"
+	"github.com/grafana/dskit/services"
+	"github.com/weaveworks/common/server"
+	"google.golang.org/grpc"
+)
+
+// InitModuleForTest initializes the input module, and its dependencies, without starting any service,
+// so that a single module can be unit tested in isolation. The HTTP and gRPC servers are replaced by
+// servers not listening on any port, while the activity tracker and sanity check are skipped. Returns
+// the Mimir instance holding the initialized components and the module service, which is nil if the
+// module has no service.
+func InitModuleForTest(cfg Config, module string) (*Mimir, services.Service, error) {
+	t := &Mimir{
+		Cfg: cfg,
+		Server: &server.Server{
+			HTTP: mux.NewRouter(),
+			GRPC: grpc.NewServer(),
+		},
+	}
+
+	if err := t.setupModuleManager(); err != nil {
+		return nil, nil, err
+	}
+
+	// Replace the modules which have side effects outside the process with no-op ones.
+	for _, name := range []string{Server, ActivityTracker, SanityCheck} {
+		t.ModuleManager.RegisterModule(name, nil, modules.UserInvisibleModule)
+	}
+
+	serviceMap, err := t.ModuleManager.InitModuleServices(module)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return t, serviceMap[module], nil
+}
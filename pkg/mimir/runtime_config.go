@@ -190,3 +190,16 @@ func runtimeConfigHandler(runtimeCfgManager *runtimeconfig.Manager, defaultLimit
 		util.WriteYAMLResponse(w, output)
 	}
 }
+
+// tenantLimitsHandler returns the effective limits of the tenant set in the tenant query parameter, as JSON.
+func tenantLimitsHandler(overrides *validation.Overrides) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tenantID := r.URL.Query().Get("tenant")
+		if tenantID == "" {
+			http.Error(w, "the tenant query parameter is required", http.StatusBadRequest)
+			return
+		}
+
+		util.WriteJSONResponse(w, overrides.LimitsForUser(tenantID))
+	}
+}
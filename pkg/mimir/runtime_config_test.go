@@ -6,9 +6,13 @@
 package mimir
 
 import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 
+	"github.com/grafana/dskit/flagext"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
@@ -113,3 +117,73 @@ overrides:
 		assert.Nil(t, actual)
 	}
 }
+
+// staticTenantLimits exposes per-tenant limits based on a provided map.
+type staticTenantLimits map[string]*validation.Limits
+
+func (l staticTenantLimits) ByUserID(userID string) *validation.Limits {
+	return l[userID]
+}
+
+func (l staticTenantLimits) AllByUserID() map[string]*validation.Limits {
+	return l
+}
+
+func TestTenantLimitsHandler(t *testing.T) {
+	defaults := validation.Limits{}
+	flagext.DefaultValues(&defaults)
+
+	// Per-tenant limits are loaded on top of the defaults.
+	tenantLimits := defaults
+	tenantLimits.IngestionRate = 12345
+	tenantLimits.MaxGlobalSeriesPerUser = 1000
+
+	overrides, err := validation.NewOverrides(defaults, staticTenantLimits{"tenant-1": &tenantLimits})
+	require.NoError(t, err)
+
+	handler := tenantLimitsHandler(overrides)
+
+	tests := map[string]struct {
+		path           string
+		expectedStatus int
+		expectedLimits *validation.Limits
+	}{
+		"should return the overrides of a tenant with overrides": {
+			path:           "/api/v1/tenant_limits?tenant=tenant-1",
+			expectedStatus: http.StatusOK,
+			expectedLimits: &tenantLimits,
+		},
+		"should return the default limits of an unknown tenant": {
+			path:           "/api/v1/tenant_limits?tenant=unknown",
+			expectedStatus: http.StatusOK,
+			expectedLimits: &defaults,
+		},
+		"should fail if the tenant is missing": {
+			path:           "/api/v1/tenant_limits",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			resp := httptest.NewRecorder()
+			handler.ServeHTTP(resp, httptest.NewRequest("GET", testData.path, nil))
+			require.Equal(t, testData.expectedStatus, resp.Code)
+
+			if testData.expectedLimits == nil {
+				return
+			}
+
+			assert.Equal(t, "application/json", resp.Header().Get("Content-Type"))
+
+			// Compare the JSON encoding, which is the public contract of the endpoint.
+			expected, err := json.Marshal(testData.expectedLimits)
+			require.NoError(t, err)
+			assert.JSONEq(t, string(expected), resp.Body.String())
+
+			actual := map[string]interface{}{}
+			require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &actual))
+			assert.Equal(t, testData.expectedLimits.IngestionRate, actual["ingestion_rate"])
+		})
+	}
+}
@@ -8,10 +8,23 @@ package util
 import (
 	"fmt"
 	"reflect"
+	"sort"
 )
 
+// DiffConfigOptions configures how DiffConfigWithOptions compares the config values.
+type DiffConfigOptions struct {
+	// IgnoreSliceOrder makes slices containing the same items in a different order compare as equal.
+	// Maps are always compared regardless of the keys order.
+	IgnoreSliceOrder bool
+}
+
 // DiffConfig utility function that returns the diff between two config map objects
 func DiffConfig(defaultConfig, actualConfig map[interface{}]interface{}) (map[interface{}]interface{}, error) {
+	return DiffConfigWithOptions(defaultConfig, actualConfig, DiffConfigOptions{})
+}
+
+// DiffConfigWithOptions is like DiffConfig, but compares the config values according to the input options.
+func DiffConfigWithOptions(defaultConfig, actualConfig map[interface{}]interface{}, opts DiffConfigOptions) (map[interface{}]interface{}, error) {
 	output := make(map[interface{}]interface{})
 
 	for key, value := range actualConfig {
@@ -40,7 +53,7 @@ func DiffConfig(defaultConfig, actualConfig map[interface{}]interface{}) (map[in
 			}
 		case []interface{}:
 			defaultV, ok := defaultValue.([]interface{})
-			if !ok || !reflect.DeepEqual(defaultV, v) {
+			if !ok || !slicesEqual(defaultV, v, opts) {
 				output[key] = v
 			}
 		case float64:
@@ -57,7 +70,7 @@ func DiffConfig(defaultConfig, actualConfig map[interface{}]interface{}) (map[in
 			if !ok {
 				output[key] = value
 			}
-			diff, err := DiffConfig(defaultV, v)
+			diff, err := DiffConfigWithOptions(defaultV, v, opts)
 			if err != nil {
 				return nil, err
 			}
@@ -71,3 +84,37 @@ func DiffConfig(defaultConfig, actualConfig map[interface{}]interface{}) (map[in
 
 	return output, nil
 }
+
+// slicesEqual returns whether the input slices are equal. If slices order is ignored, the slices are
+// compared after sorting their items, recursively.
+func slicesEqual(first, second []interface{}, opts DiffConfigOptions) bool {
+	if !opts.IgnoreSliceOrder {
+		return reflect.DeepEqual(first, second)
+	}
+	return reflect.DeepEqual(canonicalizeConfigValue(first), canonicalizeConfigValue(second))
+}
+
+// canonicalizeConfigValue returns a copy of the input value with the items of each slice sorted, recursively.
+// The items are sorted by their string representation, which is deterministic for maps too, because maps are
+// printed sorted by key.
+func canonicalizeConfigValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case []interface{}:
+		out := make([]interface{}, 0, len(v))
+		for _, item := range v {
+			out = append(out, canonicalizeConfigValue(item))
+		}
+		sort.SliceStable(out, func(i, j int) bool {
+			return fmt.Sprint(out[i]) < fmt.Sprint(out[j])
+		})
+		return out
+	case map[interface{}]interface{}:
+		out := make(map[interface{}]interface{}, len(v))
+		for key, item := range v {
+			out[key] = canonicalizeConfigValue(item)
+		}
+		return out
+	default:
+		return value
+	}
+}
@@ -606,6 +606,12 @@ func (o *Overrides) ForwardingRules(user string) ForwardingRules {
 	return o.getOverridesForUser(user).ForwardingRules
 }
 
+// LimitsForUser returns the effective limits of the input tenant, which are the tenant overrides
+// (merged on top of the default limits when loaded) or the default limits if the tenant has no overrides.
+func (o *Overrides) LimitsForUser(userID string) *Limits {
+	return o.getOverridesForUser(userID)
+}
+
 func (o *Overrides) getOverridesForUser(userID string) *Limits {
 	if o.tenantLimits != nil {
 		l := o.tenantLimits.ByUserID(userID)